@@ -138,6 +138,11 @@ func ConnectDB() {
 	migrations := []interface{}{
 		&models.User{}, &models.Role{}, &models.Permission{},
 		&models.Module{}, &models.Audit{}, models.RolePermission{},
+		&models.AuditChange{}, &models.SecurityEvent{},
+		&models.AuditRetentionPolicy{}, &models.AuditArchive{}, &models.RestoredAudit{}, &models.SiemCursor{},
+		&models.SecurityAlert{}, &models.SignedReport{}, &models.ReportSigningKey{}, &models.ReportJob{},
+		&models.ReportSchedule{}, &models.ReportScheduleRun{}, &models.ReportPreset{}, &models.ReportBranding{},
+		&models.UserLogin{},
 	}
 
 	for _, model := range migrations {
@@ -148,6 +153,8 @@ func ConnectDB() {
 		}
 	}
 
+	ensureAuditFulltextIndex(db)
+
 	// // Añade la columna si no existe
 	// if err := db.Migrator().AddColumn(&models.Module{}, "ModuleKey"); err != nil {
 	// 	log.Printf("Error al agregar la columna ModuleKey: %v", err)
@@ -162,3 +169,16 @@ func ConnectDB() {
 
 	DB = db
 }
+
+// ensureAuditFulltextIndex crea el índice FULLTEXT de la búsqueda de auditorías. AutoMigrate no lo
+// declara en el modelo porque otros motores no soportan la sintaxis; allí la búsqueda usa LIKE.
+func ensureAuditFulltextIndex(db *gorm.DB) {
+	if db.Dialector.Name() != "mysql" || db.Migrator().HasIndex(&models.Audit{}, models.AuditFulltextIndex) {
+		return
+	}
+	if err := db.Exec("CREATE FULLTEXT INDEX " + models.AuditFulltextIndex + " ON audit (description)").Error; err != nil {
+		log.Printf("Error al crear el índice FULLTEXT de auditorías: %v", err)
+		return
+	}
+	log.Printf("Índice %s creado", models.AuditFulltextIndex)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"seguridad-api/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalyticsTop = 5
+	maxAnalyticsTop     = 50
+	maxAnalyticsRange   = 366 * 24 * time.Hour
+	maxHourlyRange      = 31 * 24 * time.Hour
+)
+
+// Periodo consultado por defecto cuando no se envían fechas
+var defaultAnalyticsRange = map[string]time.Duration{
+	services.AnalyticsIntervalHour: 24 * time.Hour,
+	services.AnalyticsIntervalDay:  30 * 24 * time.Hour,
+	services.AnalyticsIntervalWeek: 12 * 7 * 24 * time.Hour,
+}
+
+// GetAuditAnalytics devuelve series de tiempo de auditorías para los tableros
+// @Summary Analítica de auditorías
+// @Description Cuenta las auditorías por hora, día o semana (hora de Ecuador) agrupadas por evento, servicio de origen o usuario, con el ranking de usuarios y servicios y la comparación con el periodo anterior de igual duración. Acepta los mismos filtros del listado.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param interval query string false "Intervalo: hour, day (por defecto) o week"
+// @Param group_by query string false "Agrupación: event (por defecto), origin_service o user"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Param top query int false "Cantidad de usuarios y servicios en los rankings (por defecto: 5)"
+// @Param event query string false "Filtrar por tipo de evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por ID de usuario"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
+// @Success 200 {object} models.AuditAnalyticsResponse "Series y comparaciones"
+// @Failure 400 {object} ErrorResponseAudit "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponseAudit "Error al calcular la analítica"
+// @Router /audit/analytics [get]
+func GetAuditAnalytics(c *gin.Context) {
+	interval := c.DefaultQuery("interval", services.AnalyticsIntervalDay)
+	span, ok := defaultAnalyticsRange[interval]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El intervalo debe ser hour, day o week"})
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", services.AnalyticsGroupEvent)
+	if groupBy != services.AnalyticsGroupEvent && groupBy != services.AnalyticsGroupService && groupBy != services.AnalyticsGroupUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La agrupación debe ser event, origin_service o user"})
+		return
+	}
+
+	top, err := strconv.Atoi(c.DefaultQuery("top", strconv.Itoa(defaultAnalyticsTop)))
+	if err != nil || top < 1 || top > maxAnalyticsTop {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro top debe estar entre 1 y 50"})
+		return
+	}
+
+	filters, err := auditListFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// El rango se maneja aparte: el fin es exclusivo y el periodo anterior se calcula a partir de él
+	to := time.Now().UTC()
+	if end, ok := filters["to"].(time.Time); ok {
+		to = end.Add(time.Nanosecond)
+	}
+	from := to.Add(-span)
+	if start, ok := filters["from"].(time.Time); ok {
+		from = start
+	}
+	delete(filters, "from")
+	delete(filters, "to")
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La fecha inicial debe ser anterior a la final"})
+		return
+	}
+	if to.Sub(from) > maxAnalyticsRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El rango no puede superar un año"})
+		return
+	}
+	if interval == services.AnalyticsIntervalHour && to.Sub(from) > maxHourlyRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Con intervalo por hora el rango no puede superar 31 días"})
+		return
+	}
+
+	analytics, err := services.GetAuditAnalytics(interval, groupBy, from, to, top, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al calcular la analítica de auditorías"})
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
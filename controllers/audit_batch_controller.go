@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"seguridad-api/models"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxAuditBatchItems   = 1000
+	maxAuditBatchBytes   = 10 << 20
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	batchItemAccepted  = "ACCEPTED"
+	batchItemDuplicate = "DUPLICATE"
+	batchItemRejected  = "REJECTED"
+)
+
+// flexibleID acepta el ID del usuario como número o como cadena, igual que lo envían los módulos actuales
+type flexibleID uint
+
+func (id *flexibleID) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return errors.New("el ID del usuario debe ser un número válido")
+	}
+	*id = flexibleID(parsed)
+	return nil
+}
+
+type AuditBatchItem struct {
+	Event          string     `json:"event" example:"INSERT"`
+	Description    string     `json:"description" example:"Se registró una venta"`
+	UserID         flexibleID `json:"user_id" swaggertype:"string" example:"123"`
+	OriginService  string     `json:"origin_service" example:"VENTAS"`
+	Date           string     `json:"date" example:"2024-12-14T15:04:05Z"`
+	Outcome        string     `json:"outcome" example:"SUCCESS"`
+	FailureReason  string     `json:"failure_reason" example:""`
+	IP             string     `json:"ip" example:"192.168.1.10"`
+	UserAgent      string     `json:"user_agent" example:"Mozilla/5.0"`
+	RequestID      string     `json:"request_id" example:"4f3c2a1b9d8e7f60"`
+	IdempotencyKey string     `json:"idempotency_key" example:"ventas-8812-insert"`
+}
+
+type AuditBatchItemResult struct {
+	Index          int    `json:"index" example:"0"`
+	Status         string `json:"status" example:"ACCEPTED"`
+	IdempotencyKey string `json:"idempotency_key,omitempty" example:"ventas-8812-insert"`
+	Error          string `json:"error,omitempty" example:""`
+}
+
+type AuditBatchResponse struct {
+	Accepted   int                    `json:"accepted" example:"98"`
+	Duplicates int                    `json:"duplicates" example:"1"`
+	Rejected   int                    `json:"rejected" example:"1"`
+	Results    []AuditBatchItemResult `json:"results"`
+}
+
+// RegisterAuditBatch registra varias auditorías en una sola petición
+// @Summary Registrar auditorías en lote
+// @Description Acepta un arreglo JSON o un flujo NDJSON (application/x-ndjson) de hasta 1000 auditorías. Cada elemento se valida por separado; los válidos se guardan en segundo plano con inserciones masivas y los repetidos por idempotency_key se reportan como DUPLICATE.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param Idempotency-Key header string false "Clave del lote; los elementos sin idempotency_key usan <clave>:<posición>"
+// @Param auditData body []AuditBatchItem true "Auditorías a registrar"
+// @Success 202 {object} AuditBatchResponse "Todas las auditorías fueron aceptadas"
+// @Success 207 {object} AuditBatchResponse "Algunas auditorías fueron rechazadas"
+// @Failure 400 {object} ErrorResponseAudit "Formato inválido o ninguna auditoría válida"
+// @Failure 413 {object} ErrorResponseAudit "El lote supera el máximo permitido"
+// @Failure 500 {object} ErrorResponseAudit "Error al registrar las auditorías"
+// @Router /audit/batch [post]
+func RegisterAuditBatch(c *gin.Context) {
+	items, parseErrors, err := decodeAuditBatch(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "máximo") {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Con el encabezado Idempotency-Key el lote completo puede reintentarse: cada elemento sin clave
+	// propia recibe una derivada de su posición
+	batchKey := c.GetHeader(idempotencyKeyHeader)
+	if batchKey != "" {
+		for i := range items {
+			if items[i].IdempotencyKey == "" {
+				items[i].IdempotencyKey = fmt.Sprintf("%s:%d", batchKey, i)
+			}
+		}
+	}
+
+	results := make([]AuditBatchItemResult, len(items))
+	audits := make([]models.Audit, 0, len(items))
+	seenKeys := make(map[string]int)
+	var keys []string
+	var userIDs []uint
+
+	for i, item := range items {
+		results[i] = AuditBatchItemResult{Index: i, IdempotencyKey: item.IdempotencyKey}
+		if parseErr, ok := parseErrors[i]; ok {
+			results[i].Status, results[i].Error = batchItemRejected, parseErr
+			continue
+		}
+
+		audit, err := auditFromBatchItem(item)
+		if err != nil {
+			results[i].Status, results[i].Error = batchItemRejected, err.Error()
+			continue
+		}
+		if item.IdempotencyKey != "" {
+			if _, seen := seenKeys[item.IdempotencyKey]; seen {
+				results[i].Status = batchItemDuplicate
+				continue
+			}
+			seenKeys[item.IdempotencyKey] = i
+			keys = append(keys, item.IdempotencyKey)
+		}
+
+		services.FillAuditMetadata(c, &audit)
+		results[i].Status = batchItemAccepted
+		audits = append(audits, audit)
+		userIDs = append(userIDs, audit.UserID)
+	}
+
+	existingKeys, err := services.FindExistingIdempotencyKeys(keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar las auditorías"})
+		return
+	}
+	existingUsers, err := services.FindExistingUserIDs(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar las auditorías"})
+		return
+	}
+
+	// Descartar los registros ya guardados en peticiones anteriores y los de usuarios inexistentes.
+	// audits conserva el mismo orden que los resultados aceptados.
+	pending := make([]models.Audit, 0, len(audits))
+	next := 0
+	for i := range results {
+		if results[i].Status != batchItemAccepted {
+			continue
+		}
+		audit := audits[next]
+		next++
+
+		switch {
+		case results[i].IdempotencyKey != "" && existingKeys[results[i].IdempotencyKey]:
+			results[i].Status = batchItemDuplicate
+		case !existingUsers[audit.UserID]:
+			results[i].Status, results[i].Error = batchItemRejected, fmt.Sprintf("el usuario %d no existe", audit.UserID)
+		default:
+			pending = append(pending, audit)
+		}
+	}
+
+	response := AuditBatchResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case batchItemAccepted:
+			response.Accepted++
+		case batchItemDuplicate:
+			response.Duplicates++
+		default:
+			response.Rejected++
+		}
+	}
+
+	if response.Accepted == 0 && response.Duplicates == 0 {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if err := services.EnqueueAudits(c, pending); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar las auditorías"})
+		return
+	}
+
+	status := http.StatusAccepted
+	if response.Rejected > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, response)
+}
+
+// decodeAuditBatch lee el cuerpo como arreglo JSON o NDJSON. Las líneas NDJSON que no se pueden
+// interpretar se reportan como rechazadas sin invalidar el resto del lote.
+func decodeAuditBatch(c *gin.Context) ([]AuditBatchItem, map[int]string, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBatchBytes+1))
+	if err != nil {
+		return nil, nil, errors.New("no se pudo leer el cuerpo de la petición")
+	}
+	if len(body) > maxAuditBatchBytes {
+		return nil, nil, fmt.Errorf("el lote supera el máximo de %d MB", maxAuditBatchBytes>>20)
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil, errors.New("el lote está vacío")
+	}
+
+	var items []AuditBatchItem
+	parseErrors := make(map[int]string)
+
+	if trimmed[0] == '[' && !strings.Contains(c.ContentType(), "ndjson") {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, nil, errors.New("el cuerpo debe ser un arreglo JSON válido")
+		}
+		if len(raw) > maxAuditBatchItems {
+			return nil, nil, fmt.Errorf("el lote supera el máximo de %d auditorías", maxAuditBatchItems)
+		}
+		for i, element := range raw {
+			var item AuditBatchItem
+			if err := json.Unmarshal(element, &item); err != nil {
+				parseErrors[i] = "elemento inválido: " + err.Error()
+			}
+			items = append(items, item)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxAuditBatchBytes)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			if len(items) == maxAuditBatchItems {
+				return nil, nil, fmt.Errorf("el lote supera el máximo de %d auditorías", maxAuditBatchItems)
+			}
+			var item AuditBatchItem
+			if err := json.Unmarshal(line, &item); err != nil {
+				parseErrors[len(items)] = "línea inválida: " + err.Error()
+			}
+			items = append(items, item)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, errors.New("no se pudo leer el flujo NDJSON")
+		}
+	}
+
+	return items, parseErrors, nil
+}
+
+// auditFromBatchItem valida un elemento del lote y lo convierte en auditoría
+func auditFromBatchItem(item AuditBatchItem) (models.Audit, error) {
+	var missing []string
+	if strings.TrimSpace(item.Event) == "" {
+		missing = append(missing, "event")
+	}
+	if strings.TrimSpace(item.Description) == "" {
+		missing = append(missing, "description")
+	}
+	if item.UserID == 0 {
+		missing = append(missing, "user_id")
+	}
+	if strings.TrimSpace(item.OriginService) == "" {
+		missing = append(missing, "origin_service")
+	}
+	if item.Date == "" {
+		missing = append(missing, "date")
+	}
+	if len(missing) > 0 {
+		return models.Audit{}, fmt.Errorf("campos obligatorios faltantes: %s", strings.Join(missing, ", "))
+	}
+
+	if len(item.Event) > 50 {
+		return models.Audit{}, errors.New("el evento no puede superar 50 caracteres")
+	}
+	if len(item.IdempotencyKey) > 100 {
+		return models.Audit{}, errors.New("la idempotency_key no puede superar 100 caracteres")
+	}
+
+	date, err := time.Parse(time.RFC3339, item.Date)
+	if err != nil {
+		return models.Audit{}, errors.New("el formato de la fecha es inválido")
+	}
+
+	outcome := strings.ToUpper(item.Outcome)
+	if outcome == "" {
+		outcome = models.AuditOutcomeSuccess
+	}
+	if outcome != models.AuditOutcomeSuccess && outcome != models.AuditOutcomeFailure {
+		return models.Audit{}, errors.New("el resultado debe ser SUCCESS o FAILURE")
+	}
+
+	audit := models.Audit{
+		Event:         item.Event,
+		Description:   item.Description,
+		UserID:        uint(item.UserID),
+		OriginService: item.OriginService,
+		Date:          date.UTC(),
+		Outcome:       outcome,
+		FailureReason: item.FailureReason,
+		IP:            item.IP,
+		UserAgent:     item.UserAgent,
+		RequestID:     item.RequestID,
+	}
+	if item.IdempotencyKey != "" {
+		key := item.IdempotencyKey
+		audit.IdempotencyKey = &key
+	}
+	return audit, nil
+}
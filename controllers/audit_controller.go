@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"seguridad-api/config"
@@ -19,6 +20,13 @@ type RegisterAuditInput struct {
 	UserID        string `json:"user_id" binding:"required" example:"123"`
 	OriginService string `json:"origin_service" binding:"required" example:"INVENTARIO"`
 	Date          string `json:"date" binding:"required" example:"2024-12-14T15:04:05Z"`
+	Outcome       string `json:"outcome" example:"SUCCESS"`
+	FailureReason string `json:"failure_reason" example:""`
+	IP            string `json:"ip" example:"192.168.1.10"`
+	UserAgent     string `json:"user_agent" example:"Mozilla/5.0"`
+	RequestID     string `json:"request_id" example:"4f3c2a1b9d8e7f60"`
+	// También puede enviarse en el encabezado Idempotency-Key
+	IdempotencyKey string `json:"idempotency_key" example:"ventas-8812-insert"`
 }
 
 type RegisterAuditResponse struct {
@@ -37,6 +45,7 @@ type ErrorResponseAudit struct {
 // @Accept json
 // @Produce json
 // @Param auditData body RegisterAuditInput true "Datos de auditoría a registrar"
+// @Param Idempotency-Key header string false "Clave para que los reintentos no dupliquen la auditoría"
 // @Success 200 {object} RegisterAuditResponse "Auditoría registrada exitosamente"
 // @Failure 400 {object} ErrorResponseAudit "Datos inválidos o formato incorrecto"
 // @Failure 500 {object} ErrorResponseAudit "Error al registrar la auditoría"
@@ -66,11 +75,54 @@ func RegisterAudit(c *gin.Context) {
 	// Convertir la fecha a UTC
 	date = date.UTC()
 
-	// Registrar la auditoría con la fecha en UTC
-	if err := services.RegisterAudit(input.Event, input.Description, uint(userID), input.OriginService, date); err != nil {
+	outcome := strings.ToUpper(input.Outcome)
+	if outcome == "" {
+		outcome = models.AuditOutcomeSuccess
+	}
+	if outcome != models.AuditOutcomeSuccess && outcome != models.AuditOutcomeFailure {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El resultado debe ser SUCCESS o FAILURE"})
+		return
+	}
+
+	// Los datos de la petición se completan desde el contexto si el módulo de origen no los envía
+	audit := models.Audit{
+		Event:         input.Event,
+		Description:   input.Description,
+		UserID:        uint(userID),
+		OriginService: input.OriginService,
+		Date:          date,
+		Outcome:       outcome,
+		FailureReason: input.FailureReason,
+		IP:            input.IP,
+		UserAgent:     input.UserAgent,
+		RequestID:     input.RequestID,
+	}
+
+	idempotencyKey := input.IdempotencyKey
+	if header := c.GetHeader(idempotencyKeyHeader); header != "" {
+		idempotencyKey = header
+	}
+	if len(idempotencyKey) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La idempotency_key no puede superar 100 caracteres"})
+		return
+	}
+
+	// Registrar la auditoría con la fecha en UTC; con clave de idempotencia un reintento no la duplica
+	registered := true
+	if idempotencyKey != "" {
+		audit.IdempotencyKey = &idempotencyKey
+		registered, err = services.SaveIdempotentAudit(c, &audit)
+	} else {
+		err = services.SaveAudit(c, &audit)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar la auditoría"})
 		return
 	}
+	if !registered {
+		c.JSON(http.StatusOK, RegisterAuditResponse{Message: "La auditoría ya fue registrada anteriormente"})
+		return
+	}
 
 	c.JSON(http.StatusOK, RegisterAuditResponse{Message: "Auditoría registrada exitosamente"})
 }
@@ -137,19 +189,30 @@ func GetAuditStatistics(event, module, startDate, endDate string) ([]models.Audi
 // @Param page query int false "Número de página para la paginación (por defecto: 1)"
 // @Param pageSize query int false "Número de registros por página (por defecto: 10)"
 // @Param event query string false "Filtrar auditorías por tipo de evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por ID de usuario"
+// @Param userName query string false "Filtrar por nombre de usuario"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Param ip query string false "Filtrar por IP del cliente"
+// @Param request_id query string false "Filtrar por ID de correlación"
+// @Param method query string false "Filtrar por método HTTP"
+// @Param route query string false "Filtrar por ruta"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
 // @Success 200 {object} map[string]interface{} "audits"
+// @Failure 400 {object} ErrorResponseAudit "Filtros inválidos"
 // @Failure 500 {object} ErrorResponseAudit "Error al obtener las auditorías"
 // @Router /audit [get]
 func GetAudit(c *gin.Context) {
 	// Obtener parámetros de consulta para paginación y filtros
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
-	event := c.Query("event")
 
 	// Construir filtros
-	filters := make(map[string]interface{})
-	if event != "" {
-		filters["event"] = event
+	filters, err := auditListFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Llamar al servicio para obtener auditorias paginadas
@@ -169,6 +232,65 @@ func GetAudit(c *gin.Context) {
 	})
 }
 
+// GetAuditChanges obtiene el historial de cambios campo a campo de las entidades auditadas
+// @Summary Obtener historial de cambios
+// @Description Devuelve los cambios registrados automáticamente sobre usuarios, roles, permisos, módulos y sus asignaciones, con el diff de cada campo.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param entity_type query string false "Tipo de entidad (User, Role, Permission, Module, UserRole, RolePermission)"
+// @Param entity_id query int false "ID de la entidad"
+// @Param action query string false "Acción (CREATE, UPDATE, DELETE)"
+// @Param user_id query int false "ID del usuario que realizó el cambio"
+// @Success 200 {object} map[string]interface{} "changes"
+// @Failure 400 {object} ErrorResponseAudit "Filtros inválidos"
+// @Failure 500 {object} ErrorResponseAudit "Error al obtener el historial de cambios"
+// @Router /audit/changes [get]
+func GetAuditChanges(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	filters := make(map[string]interface{})
+	if entityType := c.Query("entity_type"); entityType != "" {
+		filters["entity_type"] = entityType
+	}
+	if action := c.Query("action"); action != "" {
+		filters["action"] = strings.ToUpper(action)
+	}
+	for _, key := range []string{"entity_id", "user_id"} {
+		if value := c.Query(key); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro " + key + " debe ser un número válido"})
+				return
+			}
+			filters[key] = uint(id)
+		}
+	}
+
+	changes, total, err := services.GetPaginatedAuditChanges(page, pageSize, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el historial de cambios"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes":    changes,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
 // func GetAuditoriaEstadisticas(c *gin.Context) {
 // 	// Obtener parámetros de consulta
 // 	event := c.Query("event")
@@ -196,22 +318,76 @@ func GetAuditoriaEstadisticas(c *gin.Context) {
 	endDate := c.Query("end_date")
 
 	// Llamar al servicio
-	stats, records, err := services.GetAuditStatistics(event, module, startDate, endDate)
+	stats, records, err := services.GetAuditStatistics(event, module, startDate, endDate, auditContextFilters(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Los registros son solo los más recientes; el total se obtiene de las estadísticas
+	var totalRecords int
+	for _, stat := range stats {
+		totalRecords += stat.Total
+	}
+
 	// Construir la respuesta JSON con estadísticas y registros
 	response := gin.H{
-		"statistics": stats,
-		"records":    records,
+		"statistics":   stats,
+		"records":      records,
+		"totalRecords": totalRecords,
 	}
 
 	// Devolver resultados
 	c.JSON(http.StatusOK, response)
 }
 
+// auditListFilters lee los filtros del listado de auditorías: evento, servicio, usuario y datos de la petición
+func auditListFilters(c *gin.Context) (map[string]interface{}, error) {
+	filters := auditContextFilters(c)
+	if event := c.Query("event"); event != "" {
+		filters["event"] = event
+	}
+	if module := c.Query("module"); module != "" {
+		filters["origin_service"] = module
+	}
+	if userName := c.Query("userName"); userName != "" {
+		filters["userName"] = userName
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			return nil, errors.New(ErrInvalidUserID)
+		}
+		filters["user_id"] = uint(id)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := parseFilterTime(startDate, false)
+		if err != nil {
+			return nil, errors.New("formato de start_date inválido")
+		}
+		filters["from"] = start
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := parseFilterTime(endDate, true)
+		if err != nil {
+			return nil, errors.New("formato de end_date inválido")
+		}
+		filters["to"] = end
+	}
+	return filters, nil
+}
+
+// auditContextFilters lee los filtros por datos de la petición comunes a los listados de auditoría
+func auditContextFilters(c *gin.Context) map[string]interface{} {
+	filters := make(map[string]interface{})
+	for _, key := range []string{"ip", "request_id", "method", "route", "outcome", "user_agent"} {
+		if value := c.Query(key); value != "" {
+			filters[key] = value
+		}
+	}
+	return filters
+}
+
 // func RegisterAudit(c *gin.Context) {
 // 	var input RegisterAuditInput
 
@@ -232,7 +408,7 @@ func GetAuditoriaEstadisticas(c *gin.Context) {
 // 		return
 // 	}
 
-// 	if err := services.RegisterAudit(input.Event, input.Description, uint(userID), input.OriginService, date); err != nil {
+// 	if err := services.RegisterAudit(c, input.Event, input.Description, uint(userID), input.OriginService, date); err != nil {
 // 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar la auditoría"})
 // 		return
 // 	}
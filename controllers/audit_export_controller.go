@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"seguridad-api/models"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditCSVHeader = []string{
+	"id", "event", "description", "user_id", "user", "origin_service", "date",
+	"ip", "user_agent", "request_id", "method", "route", "outcome", "failure_reason",
+}
+
+// ExportAudit exporta las auditorías filtradas en JSONL o CSV
+// @Summary Exportar auditorías
+// @Description Descarga las auditorías que cumplen los filtros del listado como JSON Lines (una auditoría por línea) o CSV. El archivo se genera por lotes, sin límite de registros.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param format query string false "Formato: jsonl (por defecto) o csv"
+// @Param event query string false "Filtrar por tipo de evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por ID de usuario"
+// @Param userName query string false "Filtrar por nombre de usuario"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Success 200 {file} file "Archivo exportado"
+// @Failure 400 {object} ErrorResponseAudit "Filtros o formato inválidos"
+// @Router /audit/export [get]
+func ExportAudit(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "jsonl"))
+	if format != "jsonl" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El formato debe ser jsonl o csv"})
+		return
+	}
+
+	filters, err := auditListFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName := "auditoria_" + time.Now().Format("20060102_150405") + "." + format
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+
+	var write func(models.AuditResponse) error
+	var finish func() error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(c.Writer)
+		if err := writer.Write(auditCSVHeader); err != nil {
+			return
+		}
+		write = func(audit models.AuditResponse) error {
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(audit.ID), 10), audit.Event, audit.Description,
+				strconv.FormatUint(uint64(audit.UserID), 10), audit.User, audit.OriginService,
+				audit.Date.UTC().Format(time.RFC3339), audit.IP, audit.UserAgent, audit.RequestID,
+				audit.Method, audit.Route, audit.Outcome, audit.FailureReason,
+			})
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(c.Writer)
+		write = func(audit models.AuditResponse) error {
+			return encoder.Encode(audit)
+		}
+		finish = func() error { return nil }
+	}
+	c.Status(http.StatusOK)
+
+	// Si falla a mitad del envío ya no se puede cambiar el estado; el archivo queda truncado
+	if err := services.ExportAudits(filters, write); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := finish(); err != nil {
+		c.Error(err)
+	}
+}
+
+// GetSiemStatus muestra la configuración y el avance del reenvío de auditorías al SIEM
+// @Summary Estado del reenvío al SIEM
+// @Description Devuelve el destino syslog configurado, el formato, el último ID enviado y el último error.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} services.SiemStatus "Estado del reenvío"
+// @Failure 500 {object} ErrorResponseAudit "Error al obtener el estado"
+// @Router /audit/siem/status [get]
+func GetSiemStatus(c *gin.Context) {
+	status, err := services.GetSiemStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el estado del reenvío al SIEM"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ErrInvalidPolicyID = "ID de política inválido"
+
+type RetentionPolicyInput struct {
+	OriginService string `json:"origin_service" example:"INVENTARIO"`
+	Event         string `json:"event" example:"LOGIN"`
+	RetentionDays int    `json:"retention_days" binding:"required,min=1" example:"365"`
+	Active        *bool  `json:"active" example:"true"`
+}
+
+type UpdateRetentionPolicyInput struct {
+	RetentionDays int  `json:"retention_days" binding:"required,min=1" example:"180"`
+	Active        bool `json:"active" example:"true"`
+}
+
+// CreateRetentionPolicy crea una política de retención de auditorías
+// @Summary Crear política de retención
+// @Description Define cuántos días se conservan las auditorías de un servicio y/o evento. Los campos vacíos aplican a cualquier valor y la política más específica tiene prioridad.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body RetentionPolicyInput true "Datos de la política"
+// @Success 200 {object} map[string]interface{} "policy"
+// @Failure 400 {object} ErrorResponseAudit "Datos inválidos o política duplicada"
+// @Failure 401 {object} ErrorResponseAudit "No se pudo obtener el ID del usuario"
+// @Router /audit/retention-policies [post]
+func CreateRetentionPolicy(c *gin.Context) {
+	var input RetentionPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	active := true
+	if input.Active != nil {
+		active = *input.Active
+	}
+
+	policy, err := services.CreateRetentionPolicy(input.OriginService, input.Event, input.RetentionDays, active)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	description := fmt.Sprintf("Se creó la política de retención %d (servicio: %s, evento: %s, días: %d)",
+		policy.ID, scopeLabel(policy.OriginService), scopeLabel(policy.Event), policy.RetentionDays)
+	if auditErr := services.RegisterAudit(c, "INSERT", description, uint(userID.(float64)), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Política creada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+// GetRetentionPolicies lista las políticas de retención
+// @Summary Obtener políticas de retención
+// @Description Devuelve las políticas de retención ordenadas de la más específica a la más general.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "policies"
+// @Failure 500 {object} ErrorResponseAudit "Error al obtener las políticas"
+// @Router /audit/retention-policies [get]
+func GetRetentionPolicies(c *gin.Context) {
+	policies, err := services.GetRetentionPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las políticas de retención"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// UpdateRetentionPolicy actualiza los días de retención y el estado de una política
+// @Summary Actualizar política de retención
+// @Tags Auditoría
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la política"
+// @Param input body UpdateRetentionPolicyInput true "Datos de la política"
+// @Success 200 {object} map[string]interface{} "policy"
+// @Failure 400 {object} ErrorResponseAudit "Datos inválidos"
+// @Failure 404 {object} ErrorResponseAudit "Política no encontrada"
+// @Router /audit/retention-policies/{id} [put]
+func UpdateRetentionPolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPolicyID})
+		return
+	}
+
+	var input UpdateRetentionPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	policy, err := services.UpdateRetentionPolicy(uint(id), input.RetentionDays, input.Active)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	description := fmt.Sprintf("Se actualizó la política de retención %d (días: %d, activa: %t)", policy.ID, policy.RetentionDays, policy.Active)
+	if auditErr := services.RegisterAudit(c, "UPDATE", description, uint(userID.(float64)), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Política actualizada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+// DeleteRetentionPolicy elimina una política de retención
+// @Summary Eliminar política de retención
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la política"
+// @Success 200 {object} map[string]interface{} "message"
+// @Failure 400 {object} ErrorResponseAudit "ID inválido"
+// @Failure 404 {object} ErrorResponseAudit "Política no encontrada"
+// @Router /audit/retention-policies/{id} [delete]
+func DeleteRetentionPolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPolicyID})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	policy, err := services.DeleteRetentionPolicy(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	description := fmt.Sprintf("Se eliminó la política de retención %d (servicio: %s, evento: %s)",
+		policy.ID, scopeLabel(policy.OriginService), scopeLabel(policy.Event))
+	if auditErr := services.RegisterAudit(c, "DELETE", description, uint(userID.(float64)), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Política eliminada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Política de retención eliminada exitosamente"})
+}
+
+// RunAuditRetention ejecuta la retención de auditorías sin esperar al proceso programado
+// @Summary Ejecutar retención de auditorías
+// @Description Mueve a archivos JSONL comprimidos las auditorías que superaron el tiempo de retención de su política.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "archives"
+// @Failure 409 {object} ErrorResponseAudit "Ya existe un proceso en ejecución"
+// @Failure 500 {object} ErrorResponseAudit "Error al ejecutar la retención"
+// @Router /audit/retention/run [post]
+func RunAuditRetention(c *gin.Context) {
+	archives, err := services.RunAuditRetention(c)
+	if errors.Is(err, services.ErrRetentionRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "archives": archives})
+		return
+	}
+
+	records := 0
+	for _, archive := range archives {
+		records += archive.Records
+	}
+
+	if records > 0 {
+		description := fmt.Sprintf("Se archivaron %d auditorías en %d archivo(s)", records, len(archives))
+		if auditErr := services.RegisterAudit(c, "ARCHIVE", description, services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Retención ejecutada, pero no se pudo registrar la auditoría"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"archives": archives, "records": records})
+}
+
+// GetAuditArchives lista los archivos de auditoría generados
+// @Summary Obtener archivos de auditoría
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Success 200 {object} map[string]interface{} "archives"
+// @Failure 500 {object} ErrorResponseAudit "Error al obtener los archivos"
+// @Router /audit/archives [get]
+func GetAuditArchives(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	archives, total, err := services.GetPaginatedAuditArchives(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los archivos de auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"archives":   archives,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// GetAuditArchiveRecords consulta las auditorías guardadas en un archivo
+// @Summary Consultar un archivo de auditoría
+// @Description Devuelve las auditorías del archivo que cumplen los filtros. Si el archivo está rehidratado se consulta la tabla temporal; si no, se verifica el checksum y se descomprime el archivo.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del archivo"
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param event query string false "Filtrar por evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por usuario"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
+// @Param ip query string false "Filtrar por IP"
+// @Param request_id query string false "Filtrar por ID de correlación"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Success 200 {object} map[string]interface{} "audits"
+// @Failure 400 {object} ErrorResponseAudit "Filtros inválidos"
+// @Failure 404 {object} ErrorResponseAudit "Archivo no encontrado"
+// @Failure 409 {object} ErrorResponseAudit "El checksum no coincide"
+// @Router /audit/archives/{id}/records [get]
+func GetAuditArchiveRecords(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de archivo inválido"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	filters := make(map[string]interface{})
+	for key, param := range map[string]string{"event": "event", "origin_service": "module", "outcome": "outcome", "ip": "ip", "request_id": "request_id"} {
+		if value := c.Query(param); value != "" {
+			filters[key] = value
+		}
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		parsed, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidUserID})
+			return
+		}
+		filters["user_id"] = uint(parsed)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := parseFilterTime(startDate, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de start_date inválido"})
+			return
+		}
+		filters["from"] = start
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := parseFilterTime(endDate, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de end_date inválido"})
+			return
+		}
+		filters["to"] = end
+	}
+
+	archive, audits, total, err := services.QueryAuditArchive(uint(id), page, pageSize, filters)
+	if errors.Is(err, services.ErrArchiveChecksum) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "no encontrado") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"archive":    archive,
+		"audits":     audits,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// RestoreAuditArchive rehidrata un archivo de auditoría para consultarlo sin descomprimirlo en cada página
+// @Summary Rehidratar un archivo de auditoría
+// @Description Verifica el checksum del archivo y copia sus auditorías a una tabla temporal durante AUDIT_ARCHIVE_RESTORE_TTL (por defecto 24h). Mientras dure, /audit/archives/{id}/records consulta esa tabla. Volver a restaurarlo renueva el plazo.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del archivo"
+// @Success 200 {object} map[string]interface{} "archive"
+// @Failure 400 {object} ErrorResponseAudit "ID inválido"
+// @Failure 404 {object} ErrorResponseAudit "Archivo no encontrado"
+// @Failure 409 {object} ErrorResponseAudit "El checksum no coincide"
+// @Failure 500 {object} ErrorResponseAudit "Error al rehidratar el archivo"
+// @Router /audit/archives/{id}/restore [post]
+func RestoreAuditArchive(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de archivo inválido"})
+		return
+	}
+
+	archive, err := services.RestoreAuditArchive(c, uint(id))
+	if errors.Is(err, services.ErrArchiveChecksum) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "no encontrado") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	description := fmt.Sprintf("Se rehidrató el archivo de auditoría %s (%d auditorías)", archive.FileName, archive.Records)
+	if auditErr := services.RegisterAudit(c, "RESTORE", description, services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Archivo rehidratado, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Archivo rehidratado correctamente", "archive": archive})
+}
+
+func scopeLabel(value string) string {
+	if value == "" {
+		return "todos"
+	}
+	return value
+}
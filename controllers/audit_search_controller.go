@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageSize es el máximo de registros por página de los listados de auditoría y seguridad
+const maxPageSize = 100
+
+// SearchAudit busca texto libre en las auditorías
+// @Summary Buscar auditorías
+// @Description Busca palabras en la descripción de las auditorías (índice FULLTEXT en MySQL, LIKE en otros motores) y admite los mismos filtros del listado. Cada resultado incluye su relevancia y un fragmento con las coincidencias dentro de <mark>.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce json
+// @Param q query string false "Texto a buscar en la descripción"
+// @Param events query string false "Eventos exactos separados por coma (ej. INSERT,DELETE)"
+// @Param sort query string false "Orden: relevance, date_desc, date_asc, event, user, origin_service"
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param event query string false "Filtrar por tipo de evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por ID de usuario"
+// @Param userName query string false "Filtrar por nombre de usuario"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Param ip query string false "Filtrar por IP del cliente"
+// @Param request_id query string false "Filtrar por ID de correlación"
+// @Param method query string false "Filtrar por método HTTP"
+// @Param route query string false "Filtrar por ruta"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
+// @Success 200 {object} map[string]interface{} "results"
+// @Failure 400 {object} ErrorResponseAudit "Filtros inválidos"
+// @Failure 500 {object} ErrorResponseAudit "Error al buscar las auditorías"
+// @Router /audit/search [get]
+func SearchAudit(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	filters, err := auditListFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if events := c.Query("events"); events != "" {
+		var selected []string
+		for _, event := range strings.Split(events, ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				selected = append(selected, strings.ToUpper(event))
+			}
+		}
+		if len(selected) > 0 {
+			filters["events"] = selected
+		}
+	}
+
+	sort := strings.ToLower(c.Query("sort"))
+	if sort != "" && !services.ValidAuditSort(sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Orden inválido: " + sort})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	results, total, err := services.SearchAudits(query, sort, page, pageSize, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar las auditorías"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":    results,
+		"query":      query,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"seguridad-api/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditStreamReplayBatch     = 500
+	auditStreamHeartbeat       = 15 * time.Second
+	auditStreamRetryMillis     = 3000
+	defaultAuditViewPermission = "VER_AUDITORIA"
+)
+
+// auditViewPermission es el permiso que permite ver las auditorías de todos los usuarios (AUDIT_VIEW_PERMISSION)
+func auditViewPermission() string {
+	if permission := os.Getenv("AUDIT_VIEW_PERMISSION"); permission != "" {
+		return permission
+	}
+	return defaultAuditViewPermission
+}
+
+// StreamAudit envía las auditorías nuevas en tiempo real mediante Server-Sent Events
+// @Summary Stream de auditorías
+// @Description Mantiene la conexión abierta y envía un evento "audit" por cada auditoría registrada que cumple los filtros. Con el encabezado Last-Event-ID (o el parámetro last_event_id) primero se reenvían las auditorías posteriores a ese ID. Las auditorías se confirman en paralelo, así que los eventos en vivo pueden llegar fuera del orden de sus IDs. Los usuarios sin el permiso de ver auditorías solo reciben las propias.
+// @Tags Auditoría
+// @Security BearerAuth
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Último ID recibido"
+// @Param last_event_id query int false "Último ID recibido (para clientes que no pueden enviar encabezados)"
+// @Param event query string false "Filtrar por tipo de evento"
+// @Param module query string false "Filtrar por servicio de origen"
+// @Param user_id query int false "Filtrar por ID de usuario"
+// @Param userName query string false "Filtrar por nombre de usuario"
+// @Param outcome query string false "Filtrar por resultado (SUCCESS, FAILURE)"
+// @Success 200 {string} string "Flujo de eventos"
+// @Failure 400 {object} ErrorResponseAudit "Filtros inválidos"
+// @Failure 401 {object} ErrorResponseAudit "No se pudo obtener el ID del usuario"
+// @Failure 500 {object} ErrorResponseAudit "Error al verificar los permisos"
+// @Router /audit/stream [get]
+func StreamAudit(c *gin.Context) {
+	filters, err := auditListFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := services.ActorFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	canViewAll, err := services.UserHasPermission(userID, auditViewPermission())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar los permisos del usuario"})
+		return
+	}
+	if !canViewAll {
+		filters["user_id"] = userID
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint
+	if lastEventID != "" {
+		parsed, err := strconv.ParseUint(lastEventID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El Last-Event-ID debe ser un número válido"})
+			return
+		}
+		lastID = uint(parsed)
+	}
+
+	// La suscripción se crea antes de la recuperación para no perder auditorías registradas mientras tanto
+	subscription := services.SubscribeAudits(filters)
+	defer subscription.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", auditStreamRetryMillis); err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	// IDs enviados en la recuperación; la suscripción puede volver a entregarlos y solo esos se descartan.
+	// No se usa el mayor ID enviado como límite porque las auditorías se confirman fuera de orden y una
+	// con ID menor puede llegar por la suscripción después de otra con ID mayor.
+	replayed := make(map[uint]struct{})
+	if lastEventID != "" {
+		for {
+			audits, err := services.GetAuditsAfter(lastID, auditStreamReplayBatch, filters)
+			if err != nil {
+				c.Render(-1, sse.Event{Event: "error", Data: gin.H{"error": "Error al recuperar las auditorías anteriores"}})
+				c.Writer.Flush()
+				return
+			}
+			for _, audit := range audits {
+				c.Render(-1, sse.Event{Id: strconv.FormatUint(uint64(audit.ID), 10), Event: "audit", Data: audit})
+				replayed[audit.ID] = struct{}{}
+				lastID = audit.ID
+			}
+			c.Writer.Flush()
+			if len(audits) < auditStreamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(auditStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			// Comentario SSE para mantener viva la conexión a través de proxies
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case audit, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			// Ya enviada durante la recuperación; cada auditoría se publica una sola vez
+			if _, sent := replayed[audit.ID]; sent {
+				delete(replayed, audit.ID)
+				continue
+			}
+			c.Render(-1, sse.Event{Id: strconv.FormatUint(uint64(audit.ID), 10), Event: "audit", Data: audit})
+			c.Writer.Flush()
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
 	"seguridad-api/services"
 
 	// email "seguridad-api/services/email"
@@ -56,7 +57,7 @@ func Login(c *gin.Context) {
 	}
 
 	// Autenticación del usuario
-	token, err := services.Authenticate(loginData.Email, loginData.Password, loginData.ModuleKey)
+	token, err := services.Authenticate(c, loginData.Email, loginData.Password, loginData.ModuleKey)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -82,7 +83,7 @@ func Login(c *gin.Context) {
 	description := "Se registra ingreso a la plataforma, usuario: " + loginData.Email
 	originService := "SEGURIDAD"
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Usuario creado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -109,6 +110,8 @@ func Login(c *gin.Context) {
 // @Router /logout [post]
 func Logout(c *gin.Context) {
 	// Aquí se pueden agregar acciones para invalidar el token si fuera necesario
+	userID := services.ActorFromContext(c)
+	services.RecordSecurityEvent(c, models.SecurityEventLogout, &userID, "", "", "")
 	c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada exitosamente"})
 }
 
@@ -224,7 +227,7 @@ func RequestPasswordReset(c *gin.Context) {
 
 	fmt.Println("Email recibido:", request.Email)
 
-	err := services.SendPasswordResetEmail(request.Email)
+	err := services.SendPasswordResetEmail(c, request.Email)
 	if err != nil {
 		fmt.Println("Error al enviar correo:", err) // 🔍 Log de error
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -245,7 +248,7 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	err := services.ResetPassword(request.Token, request.Password)
+	err := services.ResetPassword(c, request.Token, request.Password)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -1,34 +1,50 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
-	"seguridad-api/models"
 	"seguridad-api/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// FastPermissionPayload es un permiso de la carga rápida; los campos se validan por fila para informar cada error
+type FastPermissionPayload struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ModuleID    uint   `json:"module_id"`
+	Active      *bool  `json:"active"`
+}
+
 // ChargeFastOfData permite cargar múltiples permisos rápidamente
 // @Summary Carga rápida de permisos
-// @Description Este endpoint permite la creación masiva de permisos en una sola solicitud. Se espera que la solicitud incluya una lista de permisos a crear. Además, se requiere un token de autenticación Bearer para acceder a este endpoint.
+// @Description Este endpoint permite la creación masiva de permisos en una sola solicitud. Se espera que la solicitud incluya una lista de permisos a crear. Además, se requiere un token de autenticación Bearer para acceder a este endpoint. Con mode=transaction (por defecto) un error en cualquier fila revierte toda la carga y responde 422; con mode=continue las filas con error se descartan y las demás se guardan. dry_run=true valida cada fila contra la base sin guardar nada y upsert=true actualiza los permisos que ya existen con el mismo módulo y nombre (descripción y estado). La respuesta incluye el resultado de cada fila.
 // @Tags Permisos
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param input body []models.Permission true "Lista de permisos a crear"
-// @Success 200 {object} map[string]interface{} "Lista de permisos creados y errores de auditoría (si los hay)"
-// @Failure 400 {object} map[string]string "Error en la validación de datos"
+// @Param input body []FastPermissionPayload true "Lista de permisos a crear"
+// @Param dry_run query bool false "Validar sin guardar"
+// @Param mode query string false "transaction (por defecto) o continue"
+// @Param upsert query bool false "Actualizar los permisos existentes por módulo y nombre"
+// @Success 200 {object} map[string]interface{} "Mensaje y resultado por fila, con los errores de auditoría (si los hay)"
+// @Failure 400 {object} map[string]string "Error en la validación de datos o más filas que FAST_CHARGE_MAX_ROWS"
 // @Failure 401 {object} map[string]string "Error de autorización (no se pudo obtener el ID del usuario)"
+// @Failure 422 {object} map[string]interface{} "La carga se revirtió por errores en algunas filas"
 // @Failure 500 {object} map[string]string "Error interno del servidor"
-// @Router /permissions/fast [post]
+// @Router /permissions/fastCharge [post]
 func ChargeFastOfData(c *gin.Context) {
-	var inputs []models.Permission
+	var inputs []FastPermissionPayload
 
 	// Bind de los datos del cuerpo de la solicitud
 	if err := c.ShouldBindJSON(&inputs); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	options, ok := fastChargeOptions(c)
+	if !ok {
+		return
+	}
 
 	// Verificar si el ID del usuario está disponible en el contexto
 	userID, exists := c.Get("userID")
@@ -37,19 +53,20 @@ func ChargeFastOfData(c *gin.Context) {
 		return
 	}
 
-	// Convertir el ID del usuario a uint
-	userIDUint := uint(userID.(float64))
+	permissions := make([]services.FastPermissionInput, len(inputs))
+	for i, input := range inputs {
+		permissions[i] = services.FastPermissionInput{Name: input.Name, Description: input.Description, ModuleID: input.ModuleID, Active: input.Active}
+	}
 
 	// Llamada al servicio para procesar la carga rápida de permisos
-	createdPermissions, auditErrors, err := services.ChargeFastPermissions(inputs, userIDUint)
+	report, err := services.ChargeFastPermissions(c, permissions, options, uint(userID.(float64)))
+	if errors.Is(err, services.ErrFastChargeTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar la carga rápida", "details": err.Error()})
 		return
 	}
-
-	// Responder con los permisos creados y errores de auditoría (si los hay)
-	c.JSON(http.StatusOK, gin.H{
-		"permissions": createdPermissions,
-		"auditErrors": auditErrors,
-	})
+	fastChargeResponse(c, report, "Permisos cargados exitosamente")
 }
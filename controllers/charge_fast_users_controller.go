@@ -1,37 +1,41 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
-	helpers "seguridad-api/helpers"
 	"seguridad-api/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// FastUserPayload es un usuario de la carga rápida; los campos se validan por fila para informar cada error
 type FastUserPayload struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Active   bool   `json:"active"`
-	RoleID   uint   `json:"role_id" binding:"required"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Active   *bool  `json:"active"`
+	RoleID   uint   `json:"role_id"`
 }
 
 // ChargeFastUsers permite la creación masiva de usuarios y la asignación de roles
 // @Summary Carga rápida de usuarios y asignación de roles
-// @Description Este endpoint permite la creación masiva de usuarios, incluyendo la asignación de roles a cada uno. Requiere un token de autenticación Bearer para ser utilizado. La solicitud debe incluir una lista de usuarios a crear junto con su rol correspondiente.
+// @Description Este endpoint permite la creación masiva de usuarios, incluyendo la asignación de roles a cada uno. Requiere un token de autenticación Bearer para ser utilizado. La solicitud debe incluir una lista de usuarios a crear junto con su rol correspondiente. Con mode=transaction (por defecto) un error en cualquier fila revierte toda la carga y responde 422; con mode=continue las filas con error se descartan y las demás se guardan. dry_run=true valida cada fila contra la base sin guardar nada y upsert=true actualiza los usuarios cuyo correo ya existe (nombre, estado, contraseña si se envía y el rol se agrega). La respuesta incluye el resultado de cada fila.
 // @Tags Usuarios
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param input body []FastUserPayload true "Lista de usuarios a crear y asignar roles"
-// @Success 200 {object} map[string]string "Mensaje de éxito"
-// @Failure 400 {object} map[string]string "Error en la validación de datos"
+// @Param dry_run query bool false "Validar sin guardar"
+// @Param mode query string false "transaction (por defecto) o continue"
+// @Param upsert query bool false "Actualizar los usuarios existentes por correo"
+// @Success 200 {object} map[string]interface{} "Mensaje y resultado por fila"
+// @Failure 400 {object} map[string]string "Error en la validación de datos o más filas que FAST_CHARGE_MAX_ROWS"
 // @Failure 401 {object} map[string]string "Error de autorización (usuario no autenticado)"
+// @Failure 422 {object} map[string]interface{} "La carga se revirtió por errores en algunas filas"
 // @Failure 500 {object} map[string]string "Error interno del servidor"
-// @Router /users/fast [post]
+// @Router /users/fastCharge [post]
 func ChargeFastUsers(c *gin.Context) {
 	var inputs []FastUserPayload
 
@@ -40,6 +44,10 @@ func ChargeFastUsers(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	options, ok := fastChargeOptions(c)
+	if !ok {
+		return
+	}
 
 	// Verificar si el ID del usuario está disponible en el contexto
 	userID, exists := c.Get("userID")
@@ -47,46 +55,54 @@ func ChargeFastUsers(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
 		return
 	}
-	// Convertir el ID del usuario a uint
-	userIDUint := uint(userID.(float64))
-
-	// Obtener la hora actual ajustada a la zona horaria de Ecuador
-	currentTime := time.Now()
-	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
-
-	// Procesar cada usuario de la lista de entrada
-	for _, input := range inputs {
-		// Crear el usuario
-		user, err := services.CreateUser(input.Name, input.Email, input.Password, input.Active)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear el usuario " + input.Email + ": " + err.Error()})
-			return
-		}
 
-		// Registrar auditoría de creación de usuario
-		event := "INSERT"
-		description := "Se creó un usuario con el email: " + input.Email
-		originService := "SEGURIDAD"
-		if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Usuario creado, pero no se pudo registrar la auditoría"})
-			return
-		}
+	users := make([]services.FastUserInput, len(inputs))
+	for i, input := range inputs {
+		users[i] = services.FastUserInput{Name: input.Name, Email: input.Email, Password: input.Password, Active: input.Active, RoleID: input.RoleID}
+	}
 
-		// Asignar el rol al usuario
-		_, err = services.AssignRoleToUser(user.ID, input.RoleID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al asignar el rol al usuario " + input.Email + ": " + err.Error()})
-			return
-		}
+	report, err := services.ChargeFastUsers(c, users, options, uint(userID.(float64)))
+	if errors.Is(err, services.ErrFastChargeTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar la carga rápida", "details": err.Error()})
+		return
+	}
+	fastChargeResponse(c, report, "Usuarios y roles cargados exitosamente")
+}
 
-		// Registrar auditoría de asignación de rol
-		roleDescription := "Se asignó el rol " + strconv.Itoa(int(input.RoleID)) + " al usuario con email: " + input.Email
-		if auditErr := services.RegisterAudit(event, roleDescription, userIDUint, originService, ecuadorTime); auditErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol asignado, pero no se pudo registrar la auditoría"})
-			return
+// fastChargeOptions lee dry_run, mode y upsert de la consulta; responde 400 si no son válidos
+func fastChargeOptions(c *gin.Context) (services.FastChargeOptions, bool) {
+	options := services.FastChargeOptions{Mode: c.Query("mode")}
+	for name, target := range map[string]*bool{"dry_run": &options.DryRun, "upsert": &options.Upsert} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " debe ser true o false"})
+				return options, false
+			}
+			*target = value
 		}
 	}
+	if err := options.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return options, false
+	}
+	return options, true
+}
 
-	// Responder con mensaje de éxito
-	c.JSON(http.StatusOK, gin.H{"message": "Usuarios y roles cargados exitosamente"})
+// fastChargeResponse responde con el resultado de la carga; una carga transaccional revertida es 422
+func fastChargeResponse(c *gin.Context, report *services.FastChargeReport, message string) {
+	switch {
+	case report.DryRun:
+		c.JSON(http.StatusOK, gin.H{"message": "Validación completada; no se guardaron cambios", "report": report})
+	case !report.Committed:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "La carga se revirtió por errores en algunas filas", "report": report})
+	case report.Failed > 0:
+		c.JSON(http.StatusOK, gin.H{"message": "Carga completada con errores en algunas filas", "report": report})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": message, "report": report})
+	}
 }
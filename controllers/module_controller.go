@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	helpers "seguridad-api/helpers"
 	"seguridad-api/services"
@@ -35,19 +36,26 @@ func CreateModule(c *gin.Context) {
 		return
 	}
 
-	err := services.CreateModule(input.Name, input.Description, input.Active)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	userIDUint := uint(userID.(float64))
+
+	err := services.CreateModule(c, input.Name, input.Description, input.Active)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create module"})
 		return
 	}
 
 	currentTime := helpers.AdjustToEcuadorTime(time.Now())
-	userID, exists := c.Get("userID")
-	if exists {
-		userIDUint := uint(userID.(float64))
-		event := "INSERT"
-		description := "Se creó un módulo con el nombre: " + input.Name
-		_ = services.RegisterAudit(event, description, userIDUint, "SEGURIDAD", currentTime)
+	event := "INSERT"
+	description := "Se creó un módulo con el nombre: " + input.Name
+	// El cambio ya se guardó; un fallo de la auditoría se registra en el log sin cambiar la respuesta
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, "SEGURIDAD", currentTime); auditErr != nil {
+		log.Printf("Módulo creado, pero no se pudo registrar la auditoría: %v", auditErr)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Module created successfully"})
@@ -170,19 +178,26 @@ func UpdateModule(c *gin.Context) {
 		return
 	}
 
-	err := services.UpdateModule(uint(id), input.Name, input.Description, input.Active)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	userIDUint := uint(userID.(float64))
+
+	err := services.UpdateModule(c, uint(id), input.Name, input.Description, input.Active)
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "UPDATE", "No se pudo actualizar el módulo con ID: "+strconv.Itoa(id), services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now()), err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	currentTime := helpers.AdjustToEcuadorTime(time.Now())
-	userID, exists := c.Get("userID")
-	if exists {
-		userIDUint := uint(userID.(float64))
-		event := "UPDATE"
-		description := "Se actualizó el módulo con ID: " + strconv.Itoa(id)
-		_ = services.RegisterAudit(event, description, userIDUint, "MÓDULOS", currentTime)
+	event := "UPDATE"
+	description := "Se actualizó el módulo con ID: " + strconv.Itoa(id)
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, "SEGURIDAD", currentTime); auditErr != nil {
+		log.Printf("Módulo actualizado, pero no se pudo registrar la auditoría: %v", auditErr)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Module updated successfully"})
@@ -202,19 +217,26 @@ func UpdateModule(c *gin.Context) {
 func DeleteModule(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
-	err := services.DeleteModule(uint(id))
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	userIDUint := uint(userID.(float64))
+
+	err := services.DeleteModule(c, uint(id))
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "DELETE", "No se pudo eliminar el módulo con ID: "+strconv.Itoa(id), services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now()), err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to delete module"})
 		return
 	}
 
 	currentTime := helpers.AdjustToEcuadorTime(time.Now())
-	userID, exists := c.Get("userID")
-	if exists {
-		userIDUint := uint(userID.(float64))
-		event := "DELETE"
-		description := "Se eliminó el módulo con ID: " + strconv.Itoa(id)
-		_ = services.RegisterAudit(event, description, userIDUint, "SEGURIDAD", currentTime)
+	event := "DELETE"
+	description := "Se eliminó el módulo con ID: " + strconv.Itoa(id)
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, "SEGURIDAD", currentTime); auditErr != nil {
+		log.Printf("Módulo eliminado, pero no se pudo registrar la auditoría: %v", auditErr)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Module deleted successfully"})
@@ -235,7 +257,7 @@ func DeleteModule(c *gin.Context) {
 // 		userIDUint := uint(userID.(float64))
 // 		event := "UPDATE"
 // 		description := "Se cambió el estado activo del módulo con ID: " + strconv.Itoa(id)
-// 		_ = services.RegisterAudit(event, description, userIDUint, "SEGURIDAD", currentTime)
+// 		_ = services.RegisterAudit(c, event, description, userIDUint, "SEGURIDAD", currentTime)
 // 	}
 
 // 	c.JSON(http.StatusOK, gin.H{"message": "Module state changed successfully"})
@@ -52,7 +52,7 @@ func CreatePermission(c *gin.Context) {
 		return
 	}
 
-	permission, err := services.CreatePermission(models.Permission{
+	permission, err := services.CreatePermission(c, models.Permission{
 		Name:        input.Name,
 		Description: input.Description,
 		ModuleID:    input.ModuleID,
@@ -80,7 +80,7 @@ func CreatePermission(c *gin.Context) {
 	currentTime := time.Now()
 	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Permiso creado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -177,13 +177,14 @@ func UpdatePermission(c *gin.Context) {
 		return
 	}
 
-	permission, err := services.UpdatePermission(id, models.Permission{
+	permission, err := services.UpdatePermission(c, id, models.Permission{
 		Name:        input.Name,
 		Description: input.Description,
 		ModuleID:    input.ModuleID,
 		Active:      input.Active,
 	})
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "UPDATE", "No se pudo actualizar el permiso con ID: "+id, services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now()), err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar el permiso"})
 		return
 	}
@@ -205,7 +206,7 @@ func UpdatePermission(c *gin.Context) {
 	currentTime := time.Now()
 	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Permiso actualizado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -215,7 +216,8 @@ func UpdatePermission(c *gin.Context) {
 
 func DeletePermission(c *gin.Context) {
 	id := c.Param("id")
-	if err := services.DeletePermission(id); err != nil {
+	if err := services.DeletePermission(c, id); err != nil {
+		_ = services.RegisterFailedAudit(c, "DELETE", "No se pudo eliminar el permiso con ID: "+id, services.ActorFromContext(c), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now()), err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al eliminar el permiso"})
 		return
 	}
@@ -237,7 +239,7 @@ func DeletePermission(c *gin.Context) {
 	currentTime := time.Now()
 	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Permiso eliminado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	auditService "seguridad-api/services"
+	services "seguridad-api/services/reports"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeactivateAccountsInput struct {
+	Filters map[string]interface{} `json:"filters"`
+	UserIDs []uint                 `json:"user_ids" example:"4,9"`
+}
+
+// DeactivateRiskyAccounts desactiva las cuentas que devuelve el reporte de cuentas de riesgo
+// @Summary Desactivar cuentas de riesgo
+// @Description Desactiva las cuentas activas que lista el reporte AccountRisk con los mismos filtros (risk, inactive_days, max_roles, module_id, active). Con user_ids solo se desactivan las cuentas indicadas que estén en el resultado. La cuenta de quien hace la solicitud nunca se desactiva y se admiten como máximo 1000 cuentas por solicitud.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body DeactivateAccountsInput true "Filtros del reporte y cuentas a desactivar"
+// @Success 200 {object} map[string]interface{} "deactivated, count"
+// @Failure 400 {object} map[string]string "Filtros inválidos o sin cuentas para desactivar"
+// @Failure 500 {object} map[string]string "error"
+// @Router /reports/account-risk/deactivate [post]
+func DeactivateRiskyAccounts(c *gin.Context) {
+	var input DeactivateAccountsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ids, err := services.DeactivateRiskyAccounts(c.Request.Context(), input.Filters, input.UserIDs, userID)
+	if err != nil {
+		var filterErr *services.FilterError
+		if errors.As(err, &filterErr) || errors.Is(err, services.ErrNoRiskyAccounts) || errors.Is(err, services.ErrTooManyRiskyAccounts) {
+			reportRequestError(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	description := fmt.Sprintf("Se desactivaron %d cuentas desde el reporte de cuentas de riesgo: %v", len(ids), ids)
+	if auditErr := auditService.RegisterAudit(c, "UPDATE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cuentas desactivadas, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deactivated": ids, "count": len(ids)})
+}
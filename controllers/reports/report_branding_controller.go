@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	auditService "seguridad-api/services"
+	services "seguridad-api/services/reports"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ErrInvalidBrandingID = "ID de presentación inválido"
+
+type ReportBrandingInput struct {
+	ModuleID     *uint  `json:"module_id" example:"1"`
+	Locale       string `json:"locale" example:"es"`
+	Timezone     string `json:"timezone" example:"America/Guayaquil"`
+	DateFormat   string `json:"date_format" example:"DD/MM/YYYY HH:mm"`
+	Orientation  string `json:"orientation" example:"portrait"`
+	PrimaryColor string `json:"primary_color" example:"#1F4E79"`
+	Font         string `json:"font" example:"Roboto-Regular.ttf"`
+}
+
+func (input ReportBrandingInput) data() services.ReportBrandingData {
+	return services.ReportBrandingData{
+		ModuleID:     input.ModuleID,
+		Locale:       input.Locale,
+		Timezone:     input.Timezone,
+		DateFormat:   input.DateFormat,
+		Orientation:  input.Orientation,
+		PrimaryColor: input.PrimaryColor,
+		Font:         input.Font,
+	}
+}
+
+// CreateReportBranding guarda la presentación de los reportes de un módulo o la general
+// @Summary Guardar presentación de reportes
+// @Description Define el idioma (es, en), la zona horaria, el formato de fecha (YYYY, MM, DD, HH, mm, ss), la orientación del PDF, el color principal (#RRGGBB) y la fuente (archivo .ttf del servicio) de los reportes. Sin module_id es la presentación general; la de un módulo se usa con branding_module_id al generar el reporte. Los campos vacíos se toman de la general o de la configuración del servicio.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body ReportBrandingInput true "Datos de la presentación"
+// @Success 200 {object} map[string]interface{} "branding"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 409 {object} map[string]string "Ya existe la presentación general o la del módulo"
+// @Router /report-brandings [post]
+func CreateReportBranding(c *gin.Context) {
+	var input ReportBrandingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	branding, err := services.CreateReportBranding(input.data())
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se guardó la presentación de reportes %d (%s)", branding.ID, brandingScope(branding.ModuleID))
+	if auditErr := auditService.RegisterAudit(c, "INSERT", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Presentación guardada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"branding": branding})
+}
+
+// GetReportBrandings lista las presentaciones de reportes
+// @Summary Listar presentaciones de reportes
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "brandings"
+// @Failure 500 {object} map[string]string "error"
+// @Router /report-brandings [get]
+func GetReportBrandings(c *gin.Context) {
+	brandings, err := services.GetReportBrandings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las presentaciones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"brandings": brandings})
+}
+
+// GetReportBranding obtiene una presentación de reportes
+// @Summary Obtener presentación de reportes
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la presentación"
+// @Success 200 {object} map[string]interface{} "branding"
+// @Failure 404 {object} map[string]string "Presentación no encontrada"
+// @Router /report-brandings/{id} [get]
+func GetReportBranding(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidBrandingID})
+		return
+	}
+
+	branding, err := services.GetReportBranding(uint(id))
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"branding": branding})
+}
+
+// UpdateReportBranding reemplaza los datos de una presentación de reportes
+// @Summary Actualizar presentación de reportes
+// @Description Reemplaza los datos de la presentación; el logo se conserva.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la presentación"
+// @Param input body ReportBrandingInput true "Datos de la presentación"
+// @Success 200 {object} map[string]interface{} "branding"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 404 {object} map[string]string "Presentación no encontrada"
+// @Router /report-brandings/{id} [put]
+func UpdateReportBranding(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidBrandingID})
+		return
+	}
+	var input ReportBrandingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	branding, err := services.UpdateReportBranding(uint(id), input.data())
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se actualizó la presentación de reportes %d (%s)", branding.ID, brandingScope(branding.ModuleID))
+	if auditErr := auditService.RegisterAudit(c, "UPDATE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Presentación actualizada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"branding": branding})
+}
+
+// UploadReportBrandingLogo reemplaza el logo de una presentación de reportes
+// @Summary Subir logo de reportes
+// @Description Recibe una imagen PNG o JPEG de hasta 1 MB que reemplaza al logo del encabezado de los reportes que usan la presentación.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID de la presentación"
+// @Param file formData file true "Logo"
+// @Success 200 {object} map[string]interface{} "branding"
+// @Failure 400 {object} map[string]string "Imagen inválida"
+// @Failure 404 {object} map[string]string "Presentación no encontrada"
+// @Router /report-brandings/{id}/logo [put]
+func UploadReportBrandingLogo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidBrandingID})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxReportBrandingLogo+(64<<10))
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Debe enviar el logo en el campo file (máximo 1 MB)"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, services.MaxReportBrandingLogo+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	branding, err := services.SetReportBrandingLogo(uint(id), content)
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se cambió el logo de la presentación de reportes %d (%s)", branding.ID, brandingScope(branding.ModuleID))
+	if auditErr := auditService.RegisterAudit(c, "UPDATE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logo guardado, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"branding": branding})
+}
+
+// DeleteReportBranding elimina una presentación de reportes y su logo
+// @Summary Eliminar presentación de reportes
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la presentación"
+// @Success 200 {object} map[string]interface{} "message"
+// @Failure 404 {object} map[string]string "Presentación no encontrada"
+// @Router /report-brandings/{id} [delete]
+func DeleteReportBranding(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidBrandingID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	branding, err := services.DeleteReportBranding(uint(id))
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se eliminó la presentación de reportes %d (%s)", branding.ID, brandingScope(branding.ModuleID))
+	if auditErr := auditService.RegisterAudit(c, "DELETE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Presentación eliminada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Presentación eliminada exitosamente"})
+}
+
+func brandingScope(moduleID *uint) string {
+	if moduleID == nil {
+		return "general"
+	}
+	return fmt.Sprintf("módulo %d", *moduleID)
+}
+
+func brandingError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrReportBrandingExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	reportRequestError(c, err)
+}
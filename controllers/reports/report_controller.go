@@ -1,12 +1,92 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"os"
 	services "seguridad-api/services/reports"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	maxVerifyFileSize     = 50 << 20
+	maxPageSize           = 100
+	ErrInvalidReportJobID = "ID de reporte inválido"
+)
+
+// ReportLayoutInput elige las columnas, el ordenamiento, la agrupación y el resumen del reporte. Con
+// preset_id se parte de un diseño guardado y los campos enviados reemplazan a los del diseño.
+type ReportLayoutInput struct {
+	PresetID uint                  `json:"preset_id" example:"0"`
+	Columns  []string              `json:"columns" example:"name,module"`
+	Sort     []services.ReportSort `json:"sort"`
+	GroupBy  string                `json:"group_by" example:"module"`
+	Summary  bool                  `json:"summary" example:"false"`
+}
+
+func (input ReportLayoutInput) layout() services.ReportLayout {
+	return services.ReportLayout{Columns: input.Columns, Sort: input.Sort, GroupBy: input.GroupBy, Summary: input.Summary}
+}
+
+// resolveLayout obtiene el diseño final del reporte; solo consulta el usuario si se usa un diseño guardado
+func (input ReportLayoutInput) resolveLayout(c *gin.Context, modelName, option string) (services.ReportLayout, string, bool) {
+	if input.PresetID == 0 {
+		return input.layout(), option, true
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return services.ReportLayout{}, option, false
+	}
+	layout, option, err := services.ResolveReportLayout(userID, input.PresetID, modelName, option, input.layout())
+	if err != nil {
+		reportRequestError(c, err)
+		return layout, option, false
+	}
+	return layout, option, true
+}
+
+// ReportOutputInput ajusta el separador del CSV y la presentación del reporte: idioma, zona horaria,
+// formato de fecha, orientación del PDF y la presentación guardada de un módulo (logo y colores)
+type ReportOutputInput struct {
+	Delimiter        string `json:"delimiter" example:","`
+	BOM              bool   `json:"bom" example:"false"`
+	Locale           string `json:"locale" example:"es"`
+	Timezone         string `json:"timezone" example:"America/Guayaquil"`
+	DateFormat       string `json:"date_format" example:"DD/MM/YYYY HH:mm"`
+	Orientation      string `json:"orientation" example:"landscape"`
+	BrandingModuleID uint   `json:"branding_module_id" example:"0"`
+}
+
+func (input ReportOutputInput) options() services.ReportOutputOptions {
+	return services.ReportOutputOptions{
+		Delimiter:        input.Delimiter,
+		BOM:              input.BOM,
+		Locale:           input.Locale,
+		Timezone:         input.Timezone,
+		DateFormat:       input.DateFormat,
+		Orientation:      input.Orientation,
+		BrandingModuleID: input.BrandingModuleID,
+	}
+}
+
+// GenerateReport genera el reporte y lo descarga en la misma petición
+// @Summary Generar y descargar un reporte
+// @Description Genera el reporte con los mismos datos que POST /reports y lo devuelve en la respuesta. csv, json y ndjson se envían a medida que se generan y la firma llega en los trailers X-Report-Sha256, X-Report-Signature y X-Report-Key-Id. Excel se genera completo en un archivo temporal antes de enviarlo, con la firma en los encabezados. PDF no se envía por partes: el documento se arma completo en memoria antes de enviarlo, por eso admite como máximo REPORT_PDF_MAX_ROWS registros; para más registros use csv, ndjson o Excel.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce octet-stream
+// @Param request body ReportJobInput true "Datos del reporte"
+// @Success 200 {file} file "Archivo del reporte"
+// @Failure 400 {object} map[string]string "Datos inválidos o demasiados registros para el formato"
+// @Failure 500 {object} map[string]string "Error al generar el reporte"
+// @Router /generate-report [post]
 func GenerateReport(c *gin.Context) {
 	var requestData struct {
 		Filters  map[string]interface{} `json:"filters"`
@@ -14,6 +94,8 @@ func GenerateReport(c *gin.Context) {
 		Username string                 `json:"username"`
 		Format   string                 `json:"format"`
 		Option   string                 `json:"option"`
+		ReportLayoutInput
+		ReportOutputInput
 	}
 
 	if err := c.ShouldBindJSON(&requestData); err != nil {
@@ -21,17 +103,381 @@ func GenerateReport(c *gin.Context) {
 		return
 	}
 
-	fileBuffer, fileName, err := services.GenerateReport(requestData.Model, requestData.Filters, requestData.Username, requestData.Format, requestData.Option)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	layout, option, ok := requestData.resolveLayout(c, requestData.Model, requestData.Option)
+	if !ok {
+		return
+	}
+
+	generation, err := services.NewReport(requestData.Model, requestData.Filters, userID, requestData.Username, requestData.Format, option, requestData.options(), layout)
+	if err != nil {
+		reportRequestError(c, err)
+		return
+	}
+
+	if services.IsStreamedReportFormat(requestData.Format) {
+		streamReport(c, generation)
+		return
+	}
+	sendSpooledReport(c, generation)
+}
+
+// sendSpooledReport genera el PDF o Excel en un archivo temporal y lo envía con el hash y la firma en
+// los encabezados. Si la generación falla todavía no se envió nada y se responde con el error.
+func sendSpooledReport(c *gin.Context, generation *services.ReportGeneration) {
+	file, err := os.CreateTemp("", "reporte-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el reporte"})
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	report, err := generation.Render(file)
+	if err != nil {
+		log.Printf("Error al generar el reporte %s: %v", generation.ReportID, err)
+		if errors.Is(err, services.ErrReportTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el reporte"})
+		return
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el reporte"})
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, size, generation.ContentType, file, map[string]string{
+		"Content-Disposition": `attachment; filename="` + report.FileName + `"`,
+		"X-Report-Id":         report.ReportID,
+		"X-Report-Sha256":     report.FileHash,
+		"X-Report-Signature":  report.Signature,
+		"X-Report-Key-Id":     report.KeyID,
+	})
+}
+
+// streamReport envía un reporte de texto (CSV, JSON o NDJSON) a medida que se genera. El hash y la firma
+// solo se conocen al terminar, por eso viajan como trailers HTTP; quien necesite los encabezados debe
+// usar la generación en segundo plano (/reports/:id/download). Si la generación falla a mitad de la
+// descarga se corta la conexión para que el cliente no reciba un archivo truncado como completo.
+func streamReport(c *gin.Context, generation *services.ReportGeneration) {
+	c.Header("Content-Type", generation.ContentType)
+	c.Header("Content-Disposition", `attachment; filename="`+generation.FileName+`"`)
+	c.Header("X-Report-Id", generation.ReportID)
+	c.Header("Trailer", "X-Report-Sha256, X-Report-Signature, X-Report-Key-Id")
+	c.Status(http.StatusOK)
+
+	report, err := generation.Render(c.Writer)
+	if err != nil {
+		log.Printf("Error al generar el reporte %s: %v", generation.ReportID, err)
+		if !c.Writer.Written() {
+			for _, header := range []string{"Trailer", "Content-Type", "Content-Disposition"} {
+				c.Writer.Header().Del(header)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el reporte"})
+			return
+		}
+		abortConnection(c)
+		return
+	}
+
+	c.Writer.Header().Set("X-Report-Sha256", report.FileHash)
+	c.Writer.Header().Set("X-Report-Signature", report.Signature)
+	c.Writer.Header().Set("X-Report-Key-Id", report.KeyID)
+}
+
+// abortConnection cierra la conexión sin terminar la respuesta; el cliente ve la descarga como fallida
+func abortConnection(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		log.Printf("No se pudo cortar la descarga del reporte: %v", err)
+		return
+	}
+	conn.Close()
+}
+
+// VerifyReport comprueba que un reporte fue generado por el servicio y no fue alterado
+// @Summary Verificar reporte
+// @Description Recibe el archivo PDF o Excel y lo compara con el registro de reportes firmados. Opcionalmente acepta la firma separada (encabezado X-Report-Signature de la descarga) y el ID del reporte para validar la firma con la clave pública del servicio.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Reporte a verificar"
+// @Param report_id formData string false "ID del reporte impreso en el documento"
+// @Param signature formData string false "Firma separada en base64"
+// @Success 200 {object} services.ReportVerification "El reporte es auténtico"
+// @Failure 400 {object} map[string]string "Archivo no enviado"
+// @Failure 422 {object} services.ReportVerification "El reporte no es auténtico o fue modificado"
+// @Failure 500 {object} map[string]string "Error al verificar el reporte"
+// @Router /reports/verify [post]
+func VerifyReport(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxVerifyFileSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Debe enviar el reporte en el campo file (máximo 50 MB)"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+
+	result, err := services.VerifyReport(file, c.PostForm("report_id"), c.PostForm("signature"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el reporte"})
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, result)
+}
+
+// GetReportPublicKey devuelve la clave pública con la que se firman los reportes
+// @Summary Clave pública de reportes
+// @Description Devuelve la clave pública Ed25519 (PEM) para verificar las firmas de los reportes fuera del servicio. La firma cubre el texto "<report_id>\n<sha256 del archivo>". Con key_id se obtiene una clave anterior para verificar los reportes firmados con ella.
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param key_id query string false "ID de la clave (por defecto la vigente)"
+// @Success 200 {object} map[string]string "public_key"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /reports/public-key [get]
+func GetReportPublicKey(c *gin.Context) {
+	if keyID := c.Query("key_id"); keyID != "" {
+		key, err := services.GetReportPublicKey(keyID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Clave de firma no encontrada"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"algorithm": "Ed25519", "key_id": key.KeyID, "public_key": key.PublicKey, "retired_at": key.RetiredAt})
+		return
+	}
+
+	signer, err := services.GetReportSigner()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "La clave de firma de reportes no está disponible"})
+		return
+	}
+	publicKey, err := signer.PublicKeyPEM()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "La clave de firma de reportes no está disponible"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"algorithm": "Ed25519", "key_id": signer.KeyID, "public_key": publicKey})
+}
+
+type ReportJobInput struct {
+	Filters  map[string]interface{} `json:"filters"`
+	Model    string                 `json:"model" binding:"required" example:"Audit"`
+	Username string                 `json:"username" example:"Joan Pastillo"` // Solo se muestra en el encabezado; el reporte queda a nombre del usuario autenticado
+	Format   string                 `json:"format" binding:"required" example:"pdf"`
+	Option   string                 `json:"option" example:""`
+	ReportLayoutInput
+	ReportOutputInput
+}
+
+// CreateReportJob encola la generación de un reporte en segundo plano
+// @Summary Solicitar reporte
+// @Description Registra la generación de un reporte (pdf, excel, csv, json o ndjson) y devuelve el ID del trabajo para consultar su avance y descargarlo cuando termine. Los filtros se validan contra el esquema de cada modelo: un valor simple equivale a eq y los demás operadores (in, contains, between, gte, lte) se envían como objeto, por ejemplo {"name": {"contains": "admin"}}; un filtro no permitido devuelve 400 con allowed_filters. Las columnas, el ordenamiento y la agrupación se eligen con columns, sort y group_by (ver /reports/columns) o con un diseño guardado en preset_id. Con summary, los reportes PDF y Excel de Audit, User y Role incluyen una página de resumen con gráficos. locale (es, en), timezone, date_format, orientation y branding_module_id ajustan el idioma, las fechas, la página y el logo y colores del reporte. PDF no se genera por partes: el documento se arma completo en memoria, por eso admite como máximo REPORT_PDF_MAX_ROWS registros. Excel se escribe por partes en disco y admite REPORT_EXCEL_MAX_ROWS. Para más registros use csv, json o ndjson. El modelo AccountRisk lista las cuentas sin uso y de riesgo (filtros risk, inactive_days, max_roles, module_id y active); sus cuentas se pueden desactivar con /reports/account-risk/deactivate.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body ReportJobInput true "Datos del reporte"
+// @Success 202 {object} map[string]interface{} "job"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 429 {object} map[string]string "Demasiados reportes en proceso"
+// @Router /reports [post]
+func CreateReportJob(c *gin.Context) {
+	var input ReportJobInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos"})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	layout, option, ok := input.resolveLayout(c, input.Model, input.Option)
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueReportJob(userID, input.Model, input.Filters, input.Username, input.Format, option, layout, input.options())
+	if errors.Is(err, services.ErrTooManyReportJobs) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		reportRequestError(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/reports/%d", job.ID))
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// GetReportJobs lista los reportes solicitados por el usuario
+// @Summary Listar reportes solicitados
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param status query string false "Estado (PENDING, RUNNING, COMPLETED, FAILED, EXPIRED)"
+// @Success 200 {object} map[string]interface{} "jobs"
+// @Failure 500 {object} map[string]string "error"
+// @Router /reports [get]
+func GetReportJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	contentType := "application/pdf"
-	if requestData.Format == "excel" {
-		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	jobs, total, err := services.GetPaginatedReportJobs(userID, page, pageSize, strings.ToUpper(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los reportes"})
+		return
 	}
 
-	c.DataFromReader(http.StatusOK, int64(fileBuffer.Len()), contentType, fileBuffer, map[string]string{
-		"Content-Disposition": `attachment; filename="` + fileName + `"`})
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":       jobs,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// GetReportJob devuelve el estado y el avance de un reporte solicitado
+// @Summary Estado del reporte
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del trabajo"
+// @Success 200 {object} map[string]interface{} "job"
+// @Failure 404 {object} map[string]string "Reporte no encontrado"
+// @Router /reports/{id} [get]
+func GetReportJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidReportJobID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	job, err := services.GetReportJob(uint(id), userID)
+	if errors.Is(err, services.ErrReportJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el reporte"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// DownloadReportJob descarga el archivo de un reporte terminado
+// @Summary Descargar reporte
+// @Description Descarga el archivo generado con los encabezados de firma (X-Report-Id, X-Report-Sha256, X-Report-Signature).
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce application/pdf
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce text/csv
+// @Produce application/json
+// @Produce application/x-ndjson
+// @Param id path int true "ID del trabajo"
+// @Success 200 {file} file "Reporte"
+// @Failure 404 {object} map[string]string "Reporte no encontrado"
+// @Failure 409 {object} map[string]string "El reporte aún no está listo"
+// @Failure 410 {object} map[string]string "El reporte expiró"
+// @Router /reports/{id}/download [get]
+func DownloadReportJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidReportJobID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	job, report, err := services.GetReportJobFile(uint(id), userID)
+	switch {
+	case errors.Is(err, services.ErrReportJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrReportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status, "progress": job.Progress})
+		return
+	case errors.Is(err, services.ErrReportExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el reporte"})
+		return
+	}
+
+	c.Header("X-Report-Id", report.ReportID)
+	c.Header("X-Report-Sha256", report.FileHash)
+	c.Header("X-Report-Signature", report.Signature)
+	c.Header("X-Report-Key-Id", report.KeyID)
+	c.Header("Content-Type", services.ReportContentType(job.Format))
+	c.FileAttachment(job.FilePath, job.FileName)
+}
+
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo obtener el ID del usuario"})
+		return 0, false
+	}
+	return uint(userID.(float64)), true
+}
+
+// reportRequestError responde 400; si el problema está en los filtros incluye los filtros permitidos del modelo
+func reportRequestError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrReportPresetNotFound) || errors.Is(err, services.ErrReportBrandingNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	var filterErr *services.FilterError
+	if errors.As(err, &filterErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": filterErr.Message, "allowed_filters": filterErr.Allowed})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 }
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	auditService "seguridad-api/services"
+	services "seguridad-api/services/reports"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ErrInvalidPresetID = "ID de diseño inválido"
+
+type ReportPresetInput struct {
+	Name    string                `json:"name" binding:"required" example:"Permisos por módulo"`
+	Model   string                `json:"model" binding:"required" example:"Permission"`
+	Option  string                `json:"option" example:""`
+	Columns []string              `json:"columns" example:"module,name,active"`
+	Sort    []services.ReportSort `json:"sort"`
+	GroupBy string                `json:"group_by" example:"module"`
+	Summary bool                  `json:"summary" example:"false"`
+}
+
+func (input ReportPresetInput) data() services.ReportPresetData {
+	return services.ReportPresetData{
+		Name:   input.Name,
+		Model:  input.Model,
+		Option: input.Option,
+		Layout: services.ReportLayout{Columns: input.Columns, Sort: input.Sort, GroupBy: input.GroupBy, Summary: input.Summary},
+	}
+}
+
+// GetReportColumns lista las columnas que se pueden elegir para un modelo de reporte
+// @Summary Columnas de reporte
+// @Description Devuelve las columnas disponibles del modelo con su encabezado, si se pueden usar para ordenar y agrupar, y si forman parte del reporte por defecto.
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param model query string true "Modelo (Permission, User, Role, Module, Audit)"
+// @Param option query string false "Opción del reporte (p. ej. usuariosCompletos)"
+// @Param locale query string false "Idioma de los encabezados (es, en)"
+// @Success 200 {object} map[string]interface{} "columns"
+// @Failure 400 {object} map[string]string "Modelo no soportado"
+// @Router /reports/columns [get]
+func GetReportColumns(c *gin.Context) {
+	columns, err := services.AvailableReportColumns(c.Query("model"), c.Query("option"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"columns": columns})
+}
+
+// CreateReportPreset guarda un diseño de reporte del usuario
+// @Summary Guardar diseño de reporte
+// @Description Guarda con un nombre las columnas, el ordenamiento, la agrupación y el resumen de un modelo para reutilizarlos con preset_id al generar, solicitar o programar reportes.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body ReportPresetInput true "Datos del diseño"
+// @Success 200 {object} map[string]interface{} "preset"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 409 {object} map[string]string "Ya existe un diseño con ese nombre"
+// @Router /report-presets [post]
+func CreateReportPreset(c *gin.Context) {
+	var input ReportPresetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	preset, err := services.CreateReportPreset(userID, input.data())
+	if err != nil {
+		presetError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se guardó el diseño de reporte %d \"%s\" (%s)", preset.ID, preset.Name, preset.Model)
+	if auditErr := auditService.RegisterAudit(c, "INSERT", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Diseño guardado, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preset": preset})
+}
+
+// GetReportPresets lista los diseños de reporte del usuario
+// @Summary Listar diseños de reporte
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param model query string false "Modelo del reporte"
+// @Success 200 {object} map[string]interface{} "presets"
+// @Failure 500 {object} map[string]string "error"
+// @Router /report-presets [get]
+func GetReportPresets(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	presets, err := services.GetReportPresets(userID, c.Query("model"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los diseños"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"presets": presets})
+}
+
+// GetReportPreset obtiene un diseño de reporte del usuario
+// @Summary Obtener diseño de reporte
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del diseño"
+// @Success 200 {object} map[string]interface{} "preset"
+// @Failure 404 {object} map[string]string "Diseño no encontrado"
+// @Router /report-presets/{id} [get]
+func GetReportPreset(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPresetID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	preset, err := services.GetReportPreset(uint(id), userID)
+	if err != nil {
+		presetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preset": preset})
+}
+
+// UpdateReportPreset reemplaza los datos de un diseño de reporte
+// @Summary Actualizar diseño de reporte
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "ID del diseño"
+// @Param input body ReportPresetInput true "Datos del diseño"
+// @Success 200 {object} map[string]interface{} "preset"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 404 {object} map[string]string "Diseño no encontrado"
+// @Router /report-presets/{id} [put]
+func UpdateReportPreset(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPresetID})
+		return
+	}
+	var input ReportPresetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	preset, err := services.UpdateReportPreset(uint(id), userID, input.data())
+	if err != nil {
+		presetError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se actualizó el diseño de reporte %d \"%s\"", preset.ID, preset.Name)
+	if auditErr := auditService.RegisterAudit(c, "UPDATE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Diseño actualizado, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preset": preset})
+}
+
+// DeleteReportPreset elimina un diseño de reporte
+// @Summary Eliminar diseño de reporte
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del diseño"
+// @Success 200 {object} map[string]interface{} "message"
+// @Failure 404 {object} map[string]string "Diseño no encontrado"
+// @Router /report-presets/{id} [delete]
+func DeleteReportPreset(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPresetID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	preset, err := services.DeleteReportPreset(uint(id), userID)
+	if err != nil {
+		presetError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se eliminó el diseño de reporte %d \"%s\"", preset.ID, preset.Name)
+	if auditErr := auditService.RegisterAudit(c, "DELETE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Diseño eliminado, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Diseño eliminado exitosamente"})
+}
+
+func presetError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrReportPresetExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	reportRequestError(c, err)
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	auditService "seguridad-api/services"
+	services "seguridad-api/services/reports"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ErrInvalidScheduleID = "ID de programación inválido"
+
+type ReportScheduleInput struct {
+	Name       string                 `json:"name" binding:"required" example:"Usuarios completos semanal"`
+	Cron       string                 `json:"cron" binding:"required" example:"0 8 * * MON"`
+	Model      string                 `json:"model" binding:"required" example:"User"`
+	Filters    map[string]interface{} `json:"filters"`
+	Format     string                 `json:"format" binding:"required" example:"excel"`
+	Option     string                 `json:"option" example:"usuariosCompletos"`
+	Username   string                 `json:"username" example:"Joan Pastillo"` // Solo se muestra en el encabezado; el reporte queda a nombre del usuario autenticado
+	Recipients []string               `json:"recipients" binding:"required" example:"gerencia@utn.edu.ec"`
+	Active     *bool                  `json:"active" example:"true"`
+	ReportLayoutInput
+	ReportOutputInput
+}
+
+func (input ReportScheduleInput) data(layout services.ReportLayout, option string) services.ReportScheduleData {
+	active := true
+	if input.Active != nil {
+		active = *input.Active
+	}
+	return services.ReportScheduleData{
+		Name:       input.Name,
+		Cron:       input.Cron,
+		Model:      input.Model,
+		Filters:    input.Filters,
+		Format:     input.Format,
+		Option:     option,
+		Layout:     layout,
+		Output:     input.options(),
+		Username:   input.Username,
+		Recipients: input.Recipients,
+		Active:     active,
+	}
+}
+
+// CreateReportSchedule programa un reporte recurrente enviado por correo
+// @Summary Programar reporte
+// @Description Crea una programación del usuario con una expresión cron de cinco campos (minuto hora día mes día_semana) o un descriptor (@daily, @weekly, @monthly), interpretada en hora de Ecuador. Modelo, filtros, formato, opción, columnas y opciones de salida y presentación son los mismos de /generate-report; un diseño guardado (preset_id) se copia en la programación.
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body ReportScheduleInput true "Datos de la programación"
+// @Success 200 {object} map[string]interface{} "schedule"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Router /report-schedules [post]
+func CreateReportSchedule(c *gin.Context) {
+	var input ReportScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	layout, option, ok := input.resolveLayout(c, input.Model, input.Option)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.CreateReportSchedule(input.data(layout, option), userID)
+	if err != nil {
+		reportRequestError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se programó el reporte %d \"%s\" (%s, %s)", schedule.ID, schedule.Name, schedule.Model, schedule.Cron)
+	if auditErr := auditService.RegisterAudit(c, "INSERT", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Programación creada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+// GetReportSchedules lista las programaciones de reportes creadas por el usuario
+// @Summary Listar reportes programados
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Success 200 {object} map[string]interface{} "schedules"
+// @Failure 500 {object} map[string]string "error"
+// @Router /report-schedules [get]
+func GetReportSchedules(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	schedules, total, err := services.GetPaginatedReportSchedules(userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las programaciones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules":  schedules,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// GetReportSchedule obtiene una programación de reporte
+// @Summary Obtener reporte programado
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la programación"
+// @Success 200 {object} map[string]interface{} "schedule"
+// @Failure 404 {object} map[string]string "Programación no encontrada"
+// @Router /report-schedules/{id} [get]
+func GetReportSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScheduleID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.GetReportSchedule(uint(id), userID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+// UpdateReportSchedule reemplaza los datos de una programación
+// @Summary Actualizar reporte programado
+// @Tags Reportes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la programación"
+// @Param input body ReportScheduleInput true "Datos de la programación"
+// @Success 200 {object} map[string]interface{} "schedule"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 404 {object} map[string]string "Programación no encontrada"
+// @Router /report-schedules/{id} [put]
+func UpdateReportSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScheduleID})
+		return
+	}
+	var input ReportScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	layout, option, ok := input.resolveLayout(c, input.Model, input.Option)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.UpdateReportSchedule(uint(id), userID, input.data(layout, option))
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se actualizó el reporte programado %d \"%s\" (%s, activo: %t)", schedule.ID, schedule.Name, schedule.Cron, schedule.Active)
+	if auditErr := auditService.RegisterAudit(c, "UPDATE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Programación actualizada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+// DeleteReportSchedule elimina una programación y su historial
+// @Summary Eliminar reporte programado
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la programación"
+// @Success 200 {object} map[string]interface{} "message"
+// @Failure 404 {object} map[string]string "Programación no encontrada"
+// @Router /report-schedules/{id} [delete]
+func DeleteReportSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScheduleID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.DeleteReportSchedule(uint(id), userID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("Se eliminó el reporte programado %d \"%s\"", schedule.ID, schedule.Name)
+	if auditErr := auditService.RegisterAudit(c, "DELETE", description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Programación eliminada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Programación eliminada exitosamente"})
+}
+
+// GetReportScheduleRuns lista las ejecuciones de una programación
+// @Summary Historial de un reporte programado
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la programación"
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Success 200 {object} map[string]interface{} "runs"
+// @Failure 404 {object} map[string]string "Programación no encontrada"
+// @Router /report-schedules/{id}/runs [get]
+func GetReportScheduleRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScheduleID})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := services.GetReportSchedule(uint(id), userID); err != nil {
+		scheduleError(c, err)
+		return
+	}
+	runs, total, err := services.GetPaginatedReportScheduleRuns(uint(id), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el historial"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":       runs,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// RunReportSchedule ejecuta una programación inmediatamente
+// @Summary Ejecutar reporte programado
+// @Description Genera y envía el reporte sin esperar a su horario; la siguiente ejecución programada no cambia. Solo quien creó la programación puede consultarla, modificarla, eliminarla o ejecutarla.
+// @Tags Reportes
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID de la programación"
+// @Success 202 {object} map[string]interface{} "run"
+// @Failure 404 {object} map[string]string "Programación no encontrada"
+// @Router /report-schedules/{id}/run [post]
+func RunReportSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScheduleID})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	run, err := services.RunReportScheduleNow(uint(id), userID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"run": run})
+}
+
+func scheduleError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrReportScheduleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	reportRequestError(c, err)
+}
@@ -57,7 +57,7 @@ func CreateRole(c *gin.Context) {
 		return
 	}
 
-	role, err := services.CreateRole(input.Name, input.Description, input.IDModule)
+	role, err := services.CreateRole(c, input.Name, input.Description, input.IDModule)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear el rol"})
 		return
@@ -78,7 +78,7 @@ func CreateRole(c *gin.Context) {
 	originService := "SEGURIDAD"
 	// date := time.Now()
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol creado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -218,8 +218,9 @@ func UpdateRole(c *gin.Context) {
 	}
 
 	// Llamar al servicio para actualizar el rol
-	role, err := services.UpdateRole(id, input.Name, input.Description, input.Active)
+	role, err := services.UpdateRole(c, id, input.Name, input.Description, input.Active)
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "UPDATE", "No se pudo actualizar el rol con ID: "+c.Param("id"), services.ActorFromContext(c), "SEGURIDAD", ecuadorTime, err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -239,7 +240,7 @@ func UpdateRole(c *gin.Context) {
 	originService := "SEGURIDAD"
 	// date := time.Now()
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol actualizado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -294,8 +295,9 @@ func UpdateRoleState(c *gin.Context) {
 	}
 
 	// Llamar al servicio para actualizar solo el estado del rol
-	err = services.UpdateRoleState(id, input.Active)
+	err = services.UpdateRoleState(c, id, input.Active)
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "UPDATE", "No se pudo actualizar el estado del rol con ID: "+c.Param("id"), services.ActorFromContext(c), "SEGURIDAD", ecuadorTime, err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -315,7 +317,7 @@ func UpdateRoleState(c *gin.Context) {
 	originService := "SEGURIDAD"
 	// date := time.Now()
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Estadi del rol actualizado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -55,7 +55,7 @@ func AssignPermission(c *gin.Context) {
 		return
 	}
 
-	rolePermission, err := services.AssignPermissionToRole(uint(roleID), input.PermissionID)
+	rolePermission, err := services.AssignPermissionToRole(c, uint(roleID), input.PermissionID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -76,7 +76,7 @@ func AssignPermission(c *gin.Context) {
 	description := "Se asignó el permiso con ID " + strconv.Itoa(int(input.PermissionID)) + " al rol con ID " + strconv.Itoa(int(roleID))
 	originService := "SEGURIDAD"
 
-	if err := services.RegisterAudit(event, description, uint(userID.(float64)), originService, ecuadorTime); err != nil {
+	if err := services.RegisterAudit(c, event, description, uint(userID.(float64)), originService, ecuadorTime); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Permiso asignado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -110,7 +110,7 @@ func RemovePermission(c *gin.Context) {
 		return
 	}
 
-	err = services.RemovePermissionFromRole(uint(roleID), input.PermissionID)
+	err = services.RemovePermissionFromRole(c, uint(roleID), input.PermissionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al eliminar el permiso"})
 		return
@@ -135,7 +135,7 @@ func RemovePermission(c *gin.Context) {
 		Date:          ecuadorTime,
 	}
 
-	if err := config.DB.Create(&audit).Error; err != nil {
+	if err := services.SaveAudit(c, &audit); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Permiso eliminado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SecurityAlertStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=OPEN ACKNOWLEDGED RESOLVED" example:"RESOLVED"`
+	Note   string `json:"note" example:"Inicio de sesión confirmado con el usuario"`
+}
+
+// GetSecurityAlerts obtiene las alertas del detector de anomalías con paginación y filtros
+// @Summary Obtener alertas de seguridad
+// @Description Devuelve las alertas levantadas por el detector: horarios inusuales, ráfagas de intentos fallidos, uso repentino de varios módulos, eliminaciones masivas y viajes imposibles.
+// @Tags Seguridad
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param rule query string false "Regla que levantó la alerta"
+// @Param severity query string false "Severidad (WARNING, CRITICAL)"
+// @Param status query string false "Estado (OPEN, ACKNOWLEDGED, RESOLVED)"
+// @Param user_id query int false "ID del usuario"
+// @Param ip query string false "IP del cliente"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Success 200 {object} map[string]interface{} "alerts"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /security-alerts [get]
+func GetSecurityAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	filters := make(map[string]interface{})
+	if rule := c.Query("rule"); rule != "" {
+		rule = strings.ToUpper(rule)
+		if _, ok := models.AlertRuleSeverity(rule); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Regla desconocida: " + rule})
+			return
+		}
+		filters["rule"] = rule
+	}
+	if severity := c.Query("severity"); severity != "" {
+		filters["severity"] = strings.ToUpper(severity)
+	}
+	if status := c.Query("status"); status != "" {
+		filters["status"] = strings.ToUpper(status)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		filters["ip"] = ip
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidUserID})
+			return
+		}
+		filters["user_id"] = uint(id)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := parseFilterTime(startDate, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de start_date inválido"})
+			return
+		}
+		filters["from"] = start
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := parseFilterTime(endDate, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de end_date inválido"})
+			return
+		}
+		filters["to"] = end
+	}
+
+	alerts, total, err := services.GetPaginatedSecurityAlerts(page, pageSize, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las alertas de seguridad"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":     alerts,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// GetSecurityAlertRules devuelve el catálogo de reglas del detector
+// @Summary Catálogo de reglas de alertas
+// @Description Lista las reglas del detector de anomalías con su severidad.
+// @Tags Seguridad
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "rules"
+// @Router /security-alerts/rules [get]
+func GetSecurityAlertRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": models.AlertRuleCatalog})
+}
+
+// UpdateSecurityAlertStatus marca una alerta como reconocida, resuelta o la reabre
+// @Summary Cambiar estado de una alerta
+// @Tags Seguridad
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la alerta"
+// @Param input body SecurityAlertStatusInput true "Nuevo estado"
+// @Success 200 {object} map[string]interface{} "alert"
+// @Failure 400 {object} map[string]string "Datos inválidos"
+// @Failure 404 {object} map[string]string "Alerta no encontrada"
+// @Router /security-alerts/{id}/status [put]
+func UpdateSecurityAlertStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de alerta inválido"})
+		return
+	}
+
+	var input SecurityAlertStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrUserIDContext})
+		return
+	}
+
+	alert, err := services.UpdateSecurityAlertStatus(uint(id), input.Status, input.Note, uint(userID.(float64)))
+	if errors.Is(err, services.ErrAlertNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la alerta"})
+		return
+	}
+
+	description := fmt.Sprintf("Se cambió el estado de la alerta %d (%s) a %s", alert.ID, alert.Rule, alert.Status)
+	if auditErr := services.RegisterAudit(c, "UPDATE", description, uint(userID.(float64)), "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alerta actualizada, pero no se pudo registrar la auditoría"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alert": alert})
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seguridad-api/services"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// maxConfigDocumentSize es el tamaño máximo del documento de configuración que se importa
+const maxConfigDocumentSize = 10 << 20
+
+// ExportSecurityConfig descarga la configuración de seguridad en un documento versionado
+// @Summary Exportar configuración de seguridad
+// @Description Genera un documento con los módulos (con su clave), sus permisos, los roles y los permisos de cada rol, identificados por su clave natural para importarlo en otro ambiente con /config/import. Responde 409 si la base tiene claves de módulo repetidas o referencias a registros inexistentes. Requiere un Bearer Token.
+// @Tags Configuración
+// @Security BearerAuth
+// @Produce application/yaml
+// @Produce json
+// @Param format query string false "yaml (por defecto) o json"
+// @Success 200 {object} services.SecurityConfig "Documento de configuración"
+// @Failure 400 {object} map[string]string "Formato no soportado"
+// @Failure 409 {object} map[string]interface{} "La configuración actual no se puede exportar"
+// @Failure 500 {object} map[string]string "Error al exportar la configuración"
+// @Router /config/export [get]
+func ExportSecurityConfig(c *gin.Context) {
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format debe ser yaml o json"})
+		return
+	}
+
+	document, err := services.ExportSecurityConfig()
+	if err != nil {
+		var configErr *services.ConfigError
+		if errors.As(err, &configErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": configErr.Message, "problems": configErr.Problems})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al exportar la configuración"})
+		return
+	}
+
+	fileName := "configuracion_seguridad_" + time.Now().Format("20060102_150405") + "." + format
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	if format == "json" {
+		c.IndentedJSON(http.StatusOK, document)
+		return
+	}
+	content, err := yaml.Marshal(document)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al exportar la configuración"})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", content)
+}
+
+// ImportSecurityConfig aplica un documento de configuración de seguridad
+// @Summary Importar configuración de seguridad
+// @Description Recibe el documento de /config/export (YAML o JSON) y calcula el plan de cambios (create, update, delete) de módulos, permisos, roles y permisos de cada rol frente a la base actual. La lista de permisos de cada rol del documento es completa: se agregan los que faltan y se quitan los demás. Con prune=true también se eliminan los módulos, permisos y roles que no están en el documento; un rol con usuarios asignados es un conflicto y el plan no se aplica (409). Con dry_run=true solo se devuelve el plan. El plan se aplica en una sola transacción y cada cambio queda en la auditoría. Requiere un Bearer Token.
+// @Tags Configuración
+// @Security BearerAuth
+// @Accept application/yaml
+// @Accept json
+// @Produce json
+// @Param input body services.SecurityConfig true "Documento de configuración"
+// @Param dry_run query bool false "Calcular el plan sin aplicarlo"
+// @Param prune query bool false "Eliminar lo que no está en el documento"
+// @Success 200 {object} services.ConfigPlan "Plan calculado o aplicado"
+// @Failure 400 {object} map[string]interface{} "Documento inválido"
+// @Failure 401 {object} map[string]string "Usuario no autenticado"
+// @Failure 409 {object} map[string]interface{} "El plan tiene conflictos"
+// @Failure 422 {object} map[string]string "No se pudo aplicar el plan"
+// @Failure 500 {object} map[string]string "Error interno del servidor"
+// @Router /config/import [post]
+func ImportSecurityConfig(c *gin.Context) {
+	var options services.ConfigImportOptions
+	for name, target := range map[string]*bool{"dry_run": &options.DryRun, "prune": &options.Prune} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " debe ser true o false"})
+				return
+			}
+			*target = value
+		}
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxConfigDocumentSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el documento (máximo 10 MB)"})
+		return
+	}
+	document, err := services.ParseSecurityConfig(content)
+	if err != nil {
+		var configErr *services.ConfigError
+		if errors.As(err, &configErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": configErr.Message, "problems": configErr.Problems})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	plan, err := services.ImportSecurityConfig(c, document, options, uint(userID.(float64)))
+	switch {
+	case errors.Is(err, services.ErrConfigConflicts):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "plan": plan})
+		return
+	case err != nil && plan != nil && plan.Applied:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "plan": plan})
+		return
+	case err != nil && plan != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al calcular el plan de configuración"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
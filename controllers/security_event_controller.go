@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"net/http"
+	"seguridad-api/models"
+	"seguridad-api/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSecurityEvents obtiene los eventos de seguridad con paginación y filtros
+// @Summary Obtener eventos de seguridad
+// @Description Devuelve los intentos de inicio de sesión, bloqueos, accesos denegados y restablecimientos de contraseña, incluyendo los intentos con emails no registrados.
+// @Tags Seguridad
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Número de página (por defecto: 1)"
+// @Param pageSize query int false "Registros por página (por defecto: 10, máximo: 100)"
+// @Param type query string false "Tipos de evento separados por coma"
+// @Param severity query string false "Severidad (INFO, WARNING, CRITICAL)"
+// @Param ip query string false "IP del cliente"
+// @Param email query string false "Email utilizado en el intento"
+// @Param user_id query int false "ID del usuario"
+// @Param window query string false "Ventana de tiempo hacia atrás (ej. 15m, 24h)"
+// @Param start_date query string false "Fecha inicial (YYYY-MM-DD o RFC3339)"
+// @Param end_date query string false "Fecha final (YYYY-MM-DD o RFC3339)"
+// @Success 200 {object} map[string]interface{} "events"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /security-events [get]
+func GetSecurityEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = 10
+	}
+
+	filters := make(map[string]interface{})
+	if types := c.Query("type"); types != "" {
+		var selected []string
+		for _, eventType := range strings.Split(types, ",") {
+			eventType = strings.ToUpper(strings.TrimSpace(eventType))
+			if _, ok := models.SecurityEventSeverity(eventType); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de evento desconocido: " + eventType})
+				return
+			}
+			selected = append(selected, eventType)
+		}
+		filters["types"] = selected
+	}
+	if severity := c.Query("severity"); severity != "" {
+		filters["severity"] = strings.ToUpper(severity)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		filters["ip"] = ip
+	}
+	if email := c.Query("email"); email != "" {
+		filters["email"] = email
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidUserID})
+			return
+		}
+		filters["user_id"] = uint(id)
+	}
+
+	if window := c.Query("window"); window != "" {
+		duration, err := time.ParseDuration(window)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La ventana de tiempo debe tener el formato 15m, 2h, etc."})
+			return
+		}
+		filters["from"] = time.Now().UTC().Add(-duration)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := parseFilterTime(startDate, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de start_date inválido"})
+			return
+		}
+		filters["from"] = start
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := parseFilterTime(endDate, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de end_date inválido"})
+			return
+		}
+		filters["to"] = end
+	}
+
+	events, total, err := services.GetPaginatedSecurityEvents(page, pageSize, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los eventos de seguridad"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// GetSecurityEventTypes devuelve el catálogo de eventos de seguridad
+// @Summary Catálogo de eventos de seguridad
+// @Description Lista los tipos de eventos de seguridad que registra el servicio con su severidad.
+// @Tags Seguridad
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "types"
+// @Router /security-events/types [get]
+func GetSecurityEventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"types": models.SecurityEventCatalog})
+}
+
+// parseFilterTime acepta fechas YYYY-MM-DD (en hora de Ecuador) o RFC3339; endOfDay ajusta al final del día
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	loc, err := time.LoadLocation("America/Guayaquil")
+	if err != nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour).Add(-time.Nanosecond)
+	}
+	return t.UTC(), nil
+}
@@ -63,7 +63,7 @@ func CreateUser(c *gin.Context) {
 	}
 
 	// Crear el usuario
-	user, err := services.CreateUser(input.Name, input.Email, input.Password, input.Active)
+	user, err := services.CreateUser(c, input.Name, input.Email, input.Password, input.Active)
 	if err != nil || user.ID == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear el usuario"})
 		return
@@ -87,7 +87,7 @@ func CreateUser(c *gin.Context) {
 	event := "INSERT"
 	description := "Se creó un usuario con el email: " + input.Email
 	originService := "SEGURIDAD"
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Usuario creado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -107,7 +107,7 @@ func CreateUser(c *gin.Context) {
 	}
 
 	// Asignar el rol al usuario
-	userRole, err := services.AssignRoleToUser(user.ID, uint(rolID))
+	userRole, err := services.AssignRoleToUser(c, user.ID, uint(rolID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al asignar el rol: " + err.Error()})
 		return
@@ -118,7 +118,7 @@ func CreateUser(c *gin.Context) {
 		" al usuario " + strconv.Itoa(int(userIDUint))
 	eventInterm := "CREATE"
 	originServiceInterm := "SEGURIDAD"
-	if auditErr := services.RegisterAudit(eventInterm, descriptionInterm, userIDUint, originServiceInterm, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, eventInterm, descriptionInterm, userIDUint, originServiceInterm, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol asignado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -207,6 +207,33 @@ func GetUserPermissions(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
 }
 
+// GetUserLogins obtiene el último acceso de un usuario a cada módulo
+// @Summary Obtener últimos accesos de un usuario
+// @Description Devuelve la fecha y la IP del último inicio de sesión exitoso del usuario en cada módulo. Requiere un Bearer Token.
+// @Tags Usuarios
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {array} models.UserLoginResponse "logins"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /users/{id}/logins [get]
+func GetUserLogins(c *gin.Context) {
+	userIDInt, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario no válido"})
+		return
+	}
+
+	logins, err := services.GetUserLogins(uint(userIDInt))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los accesos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logins": logins})
+}
+
 // UpdateUser actualiza un usuario existente
 // @Summary Actualizar usuario
 // @Description Actualiza los datos de un usuario existente. Requiere un Bearer Token.
@@ -237,8 +264,9 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := services.UpdateUser(id, userData.Name, userData.Email, userData.Active)
+	updatedUser, err := services.UpdateUser(c, id, userData.Name, userData.Email, userData.Active)
 	if err != nil {
+		_ = services.RegisterFailedAudit(c, "UPDATE", "No se pudo actualizar el usuario con ID: "+id, services.ActorFromContext(c), "SEGURIDAD", ecuadorTime, err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -256,7 +284,7 @@ func UpdateUser(c *gin.Context) {
 	originService := "SEGURIDAD"
 	// date := time.Now()
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Usuario actualizado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -288,7 +316,8 @@ func DeleteUser(c *gin.Context) {
 
 	id := c.Param("id")
 
-	if err := services.DeleteUser(id); err != nil {
+	if err := services.DeleteUser(c, id); err != nil {
+		_ = services.RegisterFailedAudit(c, "DELETE", "No se pudo cambiar el estado del usuario con ID: "+id, services.ActorFromContext(c), "SEGURIDAD", ecuadorTime, err.Error())
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -298,12 +327,13 @@ func DeleteUser(c *gin.Context) {
 	originService := "SEGURIDAD"
 	// date := time.Now()
 
-	authUserID, ok := authenticatedUserID.(uint)
+	authUserIDFloat, ok := authenticatedUserID.(float64)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el ID del usuario autenticado"})
 		return
 	}
-	if auditErr := services.RegisterAudit(event, description, authUserID, originService, ecuadorTime); auditErr != nil {
+	authUserID := uint(authUserIDFloat)
+	if auditErr := services.RegisterAudit(c, event, description, authUserID, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Usuario eliminado, pero no se pudo registrar la auditoría"})
 		return
 	}
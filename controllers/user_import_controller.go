@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"seguridad-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserImportFileSize es el tamaño máximo del archivo de importación de usuarios
+const maxUserImportFileSize = 10 << 20
+
+// GetUserImportTemplate descarga la plantilla de Excel para importar usuarios
+// @Summary Plantilla de importación de usuarios
+// @Description Descarga un Excel con las columnas Nombre, Correo Electrónico, Contraseña, Activo e ID Rol, y una hoja Roles con los roles activos para consultar sus IDs. Requiere un Bearer Token.
+// @Tags Usuarios
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Success 200 {file} file "Plantilla"
+// @Failure 500 {object} map[string]string "Error al generar la plantilla"
+// @Router /users/import/template [get]
+func GetUserImportTemplate(c *gin.Context) {
+	template, err := services.UserImportTemplate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar la plantilla"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="plantilla_usuarios.xlsx"`)
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", template)
+}
+
+// ImportUsers crea usuarios a partir de un archivo Excel o CSV
+// @Summary Importar usuarios desde archivo
+// @Description Recibe un archivo .xlsx o .csv (separado por comas o punto y coma) con un usuario por fila y crea cada usuario con su rol. mapping es un JSON opcional que indica el encabezado del archivo para cada campo, por ejemplo {"name": "Nombre completo", "email": "Correo"}; sin mapeo se usan los encabezados de la plantilla o los nombres de los campos (name, email, password, active, role_id). Cada fila se valida por separado (formato del correo, correos repetidos en el archivo o ya registrados y existencia del rol) y las filas con errores no impiden crear las demás. Devuelve el archivo, con la contraseña enmascarada y las columnas Estado y Detalle en cada fila, y el resumen en los encabezados X-Import-Total, X-Import-Created y X-Import-Failed; con result=json devuelve el resumen y el estado de cada fila en JSON. Requiere un Bearer Token.
+// @Tags Usuarios
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce text/csv
+// @Produce json
+// @Param file formData file true "Archivo .xlsx o .csv (máximo 10 MB)"
+// @Param mapping formData string false "Mapeo de campos a encabezados en JSON"
+// @Param result formData string false "Formato de la respuesta: file (por defecto) o json"
+// @Success 200 {file} file "Archivo con el estado de cada fila"
+// @Failure 400 {object} map[string]string "Archivo o mapeo inválido"
+// @Failure 401 {object} map[string]string "Usuario no autenticado"
+// @Failure 500 {object} map[string]string "Error interno del servidor"
+// @Router /users/import [post]
+func ImportUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUserImportFileSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Debe enviar el archivo en el campo file (máximo 10 MB)"})
+		return
+	}
+
+	var mapping map[string]string
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El mapeo debe ser un objeto JSON de campo a encabezado"})
+			return
+		}
+	}
+	resultFormat := c.DefaultPostForm("result", "file")
+	if resultFormat != "file" && resultFormat != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "result debe ser file o json"})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+
+	result, err := services.ImportUsers(c, file, fileHeader.Filename, mapping, uint(userID.(float64)))
+	if err != nil {
+		var importErr *services.UserImportError
+		if errors.As(err, &importErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": importErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al importar los usuarios"})
+		return
+	}
+
+	if resultFormat == "json" {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	c.Header("X-Import-Total", strconv.Itoa(result.Total))
+	c.Header("X-Import-Created", strconv.Itoa(result.Created))
+	c.Header("X-Import-Failed", strconv.Itoa(result.Failed))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": result.FileName}))
+	c.Data(http.StatusOK, result.ContentType, result.File)
+}
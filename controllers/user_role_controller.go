@@ -85,7 +85,7 @@ func AssignRoleToUser(c *gin.Context) {
 	// 	return
 	// }
 
-	userRole, err := services.AssignRoleToUser(uint(userID), payload.RoleID)
+	userRole, err := services.AssignRoleToUser(c, uint(userID), payload.RoleID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al asignar el rol: " + err.Error()})
 		return
@@ -106,7 +106,7 @@ func AssignRoleToUser(c *gin.Context) {
 	description := "Se asignó el rol " + strconv.Itoa(int(payload.RoleID)) + " al usuario " + strconv.Itoa(userID)
 	originService := "SEGURIDAD"
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol asignado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -152,7 +152,7 @@ func RemoveRoleFromUser(c *gin.Context) {
 		return
 	}
 
-	err = services.RemoveRoleFromUser(uint(userID), uint(roleID))
+	err = services.RemoveRoleFromUser(c, uint(userID), uint(roleID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al eliminar el rol: " + err.Error()})
 		return
@@ -173,7 +173,7 @@ func RemoveRoleFromUser(c *gin.Context) {
 	description := "Se eliminó el rol " + strconv.Itoa(roleID) + " del usuario " + strconv.Itoa(userID)
 	originService := "SEGURIDAD"
 
-	if auditErr := services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
+	if auditErr := services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime); auditErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rol eliminado, pero no se pudo registrar la auditoría"})
 		return
 	}
@@ -216,7 +216,7 @@ func GetUserRoles(c *gin.Context) {
 	// 	description := "Se consultaron los roles del usuario " + strconv.Itoa(userID)
 	// 	originService := "SEGURIDAD"
 
-	// 	_ = services.RegisterAudit(event, description, userIDUint, originService, ecuadorTime)
+	// 	_ = services.RegisterAudit(c, event, description, userIDUint, originService, ecuadorTime)
 	// }
 
 	c.JSON(http.StatusOK, roles)
@@ -19,11 +19,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"seguridad-api/config"
+	"seguridad-api/middleware"
 	"seguridad-api/routes"
+	"seguridad-api/services"
+	reports "seguridad-api/services/reports"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/cors"
@@ -34,12 +41,32 @@ import (
 func main() {
 	config.ConnectDB()
 
+	if err := services.RegisterChangeCapture(config.DB); err != nil {
+		log.Fatalf("Error al registrar la captura de cambios: %v", err)
+	}
+	if err := services.RegisterAuditStream(config.DB); err != nil {
+		log.Fatalf("Error al registrar el stream de auditorías: %v", err)
+	}
+
+	// Sin la clave de firma no se pueden emitir ni verificar reportes
+	if _, err := reports.GetReportSigner(); err != nil {
+		log.Fatalf("Error al cargar la clave de firma de reportes: %v", err)
+	}
+
+	services.StartAuditRetentionJob()
+	services.StartAuditWriter()
+	services.StartSiemForwarder()
+	services.StartAnomalyDetector()
+	reports.StartReportJobs()
+	reports.StartReportScheduler()
+
 	router := gin.Default()
 
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposedHeaders:   []string{middleware.RequestIDHeader, "Content-Disposition", "X-Report-Id", "X-Report-Sha256", "X-Report-Signature", "X-Report-Key-Id"},
 		AllowCredentials: true,
 	})
 
@@ -49,6 +76,8 @@ func main() {
 		}))
 	})
 
+	router.Use(middleware.RequestContext())
+
 	router.Static("/docs", "/app/docs")
 
 	routes.SetupRoutes(router)
@@ -63,9 +92,26 @@ func main() {
 	port := getPort()
 	log.Println("Servidor corriendo en el puerto " + port)
 
-	if err := router.Run("0.0.0.0:" + port); err != nil {
-		log.Fatalf("Error al iniciar el servidor: %v", err)
+	server := &http.Server{Addr: "0.0.0.0:" + port, Handler: router}
+	server.RegisterOnShutdown(services.CloseAuditStreams)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error al iniciar el servidor: %v", err)
+		}
+	}()
+
+	// Al detener el servidor se guardan las auditorías que siguen en la cola
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Deteniendo el servidor...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error al detener el servidor: %v", err)
 	}
+	services.StopAuditWriter()
 }
 
 func getPort() string {
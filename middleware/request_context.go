@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"seguridad-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestContext asigna un ID de correlación a cada petición y guarda sus metadatos para la auditoría
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		userAgent := c.Request.UserAgent()
+		if len(userAgent) > 255 {
+			userAgent = userAgent[:255]
+		}
+
+		c.Set(models.RequestMetadataKey, models.RequestMetadata{
+			IP:        c.ClientIP(),
+			UserAgent: userAgent,
+			RequestID: requestID,
+			Method:    c.Request.Method,
+			Route:     route,
+		})
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
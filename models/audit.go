@@ -4,6 +4,11 @@ import (
 	"time"
 )
 
+const (
+	AuditOutcomeSuccess = "SUCCESS"
+	AuditOutcomeFailure = "FAILURE"
+)
+
 type Audit struct {
 	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
 	Event         string    `gorm:"type:varchar(50);not null" json:"event"`
@@ -11,15 +16,44 @@ type Audit struct {
 	UserID        uint      `gorm:"foreignKey:UserID;references:ID" json:"user_id"`
 	OriginService string    `gorm:"type:varchar(255)" json:"origin_service"`
 	Date          time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"date"`
-	User          User      `gorm:"foreignKey:UserID;references:ID" json:"user"`
+	IP            string    `gorm:"type:varchar(45);index" json:"ip"`
+	UserAgent     string    `gorm:"type:varchar(255)" json:"user_agent"`
+	RequestID     string    `gorm:"type:varchar(64);index" json:"request_id"`
+	Method        string    `gorm:"type:varchar(10)" json:"method"`
+	Route         string    `gorm:"type:varchar(255)" json:"route"`
+	Outcome       string    `gorm:"type:varchar(20);default:SUCCESS;index" json:"outcome"`
+	FailureReason string    `gorm:"type:text" json:"failure_reason"`
+	// Clave enviada por el módulo de origen para que los reintentos no dupliquen el registro
+	IdempotencyKey *string `gorm:"type:varchar(100);uniqueIndex" json:"idempotency_key,omitempty"`
+	User           User    `gorm:"foreignKey:UserID;references:ID" json:"user"`
 }
+
+// AuditFulltextIndex es el índice FULLTEXT sobre la descripción; solo se crea en MySQL
+const AuditFulltextIndex = "idx_audit_description_fulltext"
+
 type AuditResponse struct {
 	ID            uint      `json:"id"`
 	Event         string    `json:"event"`
 	Description   string    `json:"description"`
+	UserID        uint      `json:"user_id"`
 	User          string    `json:"user"`
 	OriginService string    `json:"origin_service"`
 	Date          time.Time `json:"date"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	RequestID     string    `json:"request_id"`
+	Method        string    `json:"method"`
+	Route         string    `json:"route"`
+	Outcome       string    `json:"outcome"`
+	FailureReason string    `json:"failure_reason"`
+}
+
+// AuditSearchResult es una auditoría encontrada por la búsqueda de texto, con su relevancia
+// y un fragmento de la descripción con las coincidencias resaltadas
+type AuditSearchResult struct {
+	AuditResponse
+	Score     float64 `json:"score"`
+	Highlight string  `json:"highlight"`
 }
 
 // type AuditStatisticsResponse struct {
@@ -47,6 +81,7 @@ type AuditStatisticsResponse struct {
 	Event             string    `json:"event"`
 	OriginService     string    `json:"origin_service"` // 🔥 Ahora se incluye en la respuesta
 	Total             int       `json:"total"`
+	Failures          int       `json:"failures"`
 	LastDate          time.Time `json:"last_date"`
 	LastDateFormatted string    `json:"last_date_formatted"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditSeriesPoint es el total de auditorías de un grupo dentro de un intervalo de tiempo
+type AuditSeriesPoint struct {
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+	Total    int64  `json:"total"`
+	Failures int64  `json:"failures"`
+}
+
+// AuditGroupComparison compara el total de un grupo con el del periodo anterior de igual duración
+type AuditGroupComparison struct {
+	Key           string   `json:"key"`
+	Total         int64    `json:"total"`
+	Failures      int64    `json:"failures"`
+	PreviousTotal int64    `json:"previous_total"`
+	ChangePercent *float64 `json:"change_percent"`
+}
+
+// AuditTopEntry es un elemento del ranking de usuarios o servicios con más auditorías
+type AuditTopEntry struct {
+	Key   string `json:"key"`
+	Total int64  `json:"total"`
+}
+
+type AuditPeriodTotals struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Total    int64     `json:"total"`
+	Failures int64     `json:"failures"`
+}
+
+type AuditAnalyticsResponse struct {
+	Interval      string                 `json:"interval"`
+	GroupBy       string                 `json:"group_by"`
+	Timezone      string                 `json:"timezone"`
+	Current       AuditPeriodTotals      `json:"current"`
+	Previous      AuditPeriodTotals      `json:"previous"`
+	ChangePercent *float64               `json:"change_percent"`
+	Series        []AuditSeriesPoint     `json:"series"`
+	Groups        []AuditGroupComparison `json:"groups"`
+	TopUsers      []AuditTopEntry        `json:"top_users"`
+	TopServices   []AuditTopEntry        `json:"top_services"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditChange guarda el detalle campo a campo de un cambio sobre una entidad auditada
+type AuditChange struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_audit_changes_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"not null;index:idx_audit_changes_entity" json:"entity_id"`
+	Action     string    `gorm:"type:varchar(20);not null" json:"action"`
+	Changes    string    `gorm:"type:text" json:"changes"`
+	UserID     uint      `gorm:"index" json:"user_id"`
+	Date       time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"date"`
+}
+
+type AuditChangeResponse struct {
+	ID         uint            `json:"id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   uint            `json:"entity_id"`
+	Action     string          `json:"action"`
+	Changes    json.RawMessage `gorm:"-" json:"changes"`
+	RawChanges string          `gorm:"column:changes" json:"-"`
+	UserID     uint            `json:"user_id"`
+	User       string          `json:"user"`
+	Date       time.Time       `json:"date"`
+}
+
+// FieldChange representa el valor anterior y el nuevo de un campo
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+func (AuditChange) TableName() string {
+	return "audit_changes"
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditRetentionPolicy define cuántos días se conservan las auditorías de un servicio y/o evento.
+// Los campos vacíos aplican a cualquier valor; la política más específica tiene prioridad.
+type AuditRetentionPolicy struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OriginService string    `gorm:"type:varchar(255);uniqueIndex:idx_retention_scope" json:"origin_service"`
+	Event         string    `gorm:"type:varchar(50);uniqueIndex:idx_retention_scope" json:"event"`
+	RetentionDays int       `gorm:"not null" json:"retention_days"`
+	Active        bool      `gorm:"default:true" json:"active"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// AuditArchive describe un archivo comprimido con auditorías que salieron de la tabla audit
+type AuditArchive struct {
+	ID       uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileName string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"file_name"`
+	Checksum string    `gorm:"type:varchar(64);not null" json:"checksum"`
+	Records  int       `gorm:"not null" json:"records"`
+	Size     int64     `json:"size"`
+	PolicyID uint      `gorm:"index" json:"policy_id"`
+	FirstID  uint      `json:"first_id"`
+	LastID   uint      `json:"last_id"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+	// RestoredUntil indica hasta cuándo las auditorías del archivo están rehidratadas en restored_audits
+	RestoredUntil *time.Time `json:"restored_until,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Specificity indica qué tan específica es la política: servicio y evento > servicio > evento > global
+func (p AuditRetentionPolicy) Specificity() int {
+	specificity := 0
+	if p.OriginService != "" {
+		specificity += 2
+	}
+	if p.Event != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// ArchivedAudit es la forma en que cada auditoría se guarda en una línea del archivo JSONL
+type ArchivedAudit struct {
+	ID            uint      `json:"id"`
+	Event         string    `json:"event"`
+	Description   string    `json:"description"`
+	UserID        uint      `json:"user_id"`
+	OriginService string    `json:"origin_service"`
+	Date          time.Time `json:"date"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	RequestID     string    `json:"request_id"`
+	Method        string    `json:"method"`
+	Route         string    `json:"route"`
+	Outcome       string    `json:"outcome"`
+	FailureReason string    `json:"failure_reason"`
+	// Se conserva para que un reintento tardío del módulo de origen siga siendo identificable
+	IdempotencyKey *string `json:"idempotency_key,omitempty"`
+}
+
+// RestoredAudit es una auditoría de un archivo copiada temporalmente a la base de datos para consultarla
+// con SQL. Las filas se eliminan cuando vence ExpiresAt.
+type RestoredAudit struct {
+	RowID          uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	ArchiveID      uint      `gorm:"not null;index:idx_restored_archive" json:"archive_id"`
+	ID             uint      `gorm:"index:idx_restored_archive" json:"id"`
+	Event          string    `gorm:"type:varchar(50)" json:"event"`
+	Description    string    `gorm:"type:text" json:"description"`
+	UserID         uint      `json:"user_id"`
+	OriginService  string    `gorm:"type:varchar(255)" json:"origin_service"`
+	Date           time.Time `json:"date"`
+	IP             string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent      string    `gorm:"type:varchar(255)" json:"user_agent"`
+	RequestID      string    `gorm:"type:varchar(64)" json:"request_id"`
+	Method         string    `gorm:"type:varchar(10)" json:"method"`
+	Route          string    `gorm:"type:varchar(255)" json:"route"`
+	Outcome        string    `gorm:"type:varchar(20)" json:"outcome"`
+	FailureReason  string    `gorm:"type:text" json:"failure_reason"`
+	IdempotencyKey *string   `gorm:"type:varchar(100)" json:"idempotency_key,omitempty"`
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`
+}
+
+func (AuditRetentionPolicy) TableName() string {
+	return "audit_retention_policies"
+}
+
+func (AuditArchive) TableName() string {
+	return "audit_archives"
+}
+
+func (RestoredAudit) TableName() string {
+	return "restored_audits"
+}
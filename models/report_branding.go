@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportBranding es la presentación de los reportes de un módulo: idioma, zona horaria, formato de fecha,
+// orientación, color, fuente y logo. La fila sin módulo es la presentación general del servicio; los campos
+// vacíos se toman de la general y, si tampoco los tiene, de la configuración por defecto.
+type ReportBranding struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ModuleID     *uint     `gorm:"uniqueIndex" json:"module_id"`
+	Locale       string    `gorm:"type:varchar(5)" json:"locale"`
+	Timezone     string    `gorm:"type:varchar(64)" json:"timezone"`
+	DateFormat   string    `gorm:"type:varchar(40)" json:"date_format"`
+	Orientation  string    `gorm:"type:varchar(10)" json:"orientation"`
+	PrimaryColor string    `gorm:"type:varchar(7)" json:"primary_color"`
+	Font         string    `gorm:"type:varchar(100)" json:"font"`
+	LogoPath     string    `gorm:"type:varchar(500)" json:"-"`
+	HasLogo      bool      `gorm:"-" json:"has_logo"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ReportBranding) TableName() string {
+	return "report_brandings"
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReportJobPending   = "PENDING"
+	ReportJobRunning   = "RUNNING"
+	ReportJobCompleted = "COMPLETED"
+	ReportJobFailed    = "FAILED"
+	ReportJobExpired   = "EXPIRED"
+)
+
+// ReportJob es una solicitud de reporte que se genera en segundo plano y se guarda en disco
+type ReportJob struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Model      string     `gorm:"type:varchar(50);not null" json:"model"`
+	Format     string     `gorm:"type:varchar(10);not null" json:"format"`
+	Option     string     `gorm:"type:varchar(50)" json:"option"`
+	Filters    string     `gorm:"type:text" json:"filters"`
+	Layout     string     `gorm:"type:text" json:"layout"`
+	Output     string     `gorm:"type:text" json:"output"`
+	Username   string     `gorm:"type:varchar(150)" json:"username"`
+	Status     string     `gorm:"type:varchar(20);not null;default:PENDING;index" json:"status"`
+	Progress   int        `gorm:"not null;default:0" json:"progress"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	FileName   string     `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	FilePath   string     `gorm:"type:varchar(500)" json:"-"`
+	Size       int64      `json:"size"`
+	ReportID   string     `gorm:"type:varchar(32)" json:"report_id,omitempty"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ReportJob) TableName() string {
+	return "report_jobs"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportPreset es un diseño de reporte guardado por un usuario: columnas, ordenamiento, agrupación y resumen
+// de un modelo. Layout tiene el mismo formato que acepta GenerateReport.
+type ReportPreset struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_report_presets_user_name" json:"user_id"`
+	Name      string    `gorm:"type:varchar(150);not null;uniqueIndex:idx_report_presets_user_name" json:"name"`
+	Model     string    `gorm:"type:varchar(50);not null" json:"model"`
+	Option    string    `gorm:"type:varchar(50)" json:"option"`
+	Layout    string    `gorm:"type:text;not null" json:"layout"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ReportPreset) TableName() string {
+	return "report_presets"
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReportRunSuccess = "SUCCESS"
+	ReportRunFailed  = "FAILED"
+	ReportRunRunning = "RUNNING"
+)
+
+// ReportSchedule genera un reporte periódicamente según una expresión cron (hora de Ecuador) y lo
+// envía por correo. Model, Filters, Format, Option, Layout y Output (opciones de salida
+// y presentación) tienen el mismo formato que acepta GenerateReport.
+type ReportSchedule struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name       string     `gorm:"type:varchar(150);not null" json:"name"`
+	Cron       string     `gorm:"type:varchar(100);not null" json:"cron"`
+	Model      string     `gorm:"type:varchar(50);not null" json:"model"`
+	Filters    string     `gorm:"type:text" json:"filters"`
+	Layout     string     `gorm:"type:text" json:"layout"`
+	Output     string     `gorm:"type:text" json:"output"`
+	Format     string     `gorm:"type:varchar(10);not null" json:"format"`
+	Option     string     `gorm:"type:varchar(50)" json:"option"`
+	Username   string     `gorm:"type:varchar(150)" json:"username"`
+	Recipients string     `gorm:"type:text;not null" json:"recipients"`
+	Active     bool       `gorm:"default:true" json:"active"`
+	NextRunAt  *time.Time `gorm:"index" json:"next_run_at"`
+	LastRunAt  *time.Time `json:"last_run_at"`
+	LastStatus string     `gorm:"type:varchar(20)" json:"last_status"`
+	CreatedBy  uint       `gorm:"index" json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ReportSchedule) TableName() string {
+	return "report_schedules"
+}
+
+// ReportScheduleRun registra cada ejecución de una programación y su resultado
+type ReportScheduleRun struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ScheduleID   uint       `gorm:"not null;index" json:"schedule_id"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	Status       string     `gorm:"type:varchar(20);not null" json:"status"`
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	ReportID     string     `gorm:"type:varchar(32)" json:"report_id,omitempty"`
+	FileName     string     `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	Records      int        `json:"records"`
+	Recipients   string     `gorm:"type:text" json:"recipients"`
+	Manual       bool       `json:"manual"`
+}
+
+func (ReportScheduleRun) TableName() string {
+	return "report_schedule_runs"
+}
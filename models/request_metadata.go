@@ -0,0 +1,13 @@
+package models
+
+// Clave con la que RequestContext guarda los metadatos en el contexto de gin
+const RequestMetadataKey = "requestMetadata"
+
+// RequestMetadata agrupa los datos de la petición HTTP que se adjuntan a la auditoría
+type RequestMetadata struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	RequestID string `json:"request_id"`
+	Method    string `json:"method"`
+	Route     string `json:"route"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// Reglas del detector de anomalías
+const (
+	AlertRuleUnusualLoginHour = "UNUSUAL_LOGIN_HOUR"
+	AlertRuleFailedLoginBurst = "FAILED_LOGIN_BURST"
+	AlertRuleManyModules      = "MANY_MODULES"
+	AlertRuleMassDeletion     = "MASS_DELETION"
+	AlertRuleImpossibleTravel = "IMPOSSIBLE_TRAVEL"
+)
+
+const (
+	AlertStatusOpen         = "OPEN"
+	AlertStatusAcknowledged = "ACKNOWLEDGED"
+	AlertStatusResolved     = "RESOLVED"
+)
+
+type AlertRule struct {
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+var AlertRuleCatalog = []AlertRule{
+	{AlertRuleUnusualLoginHour, SecuritySeverityWarning, "Inicio de sesión en un horario que el usuario no suele usar"},
+	{AlertRuleFailedLoginBurst, SecuritySeverityCritical, "Ráfaga de inicios de sesión fallidos desde una IP o contra un email"},
+	{AlertRuleManyModules, SecuritySeverityWarning, "El usuario operó en varios módulos que no usaba antes"},
+	{AlertRuleMassDeletion, SecuritySeverityCritical, "Eliminación masiva de registros por un usuario"},
+	{AlertRuleImpossibleTravel, SecuritySeverityCritical, "Inicio de sesión desde una ubicación inalcanzable desde la sesión anterior"},
+}
+
+// AlertRuleSeverity devuelve la severidad de la regla y si existe en el catálogo
+func AlertRuleSeverity(rule string) (string, bool) {
+	for _, entry := range AlertRuleCatalog {
+		if entry.Rule == rule {
+			return entry.Severity, true
+		}
+	}
+	return "", false
+}
+
+// SecurityAlert es una alerta levantada por el detector de anomalías. Las repeticiones de la misma
+// alerta mientras sigue abierta se acumulan en Occurrences en lugar de crear registros nuevos.
+type SecurityAlert struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Rule        string     `gorm:"type:varchar(50);not null;index" json:"rule"`
+	Severity    string     `gorm:"type:varchar(20);not null;index" json:"severity"`
+	Status      string     `gorm:"type:varchar(20);not null;default:OPEN;index" json:"status"`
+	DedupKey    string     `gorm:"type:varchar(191);not null;index" json:"-"`
+	UserID      *uint      `gorm:"index" json:"user_id"`
+	Email       string     `gorm:"type:varchar(150)" json:"email"`
+	IP          string     `gorm:"type:varchar(45)" json:"ip"`
+	Detail      string     `gorm:"type:text" json:"detail"`
+	Evidence    string     `gorm:"type:text" json:"evidence"`
+	Occurrences int        `gorm:"not null;default:1" json:"occurrences"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `gorm:"index" json:"last_seen_at"`
+	HandledBy   *uint      `json:"handled_by"`
+	HandledAt   *time.Time `json:"handled_at"`
+	Note        string     `gorm:"type:text" json:"note"`
+}
+
+func (SecurityAlert) TableName() string {
+	return "security_alerts"
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// Catálogo de eventos de seguridad
+const (
+	SecurityEventLoginSuccess       = "LOGIN_SUCCESS"
+	SecurityEventLoginFailed        = "LOGIN_FAILED"
+	SecurityEventLoginUnknownUser   = "LOGIN_UNKNOWN_USER"
+	SecurityEventLoginLocked        = "LOGIN_LOCKED_ACCOUNT"
+	SecurityEventLoginInactive      = "LOGIN_INACTIVE_ACCOUNT"
+	SecurityEventAccountLocked      = "ACCOUNT_LOCKED"
+	SecurityEventModuleAccessDenied = "MODULE_ACCESS_DENIED"
+	SecurityEventResetRequested     = "PASSWORD_RESET_REQUESTED"
+	SecurityEventResetUnknownUser   = "PASSWORD_RESET_UNKNOWN_USER"
+	SecurityEventResetCompleted     = "PASSWORD_RESET_COMPLETED"
+	SecurityEventResetInvalidToken  = "PASSWORD_RESET_INVALID_TOKEN"
+	SecurityEventResetExpiredToken  = "PASSWORD_RESET_EXPIRED_TOKEN"
+	SecurityEventLogout             = "LOGOUT"
+)
+
+const (
+	SecuritySeverityInfo     = "INFO"
+	SecuritySeverityWarning  = "WARNING"
+	SecuritySeverityCritical = "CRITICAL"
+)
+
+type SecurityEventType struct {
+	Type        string `json:"type"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+var SecurityEventCatalog = []SecurityEventType{
+	{SecurityEventLoginSuccess, SecuritySeverityInfo, "Inicio de sesión exitoso"},
+	{SecurityEventLoginFailed, SecuritySeverityWarning, "Contraseña incorrecta"},
+	{SecurityEventLoginUnknownUser, SecuritySeverityWarning, "Intento de inicio de sesión con un email no registrado"},
+	{SecurityEventLoginLocked, SecuritySeverityWarning, "Intento de inicio de sesión sobre una cuenta bloqueada"},
+	{SecurityEventLoginInactive, SecuritySeverityWarning, "Intento de inicio de sesión sobre una cuenta inactiva"},
+	{SecurityEventAccountLocked, SecuritySeverityCritical, "Cuenta bloqueada por exceso de intentos fallidos"},
+	{SecurityEventModuleAccessDenied, SecuritySeverityWarning, "Acceso denegado al módulo solicitado"},
+	{SecurityEventResetRequested, SecuritySeverityInfo, "Solicitud de restablecimiento de contraseña"},
+	{SecurityEventResetUnknownUser, SecuritySeverityWarning, "Solicitud de restablecimiento para un email no registrado"},
+	{SecurityEventResetCompleted, SecuritySeverityInfo, "Contraseña restablecida"},
+	{SecurityEventResetInvalidToken, SecuritySeverityWarning, "Restablecimiento con un token inválido"},
+	{SecurityEventResetExpiredToken, SecuritySeverityWarning, "Restablecimiento con un token expirado"},
+	{SecurityEventLogout, SecuritySeverityInfo, "Cierre de sesión"},
+}
+
+// SecurityEvent registra intentos de acceso y cambios de credenciales, aun cuando no exista el usuario
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type      string    `gorm:"type:varchar(50);not null;index" json:"type"`
+	Severity  string    `gorm:"type:varchar(20);not null" json:"severity"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Email     string    `gorm:"type:varchar(150);index" json:"email"`
+	ModuleKey string    `gorm:"type:varchar(15)" json:"module_key"`
+	IP        string    `gorm:"type:varchar(45);index" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	RequestID string    `gorm:"type:varchar(64)" json:"request_id"`
+	Detail    string    `gorm:"type:text" json:"detail"`
+	Date      time.Time `gorm:"index" json:"date"`
+}
+
+// SecurityEventSeverity devuelve la severidad definida en el catálogo para un tipo de evento
+func SecurityEventSeverity(eventType string) (string, bool) {
+	for _, item := range SecurityEventCatalog {
+		if item.Type == eventType {
+			return item.Severity, true
+		}
+	}
+	return "", false
+}
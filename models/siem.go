@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// SiemCursor guarda el último ID de auditoría enviado a un destino externo para retomar tras un reinicio
+type SiemCursor struct {
+	Name      string    `gorm:"type:varchar(50);primaryKey" json:"name"`
+	LastID    uint      `gorm:"not null;default:0" json:"last_id"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (SiemCursor) TableName() string {
+	return "siem_cursors"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// SignedReport registra cada reporte generado con el hash del archivo y la firma del servicio,
+// para poder comprobar después que un archivo no fue alterado. Los formatos de texto (CSV, JSON) no
+// llevan el ID impreso, así que dos reportes con los mismos datos pueden compartir el hash del archivo.
+type SignedReport struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReportID    string    `gorm:"type:varchar(32);not null;uniqueIndex" json:"report_id"`
+	FileName    string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	Model       string    `gorm:"type:varchar(50)" json:"model"`
+	Format      string    `gorm:"type:varchar(10)" json:"format"`
+	Records     int       `json:"records"`
+	FileHash    string    `gorm:"type:varchar(64);not null;index" json:"file_hash"`
+	ContentHash string    `gorm:"type:varchar(64);not null" json:"content_hash"`
+	Signature   string    `gorm:"type:varchar(128);not null" json:"signature"`
+	KeyID       string    `gorm:"type:varchar(16);not null" json:"key_id"`
+	GeneratedBy string    `gorm:"type:varchar(150)" json:"generated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReportSigningKey guarda la clave pública de cada clave con la que el servicio firmó reportes, para
+// seguir verificando los reportes emitidos antes de cambiar la clave
+type ReportSigningKey struct {
+	KeyID     string     `gorm:"type:varchar(16);primaryKey" json:"key_id"`
+	PublicKey string     `gorm:"type:text;not null" json:"public_key"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+func (SignedReport) TableName() string {
+	return "signed_reports"
+}
+
+func (ReportSigningKey) TableName() string {
+	return "report_signing_keys"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// UserLogin guarda el último inicio de sesión exitoso de un usuario en cada módulo. No tiene relación con
+// Module para no impedir que se eliminen módulos.
+type UserLogin struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_user_login_module" json:"user_id"`
+	ModuleID    uint      `gorm:"not null;uniqueIndex:idx_user_login_module" json:"module_id"`
+	LastLoginAt time.Time `gorm:"not null;index" json:"last_login_at"`
+	IP          string    `gorm:"type:varchar(45)" json:"ip"`
+}
+
+// UserLoginResponse es el último acceso de un usuario a un módulo con el nombre del módulo
+type UserLoginResponse struct {
+	ModuleID    uint      `json:"module_id"`
+	ModuleName  string    `json:"module_name"`
+	ModuleKey   string    `json:"module_key"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	IP          string    `json:"ip"`
+}
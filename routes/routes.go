@@ -20,12 +20,15 @@ func SetupRoutes(router *gin.Engine) {
 		api.Use(middleware.AuthMiddleware(os.Getenv("JWT_SECRET_KEY")))
 		{
 			api.GET("/users/:id/permissions", controllers.GetUserPermissions)
+			api.GET("/users/:id/logins", controllers.GetUserLogins)
 
 			api.POST("/users", controllers.CreateUser)
 			api.GET("/users", controllers.GetUsers)
 			api.GET("/users-dropdown", controllers.GetUsersforDropdown)
 			api.PUT("/users/:id", controllers.UpdateUser)
 			api.POST("/users/fastCharge", controllers.ChargeFastUsers)
+			api.POST("/users/import", controllers.ImportUsers)
+			api.GET("/users/import/template", controllers.GetUserImportTemplate)
 			api.DELETE("/users/:id", controllers.DeleteUser)
 
 			api.GET("/roles", controllers.GetRoles)
@@ -46,8 +49,31 @@ func SetupRoutes(router *gin.Engine) {
 			//api.GET("/modules", controllers.GetModules)
 
 			api.POST("/audit", controllers.RegisterAudit)
+			api.POST("/audit/batch", controllers.RegisterAuditBatch)
 			api.GET("/audit", controllers.GetAudit)
 			api.GET("/audit/statistics", controllers.GetAuditoriaEstadisticas)
+			api.GET("/audit/analytics", controllers.GetAuditAnalytics)
+			api.GET("/audit/search", controllers.SearchAudit)
+			api.GET("/audit/stream", controllers.StreamAudit)
+			api.GET("/audit/export", controllers.ExportAudit)
+			api.GET("/audit/siem/status", controllers.GetSiemStatus)
+			api.GET("/audit/changes", controllers.GetAuditChanges)
+
+			const RetentionPolicyRoute = "/audit/retention-policies/:id"
+			api.POST("/audit/retention-policies", controllers.CreateRetentionPolicy)
+			api.GET("/audit/retention-policies", controllers.GetRetentionPolicies)
+			api.PUT(RetentionPolicyRoute, controllers.UpdateRetentionPolicy)
+			api.DELETE(RetentionPolicyRoute, controllers.DeleteRetentionPolicy)
+			api.POST("/audit/retention/run", controllers.RunAuditRetention)
+			api.GET("/audit/archives", controllers.GetAuditArchives)
+			api.GET("/audit/archives/:id/records", controllers.GetAuditArchiveRecords)
+			api.POST("/audit/archives/:id/restore", controllers.RestoreAuditArchive)
+
+			api.GET("/security-events", controllers.GetSecurityEvents)
+			api.GET("/security-events/types", controllers.GetSecurityEventTypes)
+			api.GET("/security-alerts", controllers.GetSecurityAlerts)
+			api.GET("/security-alerts/rules", controllers.GetSecurityAlertRules)
+			api.PUT("/security-alerts/:id/status", controllers.UpdateSecurityAlertStatus)
 
 			const RolePermissionsRoute = "/roles/:role_id/permissions"
 			api.POST(RolePermissionsRoute, controllers.AssignPermission)
@@ -72,6 +98,41 @@ func SetupRoutes(router *gin.Engine) {
 			// api.PATCH("/modules/:id/toggle-active", controllers.ToggleModuleActive) // Esta ruta cambia estado activo/inactivo
 
 			api.POST("/generate-report", controllerReport.GenerateReport)
+			api.POST("/reports", controllerReport.CreateReportJob)
+			api.GET("/reports", controllerReport.GetReportJobs)
+			api.GET("/reports/:id", controllerReport.GetReportJob)
+			api.GET("/reports/:id/download", controllerReport.DownloadReportJob)
+			api.POST("/reports/verify", controllerReport.VerifyReport)
+			api.GET("/reports/public-key", controllerReport.GetReportPublicKey)
+			api.GET("/reports/columns", controllerReport.GetReportColumns)
+			api.POST("/reports/account-risk/deactivate", controllerReport.DeactivateRiskyAccounts)
+
+			api.GET("/config/export", controllers.ExportSecurityConfig)
+			api.POST("/config/import", controllers.ImportSecurityConfig)
+
+			const ReportScheduleRoute = "/report-schedules/:id"
+			api.POST("/report-schedules", controllerReport.CreateReportSchedule)
+			api.GET("/report-schedules", controllerReport.GetReportSchedules)
+			api.GET(ReportScheduleRoute, controllerReport.GetReportSchedule)
+			api.PUT(ReportScheduleRoute, controllerReport.UpdateReportSchedule)
+			api.DELETE(ReportScheduleRoute, controllerReport.DeleteReportSchedule)
+			api.GET("/report-schedules/:id/runs", controllerReport.GetReportScheduleRuns)
+			api.POST("/report-schedules/:id/run", controllerReport.RunReportSchedule)
+
+			const ReportPresetRoute = "/report-presets/:id"
+			api.POST("/report-presets", controllerReport.CreateReportPreset)
+			api.GET("/report-presets", controllerReport.GetReportPresets)
+			api.GET(ReportPresetRoute, controllerReport.GetReportPreset)
+			api.PUT(ReportPresetRoute, controllerReport.UpdateReportPreset)
+			api.DELETE(ReportPresetRoute, controllerReport.DeleteReportPreset)
+
+			const ReportBrandingRoute = "/report-brandings/:id"
+			api.POST("/report-brandings", controllerReport.CreateReportBranding)
+			api.GET("/report-brandings", controllerReport.GetReportBrandings)
+			api.GET(ReportBrandingRoute, controllerReport.GetReportBranding)
+			api.PUT(ReportBrandingRoute, controllerReport.UpdateReportBranding)
+			api.DELETE(ReportBrandingRoute, controllerReport.DeleteReportBranding)
+			api.PUT("/report-brandings/:id/logo", controllerReport.UploadReportBrandingLogo)
 
 		}
 	}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"net"
+	"os"
+	"seguridad-api/config"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	anomalyQueueSize       = 5000
+	anomalyLoginHistory    = 200
+	anomalyFailedWindow    = 5 * time.Minute
+	anomalyModulesWindow   = time.Hour
+	anomalyModulesBaseline = 30 * 24 * time.Hour
+	anomalyDeleteWindow    = 10 * time.Minute
+	anomalyTravelWindow    = 30 * time.Minute
+	anomalyAlertCooldown   = 30 * time.Minute
+
+	// Velocidad máxima razonable entre dos inicios de sesión (avión comercial)
+	anomalyMaxTravelSpeedKmh = 900
+)
+
+// IPLocator ubica una IP en coordenadas geográficas. Sin un localizador configurado el detector
+// de viaje imposible compara las redes de las IP.
+type IPLocator interface {
+	Locate(ip string) (latitude, longitude float64, ok bool)
+}
+
+// anomalyThresholds son los umbrales de las reglas, configurables con las variables ANOMALY_*
+type anomalyThresholds struct {
+	failedPerIP     int
+	failedPerEmail  int
+	newModules      int
+	deletions       int
+	minLoginHistory int
+}
+
+type anomalySignal struct {
+	audit *models.Audit
+	event *models.SecurityEvent
+}
+
+type anomalyDetector struct {
+	queue      chan anomalySignal
+	thresholds anomalyThresholds
+	alertTo    []string
+	minNotify  string
+}
+
+var (
+	detector      *anomalyDetector
+	detectorMutex sync.Mutex
+	ipLocator     IPLocator
+)
+
+// SetIPLocator reemplaza el localizador de IP usado por la regla de viaje imposible
+func SetIPLocator(locator IPLocator) {
+	detectorMutex.Lock()
+	defer detectorMutex.Unlock()
+	ipLocator = locator
+}
+
+// StartAnomalyDetector inicia el análisis en segundo plano de auditorías y eventos de seguridad.
+// Se desactiva con ANOMALY_DETECTION=off. Las alertas de severidad ALERT_EMAIL_MIN_SEVERITY
+// o superior (CRITICAL por defecto) se notifican a los correos de ALERT_EMAILS.
+func StartAnomalyDetector() {
+	if strings.EqualFold(os.Getenv("ANOMALY_DETECTION"), "off") {
+		log.Println("Detección de anomalías deshabilitada")
+		return
+	}
+
+	detectorMutex.Lock()
+	defer detectorMutex.Unlock()
+	if detector != nil {
+		return
+	}
+
+	d := &anomalyDetector{
+		queue: make(chan anomalySignal, anomalyQueueSize),
+		thresholds: anomalyThresholds{
+			failedPerIP:     envInt("ANOMALY_FAILED_LOGINS_PER_IP", 10),
+			failedPerEmail:  envInt("ANOMALY_FAILED_LOGINS_PER_EMAIL", 5),
+			newModules:      envInt("ANOMALY_NEW_MODULES", 3),
+			deletions:       envInt("ANOMALY_DELETIONS", 20),
+			minLoginHistory: envInt("ANOMALY_MIN_LOGIN_HISTORY", 10),
+		},
+		minNotify: strings.ToUpper(os.Getenv("ALERT_EMAIL_MIN_SEVERITY")),
+	}
+	if d.minNotify == "" {
+		d.minNotify = models.SecuritySeverityCritical
+	}
+	for _, email := range strings.Split(os.Getenv("ALERT_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			d.alertTo = append(d.alertTo, email)
+		}
+	}
+
+	go d.run()
+	detector = d
+}
+
+// observeAudit encola una auditoría confirmada para evaluarla sin bloquear a quien la registra
+func observeAudit(audit models.Audit) {
+	observe(anomalySignal{audit: &audit})
+}
+
+// observeSecurityEvent encola un evento de seguridad para evaluarlo
+func observeSecurityEvent(event models.SecurityEvent) {
+	observe(anomalySignal{event: &event})
+}
+
+func observe(signal anomalySignal) {
+	detectorMutex.Lock()
+	d := detector
+	detectorMutex.Unlock()
+	if d == nil {
+		return
+	}
+
+	select {
+	case d.queue <- signal:
+	default:
+		log.Println("Cola del detector de anomalías llena, se descarta la señal")
+	}
+}
+
+func (d *anomalyDetector) run() {
+	for signal := range d.queue {
+		var err error
+		if signal.audit != nil {
+			err = d.evaluateAudit(*signal.audit)
+		} else {
+			err = d.evaluateSecurityEvent(*signal.event)
+		}
+		if err != nil {
+			log.Printf("Error en el detector de anomalías: %v", err)
+		}
+	}
+}
+
+func (d *anomalyDetector) evaluateSecurityEvent(event models.SecurityEvent) error {
+	switch event.Type {
+	case models.SecurityEventLoginSuccess:
+		if event.UserID == nil {
+			return nil
+		}
+		if err := d.checkUnusualLoginHour(event); err != nil {
+			return err
+		}
+		return d.checkImpossibleTravel(event)
+	case models.SecurityEventLoginFailed, models.SecurityEventLoginUnknownUser, models.SecurityEventLoginLocked:
+		return d.checkFailedLoginBurst(event)
+	}
+	return nil
+}
+
+func (d *anomalyDetector) evaluateAudit(audit models.Audit) error {
+	if audit.UserID == 0 {
+		return nil
+	}
+	if err := d.checkManyModules(audit); err != nil {
+		return err
+	}
+	if strings.HasPrefix(strings.ToUpper(audit.Event), "DELETE") {
+		return d.checkMassDeletion(audit)
+	}
+	return nil
+}
+
+// checkUnusualLoginHour compara la hora del inicio de sesión (en hora de Ecuador) con el historial del usuario
+func (d *anomalyDetector) checkUnusualLoginHour(event models.SecurityEvent) error {
+	var previous []time.Time
+	err := config.DB.Model(&models.SecurityEvent{}).
+		Where("type = ? AND user_id = ? AND id < ?", models.SecurityEventLoginSuccess, *event.UserID, event.ID).
+		Order("id DESC").Limit(anomalyLoginHistory).Pluck("date", &previous).Error
+	if err != nil {
+		return err
+	}
+	if len(previous) < d.thresholds.minLoginHistory {
+		return nil
+	}
+
+	hour := helpers.AdjustToEcuadorTime(event.Date).Hour()
+	hours := make(map[int]int)
+	for _, date := range previous {
+		hours[helpers.AdjustToEcuadorTime(date).Hour()]++
+	}
+	// Se toleran los inicios de sesión a una hora de distancia de los habituales
+	for _, offset := range []int{-1, 0, 1} {
+		if hours[(hour+offset+24)%24] > 0 {
+			return nil
+		}
+	}
+
+	return d.raise(models.SecurityAlert{
+		Rule:   models.AlertRuleUnusualLoginHour,
+		UserID: event.UserID,
+		Email:  event.Email,
+		IP:     event.IP,
+		Detail: fmt.Sprintf("Inicio de sesión a las %02d:00 sin antecedentes en los últimos %d accesos", hour, len(previous)),
+	}, fmt.Sprintf("user:%d", *event.UserID), map[string]interface{}{
+		"event_id": event.ID, "hour": hour, "history": len(previous), "usual_hours": hours,
+	}, event.Date)
+}
+
+// checkFailedLoginBurst cuenta los intentos fallidos recientes desde la misma IP y contra el mismo email
+func (d *anomalyDetector) checkFailedLoginBurst(event models.SecurityEvent) error {
+	failedTypes := []string{models.SecurityEventLoginFailed, models.SecurityEventLoginUnknownUser, models.SecurityEventLoginLocked}
+	since := event.Date.Add(-anomalyFailedWindow)
+
+	if event.IP != "" {
+		var count int64
+		err := config.DB.Model(&models.SecurityEvent{}).
+			Where("type IN ? AND ip = ? AND date >= ? AND date <= ?", failedTypes, event.IP, since, event.Date).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= int64(d.thresholds.failedPerIP) {
+			if err := d.raise(models.SecurityAlert{
+				Rule:   models.AlertRuleFailedLoginBurst,
+				IP:     event.IP,
+				Detail: fmt.Sprintf("%d intentos fallidos desde %s en %s", count, event.IP, anomalyFailedWindow),
+			}, "ip:"+event.IP, map[string]interface{}{"event_id": event.ID, "attempts": count}, event.Date); err != nil {
+				return err
+			}
+		}
+	}
+
+	if event.Email != "" {
+		var count int64
+		err := config.DB.Model(&models.SecurityEvent{}).
+			Where("type IN ? AND email = ? AND date >= ? AND date <= ?", failedTypes, event.Email, since, event.Date).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= int64(d.thresholds.failedPerEmail) {
+			return d.raise(models.SecurityAlert{
+				Rule:   models.AlertRuleFailedLoginBurst,
+				UserID: event.UserID,
+				Email:  event.Email,
+				IP:     event.IP,
+				Detail: fmt.Sprintf("%d intentos fallidos contra %s en %s", count, event.Email, anomalyFailedWindow),
+			}, "email:"+event.Email, map[string]interface{}{"event_id": event.ID, "attempts": count}, event.Date)
+		}
+	}
+	return nil
+}
+
+// checkManyModules detecta cuando el usuario opera en la última hora en varios servicios que no usó en los 30 días previos
+func (d *anomalyDetector) checkManyModules(audit models.Audit) error {
+	recentFrom := audit.Date.Add(-anomalyModulesWindow)
+	baselineFrom := recentFrom.Add(-anomalyModulesBaseline)
+
+	// Si el servicio de esta auditoría ya era habitual no hay nada nuevo que evaluar
+	var known int64
+	err := config.DB.Model(&models.Audit{}).
+		Where("user_id = ? AND origin_service = ? AND date >= ? AND date < ?", audit.UserID, audit.OriginService, baselineFrom, recentFrom).
+		Count(&known).Error
+	if err != nil || known > 0 {
+		return err
+	}
+
+	// NOT EXISTS en lugar de NOT IN: un origin_service NULL en la base haría que NOT IN no devuelva nada
+	baseline := config.DB.Table("audit AS baseline").Select("1").
+		Where("baseline.user_id = ? AND baseline.origin_service = audit.origin_service AND baseline.date >= ? AND baseline.date < ?", audit.UserID, baselineFrom, recentFrom)
+	var newServices []string
+	err = config.DB.Model(&models.Audit{}).Distinct("origin_service").
+		Where("user_id = ? AND date >= ? AND date <= ? AND origin_service IS NOT NULL", audit.UserID, recentFrom, audit.Date).
+		Where("NOT EXISTS (?)", baseline).
+		Pluck("origin_service", &newServices).Error
+	if err != nil {
+		return err
+	}
+	if len(newServices) < d.thresholds.newModules {
+		return nil
+	}
+
+	userID := audit.UserID
+	return d.raise(models.SecurityAlert{
+		Rule:   models.AlertRuleManyModules,
+		UserID: &userID,
+		IP:     audit.IP,
+		Detail: fmt.Sprintf("Operó en %d servicios nuevos en la última hora: %s", len(newServices), strings.Join(newServices, ", ")),
+	}, fmt.Sprintf("user:%d", audit.UserID), map[string]interface{}{"audit_id": audit.ID, "services": newServices}, audit.Date)
+}
+
+// checkMassDeletion cuenta las eliminaciones recientes del usuario
+func (d *anomalyDetector) checkMassDeletion(audit models.Audit) error {
+	var count int64
+	err := config.DB.Model(&models.Audit{}).
+		Where("user_id = ? AND UPPER(event) LIKE ? AND date >= ? AND date <= ?", audit.UserID, "DELETE%", audit.Date.Add(-anomalyDeleteWindow), audit.Date).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count < int64(d.thresholds.deletions) {
+		return nil
+	}
+
+	userID := audit.UserID
+	return d.raise(models.SecurityAlert{
+		Rule:   models.AlertRuleMassDeletion,
+		UserID: &userID,
+		IP:     audit.IP,
+		Detail: fmt.Sprintf("%d eliminaciones en %s (último servicio: %s)", count, anomalyDeleteWindow, audit.OriginService),
+	}, fmt.Sprintf("user:%d", audit.UserID), map[string]interface{}{"audit_id": audit.ID, "deletions": count}, audit.Date)
+}
+
+// checkImpossibleTravel compara el inicio de sesión con el anterior del mismo usuario. Con un IPLocator se
+// calcula la velocidad necesaria para el desplazamiento; sin él se considera sospechoso cambiar de red
+// pública en menos de 30 minutos.
+func (d *anomalyDetector) checkImpossibleTravel(event models.SecurityEvent) error {
+	if event.IP == "" {
+		return nil
+	}
+
+	var previous models.SecurityEvent
+	err := config.DB.Where("type = ? AND user_id = ? AND id < ? AND ip <> ''", models.SecurityEventLoginSuccess, *event.UserID, event.ID).
+		Order("id DESC").Limit(1).Find(&previous).Error
+	if err != nil || previous.ID == 0 || previous.IP == event.IP {
+		return err
+	}
+
+	elapsed := event.Date.Sub(previous.Date)
+	evidence := map[string]interface{}{
+		"event_id": event.ID, "previous_event_id": previous.ID, "previous_ip": previous.IP,
+		"elapsed_minutes": math.Round(elapsed.Minutes()),
+	}
+
+	detectorMutex.Lock()
+	locator := ipLocator
+	detectorMutex.Unlock()
+
+	var detail string
+	if locator != nil {
+		lat1, lon1, ok1 := locator.Locate(previous.IP)
+		lat2, lon2, ok2 := locator.Locate(event.IP)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		distance := haversineKm(lat1, lon1, lat2, lon2)
+		hours := math.Max(elapsed.Hours(), 1.0/60)
+		if distance/hours <= anomalyMaxTravelSpeedKmh {
+			return nil
+		}
+		evidence["distance_km"] = math.Round(distance)
+		detail = fmt.Sprintf("Inicio de sesión desde %s a %.0f km de %s en %s", event.IP, distance, previous.IP, elapsed.Round(time.Minute))
+	} else {
+		if elapsed > anomalyTravelWindow || !differentPublicNetworks(previous.IP, event.IP) {
+			return nil
+		}
+		detail = fmt.Sprintf("Inicio de sesión desde la red de %s %s después de acceder desde %s", event.IP, elapsed.Round(time.Minute), previous.IP)
+	}
+
+	return d.raise(models.SecurityAlert{
+		Rule:   models.AlertRuleImpossibleTravel,
+		UserID: event.UserID,
+		Email:  event.Email,
+		IP:     event.IP,
+		Detail: detail,
+	}, fmt.Sprintf("user:%d", *event.UserID), evidence, event.Date)
+}
+
+// differentPublicNetworks indica si dos IP públicas pertenecen a redes distintas (/16 en IPv4, /32 en IPv6)
+func differentPublicNetworks(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return false
+	}
+	for _, ip := range []net.IP{ipA, ipB} {
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			return false
+		}
+	}
+
+	if ipA.To4() != nil && ipB.To4() != nil {
+		mask := net.CIDRMask(16, 32)
+		return !ipA.To4().Mask(mask).Equal(ipB.To4().Mask(mask))
+	}
+	mask := net.CIDRMask(32, 128)
+	return !ipA.To16().Mask(mask).Equal(ipB.To16().Mask(mask))
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLon := toRad(lat2-lat1), toRad(lon2-lon1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// raise guarda la alerta. Si ya hay una alerta abierta de la misma regla y sujeto vista dentro del
+// periodo de espera, solo se incrementan sus ocurrencias para no inundar el listado ni el correo.
+func (d *anomalyDetector) raise(alert models.SecurityAlert, subject string, evidence map[string]interface{}, seenAt time.Time) error {
+	payload, err := json.Marshal(evidence)
+	if err != nil {
+		return err
+	}
+	seenAt = seenAt.UTC()
+	dedupKey := alert.Rule + ":" + subject
+
+	var existing models.SecurityAlert
+	err = config.DB.Where("dedup_key = ? AND status <> ? AND last_seen_at >= ?", dedupKey, models.AlertStatusResolved, seenAt.Add(-anomalyAlertCooldown)).
+		Order("id DESC").Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	if existing.ID != 0 {
+		return config.DB.Model(&existing).Updates(map[string]interface{}{
+			"occurrences":  existing.Occurrences + 1,
+			"last_seen_at": seenAt,
+			"detail":       alert.Detail,
+			"evidence":     string(payload),
+		}).Error
+	}
+
+	severity, _ := models.AlertRuleSeverity(alert.Rule)
+	alert.Severity = severity
+	alert.Status = models.AlertStatusOpen
+	alert.DedupKey = dedupKey
+	alert.Evidence = string(payload)
+	alert.Occurrences = 1
+	alert.FirstSeenAt = seenAt
+	alert.LastSeenAt = seenAt
+	if err := config.DB.Create(&alert).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Alerta de seguridad %d (%s): %s", alert.ID, alert.Rule, alert.Detail)
+	d.notify(alert)
+	return nil
+}
+
+func (d *anomalyDetector) notify(alert models.SecurityAlert) {
+	if len(d.alertTo) == 0 || severityRank(alert.Severity) < severityRank(d.minNotify) {
+		return
+	}
+
+	// El detalle, la IP y el email vienen de la petición auditada y SendEmail los inserta en el HTML
+	subject := fmt.Sprintf("[%s] Alerta de seguridad: %s", alert.Severity, alert.Rule)
+	body := fmt.Sprintf("Alerta %d detectada el %s.\n%s\nIP: %s\nEmail: %s",
+		alert.ID, helpers.AdjustToEcuadorTime(alert.FirstSeenAt).Format("2006-01-02 15:04:05"),
+		html.EscapeString(alert.Detail), html.EscapeString(alert.IP), html.EscapeString(alert.Email))
+	for _, to := range d.alertTo {
+		if err := SendEmail(to, subject, body); err != nil {
+			log.Printf("No se pudo notificar la alerta %d a %s: %v", alert.ID, to, err)
+		}
+	}
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case models.SecuritySeverityCritical:
+		return 3
+	case models.SecuritySeverityWarning:
+		return 2
+	case models.SecuritySeverityInfo:
+		return 1
+	}
+	return 0
+}
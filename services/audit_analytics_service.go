@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AnalyticsIntervalHour = "hour"
+	AnalyticsIntervalDay  = "day"
+	AnalyticsIntervalWeek = "week"
+
+	AnalyticsGroupEvent   = "event"
+	AnalyticsGroupService = "origin_service"
+	AnalyticsGroupUser    = "user"
+
+	analyticsTimezone = "America/Guayaquil"
+
+	// Las fechas se guardan en UTC y Ecuador no tiene horario de verano, por eso basta un desfase fijo
+	// (CONVERT_TZ con nombres de zona requiere cargar las tablas de zonas horarias en MySQL)
+	analyticsLocalDate = "CONVERT_TZ(audit.date, '+00:00', '-05:00')"
+)
+
+// Expresión SQL del inicio de cada intervalo en hora de Ecuador; las semanas empiezan el lunes
+var analyticsBuckets = map[string]string{
+	AnalyticsIntervalHour: "DATE_FORMAT(" + analyticsLocalDate + ", '%Y-%m-%d %H:00')",
+	AnalyticsIntervalDay:  "DATE_FORMAT(" + analyticsLocalDate + ", '%Y-%m-%d')",
+	AnalyticsIntervalWeek: "DATE_FORMAT(DATE_SUB(" + analyticsLocalDate + ", INTERVAL WEEKDAY(" + analyticsLocalDate + ") DAY), '%Y-%m-%d')",
+}
+
+var analyticsGroups = map[string]string{
+	AnalyticsGroupEvent:   "audit.event",
+	AnalyticsGroupService: "UPPER(audit.origin_service)",
+	AnalyticsGroupUser:    "users.name",
+}
+
+// GetAuditAnalytics calcula en la base de datos las series por intervalo, los rankings y la
+// comparación con el periodo anterior de igual duración
+func GetAuditAnalytics(interval, groupBy string, from, to time.Time, top int, filters map[string]interface{}) (models.AuditAnalyticsResponse, error) {
+	response := models.AuditAnalyticsResponse{
+		Interval: interval,
+		GroupBy:  groupBy,
+		Timezone: analyticsTimezone,
+	}
+
+	bucket, ok := analyticsBuckets[interval]
+	if !ok {
+		return response, fmt.Errorf("intervalo no soportado: %s", interval)
+	}
+	group, ok := analyticsGroups[groupBy]
+	if !ok {
+		return response, fmt.Errorf("agrupación no soportada: %s", groupBy)
+	}
+
+	from, to = from.UTC(), to.UTC()
+	previousFrom := from.Add(-to.Sub(from))
+	response.Current = models.AuditPeriodTotals{From: from, To: to}
+	response.Previous = models.AuditPeriodTotals{From: previousFrom, To: from}
+
+	base := func(start, end time.Time) *gorm.DB {
+		query := config.DB.Model(&models.Audit{}).
+			Joins("INNER JOIN users ON users.id = audit.user_id").
+			Where("audit.date >= ? AND audit.date < ?", start, end)
+		return applyAuditListFilters(query, filters)
+	}
+
+	// Series por intervalo y grupo
+	err := base(from, to).
+		Select(bucket+" AS bucket, "+group+" AS `key`, COUNT(*) AS total, "+
+			"SUM(CASE WHEN audit.outcome = ? THEN 1 ELSE 0 END) AS failures", models.AuditOutcomeFailure).
+		Group("bucket, `key`").
+		Order("bucket, total DESC").
+		Scan(&response.Series).Error
+	if err != nil {
+		return response, err
+	}
+
+	// Totales de ambos periodos en una sola consulta
+	var totals struct {
+		Total            int64
+		Failures         int64
+		PreviousTotal    int64
+		PreviousFailures int64
+	}
+	err = base(previousFrom, to).
+		Select("SUM(CASE WHEN audit.date >= ? THEN 1 ELSE 0 END) AS total, "+
+			"SUM(CASE WHEN audit.date >= ? AND audit.outcome = ? THEN 1 ELSE 0 END) AS failures, "+
+			"SUM(CASE WHEN audit.date < ? THEN 1 ELSE 0 END) AS previous_total, "+
+			"SUM(CASE WHEN audit.date < ? AND audit.outcome = ? THEN 1 ELSE 0 END) AS previous_failures",
+			from, from, models.AuditOutcomeFailure, from, from, models.AuditOutcomeFailure).
+		Scan(&totals).Error
+	if err != nil {
+		return response, err
+	}
+	response.Current.Total, response.Current.Failures = totals.Total, totals.Failures
+	response.Previous.Total, response.Previous.Failures = totals.PreviousTotal, totals.PreviousFailures
+	response.ChangePercent = changePercent(totals.Total, totals.PreviousTotal)
+
+	// Comparación por grupo contra el periodo anterior
+	err = base(previousFrom, to).
+		Select(group+" AS `key`, "+
+			"SUM(CASE WHEN audit.date >= ? THEN 1 ELSE 0 END) AS total, "+
+			"SUM(CASE WHEN audit.date >= ? AND audit.outcome = ? THEN 1 ELSE 0 END) AS failures, "+
+			"SUM(CASE WHEN audit.date < ? THEN 1 ELSE 0 END) AS previous_total",
+			from, from, models.AuditOutcomeFailure, from).
+		Group("`key`").
+		Order("total DESC").
+		Scan(&response.Groups).Error
+	if err != nil {
+		return response, err
+	}
+	for i := range response.Groups {
+		response.Groups[i].ChangePercent = changePercent(response.Groups[i].Total, response.Groups[i].PreviousTotal)
+	}
+
+	// Rankings del periodo actual
+	if err := base(from, to).
+		Select("users.name AS `key`, COUNT(*) AS total").
+		Group("audit.user_id, users.name").
+		Order("total DESC").
+		Limit(top).
+		Scan(&response.TopUsers).Error; err != nil {
+		return response, err
+	}
+	if err := base(from, to).
+		Select("UPPER(audit.origin_service) AS `key`, COUNT(*) AS total").
+		Group("`key`").
+		Order("total DESC").
+		Limit(top).
+		Scan(&response.TopServices).Error; err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+func changePercent(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := float64(current-previous) / float64(previous) * 100
+	return &change
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveStorage abstrae dónde se guardan los archivos de auditoría archivada
+type ArchiveStorage interface {
+	Save(name string, content io.Reader) (int64, error)
+	Open(name string) (io.ReadCloser, error)
+	Delete(name string) error
+}
+
+// LocalArchiveStorage guarda los archivos en un directorio del disco local
+type LocalArchiveStorage struct {
+	Dir string
+}
+
+func NewLocalArchiveStorage(dir string) *LocalArchiveStorage {
+	return &LocalArchiveStorage{Dir: dir}
+}
+
+func (s *LocalArchiveStorage) path(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") || filepath.Base(name) != name {
+		return "", errors.New("nombre de archivo inválido")
+	}
+	return filepath.Join(s.Dir, name), nil
+}
+
+func (s *LocalArchiveStorage) Save(name string, content io.Reader) (int64, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return 0, err
+	}
+
+	// Se escribe en un archivo temporal para no dejar archivos incompletos
+	tmp, err := os.CreateTemp(s.Dir, name+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+
+	return written, os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalArchiveStorage) Open(name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalArchiveStorage) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+var archiveStorage ArchiveStorage = NewLocalArchiveStorage(archiveDir())
+
+// SetArchiveStorage reemplaza el almacenamiento de archivos (por ejemplo, por uno remoto)
+func SetArchiveStorage(storage ArchiveStorage) {
+	archiveStorage = storage
+}
+
+func archiveDir() string {
+	if dir := os.Getenv("AUDIT_ARCHIVE_DIR"); dir != "" {
+		return dir
+	}
+	return "storage/audit-archives"
+}
@@ -0,0 +1,578 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Cantidad máxima de auditorías por archivo
+	auditArchiveBatchSize = 5000
+	// Filas por INSERT al rehidratar un archivo
+	auditRestoreBatchSize = 500
+	// Tiempo por defecto que un archivo queda rehidratado en restored_audits
+	defaultAuditRestoreTTL = 24 * time.Hour
+)
+
+var (
+	ErrRetentionRunning = errors.New("ya existe un proceso de retención en ejecución")
+	ErrArchiveChecksum  = errors.New("el checksum del archivo no coincide, el archivo pudo ser modificado")
+
+	retentionMutex sync.Mutex
+)
+
+// CreateRetentionPolicy crea una política de retención para un servicio y/o evento
+func CreateRetentionPolicy(originService, event string, retentionDays int, active bool) (models.AuditRetentionPolicy, error) {
+	policy := models.AuditRetentionPolicy{
+		OriginService: strings.ToUpper(strings.TrimSpace(originService)),
+		Event:         strings.ToUpper(strings.TrimSpace(event)),
+		RetentionDays: retentionDays,
+		Active:        active,
+	}
+
+	var count int64
+	config.DB.Model(&models.AuditRetentionPolicy{}).
+		Where("origin_service = ? AND event = ?", policy.OriginService, policy.Event).
+		Count(&count)
+	if count > 0 {
+		return policy, errors.New("ya existe una política para ese servicio y evento")
+	}
+
+	err := config.DB.Create(&policy).Error
+	return policy, err
+}
+
+// GetRetentionPolicies obtiene las políticas ordenadas de la más específica a la más general
+func GetRetentionPolicies() ([]models.AuditRetentionPolicy, error) {
+	var policies []models.AuditRetentionPolicy
+	if err := config.DB.Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	sortPolicies(policies)
+	return policies, nil
+}
+
+// UpdateRetentionPolicy actualiza los días de retención y el estado de una política
+func UpdateRetentionPolicy(id uint, retentionDays int, active bool) (models.AuditRetentionPolicy, error) {
+	var policy models.AuditRetentionPolicy
+	if err := config.DB.First(&policy, id).Error; err != nil {
+		return policy, errors.New("política de retención no encontrada")
+	}
+
+	policy.RetentionDays = retentionDays
+	policy.Active = active
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return policy, errors.New("error al actualizar la política de retención")
+	}
+	return policy, nil
+}
+
+// DeleteRetentionPolicy elimina una política de retención
+func DeleteRetentionPolicy(id uint) (models.AuditRetentionPolicy, error) {
+	var policy models.AuditRetentionPolicy
+	if err := config.DB.First(&policy, id).Error; err != nil {
+		return policy, errors.New("política de retención no encontrada")
+	}
+	return policy, config.DB.Delete(&policy).Error
+}
+
+func sortPolicies(policies []models.AuditRetentionPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policies[i].Specificity() > policies[j].Specificity()
+	})
+}
+
+// policiesOverlap indica si existen auditorías a las que aplican ambas políticas
+func policiesOverlap(a, b models.AuditRetentionPolicy) bool {
+	sameService := a.OriginService == "" || b.OriginService == "" || a.OriginService == b.OriginService
+	sameEvent := a.Event == "" || b.Event == "" || a.Event == b.Event
+	return sameService && sameEvent
+}
+
+func policyScope(query *gorm.DB, policy models.AuditRetentionPolicy) *gorm.DB {
+	if policy.OriginService != "" {
+		query = query.Where("UPPER(origin_service) = ?", policy.OriginService)
+	}
+	if policy.Event != "" {
+		query = query.Where("UPPER(event) = ?", policy.Event)
+	}
+	return query
+}
+
+// retentionScope limita la consulta a las auditorías gobernadas por la política,
+// excluyendo las que cubre una política activa más específica
+func retentionScope(query *gorm.DB, policy models.AuditRetentionPolicy, policies []models.AuditRetentionPolicy) *gorm.DB {
+	query = policyScope(query, policy)
+
+	for _, other := range policies {
+		if other.ID == policy.ID || other.Specificity() <= policy.Specificity() || !policiesOverlap(policy, other) {
+			continue
+		}
+		switch {
+		case other.OriginService != "" && other.Event != "":
+			query = query.Where("NOT (UPPER(origin_service) = ? AND UPPER(event) = ?)", other.OriginService, other.Event)
+		case other.OriginService != "":
+			query = query.Where("UPPER(origin_service) <> ?", other.OriginService)
+		case other.Event != "":
+			query = query.Where("UPPER(event) <> ?", other.Event)
+		}
+	}
+	return query
+}
+
+// RunAuditRetention mueve a archivos comprimidos las auditorías que superaron su tiempo de retención
+func RunAuditRetention(ctx context.Context) ([]models.AuditArchive, error) {
+	if !retentionMutex.TryLock() {
+		return nil, ErrRetentionRunning
+	}
+	defer retentionMutex.Unlock()
+
+	var policies []models.AuditRetentionPolicy
+	if err := config.DB.WithContext(ctx).Where("active = ?", true).Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	sortPolicies(policies)
+
+	var archives []models.AuditArchive
+	for _, policy := range policies {
+		if policy.RetentionDays <= 0 {
+			continue
+		}
+		created, err := archivePolicy(ctx, policy, policies)
+		archives = append(archives, created...)
+		if err != nil {
+			return archives, err
+		}
+	}
+	return archives, nil
+}
+
+func archivePolicy(ctx context.Context, policy models.AuditRetentionPolicy, policies []models.AuditRetentionPolicy) ([]models.AuditArchive, error) {
+	cutoff := time.Now().UTC().AddDate(0, 0, -policy.RetentionDays)
+	var archives []models.AuditArchive
+
+	for {
+		var audits []models.Audit
+		query := retentionScope(config.DB.WithContext(ctx).Model(&models.Audit{}), policy, policies).
+			Where("date < ?", cutoff).
+			Order("id").
+			Limit(auditArchiveBatchSize)
+		if err := query.Find(&audits).Error; err != nil {
+			return archives, err
+		}
+		if len(audits) == 0 {
+			return archives, nil
+		}
+
+		archive, err := archiveAudits(ctx, policy, audits)
+		if err != nil {
+			return archives, err
+		}
+		archives = append(archives, archive)
+
+		if len(audits) < auditArchiveBatchSize {
+			return archives, nil
+		}
+	}
+}
+
+// archiveAudits escribe el lote en un archivo JSONL comprimido y, si se guardó correctamente, lo elimina de la tabla
+func archiveAudits(ctx context.Context, policy models.AuditRetentionPolicy, audits []models.Audit) (models.AuditArchive, error) {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	encoder := json.NewEncoder(gz)
+
+	archive := models.AuditArchive{
+		PolicyID: policy.ID,
+		Records:  len(audits),
+		FirstID:  audits[0].ID,
+		LastID:   audits[len(audits)-1].ID,
+		FromDate: audits[0].Date,
+		ToDate:   audits[0].Date,
+	}
+
+	ids := make([]uint, 0, len(audits))
+	for _, audit := range audits {
+		if err := encoder.Encode(toArchivedAudit(audit)); err != nil {
+			return archive, fmt.Errorf("error al serializar la auditoría %d: %w", audit.ID, err)
+		}
+		if audit.Date.Before(archive.FromDate) {
+			archive.FromDate = audit.Date
+		}
+		if audit.Date.After(archive.ToDate) {
+			archive.ToDate = audit.Date
+		}
+		ids = append(ids, audit.ID)
+	}
+	if err := gz.Close(); err != nil {
+		return archive, err
+	}
+
+	sum := sha256.Sum256(buffer.Bytes())
+	archive.Checksum = hex.EncodeToString(sum[:])
+	archive.FileName = fmt.Sprintf("audit_%s_p%d_%d-%d.jsonl.gz",
+		time.Now().UTC().Format("20060102150405"), policy.ID, archive.FirstID, archive.LastID)
+
+	size, err := archiveStorage.Save(archive.FileName, &buffer)
+	if err != nil {
+		return archive, fmt.Errorf("error al guardar el archivo %s: %w", archive.FileName, err)
+	}
+	archive.Size = size
+
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&archive).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Audit{}).Error
+	})
+	if err != nil {
+		// Sin el registro en la base de datos el archivo quedaría huérfano
+		if removeErr := archiveStorage.Delete(archive.FileName); removeErr != nil {
+			log.Printf("Error al eliminar el archivo huérfano %s: %v", archive.FileName, removeErr)
+		}
+		return archive, fmt.Errorf("error al registrar el archivo %s: %w", archive.FileName, err)
+	}
+
+	return archive, nil
+}
+
+func toArchivedAudit(audit models.Audit) models.ArchivedAudit {
+	return models.ArchivedAudit{
+		ID:             audit.ID,
+		Event:          audit.Event,
+		Description:    audit.Description,
+		UserID:         audit.UserID,
+		OriginService:  audit.OriginService,
+		Date:           audit.Date.UTC(),
+		IP:             audit.IP,
+		UserAgent:      audit.UserAgent,
+		RequestID:      audit.RequestID,
+		Method:         audit.Method,
+		Route:          audit.Route,
+		Outcome:        audit.Outcome,
+		FailureReason:  audit.FailureReason,
+		IdempotencyKey: audit.IdempotencyKey,
+	}
+}
+
+// GetPaginatedAuditArchives obtiene los archivos generados, del más reciente al más antiguo
+func GetPaginatedAuditArchives(page, pageSize int) ([]models.AuditArchive, int64, error) {
+	var archives []models.AuditArchive
+	var total int64
+
+	query := config.DB.Model(&models.AuditArchive{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&archives).Error
+	return archives, total, err
+}
+
+// QueryAuditArchive devuelve las auditorías del archivo que cumplen los filtros. Si el archivo está
+// rehidratado se consulta restored_audits; si no, se verifica el checksum y se descomprime el archivo.
+func QueryAuditArchive(id uint, page, pageSize int, filters map[string]interface{}) (models.AuditArchive, []models.ArchivedAudit, int64, error) {
+	var archive models.AuditArchive
+	if err := config.DB.First(&archive, id).Error; err != nil {
+		return archive, nil, 0, errors.New("archivo de auditoría no encontrado")
+	}
+	if archive.RestoredUntil != nil && archive.RestoredUntil.After(time.Now().UTC()) {
+		records, total, err := queryRestoredAudits(archive.ID, page, pageSize, filters)
+		return archive, records, total, err
+	}
+
+	file, err := archiveStorage.Open(archive.FileName)
+	if err != nil {
+		return archive, nil, 0, fmt.Errorf("no se pudo abrir el archivo %s: %w", archive.FileName, err)
+	}
+	defer file.Close()
+
+	// El hash se calcula mientras se descomprime para leer el archivo una sola vez
+	hash := sha256.New()
+	content := io.TeeReader(file, hash)
+
+	records, total, readErr := readArchivedAudits(content, page, pageSize, filters)
+
+	// Un archivo alterado suele producir también errores de lectura, por eso el checksum se valida primero
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return archive, nil, 0, err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != archive.Checksum {
+		return archive, nil, 0, ErrArchiveChecksum
+	}
+	if readErr != nil {
+		return archive, nil, 0, fmt.Errorf("error al leer el archivo %s: %w", archive.FileName, readErr)
+	}
+
+	return archive, records, total, nil
+}
+
+func readArchivedAudits(content io.Reader, page, pageSize int, filters map[string]interface{}) ([]models.ArchivedAudit, int64, error) {
+	offset := (page - 1) * pageSize
+	var records []models.ArchivedAudit
+	var total int64
+
+	err := scanArchivedAudits(content, func(record models.ArchivedAudit) error {
+		if !matchesArchiveFilters(record, filters) {
+			return nil
+		}
+		if total >= int64(offset) && len(records) < pageSize {
+			records = append(records, record)
+		}
+		total++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// scanArchivedAudits descomprime el archivo y llama a fn con cada auditoría, en el orden en que se guardaron
+func scanArchivedAudits(content io.Reader, fn func(models.ArchivedAudit) error) error {
+	gz, err := gzip.NewReader(content)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var record models.ArchivedAudit
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// RestoreAuditArchive verifica el checksum del archivo y copia sus auditorías a restored_audits durante
+// AUDIT_ARCHIVE_RESTORE_TTL (por defecto 24h). Mientras tanto las consultas del archivo se resuelven con
+// SQL en lugar de descomprimirlo en cada página. Restaurar de nuevo renueva el plazo.
+func RestoreAuditArchive(ctx context.Context, id uint) (models.AuditArchive, error) {
+	var archive models.AuditArchive
+	if err := config.DB.WithContext(ctx).First(&archive, id).Error; err != nil {
+		return archive, errors.New("archivo de auditoría no encontrado")
+	}
+	if err := PurgeExpiredAuditRestores(ctx); err != nil {
+		log.Printf("Error al eliminar auditorías rehidratadas vencidas: %v", err)
+	}
+
+	file, err := archiveStorage.Open(archive.FileName)
+	if err != nil {
+		return archive, fmt.Errorf("no se pudo abrir el archivo %s: %w", archive.FileName, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	content := io.TeeReader(file, hash)
+	expiresAt := time.Now().UTC().Add(auditRestoreTTL())
+
+	// Las filas se insertan dentro de la transacción y solo se confirman si el checksum coincide
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("archive_id = ?", archive.ID).Delete(&models.RestoredAudit{}).Error; err != nil {
+			return err
+		}
+
+		batch := make([]models.RestoredAudit, 0, auditRestoreBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := tx.Create(&batch).Error
+			batch = batch[:0]
+			return err
+		}
+		readErr := scanArchivedAudits(content, func(record models.ArchivedAudit) error {
+			batch = append(batch, toRestoredAudit(archive.ID, record, expiresAt))
+			if len(batch) < auditRestoreBatchSize {
+				return nil
+			}
+			return flush()
+		})
+
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return err
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != archive.Checksum {
+			return ErrArchiveChecksum
+		}
+		if readErr != nil {
+			return fmt.Errorf("error al leer el archivo %s: %w", archive.FileName, readErr)
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		return tx.Model(&archive).Update("restored_until", expiresAt).Error
+	})
+	return archive, err
+}
+
+// PurgeExpiredAuditRestores elimina las auditorías rehidratadas cuyo plazo venció
+func PurgeExpiredAuditRestores(ctx context.Context) error {
+	now := time.Now().UTC()
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", now).Delete(&models.RestoredAudit{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AuditArchive{}).Where("restored_until <= ?", now).Update("restored_until", nil).Error
+	})
+}
+
+func auditRestoreTTL() time.Duration {
+	ttl := defaultAuditRestoreTTL
+	if value := os.Getenv("AUDIT_ARCHIVE_RESTORE_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			ttl = parsed
+		} else {
+			log.Printf("AUDIT_ARCHIVE_RESTORE_TTL inválido (%s), se usará %s", value, ttl)
+		}
+	}
+	return ttl
+}
+
+func toRestoredAudit(archiveID uint, record models.ArchivedAudit, expiresAt time.Time) models.RestoredAudit {
+	return models.RestoredAudit{
+		ArchiveID:      archiveID,
+		ID:             record.ID,
+		Event:          record.Event,
+		Description:    record.Description,
+		UserID:         record.UserID,
+		OriginService:  record.OriginService,
+		Date:           record.Date,
+		IP:             record.IP,
+		UserAgent:      record.UserAgent,
+		RequestID:      record.RequestID,
+		Method:         record.Method,
+		Route:          record.Route,
+		Outcome:        record.Outcome,
+		FailureReason:  record.FailureReason,
+		IdempotencyKey: record.IdempotencyKey,
+		ExpiresAt:      expiresAt,
+	}
+}
+
+// queryRestoredAudits aplica en SQL los mismos filtros que matchesArchiveFilters
+func queryRestoredAudits(archiveID uint, page, pageSize int, filters map[string]interface{}) ([]models.ArchivedAudit, int64, error) {
+	query := config.DB.Model(&models.RestoredAudit{}).Where("archive_id = ?", archiveID)
+	if event, ok := filters["event"]; ok {
+		query = query.Where("UPPER(event) LIKE ?", "%"+strings.ToUpper(event.(string))+"%")
+	}
+	if module, ok := filters["origin_service"]; ok {
+		query = query.Where("UPPER(origin_service) = ?", strings.ToUpper(module.(string)))
+	}
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("user_id = ?", userID)
+	}
+	if outcome, ok := filters["outcome"]; ok {
+		query = query.Where("UPPER(outcome) = ?", strings.ToUpper(outcome.(string)))
+	}
+	if ip, ok := filters["ip"]; ok {
+		query = query.Where("ip = ?", ip)
+	}
+	if requestID, ok := filters["request_id"]; ok {
+		query = query.Where("request_id = ?", requestID)
+	}
+	if from, ok := filters["from"]; ok {
+		query = query.Where("date >= ?", from)
+	}
+	if to, ok := filters["to"]; ok {
+		query = query.Where("date <= ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []models.ArchivedAudit
+	err := query.Order("row_id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error
+	return records, total, err
+}
+
+func matchesArchiveFilters(record models.ArchivedAudit, filters map[string]interface{}) bool {
+	if event, ok := filters["event"]; ok && !strings.Contains(strings.ToUpper(record.Event), strings.ToUpper(event.(string))) {
+		return false
+	}
+	if module, ok := filters["origin_service"]; ok && !strings.EqualFold(record.OriginService, module.(string)) {
+		return false
+	}
+	if userID, ok := filters["user_id"]; ok && record.UserID != userID.(uint) {
+		return false
+	}
+	if outcome, ok := filters["outcome"]; ok && !strings.EqualFold(record.Outcome, outcome.(string)) {
+		return false
+	}
+	if ip, ok := filters["ip"]; ok && record.IP != ip.(string) {
+		return false
+	}
+	if requestID, ok := filters["request_id"]; ok && record.RequestID != requestID.(string) {
+		return false
+	}
+	if from, ok := filters["from"]; ok && record.Date.Before(from.(time.Time)) {
+		return false
+	}
+	if to, ok := filters["to"]; ok && record.Date.After(to.(time.Time)) {
+		return false
+	}
+	return true
+}
+
+// StartAuditRetentionJob ejecuta la retención periódicamente según AUDIT_RETENTION_INTERVAL (por defecto 24h).
+// Con el valor "off" el proceso automático queda deshabilitado.
+func StartAuditRetentionJob() {
+	interval := 24 * time.Hour
+	if value := os.Getenv("AUDIT_RETENTION_INTERVAL"); value != "" {
+		if strings.EqualFold(value, "off") {
+			log.Println("Retención automática de auditorías deshabilitada")
+			return
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("AUDIT_RETENTION_INTERVAL inválido (%s), se usará %s", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := PurgeExpiredAuditRestores(context.Background()); err != nil {
+				log.Printf("Error al eliminar auditorías rehidratadas vencidas: %v", err)
+			}
+			archives, err := RunAuditRetention(context.Background())
+			if err != nil {
+				log.Printf("Error en la retención de auditorías: %v", err)
+			}
+			if len(archives) > 0 {
+				log.Printf("Retención de auditorías: %d archivo(s) generados", len(archives))
+			}
+		}
+	}()
+}
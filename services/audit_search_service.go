@@ -0,0 +1,212 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	AuditSortRelevance = "relevance"
+	AuditSortDateDesc  = "date_desc"
+	AuditSortDateAsc   = "date_asc"
+	AuditSortEvent     = "event"
+	AuditSortUser      = "user"
+	AuditSortService   = "origin_service"
+
+	// InnoDB ignora en el índice FULLTEXT las palabras más cortas que innodb_ft_min_token_size (3 por defecto)
+	fulltextMinTermLength = 3
+	maxSearchTerms        = 10
+	highlightContext      = 60
+	highlightLength       = 200
+)
+
+var auditSortOrders = map[string]string{
+	AuditSortDateDesc: "audit.date DESC, audit.id DESC",
+	AuditSortDateAsc:  "audit.date ASC, audit.id ASC",
+	AuditSortEvent:    "audit.event ASC, audit.date DESC",
+	AuditSortUser:     "users.name ASC, audit.date DESC",
+	AuditSortService:  "audit.origin_service ASC, audit.date DESC",
+}
+
+// ValidAuditSort indica si el orden solicitado es uno de los soportados por la búsqueda
+func ValidAuditSort(sort string) bool {
+	_, ok := auditSortOrders[sort]
+	return ok || sort == AuditSortRelevance
+}
+
+var (
+	fulltextOnce      sync.Once
+	fulltextAvailable bool
+)
+
+// auditFulltextEnabled indica si la base es MySQL y tiene el índice FULLTEXT de la descripción
+func auditFulltextEnabled() bool {
+	fulltextOnce.Do(func() {
+		fulltextAvailable = config.DB.Dialector.Name() == "mysql" &&
+			config.DB.Migrator().HasIndex(&models.Audit{}, models.AuditFulltextIndex)
+	})
+	return fulltextAvailable
+}
+
+// SearchAudits busca texto libre en la descripción de las auditorías combinado con los filtros del
+// listado y el filtro "events" (lista exacta de eventos). En MySQL usa el índice FULLTEXT en modo
+// booleano; si no está disponible o algún término es muy corto para el índice, usa LIKE.
+func SearchAudits(text, sort string, page, pageSize int, filters map[string]interface{}) ([]models.AuditSearchResult, int64, error) {
+	var results []models.AuditSearchResult
+	var total int64
+
+	terms := searchTerms(text)
+	useFulltext := len(terms) > 0 && auditFulltextEnabled()
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < fulltextMinTermLength {
+			useFulltext = false
+		}
+	}
+
+	query := auditListQuery(filters)
+	if events, ok := filters["events"]; ok {
+		query = query.Where("audit.event IN ?", events)
+	}
+
+	var booleanQuery string
+	if useFulltext {
+		booleanQuery = fulltextBooleanQuery(terms)
+		query = query.Where("MATCH(audit.description) AGAINST (? IN BOOLEAN MODE)", booleanQuery)
+	} else {
+		for _, term := range terms {
+			query = query.Where("LOWER(audit.description) LIKE ?", "%"+strings.ToLower(term)+"%")
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if sort == "" {
+		sort = AuditSortDateDesc
+		if len(terms) > 0 {
+			sort = AuditSortRelevance
+		}
+	}
+	if useFulltext {
+		query = query.Select(auditResponseColumns+", MATCH(audit.description) AGAINST (? IN BOOLEAN MODE) AS score", booleanQuery)
+	}
+	if sort == AuditSortRelevance {
+		// Sin índice FULLTEXT no hay puntaje; las coincidencias se ordenan por fecha
+		if useFulltext {
+			query = query.Order("score DESC, audit.id DESC")
+		} else {
+			query = query.Order(auditSortOrders[AuditSortDateDesc])
+		}
+	} else {
+		query = query.Order(auditSortOrders[sort])
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+
+	matcher := highlightMatcher(terms)
+	for i := range results {
+		results[i].Highlight = highlightSnippet(results[i].Description, matcher)
+	}
+
+	return results, total, nil
+}
+
+// searchTerms separa el texto en palabras sin los operadores del modo booleano de MySQL
+func searchTerms(text string) []string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, text)
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(cleaned) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// fulltextBooleanQuery exige cada término y acepta palabras que empiecen con él
+func fulltextBooleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = "+" + term + "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+func highlightMatcher(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// highlightSnippet devuelve un fragmento de la descripción alrededor de la primera coincidencia, con el
+// texto escapado para HTML y las coincidencias dentro de <mark>
+func highlightSnippet(description string, matcher *regexp.Regexp) string {
+	if matcher == nil {
+		return ""
+	}
+	first := matcher.FindStringIndex(description)
+	if first == nil {
+		return ""
+	}
+
+	start := first[0] - highlightContext
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(description[start]) {
+		start--
+	}
+	end := start + highlightLength
+	if end > len(description) {
+		end = len(description)
+	}
+	for end < len(description) && !utf8.RuneStart(description[end]) {
+		end++
+	}
+	fragment := description[start:end]
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	last := 0
+	for _, match := range matcher.FindAllStringIndex(fragment, -1) {
+		builder.WriteString(html.EscapeString(fragment[last:match[0]]))
+		builder.WriteString("<mark>")
+		builder.WriteString(html.EscapeString(fragment[match[0]:match[1]]))
+		builder.WriteString("</mark>")
+		last = match[1]
+	}
+	builder.WriteString(html.EscapeString(fragment[last:]))
+	if end < len(description) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
@@ -1,27 +1,139 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"seguridad-api/config"
 	"seguridad-api/models"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Registrar auditoría
-func RegisterAudit(event, description string, userID uint, originService string, date time.Time) error {
+func RegisterAudit(ctx context.Context, event, description string, userID uint, originService string, date time.Time) error {
+	audit := models.Audit{
+		Event:         event,
+		Description:   description,
+		UserID:        userID,
+		OriginService: originService,
+		Date:          date,
+		Outcome:       models.AuditOutcomeSuccess,
+	}
+
+	return SaveAudit(ctx, &audit)
+}
+
+// Registrar auditoría de una operación que no se pudo completar
+func RegisterFailedAudit(ctx context.Context, event, description string, userID uint, originService string, date time.Time, reason string) error {
 	audit := models.Audit{
 		Event:         event,
 		Description:   description,
 		UserID:        userID,
 		OriginService: originService,
 		Date:          date,
+		Outcome:       models.AuditOutcomeFailure,
+		FailureReason: reason,
 	}
 
-	result := config.DB.Create(&audit)
+	return SaveAudit(ctx, &audit)
+}
+
+// SaveAudit completa los datos de la petición que falten en el registro y lo guarda
+func SaveAudit(ctx context.Context, audit *models.Audit) error {
+	FillAuditMetadata(ctx, audit)
+
+	result := config.DB.WithContext(ctx).Create(audit)
 	return result.Error
 }
 
+// FillAuditMetadata completa con los datos de la petición los campos que el registro no trae
+func FillAuditMetadata(ctx context.Context, audit *models.Audit) {
+	meta := RequestMetadataFromContext(ctx)
+	if audit.IP == "" {
+		audit.IP = meta.IP
+	}
+	if audit.UserAgent == "" {
+		audit.UserAgent = meta.UserAgent
+	}
+	if audit.RequestID == "" {
+		audit.RequestID = meta.RequestID
+	}
+	if audit.Method == "" {
+		audit.Method = meta.Method
+	}
+	if audit.Route == "" {
+		audit.Route = meta.Route
+	}
+	if audit.Outcome == "" {
+		audit.Outcome = models.AuditOutcomeSuccess
+	}
+}
+
+// FindExistingIdempotencyKeys devuelve cuáles de las claves ya tienen una auditoría registrada
+func FindExistingIdempotencyKeys(keys []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(keys) == 0 {
+		return existing, nil
+	}
+
+	var found []string
+	if err := config.DB.Model(&models.Audit{}).Where("idempotency_key IN ?", keys).Pluck("idempotency_key", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range found {
+		existing[key] = true
+	}
+	return existing, nil
+}
+
+// SaveIdempotentAudit guarda la auditoría si su clave de idempotencia no se registró antes; devuelve
+// false si ya existía. La inserción ignora el conflicto con el índice único, así dos reintentos
+// simultáneos con la misma clave no terminan en error.
+func SaveIdempotentAudit(ctx context.Context, audit *models.Audit) (bool, error) {
+	FillAuditMetadata(ctx, audit)
+
+	result := config.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(audit)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RequestMetadataFromContext obtiene los metadatos guardados por el middleware RequestContext
+func RequestMetadataFromContext(ctx context.Context) models.RequestMetadata {
+	if ctx == nil {
+		return models.RequestMetadata{}
+	}
+	meta, _ := ctx.Value(models.RequestMetadataKey).(models.RequestMetadata)
+	return meta
+}
+
+// applyAuditContextFilters aplica los filtros por datos de la petición y resultado
+func applyAuditContextFilters(query *gorm.DB, filters map[string]interface{}) *gorm.DB {
+	if ip, ok := filters["ip"]; ok {
+		query = query.Where("audit.ip = ?", ip)
+	}
+	if requestID, ok := filters["request_id"]; ok {
+		query = query.Where("audit.request_id = ?", requestID)
+	}
+	if method, ok := filters["method"]; ok {
+		query = query.Where("audit.method = ?", strings.ToUpper(method.(string)))
+	}
+	if route, ok := filters["route"]; ok {
+		query = query.Where("audit.route LIKE ?", "%"+route.(string)+"%")
+	}
+	if outcome, ok := filters["outcome"]; ok {
+		query = query.Where("audit.outcome = ?", strings.ToUpper(outcome.(string)))
+	}
+	if userAgent, ok := filters["user_agent"]; ok {
+		query = query.Where("audit.user_agent LIKE ?", "%"+userAgent.(string)+"%")
+	}
+	return query
+}
+
 // Obtener todas las auditorías
 func GetAudit() ([]models.AuditResponse, error) {
 	var audits []models.AuditResponse
@@ -29,25 +141,49 @@ func GetAudit() ([]models.AuditResponse, error) {
 	return audits, result.Error
 }
 
-func GetPaginatedAudit(page, pageSize int, filters map[string]interface{}) ([]models.AuditResponse, int64, error) {
-	var audits []models.AuditResponse
-	var total int64
+const auditResponseColumns = "audit.id, audit.event, audit.description, audit.user_id, users.name AS user, audit.origin_service, audit.date, " +
+	"audit.ip, audit.user_agent, audit.request_id, audit.method, audit.route, audit.outcome, audit.failure_reason"
 
-	query := config.DB.Model(&models.Audit{})
+// auditListQuery arma la consulta del listado de auditorías con los filtros por evento, servicio,
+// usuario y datos de la petición
+func auditListQuery(filters map[string]interface{}) *gorm.DB {
+	query := config.DB.Model(&models.Audit{}).
+		Joins("INNER JOIN users ON users.id = audit.user_id").
+		Select(auditResponseColumns)
+
+	return applyAuditListFilters(query, filters)
+}
 
-	// Aplicar filtros
+// applyAuditListFilters aplica los filtros del listado; la consulta debe incluir el JOIN con users
+func applyAuditListFilters(query *gorm.DB, filters map[string]interface{}) *gorm.DB {
 	if event, ok := filters["event"]; ok {
-		query = query.Joins("INNER JOIN users ON users.id = audit.user_id").
-			Where("audit.event LIKE ?", "%"+event.(string)+"%").
-			Select("audit.id, audit.event, audit.description, users.name AS user, audit.origin_service, audit.date")
-	} else {
-		query = query.Joins("INNER JOIN users ON users.id = audit.user_id").
-			Select("audit.id, audit.event, audit.description, users.name AS user, audit.origin_service, audit.date")
+		query = query.Where("audit.event LIKE ?", "%"+event.(string)+"%")
+	}
+	if originService, ok := filters["origin_service"]; ok {
+		query = query.Where("UPPER(audit.origin_service) = ?", strings.ToUpper(originService.(string)))
 	}
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("audit.user_id = ?", userID)
+	}
+	if from, ok := filters["from"]; ok {
+		query = query.Where("audit.date >= ?", from)
+	}
+	if to, ok := filters["to"]; ok {
+		query = query.Where("audit.date <= ?", to)
+	}
+	query = applyAuditContextFilters(query, filters)
 
 	if userName, ok := filters["userName"]; ok {
 		query = query.Where("LOWER(users.name) LIKE LOWER(?)", "%"+userName.(string)+"%")
 	}
+	return query
+}
+
+func GetPaginatedAudit(page, pageSize int, filters map[string]interface{}) ([]models.AuditResponse, int64, error) {
+	var audits []models.AuditResponse
+	var total int64
+
+	query := auditListQuery(filters)
 	err := query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -66,6 +202,39 @@ func GetPaginatedAudit(page, pageSize int, filters map[string]interface{}) ([]mo
 	return audits, total, nil
 }
 
+const auditExportBatchSize = 500
+
+// ExportAudits recorre por lotes las auditorías que cumplen los filtros sin cargarlas todas en memoria
+func ExportAudits(filters map[string]interface{}, fn func(models.AuditResponse) error) error {
+	var lastID uint
+	for {
+		audits, err := GetAuditsAfter(lastID, auditExportBatchSize, filters)
+		if err != nil {
+			return err
+		}
+		for _, audit := range audits {
+			if err := fn(audit); err != nil {
+				return err
+			}
+			lastID = audit.ID
+		}
+		if len(audits) < auditExportBatchSize {
+			return nil
+		}
+	}
+}
+
+// GetAuditsAfter obtiene, en orden, las auditorías posteriores al ID indicado que cumplen los filtros
+func GetAuditsAfter(lastID uint, limit int, filters map[string]interface{}) ([]models.AuditResponse, error) {
+	var audits []models.AuditResponse
+	err := auditListQuery(filters).
+		Where("audit.id > ?", lastID).
+		Order("audit.id").
+		Limit(limit).
+		Find(&audits).Error
+	return audits, err
+}
+
 // Obtener estadísticas de auditoría con filtros dinámicos
 // func GetAuditStatistics(event, userID, originService, startDate, endDate string) ([]models.AuditStatisticsResponse, error) {
 // 	var stats []models.AuditStatisticsResponse
@@ -242,14 +411,31 @@ func GetPaginatedAudit(page, pageSize int, filters map[string]interface{}) ([]mo
 //		// Devuelvo ambas respuestas juntas
 //		return stats, records, nil
 //	}
-func GetAuditStatistics(event, module, startDate, endDate string) ([]models.AuditStatisticsResponse, []models.Audit, error) {
+
+// Cantidad máxima de auditorías que acompañan a las estadísticas; el total sale de las estadísticas
+const auditStatisticsRecordLimit = 100
+
+const auditStatisticsColumns = "event, UPPER(origin_service) AS origin_service, COUNT(*) as total, " +
+	"SUM(CASE WHEN outcome = ? THEN 1 ELSE 0 END) AS failures, MAX(date) as last_date"
+
+// AuditStatisticsQuery agrupa las auditorías de la consulta por evento y servicio de origen con su total,
+// fallos y última fecha; lo usan las estadísticas y el resumen de los reportes
+func AuditStatisticsQuery(query *gorm.DB) *gorm.DB {
+	return query.Select(auditStatisticsColumns, models.AuditOutcomeFailure).
+		Group("event, origin_service") // 🔥 Agrupar por evento y módulo
+}
+
+// GetAuditStatistics agrupa en SQL las auditorías que cumplen los filtros y devuelve, junto a las
+// estadísticas, solo las auditorías más recientes (hasta auditStatisticsRecordLimit)
+func GetAuditStatistics(event, module, startDate, endDate string, filters map[string]interface{}) ([]models.AuditStatisticsResponse, []models.Audit, error) {
 	var stats []models.AuditStatisticsResponse
 	var records []models.Audit
 
-	// Crear la consulta base
-	query := config.DB.Model(&models.Audit{}).
-		Select("event, UPPER(origin_service) AS origin_service, COUNT(*) as total, MAX(date) as last_date").
-		Group("event, origin_service") // 🔥 Agrupar por evento y módulo
+	// Crear la consulta base; las estadísticas y los registros comparten los mismos filtros
+	query := config.DB.Model(&models.Audit{})
+
+	// Aplicar filtros por IP, resultado y datos de la petición
+	query = applyAuditContextFilters(query, filters)
 
 	// Aplicar filtro por evento si se proporciona
 	if event != "" {
@@ -288,14 +474,14 @@ func GetAuditStatistics(event, module, startDate, endDate string) ([]models.Audi
 	fmt.Println("SQL Generado:", query.Statement.SQL.String())
 
 	// Obtener los datos de estadísticas
-	err := query.Scan(&stats).Error
+	err := AuditStatisticsQuery(query.Session(&gorm.Session{})).Scan(&stats).Error
 	if err != nil {
 		fmt.Println("Error al ejecutar consulta:", err)
 		return nil, nil, err
 	}
 
-	// Obtener los registros individuales con los mismos filtros
-	err = config.DB.Model(&models.Audit{}).Where(query).Find(&records).Error
+	// Obtener los registros más recientes con los mismos filtros
+	err = query.Session(&gorm.Session{}).Order("date DESC, id DESC").Limit(auditStatisticsRecordLimit).Find(&records).Error
 	if err != nil {
 		fmt.Println("Error al obtener registros:", err)
 		return nil, nil, err
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"log"
+	"reflect"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	auditStreamQueueSize      = 1000
+	auditSubscriberBufferSize = 100
+	auditStreamUserCacheSize  = 1000
+)
+
+// AuditSubscription recibe las auditorías nuevas que cumplen sus filtros. El canal se cierra
+// cuando el suscriptor no consume a tiempo o el servidor se detiene; el cliente debe reconectarse
+// con Last-Event-ID para recuperar lo que no recibió.
+type AuditSubscription struct {
+	C       chan models.AuditResponse
+	filters map[string]interface{}
+	closed  bool
+}
+
+type auditHub struct {
+	mu          sync.Mutex
+	subscribers map[*AuditSubscription]struct{}
+	incoming    chan models.Audit
+	userNames   map[uint]string
+}
+
+var streamHub = &auditHub{
+	subscribers: make(map[*AuditSubscription]struct{}),
+	incoming:    make(chan models.Audit, auditStreamQueueSize),
+	userNames:   make(map[uint]string),
+}
+
+// RegisterAuditStream registra el callback que publica cada auditoría una vez confirmada en la base de datos
+func RegisterAuditStream(db *gorm.DB) error {
+	go streamHub.run()
+	return db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("audit:stream", publishCreatedAudits)
+}
+
+func publishCreatedAudits(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Name != "Audit" {
+		return
+	}
+
+	// Dentro de una transacción externa el registro aún no está confirmado; quien la abre publica al terminar
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+		return
+	}
+
+	var audits []models.Audit
+	eachModelValue(db.Statement.ReflectValue, func(value reflect.Value) {
+		if audit, ok := value.Interface().(models.Audit); ok {
+			audits = append(audits, audit)
+		}
+	})
+	PublishAudits(audits)
+}
+
+// PublishAudits envía las auditorías a los suscriptores y al detector de anomalías sin bloquear a quien las registra
+func PublishAudits(audits []models.Audit) {
+	for _, audit := range audits {
+		// Sin ID el cliente no podría retomar desde Last-Event-ID
+		if audit.ID == 0 {
+			continue
+		}
+		observeAudit(audit)
+		select {
+		case streamHub.incoming <- audit:
+		default:
+			log.Printf("Cola del stream de auditorías llena, se descarta la auditoría %d", audit.ID)
+		}
+	}
+}
+
+// SubscribeAudits crea una suscripción a las auditorías nuevas con los mismos filtros del listado
+func SubscribeAudits(filters map[string]interface{}) *AuditSubscription {
+	subscription := &AuditSubscription{
+		C:       make(chan models.AuditResponse, auditSubscriberBufferSize),
+		filters: filters,
+	}
+
+	streamHub.mu.Lock()
+	streamHub.subscribers[subscription] = struct{}{}
+	streamHub.mu.Unlock()
+	return subscription
+}
+
+// Close cancela la suscripción
+func (s *AuditSubscription) Close() {
+	streamHub.mu.Lock()
+	defer streamHub.mu.Unlock()
+	streamHub.remove(s)
+}
+
+// CloseAuditStreams cierra todas las suscripciones para que las conexiones abiertas terminen
+func CloseAuditStreams() {
+	streamHub.mu.Lock()
+	defer streamHub.mu.Unlock()
+	for subscription := range streamHub.subscribers {
+		streamHub.remove(subscription)
+	}
+}
+
+func (h *auditHub) remove(s *AuditSubscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.C)
+	delete(h.subscribers, s)
+}
+
+func (h *auditHub) run() {
+	for audit := range h.incoming {
+		entry := models.AuditResponse{
+			ID:            audit.ID,
+			Event:         audit.Event,
+			Description:   audit.Description,
+			UserID:        audit.UserID,
+			User:          h.userName(audit.UserID),
+			OriginService: audit.OriginService,
+			Date:          audit.Date,
+			IP:            audit.IP,
+			UserAgent:     audit.UserAgent,
+			RequestID:     audit.RequestID,
+			Method:        audit.Method,
+			Route:         audit.Route,
+			Outcome:       audit.Outcome,
+			FailureReason: audit.FailureReason,
+		}
+
+		h.mu.Lock()
+		for subscription := range h.subscribers {
+			if !matchesAuditFilters(entry, subscription.filters) {
+				continue
+			}
+			select {
+			case subscription.C <- entry:
+			default:
+				// El suscriptor no alcanza a consumir; se desconecta para que retome desde su último ID
+				h.remove(subscription)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *auditHub) userName(userID uint) string {
+	if name, ok := h.userNames[userID]; ok {
+		return name
+	}
+
+	var user models.User
+	if err := config.DB.Select("id, name").First(&user, userID).Error; err != nil {
+		return ""
+	}
+	if len(h.userNames) >= auditStreamUserCacheSize {
+		h.userNames = make(map[uint]string)
+	}
+	h.userNames[userID] = user.Name
+	return user.Name
+}
+
+// matchesAuditFilters aplica en memoria los mismos filtros que auditListQuery
+func matchesAuditFilters(entry models.AuditResponse, filters map[string]interface{}) bool {
+	contains := func(value, filter string) bool {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(filter))
+	}
+
+	if event, ok := filters["event"]; ok && !contains(entry.Event, event.(string)) {
+		return false
+	}
+	if originService, ok := filters["origin_service"]; ok && !strings.EqualFold(entry.OriginService, originService.(string)) {
+		return false
+	}
+	if userID, ok := filters["user_id"]; ok && entry.UserID != userID.(uint) {
+		return false
+	}
+	if userName, ok := filters["userName"]; ok && !contains(entry.User, userName.(string)) {
+		return false
+	}
+	if ip, ok := filters["ip"]; ok && entry.IP != ip.(string) {
+		return false
+	}
+	if requestID, ok := filters["request_id"]; ok && entry.RequestID != requestID.(string) {
+		return false
+	}
+	if method, ok := filters["method"]; ok && !strings.EqualFold(entry.Method, method.(string)) {
+		return false
+	}
+	if route, ok := filters["route"]; ok && !contains(entry.Route, route.(string)) {
+		return false
+	}
+	if outcome, ok := filters["outcome"]; ok && !strings.EqualFold(entry.Outcome, outcome.(string)) {
+		return false
+	}
+	if userAgent, ok := filters["user_agent"]; ok && !contains(entry.UserAgent, userAgent.(string)) {
+		return false
+	}
+	if from, ok := filters["from"]; ok && entry.Date.Before(from.(time.Time)) {
+		return false
+	}
+	if to, ok := filters["to"]; ok && entry.Date.After(to.(time.Time)) {
+		return false
+	}
+	return true
+}
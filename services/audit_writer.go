@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultAuditBufferSize    = 10000
+	defaultAuditFlushSize     = 500
+	defaultAuditFlushInterval = time.Second
+)
+
+// auditWriter acumula auditorías en memoria y las guarda con inserciones masivas
+type auditWriter struct {
+	queue     chan models.Audit
+	flushSize int
+	interval  time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+var (
+	bufferedWriter *auditWriter
+	writerMutex    sync.Mutex
+)
+
+// StartAuditWriter inicia el escritor en segundo plano. El tamaño de la cola, del lote y el intervalo
+// se configuran con AUDIT_BUFFER_SIZE, AUDIT_FLUSH_SIZE y AUDIT_FLUSH_INTERVAL.
+func StartAuditWriter() {
+	writerMutex.Lock()
+	defer writerMutex.Unlock()
+
+	if bufferedWriter != nil {
+		return
+	}
+
+	writer := &auditWriter{
+		queue:     make(chan models.Audit, envInt("AUDIT_BUFFER_SIZE", defaultAuditBufferSize)),
+		flushSize: envInt("AUDIT_FLUSH_SIZE", defaultAuditFlushSize),
+		interval:  defaultAuditFlushInterval,
+		stop:      make(chan struct{}),
+	}
+	if value := os.Getenv("AUDIT_FLUSH_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil && interval > 0 {
+			writer.interval = interval
+		} else {
+			log.Printf("AUDIT_FLUSH_INTERVAL inválido (%s), se usará %s", value, writer.interval)
+		}
+	}
+
+	writer.wg.Add(1)
+	go writer.run()
+	bufferedWriter = writer
+}
+
+// StopAuditWriter guarda las auditorías pendientes y detiene el escritor
+func StopAuditWriter() {
+	writerMutex.Lock()
+	writer := bufferedWriter
+	bufferedWriter = nil
+	writerMutex.Unlock()
+
+	if writer == nil {
+		return
+	}
+	close(writer.stop)
+	writer.wg.Wait()
+}
+
+// EnqueueAudits encola las auditorías para guardarlas en lote. Si el escritor no está iniciado
+// o la cola está llena, las restantes se guardan de inmediato para no perderlas.
+func EnqueueAudits(ctx context.Context, audits []models.Audit) error {
+	writerMutex.Lock()
+	writer := bufferedWriter
+	writerMutex.Unlock()
+
+	if writer == nil {
+		return insertAudits(ctx, audits)
+	}
+
+	for i, audit := range audits {
+		select {
+		case writer.queue <- audit:
+		default:
+			log.Printf("Cola de auditorías llena, se guardan %d registros de forma directa", len(audits)-i)
+			return insertAudits(ctx, audits[i:])
+		}
+	}
+	return nil
+}
+
+func (w *auditWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]models.Audit, 0, w.flushSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := insertAudits(context.Background(), batch); err != nil {
+			log.Printf("Error al guardar el lote de %d auditorías: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case audit := <-w.queue:
+			batch = append(batch, audit)
+			if len(batch) >= w.flushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.stop:
+			// Vaciar lo que quede en la cola antes de terminar
+			for {
+				select {
+				case audit := <-w.queue:
+					batch = append(batch, audit)
+					if len(batch) >= w.flushSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// insertAudits guarda las auditorías en una sola transacción y publica solo las que se insertaron.
+// Las que no tienen clave de idempotencia no pueden chocar con el índice único y se guardan en lotes;
+// las que la tienen se insertan una a una, porque en MySQL una fila omitida por ON DUPLICATE KEY
+// desplaza los IDs que GORM asigna al resto del lote a partir de LastInsertId. Si la transacción
+// falla se reintenta registro por registro para no perder los válidos.
+func insertAudits(ctx context.Context, audits []models.Audit) error {
+	if len(audits) == 0 {
+		return nil
+	}
+
+	var inserted []models.Audit
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		inserted = inserted[:0]
+		var keyed []models.Audit
+		for _, audit := range audits {
+			if audit.IdempotencyKey != nil {
+				keyed = append(keyed, audit)
+				continue
+			}
+			inserted = append(inserted, audit)
+		}
+		if len(inserted) > 0 {
+			if err := tx.CreateInBatches(&inserted, defaultAuditFlushSize).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range keyed {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&keyed[i])
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				inserted = append(inserted, keyed[i])
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		PublishAudits(inserted)
+		return nil
+	}
+
+	// Fuera de la transacción cada inserción se publica desde el callback de GORM; una clave repetida
+	// no afecta filas, queda sin ID y no se publica
+	log.Printf("Error en la inserción masiva de auditorías, se reintenta individualmente: %v", err)
+	var lastErr error
+	for i := range audits {
+		audit := audits[i]
+		audit.ID = 0
+		if err := config.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&audit).Error; err != nil {
+			log.Printf("No se pudo guardar la auditoría %q del servicio %s: %v", audit.Event, audit.OriginService, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("%s inválido (%s), se usará %d", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"seguridad-api/config"
+	"seguridad-api/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupAuditTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Una sola conexión para que todas las consultas vean la misma base en memoria
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Audit{}); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+	drainPublishedAudits()
+}
+
+// drainPublishedAudits vacía la cola del stream; las pruebas no inician el hub que la consume
+func drainPublishedAudits() []models.Audit {
+	var published []models.Audit
+	for {
+		select {
+		case audit := <-streamHub.incoming:
+			published = append(published, audit)
+		default:
+			return published
+		}
+	}
+}
+
+func TestInsertAuditsSkipsRepeatedIdempotencyKey(t *testing.T) {
+	setupAuditTestDB(t)
+	ctx := context.Background()
+	key := "retry-1"
+	newAudit := func(event string, idempotencyKey *string) models.Audit {
+		return models.Audit{Event: event, OriginService: "INVENTARIO", UserID: 1, Date: time.Now().UTC(), IdempotencyKey: idempotencyKey}
+	}
+
+	if err := insertAudits(ctx, []models.Audit{newAudit("INSERT", &key)}); err != nil {
+		t.Fatal(err)
+	}
+	first := drainPublishedAudits()
+
+	// El reintento llega en otro lote, mezclado con auditorías sin clave y con la clave repetida en el mismo lote
+	retry := []models.Audit{newAudit("LOGIN", nil), newAudit("INSERT", &key), newAudit("INSERT", &key), newAudit("LOGOUT", nil)}
+	if err := insertAudits(ctx, retry); err != nil {
+		t.Fatal(err)
+	}
+	second := drainPublishedAudits()
+
+	var keyed int64
+	config.DB.Model(&models.Audit{}).Where("idempotency_key = ?", key).Count(&keyed)
+	if keyed != 1 {
+		t.Errorf("se guardaron %d auditorías con la clave %s, se esperaba 1", keyed, key)
+	}
+	if len(first) != 1 || first[0].IdempotencyKey == nil || *first[0].IdempotencyKey != key {
+		t.Fatalf("el primer lote publicó %+v, se esperaba la auditoría con la clave", first)
+	}
+	if len(second) != 2 {
+		t.Fatalf("el reintento publicó %d auditorías, se esperaban solo las 2 sin clave", len(second))
+	}
+
+	// Cada auditoría publicada debe llevar el ID con el que quedó guardada
+	for _, published := range append(first, second...) {
+		var stored models.Audit
+		if err := config.DB.First(&stored, published.ID).Error; err != nil {
+			t.Fatalf("la auditoría publicada %d no existe: %v", published.ID, err)
+		}
+		if stored.Event != published.Event {
+			t.Errorf("la auditoría %d se publicó como %s pero se guardó como %s", published.ID, published.Event, stored.Event)
+		}
+	}
+}
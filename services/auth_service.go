@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
@@ -86,21 +87,24 @@ const LOCK_DURATION = 15 * time.Minute
 
 //		return token.SignedString([]byte(secretKey))
 //	}
-func Authenticate(email, password, moduleKey string) (string, error) {
+func Authenticate(ctx context.Context, email, password, moduleKey string) (string, error) {
 	var user models.User
 
 	// Verificar si el usuario existe
 	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		RecordSecurityEvent(ctx, models.SecurityEventLoginUnknownUser, nil, email, moduleKey, "")
 		return "", errors.New("usuario o contraseña inválidos")
 	}
 
 	// Verificar si la cuenta está bloqueada
 	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		RecordSecurityEvent(ctx, models.SecurityEventLoginLocked, &user.ID, email, moduleKey, "Bloqueada hasta "+user.LockedUntil.Format(time.RFC3339))
 		return "", errors.New("la cuenta está bloqueada. Inténtelo más tarde")
 	}
 
 	// Verificar si la cuenta está activa
 	if !user.Active {
+		RecordSecurityEvent(ctx, models.SecurityEventLoginInactive, &user.ID, email, moduleKey, "")
 		return "", errors.New("la cuenta está inactiva")
 	}
 
@@ -109,18 +113,25 @@ func Authenticate(email, password, moduleKey string) (string, error) {
 	if err != nil {
 		// Incrementar intentos fallidos
 		user.FailedAttempts++
+		locked := false
 		if user.FailedAttempts >= MAX_ATTEMPTS {
 			lockTime := time.Now().Add(LOCK_DURATION)
 			user.LockedUntil = &lockTime
+			locked = true
+		}
+		config.DB.WithContext(ctx).Save(&user)
+
+		RecordSecurityEvent(ctx, models.SecurityEventLoginFailed, &user.ID, email, moduleKey, fmt.Sprintf("Intento fallido %d de %d", user.FailedAttempts, MAX_ATTEMPTS))
+		if locked {
+			RecordSecurityEvent(ctx, models.SecurityEventAccountLocked, &user.ID, email, moduleKey, "Bloqueada hasta "+user.LockedUntil.Format(time.RFC3339))
 		}
-		config.DB.Save(&user)
 		return "", errors.New("usuario o contraseña inválidos")
 	}
 
 	// Reiniciar intentos fallidos al iniciar sesión correctamente
 	user.FailedAttempts = 0
 	user.LockedUntil = nil
-	config.DB.Save(&user)
+	config.DB.WithContext(ctx).Save(&user)
 
 	// Obtener los roles del usuario
 	var roles []models.Role
@@ -153,6 +164,7 @@ func Authenticate(email, password, moduleKey string) (string, error) {
 	}
 
 	if !hasAccess {
+		RecordSecurityEvent(ctx, models.SecurityEventModuleAccessDenied, &user.ID, email, moduleKey, "")
 		return "", errors.New("no dispone de acceso a este módulo")
 	}
 
@@ -171,7 +183,14 @@ func Authenticate(email, password, moduleKey string) (string, error) {
 		return "", errors.New("clave secreta no definida en .env")
 	}
 
-	return token.SignedString([]byte(secretKey))
+	signedToken, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", err
+	}
+
+	RecordSecurityEvent(ctx, models.SecurityEventLoginSuccess, &user.ID, email, moduleKey, "")
+	recordUserLogin(ctx, user.ID, moduleKey)
+	return signedToken, nil
 }
 
 func generateResetToken() string {
@@ -181,9 +200,10 @@ func generateResetToken() string {
 }
 
 // Enviar correo con enlace de restablecimiento
-func SendPasswordResetEmail(email string) error {
+func SendPasswordResetEmail(ctx context.Context, email string) error {
 	var user models.User
 	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		RecordSecurityEvent(ctx, models.SecurityEventResetUnknownUser, nil, email, "", "")
 		return errors.New("usuario no encontrado")
 	}
 
@@ -192,7 +212,8 @@ func SendPasswordResetEmail(email string) error {
 	expiry := time.Now().Add(30 * time.Minute) // Expira en 30 minutos
 	user.ResetToken = token
 	user.ResetTokenExpiry = &expiry
-	config.DB.Save(&user)
+	config.DB.WithContext(ctx).Save(&user)
+	RecordSecurityEvent(ctx, models.SecurityEventResetRequested, &user.ID, user.Email, "", "Expira "+expiry.Format(time.RFC3339))
 
 	// 🔍 Verificar token generado
 	fmt.Println("Token generado para", user.Email, ":", token)
@@ -230,16 +251,18 @@ func SendPasswordResetEmail(email string) error {
 }
 
 // Validar token y actualizar contraseña
-func ResetPassword(token string, newPassword string) error {
+func ResetPassword(ctx context.Context, token string, newPassword string) error {
 	var user models.User
 
 	// Buscar usuario por token
-	if err := config.DB.Where("reset_token = ?", token).First(&user).Error; err != nil {
+	if token == "" || config.DB.Where("reset_token = ?", token).First(&user).Error != nil {
+		RecordSecurityEvent(ctx, models.SecurityEventResetInvalidToken, nil, "", "", "")
 		return errors.New("token inválido o expirado")
 	}
 
 	// Verificar si el token ha expirado
 	if user.ResetTokenExpiry == nil || time.Now().After(*user.ResetTokenExpiry) {
+		RecordSecurityEvent(ctx, models.SecurityEventResetExpiredToken, &user.ID, user.Email, "", "")
 		return errors.New("el token ha expirado")
 	}
 
@@ -253,10 +276,11 @@ func ResetPassword(token string, newPassword string) error {
 	user.Password = string(hashedPassword)
 	user.ResetToken = ""
 	user.ResetTokenExpiry = nil
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := config.DB.WithContext(ctx).Save(&user).Error; err != nil {
 		return errors.New("error al actualizar la contraseña")
 	}
 
+	RecordSecurityEvent(ctx, models.SecurityEventResetCompleted, &user.ID, user.Email, "", "")
 	return nil
 }
 
@@ -295,3 +319,46 @@ func SendEmail(to, subject, body string) error {
 
 	return nil
 }
+
+// SendEmailWithAttachment envía un correo con un archivo adjunto a varios destinatarios
+func SendEmailWithAttachment(to []string, subject, body, fileName, contentType string, content []byte) error {
+	apiKey := os.Getenv("SENDGRID_API_KEY")
+	if apiKey == "" {
+		log.Println("ERROR: `SENDGRID_API_KEY` no está configurado en las variables de entorno.")
+		return fmt.Errorf("configuración de SendGrid incorrecta")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("el correo no tiene destinatarios")
+	}
+
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail("Security Service", "sheremypavon12@gmail.com"))
+	message.Subject = subject
+
+	personalization := mail.NewPersonalization()
+	for _, address := range to {
+		personalization.AddTos(mail.NewEmail("", address))
+	}
+	message.AddPersonalizations(personalization)
+	message.AddContent(mail.NewContent("text/plain", body))
+
+	attachment := mail.NewAttachment().
+		SetContent(base64.StdEncoding.EncodeToString(content)).
+		SetType(contentType).
+		SetFilename(fileName).
+		SetDisposition("attachment")
+	message.AddAttachment(attachment)
+
+	response, err := sendgrid.NewSendClient(apiKey).Send(message)
+	if err != nil {
+		log.Printf("Error al enviar correo: %v", err)
+		return err
+	}
+	if response.StatusCode >= 400 {
+		log.Printf("Error en el envío: Código %d - %s", response.StatusCode, response.Body)
+		return fmt.Errorf("error en el envío del correo, código %d", response.StatusCode)
+	}
+
+	log.Printf("Correo con adjunto %s enviado a %d destinatario(s)", fileName, len(to))
+	return nil
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ChangeActionCreate = "CREATE"
+	ChangeActionUpdate = "UPDATE"
+	ChangeActionDelete = "DELETE"
+
+	changeSnapshotKey = "audit:before_snapshot"
+	redactedValue     = "[REDACTED]"
+)
+
+// Entidades cuyo cambio se registra automáticamente
+var trackedEntities = map[string]bool{
+	"User":           true,
+	"Role":           true,
+	"Permission":     true,
+	"Module":         true,
+	"UserRole":       true,
+	"RolePermission": true,
+}
+
+// Columnas cuyo valor nunca se guarda en el historial
+var redactedColumns = map[string]bool{
+	"password":           true,
+	"reset_token":        true,
+	"reset_token_expiry": true,
+}
+
+type entitySnapshot struct {
+	id     uint
+	values map[string]interface{}
+}
+
+// RegisterChangeCapture registra los callbacks de GORM que guardan el diff de cada entidad auditada
+func RegisterChangeCapture(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:capture_create", captureCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:snapshot_update", loadBeforeSnapshot); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("audit:capture_update", captureUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("audit:snapshot_delete", loadBeforeSnapshot); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("audit:capture_delete", captureDelete)
+}
+
+func isTracked(db *gorm.DB) bool {
+	return db.Statement.Schema != nil && trackedEntities[db.Statement.Schema.Name]
+}
+
+func captureCreate(db *gorm.DB) {
+	if db.Error != nil || !isTracked(db) {
+		return
+	}
+
+	for _, after := range snapshotsFromValue(db) {
+		saveChange(db, ChangeActionCreate, after.id, diffSnapshots(nil, after.values))
+	}
+}
+
+func captureUpdate(db *gorm.DB) {
+	if db.Error != nil || !isTracked(db) {
+		return
+	}
+
+	before := instanceSnapshots(db)
+	if len(before) == 0 {
+		return
+	}
+
+	ids := make([]interface{}, 0, len(before))
+	for _, snapshot := range before {
+		ids = append(ids, snapshot.id)
+	}
+
+	after := indexSnapshots(querySnapshots(db, nil, ids))
+	for _, old := range before {
+		current, ok := after[old.id]
+		if !ok {
+			continue
+		}
+		saveChange(db, ChangeActionUpdate, old.id, diffSnapshots(old.values, current.values))
+	}
+}
+
+func captureDelete(db *gorm.DB) {
+	if db.Error != nil || !isTracked(db) {
+		return
+	}
+
+	for _, old := range instanceSnapshots(db) {
+		saveChange(db, ChangeActionDelete, old.id, diffSnapshots(old.values, nil))
+	}
+}
+
+// loadBeforeSnapshot guarda el estado de las filas afectadas antes de modificarlas
+func loadBeforeSnapshot(db *gorm.DB) {
+	if db.Error != nil || !isTracked(db) {
+		return
+	}
+
+	var where *clause.Where
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			where = &w
+		}
+	}
+
+	ids := primaryKeysFromValue(db)
+	if where == nil && len(ids) == 0 {
+		return
+	}
+
+	db.InstanceSet(changeSnapshotKey, querySnapshots(db, where, ids))
+}
+
+func instanceSnapshots(db *gorm.DB) []entitySnapshot {
+	value, ok := db.InstanceGet(changeSnapshotKey)
+	if !ok {
+		return nil
+	}
+	snapshots, _ := value.([]entitySnapshot)
+	return snapshots
+}
+
+// querySnapshots lee desde la base de datos las filas que cumplen la condición dentro de la misma transacción
+func querySnapshots(db *gorm.DB, where *clause.Where, ids []interface{}) []entitySnapshot {
+	sch := db.Statement.Schema
+	if sch.PrioritizedPrimaryField == nil {
+		return nil
+	}
+
+	records := reflect.New(reflect.SliceOf(sch.ModelType))
+	tx := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Table)
+	if where != nil {
+		tx = tx.Clauses(*where)
+	}
+	if len(ids) > 0 {
+		tx = tx.Where(clause.IN{
+			Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName},
+			Values: ids,
+		})
+	}
+	if err := tx.Find(records.Interface()).Error; err != nil {
+		db.AddError(fmt.Errorf("error al obtener el estado previo de %s: %w", sch.Name, err))
+		return nil
+	}
+
+	var snapshots []entitySnapshot
+	rows := records.Elem()
+	for i := 0; i < rows.Len(); i++ {
+		snapshots = append(snapshots, takeSnapshot(db, rows.Index(i)))
+	}
+	return snapshots
+}
+
+func snapshotsFromValue(db *gorm.DB) []entitySnapshot {
+	var snapshots []entitySnapshot
+	eachModelValue(db.Statement.ReflectValue, func(value reflect.Value) {
+		snapshots = append(snapshots, takeSnapshot(db, value))
+	})
+	return snapshots
+}
+
+func primaryKeysFromValue(db *gorm.DB) []interface{} {
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil
+	}
+
+	var ids []interface{}
+	eachModelValue(db.Statement.ReflectValue, func(value reflect.Value) {
+		if id, zero := pk.ValueOf(db.Statement.Context, value); !zero {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+func eachModelValue(value reflect.Value, fn func(reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		fn(value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fn(reflect.Indirect(value.Index(i)))
+		}
+	}
+}
+
+// takeSnapshot convierte una fila en un mapa columna → valor, sin relaciones ni marcas de tiempo automáticas
+func takeSnapshot(db *gorm.DB, value reflect.Value) entitySnapshot {
+	sch := db.Statement.Schema
+	snapshot := entitySnapshot{values: map[string]interface{}{}}
+
+	for _, field := range sch.Fields {
+		if field.DBName == "" || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+			continue
+		}
+
+		fieldValue, _ := field.ValueOf(db.Statement.Context, value)
+		if rv := reflect.ValueOf(fieldValue); rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				fieldValue = nil
+			} else {
+				fieldValue = rv.Elem().Interface()
+			}
+		}
+		snapshot.values[field.DBName] = fieldValue
+
+		if field == sch.PrioritizedPrimaryField {
+			id, _ := strconv.ParseUint(fmt.Sprint(fieldValue), 10, 64)
+			snapshot.id = uint(id)
+		}
+	}
+
+	return snapshot
+}
+
+func indexSnapshots(snapshots []entitySnapshot) map[uint]entitySnapshot {
+	indexed := make(map[uint]entitySnapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		indexed[snapshot.id] = snapshot
+	}
+	return indexed
+}
+
+// diffSnapshots devuelve únicamente los campos que cambiaron, ocultando los valores sensibles
+func diffSnapshots(before, after map[string]interface{}) map[string]models.FieldChange {
+	changes := map[string]models.FieldChange{}
+
+	columns := map[string]bool{}
+	for column := range before {
+		columns[column] = true
+	}
+	for column := range after {
+		columns[column] = true
+	}
+
+	for column := range columns {
+		oldValue, newValue := before[column], after[column]
+		if sameValue(oldValue, newValue) && before != nil && after != nil {
+			continue
+		}
+
+		if redactedColumns[column] {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		changes[column] = models.FieldChange{Old: oldValue, New: newValue}
+	}
+
+	return changes
+}
+
+func sameValue(a, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Equal(bt)
+		}
+	}
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
+
+func redact(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return redactedValue
+}
+
+func saveChange(db *gorm.DB, action string, entityID uint, changes map[string]models.FieldChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		db.AddError(fmt.Errorf("error al serializar los cambios: %w", err))
+		return
+	}
+
+	change := models.AuditChange{
+		EntityType: db.Statement.Schema.Name,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    string(payload),
+		UserID:     ActorFromContext(db.Statement.Context),
+		Date:       time.Now().UTC(),
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&change).Error; err != nil {
+		db.AddError(fmt.Errorf("error al registrar el cambio de %s: %w", change.EntityType, err))
+	}
+}
+
+// ActorFromContext obtiene el ID del usuario autenticado guardado por AuthMiddleware
+func ActorFromContext(ctx context.Context) uint {
+	if ctx == nil {
+		return 0
+	}
+
+	switch id := ctx.Value("userID").(type) {
+	case float64:
+		return uint(id)
+	case uint:
+		return id
+	case int:
+		return uint(id)
+	}
+	return 0
+}
+
+// GetPaginatedAuditChanges obtiene el historial de cambios con filtros por entidad, acción y usuario
+func GetPaginatedAuditChanges(page, pageSize int, filters map[string]interface{}) ([]models.AuditChangeResponse, int64, error) {
+	var changes []models.AuditChangeResponse
+	var total int64
+
+	query := config.DB.Model(&models.AuditChange{}).
+		Joins("LEFT JOIN users ON users.id = audit_changes.user_id").
+		Select("audit_changes.id, audit_changes.entity_type, audit_changes.entity_id, audit_changes.action, audit_changes.changes, audit_changes.user_id, COALESCE(users.name, '') AS user, audit_changes.date")
+
+	if entityType, ok := filters["entity_type"]; ok {
+		query = query.Where("audit_changes.entity_type = ?", entityType)
+	}
+	if entityID, ok := filters["entity_id"]; ok {
+		query = query.Where("audit_changes.entity_id = ?", entityID)
+	}
+	if action, ok := filters["action"]; ok {
+		query = query.Where("audit_changes.action = ?", action)
+	}
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("audit_changes.user_id = ?", userID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("audit_changes.id DESC").Offset(offset).Limit(pageSize).Scan(&changes).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range changes {
+		changes[i].Changes = json.RawMessage(changes[i].RawChanges)
+	}
+
+	return changes, total, nil
+}
@@ -1,40 +1,96 @@
 package services
 
 import (
-	"seguridad-api/config"
+	"context"
+	"errors"
+	"fmt"
 	"seguridad-api/models"
-	"time"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
-func ChargeFastPermissions(inputs []models.Permission, userID uint) ([]models.Permission, []string, error) {
-	var createdPermissions []models.Permission
-	var auditErrors []string
+// FastPermissionInput es un permiso de la carga rápida. Active en nil crea el permiso activo y, al
+// actualizar, conserva el estado actual; Description vacía al actualizar conserva la descripción.
+type FastPermissionInput struct {
+	Name        string
+	Description string
+	ModuleID    uint
+	Active      *bool
+}
+
+// ChargeFastPermissions crea los permisos de la lista según las opciones de la carga. La clave natural
+// de un permiso es su módulo y su nombre; con Upsert un permiso existente se actualiza.
+func ChargeFastPermissions(ctx context.Context, inputs []FastPermissionInput, options FastChargeOptions, userID uint) (*FastChargeReport, error) {
+	firstRow := make(map[string]int, len(inputs))
+
+	return runFastCharge(ctx, options, len(inputs), userID, func(tx *gorm.DB, i int, row *FastChargeRow) error {
+		input := inputs[i]
+		name := strings.TrimSpace(input.Name)
+		row.Key = fmt.Sprintf("%d/%s", input.ModuleID, name)
 
-	for _, input := range inputs {
-		input.ID = 0
-		input.CreatedAt = time.Now()
-		input.UpdatedAt = time.Now()
+		var problems []string
+		if name == "" {
+			problems = append(problems, "El nombre es obligatorio")
+		} else if len(name) > 100 {
+			problems = append(problems, "El nombre supera los 100 caracteres")
+		}
+		if len(input.Description) > 255 {
+			problems = append(problems, "La descripción supera los 255 caracteres")
+		}
+		if input.ModuleID == 0 {
+			problems = append(problems, "El módulo es obligatorio")
+		}
+		if len(problems) > 0 {
+			return errors.New(strings.Join(problems, "; "))
+		}
 
-		if err := config.DB.Create(&input).Error; err != nil {
-			return nil, nil, err
+		key := fmt.Sprintf("%d/%s", input.ModuleID, strings.ToLower(name))
+		if first, ok := firstRow[key]; ok {
+			return fmt.Errorf("El permiso está repetido en la fila %d", first)
 		}
+		firstRow[key] = row.Row
 
-		createdPermissions = append(createdPermissions, input)
+		var modules int64
+		if err := tx.Model(&models.Module{}).Where("id = ?", input.ModuleID).Count(&modules).Error; err != nil {
+			return err
+		}
+		if modules == 0 {
+			return fmt.Errorf("El módulo %d no existe", input.ModuleID)
+		}
 
-		event := "INSERT"
-		description := "Se creó un permiso con el nombre: " + input.Name
-		audit := models.Audit{
-			Event:         event,
-			Description:   description,
-			UserID:        userID,
-			OriginService: "SEGURIDAD",
-			Date:          time.Now(),
+		var existing models.Permission
+		if err := tx.Where("module_id = ? AND LOWER(name) = ?", input.ModuleID, strings.ToLower(name)).Limit(1).Find(&existing).Error; err != nil {
+			return err
 		}
 
-		if err := config.DB.Create(&audit).Error; err != nil {
-			auditErrors = append(auditErrors, "Error en auditoría para permiso: "+input.Name)
+		if existing.ID == 0 {
+			permission := models.Permission{Name: name, Description: input.Description, ModuleID: input.ModuleID}
+			if err := createKeepingActive(tx, &permission, input.Active == nil || *input.Active); err != nil {
+				return err
+			}
+			row.Action, row.ID = "INSERT", permission.ID
+			row.audit = "Se creó un permiso con el nombre: " + name
+			return nil
 		}
-	}
 
-	return createdPermissions, auditErrors, nil
+		if !options.Upsert {
+			return fmt.Errorf("Ya existe el permiso %s en el módulo %d", existing.Name, input.ModuleID)
+		}
+		updates := map[string]interface{}{}
+		if input.Description != "" {
+			updates["description"] = input.Description
+		}
+		if input.Active != nil {
+			updates["active"] = *input.Active
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		row.Action, row.ID = "UPDATE", existing.ID
+		row.audit = "Se actualizó por carga rápida el permiso con el nombre: " + name
+		return nil
+	})
 }
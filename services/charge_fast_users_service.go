@@ -1,40 +1,125 @@
 package services
 
 import (
-	"strconv"
-	"time"
+	"context"
+	"errors"
+	"fmt"
+	"seguridad-api/models"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
-func ChargeFastUsers(users []struct {
+// FastUserInput es un usuario de la carga rápida. Active en nil crea el usuario activo y, al actualizar,
+// conserva el estado actual; Password vacío al actualizar conserva la contraseña.
+type FastUserInput struct {
 	Name     string
 	Email    string
 	Password string
-	Active   bool
+	Active   *bool
 	RoleID   uint
-}, userID uint) error {
-	currentTime := time.Now()
+}
 
-	for _, userInput := range users {
-		user, err := CreateUser(userInput.Name, userInput.Email, userInput.Password, userInput.Active)
-		if err != nil {
-			return err
+// ChargeFastUsers crea los usuarios de la lista y les asigna su rol según las opciones de la carga. Con
+// Upsert un correo ya registrado actualiza el usuario y le agrega el rol si no lo tenía.
+func ChargeFastUsers(ctx context.Context, users []FastUserInput, options FastChargeOptions, userID uint) (*FastChargeReport, error) {
+	if err := checkFastChargeSize(len(users)); err != nil {
+		return nil, err
+	}
+	// bcrypt tarda decenas de milisegundos por contraseña; se encriptan antes de abrir la transacción para
+	// no mantenerla abierta mientras tanto. Una contraseña que no se pudo encriptar queda vacía.
+	passwords := make([]string, len(users))
+	for i, input := range users {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if input.Password == "" {
+			continue
+		}
+		if hashedPassword, err := hashPassword(input.Password); err == nil {
+			passwords[i] = hashedPassword
+		}
+	}
+	firstRow := make(map[string]int, len(users))
+
+	return runFastCharge(ctx, options, len(users), userID, func(tx *gorm.DB, i int, row *FastChargeRow) error {
+		input := users[i]
+		name, email := strings.TrimSpace(input.Name), strings.TrimSpace(input.Email)
+		row.Key = email
+
+		var problems []string
+		if name == "" {
+			problems = append(problems, "El nombre es obligatorio")
+		}
+		if !isValidEmail(email) {
+			problems = append(problems, "El correo no tiene un formato válido")
+		}
+		if input.RoleID == 0 {
+			problems = append(problems, "El rol es obligatorio")
+		}
+		if len(problems) > 0 {
+			return errors.New(strings.Join(problems, "; "))
 		}
 
-		err = RegisterAudit("INSERT", "Se creó un usuario con email: "+userInput.Email, userID, "SEGURIDAD", currentTime)
-		if err != nil {
-			return err
+		key := strings.ToLower(email)
+		if first, ok := firstRow[key]; ok {
+			return fmt.Errorf("El correo está repetido en la fila %d", first)
 		}
+		firstRow[key] = row.Row
 
-		_, err = AssignRoleToUser(user.ID, userInput.RoleID)
-		if err != nil {
+		var roles int64
+		if err := tx.Model(&models.Role{}).Where("id = ?", input.RoleID).Count(&roles).Error; err != nil {
 			return err
 		}
+		if roles == 0 {
+			return fmt.Errorf("El rol %d no existe", input.RoleID)
+		}
 
-		err = RegisterAudit("INSERT", "Se asignó el rol "+strconv.Itoa(int(userInput.RoleID))+" al usuario con email: "+userInput.Email, userID, "SEGURIDAD", currentTime)
-		if err != nil {
+		var existing models.User
+		if err := tx.Where("LOWER(email) = ?", key).Limit(1).Find(&existing).Error; err != nil {
 			return err
 		}
-	}
 
-	return nil
+		if input.Password != "" && passwords[i] == "" {
+			return errors.New("error al encriptar la contraseña")
+		}
+		if existing.ID == 0 {
+			if input.Password == "" {
+				return errors.New("La contraseña es obligatoria")
+			}
+			user := models.User{Name: name, Email: email, Password: passwords[i], Active: input.Active == nil || *input.Active}
+			if err := createUserWithRole(tx, &user, input.RoleID); err != nil {
+				return err
+			}
+			row.Action, row.ID = "INSERT", user.ID
+			row.audit = fmt.Sprintf("Se creó un usuario con el email: %s y se le asignó el rol %d", email, input.RoleID)
+			return nil
+		}
+
+		if !options.Upsert {
+			return errors.New("Ya existe un usuario con ese correo")
+		}
+		updates := map[string]interface{}{"name": name}
+		if input.Active != nil {
+			updates["active"] = *input.Active
+		}
+		if input.Password != "" {
+			updates["password"] = passwords[i]
+		}
+		if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		var assigned int64
+		if err := tx.Model(&models.UserRole{}).Where("user_id = ? AND role_id = ?", existing.ID, input.RoleID).Count(&assigned).Error; err != nil {
+			return err
+		}
+		if assigned == 0 {
+			if err := tx.Create(&models.UserRole{UserID: existing.ID, RoleID: input.RoleID}).Error; err != nil {
+				return err
+			}
+		}
+		row.Action, row.ID = "UPDATE", existing.ID
+		row.audit = fmt.Sprintf("Se actualizó el usuario con el email: %s por carga rápida y se le asignó el rol %d", email, input.RoleID)
+		return nil
+	})
 }
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	helpers "seguridad-api/helpers"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Modos de las cargas rápidas: en transaction un error en cualquier fila revierte toda la carga; en
+// continue las filas con error se descartan y las demás se guardan
+const (
+	FastChargeTransaction = "transaction"
+	FastChargeContinue    = "continue"
+)
+
+// Estados de cada fila de una carga rápida
+const (
+	FastChargeCreated    = "Creado"
+	FastChargeUpdated    = "Actualizado"
+	FastChargeValid      = "Válido"
+	FastChargeRolledBack = "Revertido"
+	FastChargeError      = "Error"
+)
+
+// errFastChargeRollback descarta la transacción de una prueba o de una carga con errores
+var errFastChargeRollback = errors.New("carga revertida")
+
+// defaultFastChargeMaxRows es el máximo de filas por carga (FAST_CHARGE_MAX_ROWS); todas las filas se
+// aplican en una sola transacción
+const defaultFastChargeMaxRows = 1000
+
+var ErrFastChargeTooLarge = errors.New("la carga supera el máximo de filas")
+
+// checkFastChargeSize rechaza una carga con más filas que FAST_CHARGE_MAX_ROWS
+func checkFastChargeSize(total int) error {
+	if maxRows := envInt("FAST_CHARGE_MAX_ROWS", defaultFastChargeMaxRows); total > maxRows {
+		return fmt.Errorf("%w: la lista tiene %d filas; se admiten como máximo %d por carga", ErrFastChargeTooLarge, total, maxRows)
+	}
+	return nil
+}
+
+// FastChargeOptions indica cómo se aplica una carga rápida. Con DryRun se valida cada fila contra la base
+// sin guardar nada; con Upsert los registros que ya existen (por correo o por módulo y nombre) se
+// actualizan en lugar de rechazarse.
+type FastChargeOptions struct {
+	DryRun bool
+	Mode   string
+	Upsert bool
+}
+
+// Validate completa el modo por defecto y rechaza un modo desconocido
+func (o *FastChargeOptions) Validate() error {
+	if o.Mode == "" {
+		o.Mode = FastChargeTransaction
+	}
+	if o.Mode != FastChargeTransaction && o.Mode != FastChargeContinue {
+		return fmt.Errorf("modo no soportado: %s (use %s o %s)", o.Mode, FastChargeTransaction, FastChargeContinue)
+	}
+	return nil
+}
+
+// FastChargeRow es el resultado de un elemento de la carga; Row es su posición en la lista (desde 1) y
+// Key su clave natural
+type FastChargeRow struct {
+	Row     int    `json:"row"`
+	Key     string `json:"key"`
+	Action  string `json:"action,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	ID      uint   `json:"id,omitempty"`
+
+	// audit es la descripción que se registra en la auditoría si la fila se guarda
+	audit string
+}
+
+// FastChargeReport es el resultado de una carga rápida. Created y Updated cuentan las filas válidas por
+// acción aunque la carga no se haya guardado; Committed indica si los cambios quedaron en la base.
+type FastChargeReport struct {
+	DryRun      bool            `json:"dry_run"`
+	Mode        string          `json:"mode"`
+	Upsert      bool            `json:"upsert"`
+	Committed   bool            `json:"committed"`
+	Total       int             `json:"total"`
+	Created     int             `json:"created"`
+	Updated     int             `json:"updated"`
+	Failed      int             `json:"failed"`
+	Rows        []FastChargeRow `json:"rows"`
+	AuditErrors []string        `json:"audit_errors,omitempty"`
+}
+
+// runFastCharge aplica las filas en una sola transacción; cada fila usa un punto de guardado para
+// descartarla sin perder las anteriores. apply devuelve el error de la fila y completa su acción, ID y
+// descripción de auditoría. La auditoría se registra solo cuando la transacción se confirma. Una carga
+// con más filas que FAST_CHARGE_MAX_ROWS devuelve ErrFastChargeTooLarge sin abrir la transacción.
+func runFastCharge(ctx context.Context, options FastChargeOptions, total int, userID uint, apply func(tx *gorm.DB, i int, row *FastChargeRow) error) (*FastChargeReport, error) {
+	if err := checkFastChargeSize(total); err != nil {
+		return nil, err
+	}
+	report := &FastChargeReport{DryRun: options.DryRun, Mode: options.Mode, Upsert: options.Upsert, Total: total, Rows: make([]FastChargeRow, total)}
+
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range report.Rows {
+			row := &report.Rows[i]
+			row.Row = i + 1
+			if err := tx.Transaction(func(rowTx *gorm.DB) error { return apply(rowTx, i, row) }); err != nil {
+				row.Status, row.Message, row.Action = FastChargeError, err.Error(), ""
+				report.Failed++
+			}
+		}
+		if options.DryRun || (options.Mode == FastChargeTransaction && report.Failed > 0) {
+			return errFastChargeRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFastChargeRollback) {
+		return nil, err
+	}
+	report.Committed = err == nil
+
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		if row.Status == FastChargeError {
+			continue
+		}
+		if row.Action == "INSERT" {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+
+		switch {
+		case report.Committed && row.Action == "INSERT":
+			row.Status = FastChargeCreated
+		case report.Committed:
+			row.Status = FastChargeUpdated
+		case options.DryRun:
+			row.Status = FastChargeValid
+		default:
+			row.Status = FastChargeRolledBack
+			row.Message = "La carga se revirtió por errores en otras filas"
+		}
+		// El ID de un registro nuevo no existe si la carga no se guardó
+		if !report.Committed && row.Action == "INSERT" {
+			row.ID = 0
+		}
+
+		if report.Committed {
+			if auditErr := RegisterAudit(ctx, row.Action, row.audit, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+				report.AuditErrors = append(report.AuditErrors, fmt.Sprintf("Error en auditoría para la fila %d (%s)", row.Row, row.Key))
+			}
+		}
+	}
+	return report, nil
+}
+
+// createKeepingActive crea el registro respetando active. GORM reemplaza un campo en false por el valor
+// por defecto de la columna (true), por eso un registro inactivo se actualiza después de crearlo.
+func createKeepingActive(tx *gorm.DB, value interface{}, active bool) error {
+	if err := tx.Create(value).Error; err != nil {
+		return err
+	}
+	if active {
+		return nil
+	}
+	return tx.Model(value).Update("active", false).Error
+}
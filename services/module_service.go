@@ -1,19 +1,20 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"seguridad-api/config"
 	"seguridad-api/models"
 )
 
-func CreateModule(name, description string, active bool) error {
+func CreateModule(ctx context.Context, name, description string, active bool) error {
 	module := models.Module{
 		Name:        name,
 		Description: description,
 		Active:      active,
 	}
 
-	result := config.DB.Create(&module)
+	result := config.DB.WithContext(ctx).Create(&module)
 	return result.Error
 }
 
@@ -42,7 +43,7 @@ func GetAllModules() ([]models.Module, error) {
 	return modules, nil
 }
 
-func UpdateModule(id uint, name, description *string, active *bool) error {
+func UpdateModule(ctx context.Context, id uint, name, description *string, active *bool) error {
 	var module models.Module
 	if err := config.DB.First(&module, id).Error; err != nil {
 		return errors.New("module not found")
@@ -58,17 +59,17 @@ func UpdateModule(id uint, name, description *string, active *bool) error {
 		module.Active = *active
 	}
 
-	result := config.DB.Save(&module)
+	result := config.DB.WithContext(ctx).Save(&module)
 	return result.Error
 }
 
-func DeleteModule(id uint) error {
+func DeleteModule(ctx context.Context, id uint) error {
 	var module models.Module
 	if err := config.DB.First(&module, id).Error; err != nil {
 		return errors.New("module not found")
 	}
 
-	result := config.DB.Delete(&module)
+	result := config.DB.WithContext(ctx).Delete(&module)
 	return result.Error
 }
 
@@ -1,12 +1,13 @@
 package services
 
 import (
+	"context"
 	"seguridad-api/config"
 	"seguridad-api/models"
 )
 
-func CreatePermission(permission models.Permission) (models.Permission, error) {
-	if err := config.DB.Create(&permission).Error; err != nil {
+func CreatePermission(ctx context.Context, permission models.Permission) (models.Permission, error) {
+	if err := config.DB.WithContext(ctx).Create(&permission).Error; err != nil {
 		return models.Permission{}, err
 	}
 	return permission, nil
@@ -56,7 +57,7 @@ func GetPaginatedPermissions(page, pageSize int, filters map[string]interface{})
 	return permissions, total, nil
 }
 
-func UpdatePermission(id string, updatedPermission models.Permission) (models.Permission, error) {
+func UpdatePermission(ctx context.Context, id string, updatedPermission models.Permission) (models.Permission, error) {
 	var permission models.Permission
 	if err := config.DB.First(&permission, "id = ?", id).Error; err != nil {
 		return models.Permission{}, err
@@ -65,14 +66,14 @@ func UpdatePermission(id string, updatedPermission models.Permission) (models.Pe
 	permission.Description = updatedPermission.Description
 	permission.ModuleID = updatedPermission.ModuleID
 	permission.Active = updatedPermission.Active
-	if err := config.DB.Save(&permission).Error; err != nil {
+	if err := config.DB.WithContext(ctx).Save(&permission).Error; err != nil {
 		return models.Permission{}, err
 	}
 	return permission, nil
 }
 
-func DeletePermission(id string) error {
-	if err := config.DB.Delete(&models.Permission{}, id).Error; err != nil {
+func DeletePermission(ctx context.Context, id string) error {
+	if err := config.DB.WithContext(ctx).Delete(&models.Permission{}, id).Error; err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"seguridad-api/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccessMatrixModel = "AccessMatrix"
+	// Opciones del reporte de matriz: usuarios × permisos (por defecto) o roles × permisos
+	AccessMatrixUsers = "usuarios"
+	AccessMatrixRoles = "roles"
+
+	accessGranted = "Sí"
+)
+
+type accessGrant struct {
+	RowID        uint
+	RowName      string
+	Email        string
+	RoleName     string
+	PermissionID uint
+}
+
+// newAccessMatrixSource arma la matriz de accesos efectivos de un módulo: solo cuentan los roles y
+// permisos activos, igual que en la validación de permisos. Cada fila es un usuario (la celda indica por
+// qué roles tiene el permiso) o un rol; se listan solo los que tienen algún permiso del módulo.
+func newAccessMatrixSource(filters reportFilters, option string, theme utils.ReportTheme) (*reportSource, error) {
+	if option == "" {
+		option = AccessMatrixUsers
+	}
+	if option != AccessMatrixUsers && option != AccessMatrixRoles {
+		return nil, fmt.Errorf("opción no soportada para la matriz de accesos: %s", option)
+	}
+
+	moduleID, ok := filters.value("module_id")
+	if !ok {
+		return nil, &FilterError{Message: "la matriz de accesos requiere el filtro module_id", Allowed: AllowedReportFilters(AccessMatrixModel)}
+	}
+	var module models.Module
+	if err := config.DB.First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("módulo no encontrado")
+		}
+		return nil, err
+	}
+
+	var permissions []models.Permission
+	if err := config.DB.Where("module_id = ? AND active = ?", moduleID, true).Order("name, id").Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("error al consultar los permisos: %w", err)
+	}
+
+	headers, keys := []string{theme.T("Rol")}, []string{"role"}
+	title := theme.Tf("Matriz de accesos por rol - %s", module.Name)
+	if option == AccessMatrixUsers {
+		headers, keys = []string{theme.T("Usuario"), theme.T("Correo Electrónico")}, []string{"user", "email"}
+		title = theme.Tf("Matriz de accesos por usuario - %s", module.Name)
+	}
+	granted := theme.T(accessGranted)
+	labelColumns := len(headers)
+
+	// Los nombres de permiso pueden repetirse dentro del módulo; las columnas deben ser únicas para JSON
+	columns := make(map[uint]int, len(permissions))
+	names := make(map[string]int)
+	for _, permission := range permissions {
+		names[permission.Name]++
+	}
+	for i, permission := range permissions {
+		header := permission.Name
+		if names[header] > 1 {
+			header = fmt.Sprintf("%s (#%d)", header, permission.ID)
+		}
+		headers, keys = append(headers, header), append(keys, header)
+		columns[permission.ID] = labelColumns + i
+	}
+
+	grants := config.DB.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("permissions.module_id = ? AND permissions.active = ? AND roles.active = ?", moduleID, true, true)
+	if option == AccessMatrixUsers {
+		grants = grants.
+			Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+			Joins("JOIN users ON users.id = user_roles.user_id").
+			Where("users.active = ?", true)
+	}
+
+	rowKey := "roles.id"
+	selectColumns := "roles.id AS row_id, roles.name AS row_name, '' AS email, roles.name AS role_name, permissions.id AS permission_id"
+	order := "roles.name, roles.id"
+	if option == AccessMatrixUsers {
+		rowKey = "users.id"
+		selectColumns = "users.id AS row_id, users.name AS row_name, users.email AS email, roles.name AS role_name, permissions.id AS permission_id"
+		order = "users.name, users.id, roles.name"
+	}
+
+	return &reportSource{
+		headers:      headers,
+		keys:         keys,
+		title:        title,
+		matrixLabels: labelColumns,
+		groupColumn:  -1,
+		abbreviate:   option == AccessMatrixUsers,
+		count: func() (int64, error) {
+			var total int64
+			err := grants.Session(&gorm.Session{}).Distinct(rowKey).Count(&total).Error
+			return total, err
+		},
+		each: func(fn func(row []string) error) error {
+			rows, err := grants.Session(&gorm.Session{}).Select(selectColumns).Order(order).Rows()
+			if err != nil {
+				return fmt.Errorf("error al consultar los datos: %w", err)
+			}
+			defer rows.Close()
+
+			// Las filas llegan ordenadas por usuario o rol; cada grupo consecutivo forma una fila de la matriz
+			var current []string
+			var currentID uint
+			for rows.Next() {
+				var grant accessGrant
+				if err := config.DB.ScanRows(rows, &grant); err != nil {
+					return fmt.Errorf("error al consultar los datos: %w", err)
+				}
+				if current == nil || grant.RowID != currentID {
+					if current != nil {
+						if err := fn(current); err != nil {
+							return err
+						}
+					}
+					currentID = grant.RowID
+					current = make([]string, len(headers))
+					current[0] = grant.RowName
+					if option == AccessMatrixUsers {
+						current[1] = grant.Email
+					}
+				}
+
+				column := columns[grant.PermissionID]
+				if option == AccessMatrixRoles {
+					current[column] = granted
+				} else if !containsRole(current[column], grant.RoleName) {
+					if current[column] != "" {
+						current[column] += ", "
+					}
+					current[column] += grant.RoleName
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("error al consultar los datos: %w", err)
+			}
+			if current != nil {
+				return fn(current)
+			}
+			return nil
+		},
+	}, nil
+}
+
+func containsRole(cell, role string) bool {
+	for _, name := range strings.Split(cell, ", ") {
+		if name == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"seguridad-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccountRiskModel = "AccountRisk"
+
+	// Riesgos del reporte de cuentas; sin el filtro risk se listan las cuentas con cualquiera de ellos
+	RiskDormant        = "dormant"
+	RiskInactiveModule = "inactive_module"
+	RiskLocked         = "locked"
+	RiskPendingReset   = "pending_reset"
+	RiskExcessRoles    = "excess_roles"
+
+	defaultInactiveDays = 90
+	defaultMaxRoles     = 5
+	// maxBulkDeactivate limita las cuentas que se desactivan de una vez desde el reporte
+	maxBulkDeactivate = 1000
+)
+
+var (
+	ErrNoRiskyAccounts      = errors.New("no hay cuentas activas para desactivar con esos filtros")
+	ErrTooManyRiskyAccounts = fmt.Errorf("el resultado supera las %d cuentas que se pueden desactivar de una vez; agregue filtros o indique user_ids", maxBulkDeactivate)
+)
+
+var accountRisks = []string{RiskDormant, RiskInactiveModule, RiskLocked, RiskPendingReset, RiskExcessRoles}
+
+// accountRiskLabels son los textos de cada riesgo en el reporte; se escriben en español y se traducen
+var accountRiskLabels = map[string]string{
+	RiskDormant:        "Cuenta sin uso",
+	RiskInactiveModule: "Rol en módulo inactivo",
+	RiskLocked:         "Cuenta bloqueada",
+	RiskPendingReset:   "Restablecimiento pendiente",
+	RiskExcessRoles:    "Exceso de roles",
+}
+
+// accountRiskParams son los parámetros del reporte tomados de los filtros
+type accountRiskParams struct {
+	risks        []string
+	inactiveDays int64
+	maxRoles     int64
+	moduleID     int64
+	now          time.Time
+}
+
+func (p accountRiskParams) cutoff() time.Time {
+	return p.now.AddDate(0, 0, -int(p.inactiveDays))
+}
+
+func (p accountRiskParams) has(risk string) bool {
+	return containsString(p.risks, risk)
+}
+
+// parseAccountRiskParams lee los riesgos pedidos, los días sin acceso, el máximo de roles y el módulo
+func parseAccountRiskParams(filters reportFilters) (accountRiskParams, error) {
+	params := accountRiskParams{inactiveDays: defaultInactiveDays, maxRoles: defaultMaxRoles, now: time.Now()}
+	filterError := func(format string, args ...interface{}) error {
+		return &FilterError{Message: fmt.Sprintf(format, args...), Allowed: AllowedReportFilters(AccountRiskModel)}
+	}
+
+	for _, filter := range filters {
+		switch filter.field {
+		case "risk":
+			values := []interface{}{filter.value}
+			if list, ok := filter.value.([]interface{}); ok {
+				values = list
+			}
+			if filter.operator != FilterEq && filter.operator != FilterIn {
+				return params, filterError("el filtro risk solo admite eq o in")
+			}
+			for _, value := range values {
+				risk := value.(string)
+				if _, ok := accountRiskLabels[risk]; !ok {
+					return params, filterError("riesgo no soportado: %s (use %s)", risk, strings.Join(accountRisks, ", "))
+				}
+				if !params.has(risk) {
+					params.risks = append(params.risks, risk)
+				}
+			}
+		case "inactive_days", "max_roles", "module_id":
+			if filter.operator != FilterEq {
+				return params, filterError("el filtro %s solo admite eq", filter.field)
+			}
+			value := filter.value.(int64)
+			if value < 0 || (value == 0 && filter.field != "max_roles") {
+				return params, filterError("el filtro %s debe ser mayor que cero", filter.field)
+			}
+			switch filter.field {
+			case "inactive_days":
+				params.inactiveDays = value
+			case "max_roles":
+				params.maxRoles = value
+			default:
+				params.moduleID = value
+			}
+		}
+	}
+	if len(params.risks) == 0 {
+		params.risks = accountRisks
+	}
+	return params, nil
+}
+
+// lastLoginExpression es la fecha del último acceso del usuario, en el módulo si se filtró por uno
+func (p accountRiskParams) lastLoginExpression() (string, []interface{}) {
+	if p.moduleID > 0 {
+		return "(SELECT MAX(user_logins.last_login_at) FROM user_logins WHERE user_logins.user_id = users.id AND user_logins.module_id = ?)", []interface{}{p.moduleID}
+	}
+	return "(SELECT MAX(user_logins.last_login_at) FROM user_logins WHERE user_logins.user_id = users.id)", nil
+}
+
+// accountRiskQuery arma la consulta de las cuentas con alguno de los riesgos pedidos. Una cuenta que nunca
+// inició sesión se considera sin uso desde su creación.
+func accountRiskQuery(filters reportFilters, params accountRiskParams) *gorm.DB {
+	query := config.DB.Model(&models.User{})
+	for _, filter := range filters {
+		if filter.column != "" {
+			query = reportFilters{filter}.apply(query)
+		}
+	}
+	if params.moduleID > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE user_roles.user_id = users.id AND roles.id_module = ?)", params.moduleID)
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, risk := range params.risks {
+		switch risk {
+		case RiskDormant:
+			lastLogin, loginArgs := params.lastLoginExpression()
+			conditions = append(conditions, "COALESCE("+lastLogin+", users.created_at) < ?")
+			args = append(append(args, loginArgs...), params.cutoff())
+		case RiskInactiveModule:
+			conditions = append(conditions, "EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id JOIN modules ON modules.id = roles.id_module WHERE user_roles.user_id = users.id AND modules.active = ?)")
+			args = append(args, false)
+		case RiskLocked:
+			conditions = append(conditions, "users.locked_until > ?")
+			args = append(args, params.now)
+		case RiskPendingReset:
+			conditions = append(conditions, "(users.reset_token IS NOT NULL AND users.reset_token <> '' AND users.reset_token_expiry > ?)")
+			args = append(args, params.now)
+		case RiskExcessRoles:
+			conditions = append(conditions, "(SELECT COUNT(DISTINCT user_roles.role_id) FROM user_roles WHERE user_roles.user_id = users.id) > ?")
+			args = append(args, params.maxRoles)
+		}
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// accountRiskDetail son los datos de una cuenta que explican sus riesgos
+type accountRiskDetail struct {
+	lastLogin     *time.Time
+	roles         int64
+	inactiveRoles []string
+}
+
+// loadAccountRiskDetails consulta el último acceso, la cantidad de roles y los roles en módulos inactivos
+// de un lote de usuarios
+func loadAccountRiskDetails(users []models.User, params accountRiskParams) (map[uint]*accountRiskDetail, error) {
+	ids := make([]uint, len(users))
+	details := make(map[uint]*accountRiskDetail, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+		details[user.ID] = &accountRiskDetail{}
+	}
+
+	// Se leen las filas por módulo y el máximo se calcula aquí, así el tipo de fecha no depende del motor
+	var logins []models.UserLogin
+	loginQuery := config.DB.Where("user_id IN ?", ids)
+	if params.moduleID > 0 {
+		loginQuery = loginQuery.Where("module_id = ?", params.moduleID)
+	}
+	if err := loginQuery.Find(&logins).Error; err != nil {
+		return nil, err
+	}
+	for _, login := range logins {
+		detail := details[login.UserID]
+		if detail.lastLogin == nil || login.LastLoginAt.After(*detail.lastLogin) {
+			lastLogin := login.LastLoginAt
+			detail.lastLogin = &lastLogin
+		}
+	}
+
+	roleCounts, err := reportRelations["user_roles"].load(ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, count := range roleCounts {
+		details[id].roles, _ = strconv.ParseInt(count, 10, 64)
+	}
+
+	var inactive []struct {
+		UserID     uint
+		RoleName   string
+		ModuleName string
+	}
+	err = config.DB.Table("user_roles").
+		Select("DISTINCT user_roles.user_id, roles.name AS role_name, modules.name AS module_name").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Joins("JOIN modules ON modules.id = roles.id_module").
+		Where("user_roles.user_id IN ? AND modules.active = ?", ids, false).
+		Order("roles.name").
+		Scan(&inactive).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range inactive {
+		detail := details[role.UserID]
+		detail.inactiveRoles = append(detail.inactiveRoles, fmt.Sprintf("%s (%s)", role.RoleName, role.ModuleName))
+	}
+	return details, nil
+}
+
+// risksOf aplica a una cuenta las mismas reglas que accountRiskQuery
+func (p accountRiskParams) risksOf(user models.User, detail *accountRiskDetail) []string {
+	var risks []string
+	for _, risk := range p.risks {
+		var matches bool
+		switch risk {
+		case RiskDormant:
+			reference := user.CreatedAt
+			if detail.lastLogin != nil {
+				reference = *detail.lastLogin
+			}
+			matches = reference.Before(p.cutoff())
+		case RiskInactiveModule:
+			matches = len(detail.inactiveRoles) > 0
+		case RiskLocked:
+			matches = user.LockedUntil != nil && user.LockedUntil.After(p.now)
+		case RiskPendingReset:
+			matches = user.ResetToken != "" && user.ResetTokenExpiry != nil && user.ResetTokenExpiry.After(p.now)
+		case RiskExcessRoles:
+			matches = detail.roles > p.maxRoles
+		}
+		if matches {
+			risks = append(risks, risk)
+		}
+	}
+	return risks
+}
+
+// newAccountRiskSource arma el reporte de cuentas sin uso y de riesgo: una fila por cuenta con los
+// riesgos que presenta y los datos que los explican
+func newAccountRiskSource(filters reportFilters, theme utils.ReportTheme) (*reportSource, error) {
+	params, err := parseAccountRiskParams(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []string{
+		theme.T("ID"), theme.T("Nombre"), theme.T("Correo Electrónico"), theme.T("Estado"),
+		theme.T("Último acceso"), theme.T("Días sin acceso"), theme.T("Cant. Roles"),
+		theme.T("Roles en módulos inactivos"), theme.T("Bloqueada hasta"), theme.T("Restablecimiento vigente hasta"),
+		theme.T("Riesgos"),
+	}
+	keys := []string{
+		"id", "name", "email", "active", "last_login", "idle_days", "role_count",
+		"inactive_module_roles", "locked_until", "reset_until", "risks",
+	}
+	query := accountRiskQuery(filters, params)
+
+	// Los detalles se cargan con cada lote y toRow los lee del lote actual
+	var details map[uint]*accountRiskDetail
+	related := func(batch []models.User) (relatedValues, error) {
+		var err error
+		if details, err = loadAccountRiskDetails(batch, params); err != nil {
+			return nil, fmt.Errorf("error al consultar los datos relacionados: %w", err)
+		}
+		return nil, nil
+	}
+	toRow := func(user models.User, _ relatedValues) []string {
+		detail := details[user.ID]
+		lastLogin, idleSince := theme.T("Nunca"), user.CreatedAt
+		if detail.lastLogin != nil {
+			lastLogin, idleSince = theme.FormatDate(*detail.lastLogin), *detail.lastLogin
+		}
+		lockedUntil, resetUntil := "", ""
+		if user.LockedUntil != nil && user.LockedUntil.After(params.now) {
+			lockedUntil = theme.FormatDate(*user.LockedUntil)
+		}
+		if user.ResetToken != "" && user.ResetTokenExpiry != nil && user.ResetTokenExpiry.After(params.now) {
+			resetUntil = theme.FormatDate(*user.ResetTokenExpiry)
+		}
+		risks := params.risksOf(user, detail)
+		labels := make([]string, len(risks))
+		for i, risk := range risks {
+			labels[i] = theme.T(accountRiskLabels[risk])
+		}
+		return []string{
+			strconv.FormatUint(uint64(user.ID), 10), user.Name, user.Email, activeState(theme, user.Active),
+			lastLogin, strconv.Itoa(int(params.now.Sub(idleSince).Hours() / 24)), strconv.FormatInt(detail.roles, 10),
+			strings.Join(detail.inactiveRoles, ", "), lockedUntil, resetUntil,
+			strings.Join(labels, ", "),
+		}
+	}
+
+	source := batchedSource(headers, keys, query, nil, nil, related, toRow)
+	source.title = theme.Tf("Cuentas sin uso y de riesgo (%d días sin acceso, más de %d roles)", params.inactiveDays, params.maxRoles)
+	return source, nil
+}
+
+// DeactivateRiskyAccounts desactiva las cuentas activas que devuelve el reporte de cuentas de riesgo con
+// esos filtros. Con userIDs solo se desactivan las indicadas que estén en el resultado; la cuenta de quien
+// hace la solicitud nunca se desactiva. Devuelve los IDs desactivados.
+func DeactivateRiskyAccounts(ctx context.Context, filters map[string]interface{}, userIDs []uint, requestedBy uint) ([]uint, error) {
+	conditions, err := parseReportFilters(AccountRiskModel, filters)
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseAccountRiskParams(conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	query := accountRiskQuery(conditions, params).Where("users.active = ? AND users.id <> ?", true, requestedBy)
+	if len(userIDs) > 0 {
+		query = query.Where("users.id IN ?", userIDs)
+	}
+	var ids []uint
+	if err := query.Order("users.id").Limit(maxBulkDeactivate+1).Pluck("users.id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("error al consultar las cuentas: %w", err)
+	}
+	if len(ids) > maxBulkDeactivate {
+		return nil, ErrTooManyRiskyAccounts
+	}
+	if len(ids) == 0 {
+		return nil, ErrNoRiskyAccounts
+	}
+
+	// La actualización por condición registra el cambio de cada usuario en el historial
+	err = config.DB.WithContext(ctx).Model(&models.User{}).Where("id IN ?", ids).Update("active", false).Error
+	if err != nil {
+		return nil, fmt.Errorf("error al desactivar las cuentas: %w", err)
+	}
+	return ids, nil
+}
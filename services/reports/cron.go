@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule es una expresión cron estándar de cinco campos (minuto, hora, día del mes, mes y día de
+// la semana) evaluada en una zona horaria fija
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	location                      *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// El domingo se acepta como 0 o 7
+	cronDow = cronField{min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// ReportLocation es la zona horaria en la que se interpretan las programaciones de reportes
+func ReportLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Guayaquil")
+	if err != nil {
+		return time.FixedZone("ECT", -5*60*60)
+	}
+	return loc
+}
+
+// ParseCron interpreta una expresión de cinco campos o un descriptor (@daily, @weekly, etc.)
+func ParseCron(expression string, location *time.Location) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expression)]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("la expresión cron debe tener 5 campos (minuto hora día mes día_semana)")
+	}
+
+	schedule := &CronSchedule{location: location}
+	var err error
+	if schedule.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("minuto inválido: %w", err)
+	}
+	if schedule.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("hora inválida: %w", err)
+	}
+	if schedule.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("día del mes inválido: %w", err)
+	}
+	if schedule.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("mes inválido: %w", err)
+	}
+	if schedule.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("día de la semana inválido: %w", err)
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domAny = fields[2] == "*" || fields[2] == "?"
+	schedule.dowAny = fields[4] == "*" || fields[4] == "?"
+
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("la expresión cron nunca se cumple")
+	}
+	return schedule, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			value, err := strconv.Atoi(part[index+1:])
+			if err != nil || value <= 0 {
+				return 0, fmt.Errorf("paso inválido en %q", part)
+			}
+			step = value
+			part = part[:index]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("rango inválido %q", part)
+			}
+		default:
+			value, err := f.value(part)
+			if err != nil {
+				return 0, err
+			}
+			start = value
+			if step == 1 {
+				end = value
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	if value, ok := f.names[strings.ToUpper(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("valor fuera de rango %q (%d-%d)", text, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Next devuelve el siguiente instante posterior a after que cumple la expresión, o el tiempo cero
+// si no hay ninguno en los próximos cinco años
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches sigue la regla clásica de cron: si se restringen el día del mes y el día de la semana,
+// basta con que se cumpla uno de los dos
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		field      func(*CronSchedule) uint64
+		want       []int
+	}{
+		{"valor único", "5 * * * *", func(s *CronSchedule) uint64 { return s.minute }, []int{5}},
+		{"lista", "0,15,45 * * * *", func(s *CronSchedule) uint64 { return s.minute }, []int{0, 15, 45}},
+		{"rango", "* 9-12 * * *", func(s *CronSchedule) uint64 { return s.hour }, []int{9, 10, 11, 12}},
+		{"paso sobre todo", "*/20 * * * *", func(s *CronSchedule) uint64 { return s.minute }, []int{0, 20, 40}},
+		{"paso sobre rango", "* 8-18/4 * * *", func(s *CronSchedule) uint64 { return s.hour }, []int{8, 12, 16}},
+		{"paso desde un valor", "* * 25/3 * *", func(s *CronSchedule) uint64 { return s.dom }, []int{25, 28, 31}},
+		{"nombres de mes", "* * * JAN,jun-aug *", func(s *CronSchedule) uint64 { return s.month }, []int{1, 6, 7, 8}},
+		{"nombres de día", "* * * * MON-WED", func(s *CronSchedule) uint64 { return s.dow }, []int{1, 2, 3}},
+		{"domingo como 7", "* * * * 7", func(s *CronSchedule) uint64 { return s.dow }, []int{0, 7}},
+		{"domingo como 0", "* * * * SUN", func(s *CronSchedule) uint64 { return s.dow }, []int{0}},
+		{"descriptor", "@weekly", func(s *CronSchedule) uint64 { return s.dow }, []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expression, err)
+			}
+			var want uint64
+			for _, value := range tt.want {
+				want |= 1 << uint(value)
+			}
+			if got := tt.field(schedule); got != want {
+				t.Errorf("ParseCron(%q) = %b, se esperaba %b", tt.expression, got, want)
+			}
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{"pocos campos", "* * * *"},
+		{"demasiados campos", "* * * * * *"},
+		{"minuto fuera de rango", "60 * * * *"},
+		{"día del mes cero", "* * 0 * *"},
+		{"día de la semana fuera de rango", "* * * * 8"},
+		{"rango invertido", "* 12-9 * * *"},
+		{"paso cero", "*/0 * * * *"},
+		{"nombre desconocido", "* * * FOO *"},
+		{"nunca se cumple", "0 0 31 FEB *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expression, time.UTC); err == nil {
+				t.Errorf("ParseCron(%q) no devolvió error", tt.expression)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"siguiente minuto", "* * * * *", date(2024, 3, 10, 8, 30), date(2024, 3, 10, 8, 31)},
+		{"descarta los segundos", "* * * * *", date(2024, 3, 10, 8, 30).Add(45 * time.Second), date(2024, 3, 10, 8, 31)},
+		{"misma hora no se repite", "30 8 * * *", date(2024, 3, 10, 8, 30), date(2024, 3, 11, 8, 30)},
+		{"cruce de mes", "0 6 * * *", date(2024, 1, 31, 7, 0), date(2024, 2, 1, 6, 0)},
+		{"cruce de año", "0 0 * * *", date(2024, 12, 31, 23, 59), date(2025, 1, 1, 0, 0)},
+		{"mes corto", "0 0 31 * *", date(2024, 4, 1, 0, 0), date(2024, 5, 31, 0, 0)},
+		{"año bisiesto", "0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"domingo como 7", "0 9 * * 7", date(2024, 3, 6, 0, 0), date(2024, 3, 10, 9, 0)},
+		{"día de la semana con día del mes libre", "0 9 * * MON", date(2024, 3, 6, 0, 0), date(2024, 3, 11, 9, 0)},
+		{"día del mes o día de la semana", "0 9 15 * MON", date(2024, 3, 12, 0, 0), date(2024, 3, 15, 9, 0)},
+		{"día de la semana antes que el día del mes", "0 9 15 * MON", date(2024, 3, 16, 0, 0), date(2024, 3, 18, 9, 0)},
+		{"mes por nombre en el año siguiente", "0 0 1 JAN *", date(2024, 6, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expression, err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) con %q = %s, se esperaba %s", tt.after, tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	location := time.FixedZone("ECT", -5*60*60)
+	schedule, err := ParseCron("0 7 * * *", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Las 11:00 UTC son las 06:00 en Ecuador, así que toca el mismo día a las 07:00 locales
+	got := schedule.Next(time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC))
+	want := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("Next = %s, se esperaba %s", got, want)
+	}
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"seguridad-api/models"
+	"seguridad-api/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+
+	maxSortColumns = 5
+)
+
+// ReportSort es una clave de ordenamiento del reporte
+type ReportSort struct {
+	Column    string `json:"column" example:"name"`
+	Direction string `json:"direction" example:"asc"`
+}
+
+// ReportLayout define las columnas del reporte, su orden, las claves de ordenamiento, la agrupación y
+// si PDF y Excel incluyen la página de resumen con gráficos. Sin columnas se usan las del modelo por defecto.
+type ReportLayout struct {
+	Columns []string     `json:"columns,omitempty"`
+	Sort    []ReportSort `json:"sort,omitempty"`
+	GroupBy string       `json:"group_by,omitempty"`
+	Summary bool         `json:"summary,omitempty"`
+}
+
+// IsZero indica que el reporte usa las columnas y el orden por defecto, sin resumen
+func (l ReportLayout) IsZero() bool {
+	return len(l.Columns) == 0 && len(l.Sort) == 0 && l.GroupBy == "" && !l.Summary
+}
+
+// ReportColumnInfo describe una columna disponible para un modelo de reporte
+type ReportColumnInfo struct {
+	Key      string `json:"key"`
+	Header   string `json:"header"`
+	Sortable bool   `json:"sortable"`
+	Default  bool   `json:"default"`
+}
+
+// reportColumn es una columna del registro de un modelo. Las columnas con sortColumn se pueden usar para
+// ordenar y agrupar; join y preload se agregan a la consulta solo cuando la columna se usa. El valor sale
+// de value, o de date y flag cuando depende del formato de fecha o del idioma del reporte, o de relation
+// (ver reportRelations) con el ID que devuelve relationKey; empty es el texto que se muestra cuando el
+// valor está vacío. header y empty se escriben en español y se traducen.
+type reportColumn[T any] struct {
+	key         string
+	header      string
+	sortColumn  string
+	join        string
+	preload     string
+	value       func(T) string
+	date        func(T) time.Time
+	flag        func(T) bool
+	relation    string
+	relationKey func(T) uint
+	empty       string
+}
+
+func (c reportColumn[T]) format(record T, theme utils.ReportTheme, related relatedValues) string {
+	var value string
+	switch {
+	case c.date != nil:
+		return theme.FormatDate(c.date(record))
+	case c.flag != nil:
+		return activeState(theme, c.flag(record))
+	case c.relation != "":
+		value = related[c.relation][c.relationKey(record)]
+	default:
+		value = c.value(record)
+	}
+	if value != "" || c.empty == "" {
+		return value
+	}
+	return theme.T(c.empty)
+}
+
+// columnRegistry son las columnas de un modelo con las columnas por defecto de cada opción del reporte
+type columnRegistry[T any] struct {
+	table    string
+	columns  []reportColumn[T]
+	defaults map[string][]string
+}
+
+// columnSet permite tratar igual los registros de modelos distintos
+type columnSet interface {
+	describe(option string, theme utils.ReportTheme) []ReportColumnInfo
+	validate(option string, layout ReportLayout) error
+	source(query *gorm.DB, option string, layout ReportLayout, theme utils.ReportTheme) (*reportSource, error)
+}
+
+var reportColumnRegistries = map[string]columnSet{
+	"Permission": columnRegistry[models.Permission]{
+		table: "permissions",
+		columns: []reportColumn[models.Permission]{
+			{key: "id", header: "ID", sortColumn: "permissions.id", value: func(p models.Permission) string { return fmt.Sprintf("%d", p.ID) }},
+			{key: "name", header: "Nombre", sortColumn: "permissions.name", value: func(p models.Permission) string { return p.Name }},
+			{key: "description", header: "Descripción", sortColumn: "permissions.description", value: func(p models.Permission) string { return p.Description }},
+			{key: "active", header: "Estado", sortColumn: "permissions.active", flag: func(p models.Permission) bool { return p.Active }},
+			{key: "module", header: "Módulo", sortColumn: "modules.name", join: "LEFT JOIN modules ON modules.id = permissions.module_id", preload: "Module", value: permissionModuleName, empty: "Sin módulo"},
+			{key: "role_count", header: "Cant. Roles", sortColumn: "(SELECT COUNT(DISTINCT role_permissions.role_id) FROM role_permissions WHERE role_permissions.permission_id = permissions.id)", relation: "permission_roles", relationKey: func(p models.Permission) uint { return p.ID }},
+			{key: "created_at", header: "F. Creación", sortColumn: "permissions.created_at", date: func(p models.Permission) time.Time { return p.CreatedAt }},
+			{key: "updated_at", header: "F. Actualización", sortColumn: "permissions.updated_at", date: func(p models.Permission) time.Time { return p.UpdatedAt }},
+		},
+		defaults: map[string][]string{"": {"name", "description", "active", "module", "role_count", "created_at", "updated_at"}},
+	},
+	"User": columnRegistry[models.User]{
+		table: "users",
+		columns: []reportColumn[models.User]{
+			{key: "id", header: "ID", sortColumn: "users.id", value: func(u models.User) string { return fmt.Sprintf("%d", u.ID) }},
+			{key: "name", header: "Nombre", sortColumn: "users.name", value: func(u models.User) string { return u.Name }},
+			{key: "email", header: "Correo Electrónico", sortColumn: "users.email", value: func(u models.User) string { return u.Email }},
+			{key: "active", header: "Estado", sortColumn: "users.active", flag: func(u models.User) bool { return u.Active }},
+			{key: "roles", header: "Roles", preload: "Roles.Permissions.Module", value: func(u models.User) string { roles, _, _ := formatUserDetails(u); return roles }},
+			{key: "permissions", header: "Permisos", preload: "Roles.Permissions.Module", value: func(u models.User) string { _, permissions, _ := formatUserDetails(u); return permissions }},
+			{key: "modules", header: "Módulos", preload: "Roles.Permissions.Module", value: func(u models.User) string { _, _, modules := formatUserDetails(u); return modules }},
+			{key: "role_count", header: "Cant. Roles", sortColumn: "(SELECT COUNT(DISTINCT user_roles.role_id) FROM user_roles WHERE user_roles.user_id = users.id)", relation: "user_roles", relationKey: func(u models.User) uint { return u.ID }},
+			{key: "permission_count", header: "Cant. Permisos", sortColumn: "(SELECT COUNT(DISTINCT role_permissions.permission_id) FROM user_roles JOIN role_permissions ON role_permissions.role_id = user_roles.role_id WHERE user_roles.user_id = users.id)", relation: "user_permissions", relationKey: func(u models.User) uint { return u.ID }},
+			{key: "created_at", header: "F. Creación", sortColumn: "users.created_at", date: func(u models.User) time.Time { return u.CreatedAt }},
+			{key: "updated_at", header: "F. Actualización", sortColumn: "users.updated_at", date: func(u models.User) time.Time { return u.UpdatedAt }},
+		},
+		defaults: map[string][]string{
+			"":                  {"name", "email", "active", "role_count", "created_at", "updated_at"},
+			"usuariosCompletos": {"name", "roles", "permissions", "modules"},
+		},
+	},
+	"Role": columnRegistry[models.Role]{
+		table: "roles",
+		columns: []reportColumn[models.Role]{
+			{key: "id", header: "ID", sortColumn: "roles.id", value: func(r models.Role) string { return fmt.Sprintf("%d", r.ID) }},
+			{key: "name", header: "Nombre del Rol", sortColumn: "roles.name", value: func(r models.Role) string { return r.Name }},
+			{key: "description", header: "Descripción", sortColumn: "roles.description", value: func(r models.Role) string { return r.Description }},
+			{key: "active", header: "Estado", sortColumn: "roles.active", flag: func(r models.Role) bool { return r.Active }},
+			{key: "id_module", header: "ID Módulo", sortColumn: "roles.id_module", value: func(r models.Role) string { return fmt.Sprintf("%d", r.IDModule) }},
+			{key: "module", header: "Módulo", sortColumn: "modules.name", join: "LEFT JOIN modules ON modules.id = roles.id_module", relation: "module_name", relationKey: func(r models.Role) uint { return r.IDModule }, empty: "Sin módulo"},
+			{key: "user_count", header: "Cant. Usuarios", sortColumn: "(SELECT COUNT(DISTINCT user_roles.user_id) FROM user_roles WHERE user_roles.role_id = roles.id)", relation: "role_users", relationKey: func(r models.Role) uint { return r.ID }},
+			{key: "permission_count", header: "Cant. Permisos", sortColumn: "(SELECT COUNT(DISTINCT role_permissions.permission_id) FROM role_permissions WHERE role_permissions.role_id = roles.id)", relation: "role_permissions", relationKey: func(r models.Role) uint { return r.ID }},
+			{key: "created_at", header: "F. Creación", sortColumn: "roles.created_at", date: func(r models.Role) time.Time { return r.CreatedAt }},
+			{key: "updated_at", header: "F. Actualización", sortColumn: "roles.updated_at", date: func(r models.Role) time.Time { return r.UpdatedAt }},
+		},
+		defaults: map[string][]string{"": {"name", "description", "module", "active", "user_count", "permission_count", "created_at", "updated_at"}},
+	},
+	"Module": columnRegistry[models.Module]{
+		table: "modules",
+		columns: []reportColumn[models.Module]{
+			{key: "id", header: "ID", sortColumn: "modules.id", value: func(m models.Module) string { return fmt.Sprintf("%d", m.ID) }},
+			{key: "name", header: "Nombre del Módulo", sortColumn: "modules.name", value: func(m models.Module) string { return m.Name }},
+			{key: "description", header: "Descripción", sortColumn: "modules.description", value: func(m models.Module) string { return m.Description }},
+			{key: "module_key", header: "Clave", sortColumn: "modules.module_key", value: func(m models.Module) string { return m.ModuleKey }},
+			{key: "active", header: "Estado", sortColumn: "modules.active", flag: func(m models.Module) bool { return m.Active }},
+			{key: "role_count", header: "Cant. Roles", sortColumn: "(SELECT COUNT(*) FROM roles WHERE roles.id_module = modules.id)", relation: "module_roles", relationKey: func(m models.Module) uint { return m.ID }},
+			{key: "permission_count", header: "Cant. Permisos", sortColumn: "(SELECT COUNT(*) FROM permissions WHERE permissions.module_id = modules.id)", relation: "module_permissions", relationKey: func(m models.Module) uint { return m.ID }},
+			{key: "created_at", header: "F. Creación", sortColumn: "modules.created_at", date: func(m models.Module) time.Time { return m.CreatedAt }},
+			{key: "updated_at", header: "F. Actualización", sortColumn: "modules.updated_at", date: func(m models.Module) time.Time { return m.UpdatedAt }},
+		},
+		defaults: map[string][]string{"": {"name", "description", "active", "role_count", "permission_count", "created_at", "updated_at"}},
+	},
+	"Audit": columnRegistry[models.Audit]{
+		table: "audit",
+		columns: []reportColumn[models.Audit]{
+			{key: "id", header: "ID", sortColumn: "audit.id", value: func(a models.Audit) string { return fmt.Sprintf("%d", a.ID) }},
+			{key: "event", header: "Evento", sortColumn: "audit.event", value: func(a models.Audit) string { return a.Event }},
+			{key: "description", header: "Descripción", value: func(a models.Audit) string { return a.Description }},
+			{key: "user_id", header: "ID Usuario", sortColumn: "audit.user_id", value: func(a models.Audit) string { return fmt.Sprintf("%d", a.UserID) }},
+			{key: "user", header: "Usuario", sortColumn: "users.name", join: "LEFT JOIN users ON users.id = audit.user_id", relation: "user_name", relationKey: func(a models.Audit) uint { return a.UserID }, empty: "Usuario desconocido"},
+			{key: "user_email", header: "Correo del Usuario", sortColumn: "users.email", join: "LEFT JOIN users ON users.id = audit.user_id", relation: "user_email", relationKey: func(a models.Audit) uint { return a.UserID }},
+			{key: "origin_service", header: "Servicio Origen", sortColumn: "audit.origin_service", value: func(a models.Audit) string { return a.OriginService }},
+			{key: "outcome", header: "Resultado", sortColumn: "audit.outcome", value: func(a models.Audit) string { return a.Outcome }},
+			{key: "ip", header: "IP", sortColumn: "audit.ip", value: func(a models.Audit) string { return a.IP }},
+			{key: "date", header: "Fecha", sortColumn: "audit.date", date: func(a models.Audit) time.Time { return a.Date }},
+		},
+		defaults: map[string][]string{"": {"event", "description", "user", "origin_service", "date"}},
+	},
+}
+
+// AvailableReportColumns devuelve las columnas que se pueden elegir para el modelo y la opción indicados,
+// con los encabezados en el idioma pedido (por defecto el de la configuración del servicio)
+func AvailableReportColumns(modelName, option, locale string) ([]ReportColumnInfo, error) {
+	registry, ok := reportColumnRegistries[modelName]
+	if !ok {
+		return nil, fmt.Errorf("el modelo %s no admite columnas personalizadas", modelName)
+	}
+	theme := defaultReportTheme()
+	if err := (reportPresentation{Locale: locale}).apply(&theme); err != nil {
+		return nil, err
+	}
+	return registry.describe(option, theme), nil
+}
+
+// validateReportLayout revisa que las columnas, el orden y la agrupación existan en el registro del modelo
+func validateReportLayout(modelName, option string, layout ReportLayout) error {
+	if layout.IsZero() {
+		return nil
+	}
+	if err := checkReportSummary(modelName, layout); err != nil {
+		return err
+	}
+	registry, ok := reportColumnRegistries[modelName]
+	if !ok {
+		return fmt.Errorf("el modelo %s no admite columnas personalizadas", modelName)
+	}
+	return registry.validate(option, layout)
+}
+
+// encodeReportLayout guarda el diseño como JSON; el diseño por defecto se guarda vacío
+func encodeReportLayout(layout ReportLayout) (string, error) {
+	if layout.IsZero() {
+		return "", nil
+	}
+	encoded, err := json.Marshal(layout)
+	if err != nil {
+		return "", fmt.Errorf("diseño de reporte inválido: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func decodeReportLayout(encoded string) (ReportLayout, error) {
+	var layout ReportLayout
+	if encoded == "" {
+		return layout, nil
+	}
+	if err := json.Unmarshal([]byte(encoded), &layout); err != nil {
+		return layout, fmt.Errorf("diseño de reporte inválido: %w", err)
+	}
+	return layout, nil
+}
+
+func (r columnRegistry[T]) find(key string) (reportColumn[T], bool) {
+	for _, column := range r.columns {
+		if column.key == key {
+			return column, true
+		}
+	}
+	return reportColumn[T]{}, false
+}
+
+func (r columnRegistry[T]) keys() []string {
+	keys := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		keys[i] = column.key
+	}
+	return keys
+}
+
+func (r columnRegistry[T]) describe(option string, theme utils.ReportTheme) []ReportColumnInfo {
+	defaults := r.defaultColumns(option)
+	columns := make([]ReportColumnInfo, len(r.columns))
+	for i, column := range r.columns {
+		columns[i] = ReportColumnInfo{
+			Key:      column.key,
+			Header:   theme.T(column.header),
+			Sortable: column.sortColumn != "",
+			Default:  containsString(defaults, column.key),
+		}
+	}
+	return columns
+}
+
+func (r columnRegistry[T]) defaultColumns(option string) []string {
+	if defaults, ok := r.defaults[option]; ok {
+		return defaults
+	}
+	return r.defaults[""]
+}
+
+// resolve devuelve las columnas elegidas y el orden de la consulta; el agrupamiento va primero en el orden
+func (r columnRegistry[T]) resolve(option string, layout ReportLayout) ([]reportColumn[T], []reportOrder, int, error) {
+	keys := layout.Columns
+	if len(keys) == 0 {
+		keys = r.defaultColumns(option)
+	}
+
+	columns := make([]reportColumn[T], 0, len(keys))
+	for _, key := range keys {
+		column, ok := r.find(key)
+		if !ok {
+			return nil, nil, 0, fmt.Errorf("columna no disponible: %s (permitidas: %s)", key, strings.Join(r.keys(), ", "))
+		}
+		for _, selected := range columns {
+			if selected.key == key {
+				return nil, nil, 0, fmt.Errorf("la columna %s está repetida", key)
+			}
+		}
+		columns = append(columns, column)
+	}
+
+	if len(layout.Sort) > maxSortColumns {
+		return nil, nil, 0, fmt.Errorf("se admiten como máximo %d columnas de ordenamiento", maxSortColumns)
+	}
+
+	var order []reportOrder
+	groupColumn := -1
+	if layout.GroupBy != "" {
+		for i, column := range columns {
+			if column.key == layout.GroupBy {
+				groupColumn = i
+			}
+		}
+		if groupColumn < 0 {
+			return nil, nil, 0, fmt.Errorf("la columna de agrupación %s debe estar entre las columnas del reporte", layout.GroupBy)
+		}
+		column := columns[groupColumn]
+		if column.sortColumn == "" {
+			return nil, nil, 0, fmt.Errorf("no se puede agrupar por la columna %s", column.key)
+		}
+		order = append(order, reportOrder{column: column.sortColumn, join: column.join})
+	}
+
+	for _, sort := range layout.Sort {
+		column, ok := r.find(sort.Column)
+		if !ok {
+			return nil, nil, 0, fmt.Errorf("columna de ordenamiento no disponible: %s", sort.Column)
+		}
+		if column.sortColumn == "" {
+			return nil, nil, 0, fmt.Errorf("no se puede ordenar por la columna %s", column.key)
+		}
+		direction := strings.ToLower(sort.Direction)
+		if direction == "" {
+			direction = SortAsc
+		}
+		if direction != SortAsc && direction != SortDesc {
+			return nil, nil, 0, fmt.Errorf("dirección de ordenamiento inválida: %s (use asc o desc)", sort.Direction)
+		}
+		// El orden indicado para la columna de agrupación solo cambia la dirección de los grupos
+		if groupColumn >= 0 && column.key == layout.GroupBy {
+			order[0].desc = direction == SortDesc
+			continue
+		}
+		order = append(order, reportOrder{column: column.sortColumn, join: column.join, desc: direction == SortDesc})
+	}
+	return columns, order, groupColumn, nil
+}
+
+func (r columnRegistry[T]) validate(option string, layout ReportLayout) error {
+	_, _, _, err := r.resolve(option, layout)
+	return err
+}
+
+func (r columnRegistry[T]) source(query *gorm.DB, option string, layout ReportLayout, theme utils.ReportTheme) (*reportSource, error) {
+	columns, order, groupColumn, err := r.resolve(option, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]string, len(columns))
+	keys := make([]string, len(columns))
+	var preloads []string
+	for i, column := range columns {
+		headers[i], keys[i] = theme.T(column.header), column.key
+		if column.preload != "" && !containsString(preloads, column.preload) {
+			preloads = append(preloads, column.preload)
+		}
+	}
+
+	// Sin un orden elegido se recorre por clave primaria, como siempre
+	var keyset *reportKeyset
+	if len(order) > 0 {
+		var joins []string
+		for _, item := range order {
+			if item.join != "" && !containsString(joins, item.join) {
+				joins = append(joins, item.join)
+				query = query.Joins(item.join)
+			}
+		}
+		keyset = &reportKeyset{table: r.table, order: order}
+	}
+
+	source := batchedSource(headers, keys, query, preloads, keyset, relationLoader(columns), func(record T, related relatedValues) []string {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = column.format(record, theme, related)
+		}
+		return row
+	})
+	source.groupColumn = groupColumn
+	return source, nil
+}
+
+// reportOrder es una columna de ordenamiento ya validada; la columna proviene del registro, nunca de la solicitud
+type reportOrder struct {
+	column string
+	join   string
+	desc   bool
+}
+
+func (o reportOrder) clause() string {
+	if o.desc {
+		return o.column + " DESC"
+	}
+	return o.column
+}
+
+func permissionModuleName(permission models.Permission) string {
+	if permission.Module.ID > 0 {
+		return permission.Module.Name
+	}
+	return ""
+}
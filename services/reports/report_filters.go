@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Operadores de los filtros de reportes. Un filtro con un valor simple equivale a eq; para los demás se
+// envía un objeto, por ejemplo {"name": {"contains": "admin"}} o {"created_at": {"between": ["2024-01-01", "2024-12-31"]}}
+const (
+	FilterEq       = "eq"
+	FilterIn       = "in"
+	FilterContains = "contains"
+	FilterBetween  = "between"
+	FilterGte      = "gte"
+	FilterLte      = "lte"
+
+	maxFilterValues = 100
+)
+
+// Tipos de valor de los filtros
+const (
+	filterText   = "texto"
+	filterNumber = "número"
+	filterBool   = "booleano"
+	filterDate   = "fecha (AAAA-MM-DD)"
+)
+
+var filterOperators = map[string][]string{
+	filterText:   {FilterEq, FilterIn, FilterContains},
+	filterNumber: {FilterEq, FilterIn},
+	filterBool:   {FilterEq},
+	filterDate:   {FilterEq, FilterBetween, FilterGte, FilterLte},
+}
+
+type filterField struct {
+	column string
+	kind   string
+	// alias indica un nombre heredado que se traduce a otro campo (p. ej. userId o date_range)
+	alias string
+}
+
+type reportFilterSchema map[string]filterField
+
+var reportFilterSchemas = map[string]reportFilterSchema{
+	"Permission": {
+		"id":          {column: "permissions.id", kind: filterNumber},
+		"name":        {column: "permissions.name", kind: filterText},
+		"description": {column: "permissions.description", kind: filterText},
+		"active":      {column: "permissions.active", kind: filterBool},
+		"module_id":   {column: "permissions.module_id", kind: filterNumber},
+		"created_at":  {column: "permissions.created_at", kind: filterDate},
+		"updated_at":  {column: "permissions.updated_at", kind: filterDate},
+		"date_range":  {alias: "created_at"},
+	},
+	"User": {
+		"id":         {column: "users.id", kind: filterNumber},
+		"name":       {column: "users.name", kind: filterText},
+		"email":      {column: "users.email", kind: filterText},
+		"active":     {column: "users.active", kind: filterBool},
+		"created_at": {column: "users.created_at", kind: filterDate},
+		"updated_at": {column: "users.updated_at", kind: filterDate},
+		"date_range": {alias: "created_at"},
+	},
+	"Role": {
+		"id":          {column: "roles.id", kind: filterNumber},
+		"id_module":   {column: "roles.id_module", kind: filterNumber},
+		"name":        {column: "roles.name", kind: filterText},
+		"description": {column: "roles.description", kind: filterText},
+		"active":      {column: "roles.active", kind: filterBool},
+		"created_at":  {column: "roles.created_at", kind: filterDate},
+		"updated_at":  {column: "roles.updated_at", kind: filterDate},
+		"date_range":  {alias: "created_at"},
+	},
+	"Module": {
+		"id":          {column: "modules.id", kind: filterNumber},
+		"name":        {column: "modules.name", kind: filterText},
+		"description": {column: "modules.description", kind: filterText},
+		"module_key":  {column: "modules.module_key", kind: filterText},
+		"active":      {column: "modules.active", kind: filterBool},
+		"created_at":  {column: "modules.created_at", kind: filterDate},
+		"updated_at":  {column: "modules.updated_at", kind: filterDate},
+		"date_range":  {alias: "created_at"},
+	},
+	"Audit": {
+		"event":          {column: "audit.event", kind: filterText},
+		"description":    {column: "audit.description", kind: filterText},
+		"origin_service": {column: "audit.origin_service", kind: filterText},
+		"user_id":        {column: "audit.user_id", kind: filterNumber},
+		"date":           {column: "audit.date", kind: filterDate},
+		"userId":         {alias: "user_id"},
+		"date_range":     {alias: "date"},
+	},
+	AccessMatrixModel: {
+		"module_id": {column: "permissions.module_id", kind: filterNumber},
+	},
+	// Salvo active, son parámetros del reporte de cuentas de riesgo y no se aplican como condición directa
+	AccountRiskModel: {
+		"risk":          {kind: filterText},
+		"inactive_days": {kind: filterNumber},
+		"max_roles":     {kind: filterNumber},
+		"module_id":     {kind: filterNumber},
+		"active":        {column: "users.active", kind: filterBool},
+	},
+}
+
+// AllowedFilter describe un filtro aceptado por un modelo de reporte
+type AllowedFilter struct {
+	Type      string   `json:"type"`
+	Operators []string `json:"operators"`
+}
+
+// FilterError es un filtro de reporte inválido; incluye los filtros permitidos para el modelo
+type FilterError struct {
+	Message string
+	Allowed map[string]AllowedFilter
+}
+
+func (e *FilterError) Error() string {
+	return e.Message
+}
+
+// AllowedReportFilters devuelve los filtros que acepta el modelo de reporte
+func AllowedReportFilters(modelName string) map[string]AllowedFilter {
+	schema := reportFilterSchemas[modelName]
+	allowed := make(map[string]AllowedFilter, len(schema))
+	for name, field := range schema {
+		if field.alias != "" {
+			continue
+		}
+		allowed[name] = AllowedFilter{Type: field.kind, Operators: filterOperators[field.kind]}
+	}
+	return allowed
+}
+
+// reportFilter es una condición ya validada; el valor está convertido al tipo del campo
+type reportFilter struct {
+	field    string
+	column   string
+	kind     string
+	operator string
+	value    interface{}
+}
+
+type reportFilters []reportFilter
+
+// parseReportFilters valida los filtros recibidos contra el esquema del modelo antes de construir SQL
+func parseReportFilters(modelName string, filters map[string]interface{}) (reportFilters, error) {
+	schema, ok := reportFilterSchemas[modelName]
+	if !ok {
+		return nil, fmt.Errorf("modelo no soportado")
+	}
+	filterError := func(format string, args ...interface{}) error {
+		return &FilterError{Message: fmt.Sprintf(format, args...), Allowed: AllowedReportFilters(modelName)}
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parsed reportFilters
+	for _, name := range names {
+		raw := filters[name]
+		field, ok := schema[name]
+		if !ok {
+			return nil, filterError("filtro no permitido para %s: %s", modelName, name)
+		}
+
+		fieldName := name
+		if field.alias != "" {
+			fieldName = field.alias
+			field = schema[field.alias]
+			// date_range mantiene su formato anterior {"start": ..., "end": ...}
+			if legacy, ok := raw.(map[string]interface{}); ok && field.kind == filterDate {
+				if _, hasOperator := legacy[FilterBetween]; !hasOperator {
+					raw = legacyDateRange(legacy)
+				}
+			}
+		}
+
+		conditions, ok := raw.(map[string]interface{})
+		if !ok {
+			conditions = map[string]interface{}{FilterEq: raw}
+		}
+		if len(conditions) == 0 {
+			return nil, filterError("el filtro %s no tiene condiciones", name)
+		}
+
+		operators := make([]string, 0, len(conditions))
+		for operator := range conditions {
+			operators = append(operators, operator)
+		}
+		sort.Strings(operators)
+
+		for _, operator := range operators {
+			if !containsString(filterOperators[field.kind], operator) {
+				return nil, filterError("el filtro %s no admite el operador %s (permitidos: %s)", name, operator, strings.Join(filterOperators[field.kind], ", "))
+			}
+			value, err := convertFilterValue(field.kind, operator, conditions[operator])
+			if err != nil {
+				return nil, filterError("valor inválido en el filtro %s: %v", name, err)
+			}
+			parsed = append(parsed, reportFilter{field: fieldName, column: field.column, kind: field.kind, operator: operator, value: value})
+		}
+	}
+	return parsed, nil
+}
+
+// legacyDateRange traduce {"start": a, "end": b} a los operadores gte/lte
+func legacyDateRange(dateRange map[string]interface{}) map[string]interface{} {
+	conditions := make(map[string]interface{})
+	if start, ok := dateRange["start"]; ok {
+		conditions[FilterGte] = start
+	}
+	if end, ok := dateRange["end"]; ok {
+		conditions[FilterLte] = end
+	}
+	return conditions
+}
+
+func convertFilterValue(kind, operator string, value interface{}) (interface{}, error) {
+	switch operator {
+	case FilterIn:
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, errors.New("in espera una lista con al menos un valor")
+		}
+		if len(list) > maxFilterValues {
+			return nil, fmt.Errorf("in admite como máximo %d valores", maxFilterValues)
+		}
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			converted, err := convertScalar(kind, item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = converted
+		}
+		return values, nil
+	case FilterBetween:
+		var bounds []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			bounds = v
+		case map[string]interface{}:
+			bounds = []interface{}{v["start"], v["end"]}
+		}
+		if len(bounds) != 2 {
+			return nil, errors.New("between espera dos valores [desde, hasta]")
+		}
+		from, err := convertScalar(kind, bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := convertScalar(kind, bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{from, to}, nil
+	case FilterContains:
+		text, ok := value.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, errors.New("contains espera un texto")
+		}
+		return text, nil
+	}
+	return convertScalar(kind, value)
+}
+
+func convertScalar(kind string, value interface{}) (interface{}, error) {
+	switch kind {
+	case filterText:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	case filterNumber:
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v), nil
+			}
+		case string:
+			if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return parsed, nil
+			}
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		}
+	case filterBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		case string:
+			if parsed, err := strconv.ParseBool(v); err == nil {
+				return parsed, nil
+			}
+		}
+	case filterDate:
+		if text, ok := value.(string); ok {
+			if _, err := time.Parse("2006-01-02", text); err == nil {
+				return text, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("se esperaba %s", kind)
+}
+
+// apply agrega las condiciones a la consulta; las columnas provienen del esquema, nunca de la solicitud
+func (filters reportFilters) apply(dbQuery *gorm.DB) *gorm.DB {
+	for _, filter := range filters {
+		// Las fechas se comparan por día, sin la hora
+		column := filter.column
+		if filter.kind == filterDate {
+			column = "DATE(" + filter.column + ")"
+		}
+
+		switch filter.operator {
+		case FilterEq:
+			dbQuery = dbQuery.Where(column+" = ?", filter.value)
+		case FilterIn:
+			dbQuery = dbQuery.Where(column+" IN ?", filter.value)
+		case FilterContains:
+			dbQuery = dbQuery.Where("LOWER("+column+") LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.value.(string)))+"%")
+		case FilterBetween:
+			bounds := filter.value.([]interface{})
+			dbQuery = dbQuery.Where(column+" BETWEEN ? AND ?", bounds[0], bounds[1])
+		case FilterGte:
+			dbQuery = dbQuery.Where(column+" >= ?", filter.value)
+		case FilterLte:
+			dbQuery = dbQuery.Where(column+" <= ?", filter.value)
+		}
+	}
+	return dbQuery
+}
+
+// value devuelve el primer valor del filtro indicado con el operador eq
+func (filters reportFilters) value(field string) (interface{}, bool) {
+	for _, filter := range filters {
+		if filter.field == field && filter.operator == FilterEq {
+			return filter.value, true
+		}
+	}
+	return nil, false
+}
+
+func escapeLike(text string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text)
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReportFiltersRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		filters map[string]interface{}
+		message string
+	}{
+		{"filtro desconocido", "User", map[string]interface{}{"password": "x"}, "filtro no permitido para User: password"},
+		{"columna de otro modelo", "Role", map[string]interface{}{"email": "a@b.com"}, "filtro no permitido"},
+		{"operador de texto en número", "User", map[string]interface{}{"id": map[string]interface{}{"contains": "1"}}, "no admite el operador contains"},
+		{"between en texto", "User", map[string]interface{}{"name": map[string]interface{}{"between": []interface{}{"a", "z"}}}, "no admite el operador between"},
+		{"in en booleano", "User", map[string]interface{}{"active": map[string]interface{}{"in": []interface{}{true}}}, "no admite el operador in"},
+		{"operador desconocido", "Audit", map[string]interface{}{"event": map[string]interface{}{"like": "%"}}, "no admite el operador like"},
+		{"texto en número", "User", map[string]interface{}{"id": "uno"}, "se esperaba número"},
+		{"número decimal", "Audit", map[string]interface{}{"user_id": 1.5}, "se esperaba número"},
+		{"número en booleano", "User", map[string]interface{}{"active": 2.0}, "se esperaba booleano"},
+		{"fecha inválida", "Audit", map[string]interface{}{"date": "19/10/2024"}, "se esperaba fecha"},
+		{"número en texto", "User", map[string]interface{}{"name": 5.0}, "se esperaba texto"},
+		{"in vacío", "User", map[string]interface{}{"id": map[string]interface{}{"in": []interface{}{}}}, "in espera una lista"},
+		{"between incompleto", "Audit", map[string]interface{}{"date": map[string]interface{}{"between": []interface{}{"2024-01-01"}}}, "between espera dos valores"},
+		{"contains vacío", "User", map[string]interface{}{"name": map[string]interface{}{"contains": " "}}, "contains espera un texto"},
+		{"sin condiciones", "User", map[string]interface{}{"name": map[string]interface{}{}}, "no tiene condiciones"},
+		{"date_range con fecha inválida", "User", map[string]interface{}{"date_range": map[string]interface{}{"start": "ayer"}}, "se esperaba fecha"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseReportFilters(tt.model, tt.filters)
+			var filterErr *FilterError
+			if !errors.As(err, &filterErr) {
+				t.Fatalf("se esperaba un FilterError, se obtuvo %v", err)
+			}
+			if !strings.Contains(filterErr.Message, tt.message) {
+				t.Errorf("mensaje %q, se esperaba que contenga %q", filterErr.Message, tt.message)
+			}
+			if !reflect.DeepEqual(filterErr.Allowed, AllowedReportFilters(tt.model)) {
+				t.Errorf("Allowed no corresponde a los filtros de %s: %v", tt.model, filterErr.Allowed)
+			}
+		})
+	}
+}
+
+func TestParseReportFiltersUnknownModel(t *testing.T) {
+	_, err := parseReportFilters("Secret", map[string]interface{}{})
+	var filterErr *FilterError
+	if err == nil || errors.As(err, &filterErr) {
+		t.Fatalf("un modelo desconocido no debe devolver FilterError, se obtuvo %v", err)
+	}
+}
+
+func TestAllowedReportFiltersHidesAliases(t *testing.T) {
+	allowed := AllowedReportFilters("Audit")
+	for _, alias := range []string{"userId", "date_range"} {
+		if _, ok := allowed[alias]; ok {
+			t.Errorf("el alias %s no debe aparecer entre los filtros permitidos", alias)
+		}
+	}
+	date, ok := allowed["date"]
+	if !ok || date.Type != filterDate || !reflect.DeepEqual(date.Operators, filterOperators[filterDate]) {
+		t.Errorf("filtro date inesperado: %+v", date)
+	}
+}
+
+func TestParseReportFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		filters map[string]interface{}
+		want    reportFilters
+	}{
+		{
+			name:    "valor simple equivale a eq",
+			model:   "User",
+			filters: map[string]interface{}{"name": "admin"},
+			want:    reportFilters{{field: "name", column: "users.name", kind: filterText, operator: FilterEq, value: "admin"}},
+		},
+		{
+			name:    "número desde JSON y desde texto",
+			model:   "Audit",
+			filters: map[string]interface{}{"user_id": map[string]interface{}{"in": []interface{}{3.0, "4"}}},
+			want:    reportFilters{{field: "user_id", column: "audit.user_id", kind: filterNumber, operator: FilterIn, value: []interface{}{int64(3), int64(4)}}},
+		},
+		{
+			name:    "booleano como texto",
+			model:   "Role",
+			filters: map[string]interface{}{"active": "false"},
+			want:    reportFilters{{field: "active", column: "roles.active", kind: filterBool, operator: FilterEq, value: false}},
+		},
+		{
+			name:    "alias userId",
+			model:   "Audit",
+			filters: map[string]interface{}{"userId": 7.0},
+			want:    reportFilters{{field: "user_id", column: "audit.user_id", kind: filterNumber, operator: FilterEq, value: int64(7)}},
+		},
+		{
+			name:  "date_range heredado",
+			model: "Audit",
+			filters: map[string]interface{}{
+				"date_range": map[string]interface{}{"start": "2024-01-01", "end": "2024-01-31"},
+			},
+			want: reportFilters{
+				{field: "date", column: "audit.date", kind: filterDate, operator: FilterGte, value: "2024-01-01"},
+				{field: "date", column: "audit.date", kind: filterDate, operator: FilterLte, value: "2024-01-31"},
+			},
+		},
+		{
+			name:    "date_range heredado solo con inicio",
+			model:   "User",
+			filters: map[string]interface{}{"date_range": map[string]interface{}{"start": "2024-03-01"}},
+			want:    reportFilters{{field: "created_at", column: "users.created_at", kind: filterDate, operator: FilterGte, value: "2024-03-01"}},
+		},
+		{
+			name:  "date_range con operador between",
+			model: "Permission",
+			filters: map[string]interface{}{
+				"date_range": map[string]interface{}{"between": []interface{}{"2024-01-01", "2024-06-30"}},
+			},
+			want: reportFilters{{field: "created_at", column: "permissions.created_at", kind: filterDate, operator: FilterBetween, value: []interface{}{"2024-01-01", "2024-06-30"}}},
+		},
+		{
+			name:    "between con objeto start/end",
+			model:   "Module",
+			filters: map[string]interface{}{"updated_at": map[string]interface{}{"between": map[string]interface{}{"start": "2024-01-01", "end": "2024-02-01"}}},
+			want:    reportFilters{{field: "updated_at", column: "modules.updated_at", kind: filterDate, operator: FilterBetween, value: []interface{}{"2024-01-01", "2024-02-01"}}},
+		},
+		{
+			name:  "varios filtros en orden alfabético",
+			model: "User",
+			filters: map[string]interface{}{
+				"name":  map[string]interface{}{"contains": "ana"},
+				"email": "ana@utn.edu.ec",
+			},
+			want: reportFilters{
+				{field: "email", column: "users.email", kind: filterText, operator: FilterEq, value: "ana@utn.edu.ec"},
+				{field: "name", column: "users.name", kind: filterText, operator: FilterContains, value: "ana"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReportFilters(tt.model, tt.filters)
+			if err != nil {
+				t.Fatalf("parseReportFilters: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseReportFilters =\n%+v\nse esperaba\n%+v", got, tt.want)
+			}
+		})
+	}
+}
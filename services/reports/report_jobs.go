@@ -0,0 +1,399 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultReportStorageDir    = "storage/reports"
+	defaultReportWorkers       = 2
+	defaultReportActivePerUser = 3
+	defaultReportRunPerUser    = 1
+	defaultReportRetention     = 24 * time.Hour
+	reportPollInterval         = 5 * time.Second
+	reportCleanupInterval      = time.Hour
+)
+
+var (
+	ErrReportJobNotFound = errors.New("reporte no encontrado")
+	ErrTooManyReportJobs = errors.New("ya tiene demasiados reportes en proceso, espere a que terminen")
+	ErrReportNotReady    = errors.New("el reporte aún no está listo")
+	ErrReportExpired     = errors.New("el reporte expiró, debe generarlo nuevamente")
+)
+
+var supportedReportModels = map[string]bool{"Permission": true, "User": true, "Role": true, "Module": true, "Audit": true, AccessMatrixModel: true, AccountRiskModel: true}
+
+type reportJobRunner struct {
+	storageDir    string
+	workers       int
+	activePerUser int
+	runPerUser    int
+	retention     time.Duration
+	wake          chan struct{}
+	claimMu       sync.Mutex
+}
+
+var jobRunner *reportJobRunner
+
+// StartReportJobs inicia los workers de reportes y la limpieza de archivos vencidos. Se configura con
+// REPORT_STORAGE_DIR, REPORT_WORKERS, REPORT_MAX_ACTIVE_PER_USER, REPORT_MAX_RUNNING_PER_USER y REPORT_RETENTION.
+func StartReportJobs() {
+	if jobRunner != nil {
+		return
+	}
+
+	runner := newReportJobRunner()
+	if err := os.MkdirAll(runner.storageDir, 0o755); err != nil {
+		log.Printf("No se pudo crear el directorio de reportes %s: %v", runner.storageDir, err)
+		return
+	}
+
+	// Los trabajos que quedaron en ejecución al detenerse el servicio vuelven a la cola
+	if err := config.DB.Model(&models.ReportJob{}).Where("status = ?", models.ReportJobRunning).
+		Updates(map[string]interface{}{"status": models.ReportJobPending, "progress": 0, "started_at": nil}).Error; err != nil {
+		log.Printf("Error al reencolar los reportes interrumpidos: %v", err)
+	}
+
+	for i := 0; i < runner.workers; i++ {
+		go runner.work()
+	}
+	go runner.cleanupLoop()
+	jobRunner = runner
+}
+
+func newReportJobRunner() *reportJobRunner {
+	runner := &reportJobRunner{
+		storageDir:    os.Getenv("REPORT_STORAGE_DIR"),
+		workers:       reportEnvInt("REPORT_WORKERS", defaultReportWorkers),
+		activePerUser: reportEnvInt("REPORT_MAX_ACTIVE_PER_USER", defaultReportActivePerUser),
+		runPerUser:    reportEnvInt("REPORT_MAX_RUNNING_PER_USER", defaultReportRunPerUser),
+		retention:     defaultReportRetention,
+		wake:          make(chan struct{}, 1),
+	}
+	if runner.storageDir == "" {
+		runner.storageDir = defaultReportStorageDir
+	}
+	if value := os.Getenv("REPORT_RETENTION"); value != "" {
+		if retention, err := time.ParseDuration(value); err == nil && retention > 0 {
+			runner.retention = retention
+		} else {
+			log.Printf("REPORT_RETENTION inválido (%s), se usará %s", value, runner.retention)
+		}
+	}
+	return runner
+}
+
+// EnqueueReportJob registra la solicitud de reporte y despierta a los workers
+func EnqueueReportJob(userID uint, modelName string, filters map[string]interface{}, userName, format, option string, layout ReportLayout, options ReportOutputOptions) (models.ReportJob, error) {
+	if jobRunner == nil {
+		return models.ReportJob{}, errors.New("la generación de reportes en segundo plano no está disponible")
+	}
+	if !ValidReportFormat(format) {
+		return models.ReportJob{}, fmt.Errorf("formato no soportado")
+	}
+	if !supportedReportModels[modelName] {
+		return models.ReportJob{}, fmt.Errorf("modelo no soportado")
+	}
+	if _, err := parseReportFilters(modelName, filters); err != nil {
+		return models.ReportJob{}, err
+	}
+	if err := validateReportLayout(modelName, option, layout); err != nil {
+		return models.ReportJob{}, err
+	}
+	if err := options.validate(); err != nil {
+		return models.ReportJob{}, err
+	}
+
+	var active int64
+	if err := config.DB.Model(&models.ReportJob{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.ReportJobPending, models.ReportJobRunning}).
+		Count(&active).Error; err != nil {
+		return models.ReportJob{}, err
+	}
+	if active >= int64(jobRunner.activePerUser) {
+		return models.ReportJob{}, ErrTooManyReportJobs
+	}
+
+	encodedFilters, err := json.Marshal(filters)
+	if err != nil {
+		return models.ReportJob{}, fmt.Errorf("filtros inválidos: %w", err)
+	}
+	encodedLayout, err := encodeReportLayout(layout)
+	if err != nil {
+		return models.ReportJob{}, err
+	}
+	encodedOutput, err := encodeReportOutput(options)
+	if err != nil {
+		return models.ReportJob{}, err
+	}
+
+	job := models.ReportJob{
+		UserID:   userID,
+		Model:    modelName,
+		Format:   format,
+		Option:   option,
+		Filters:  string(encodedFilters),
+		Layout:   encodedLayout,
+		Output:   encodedOutput,
+		Username: userName,
+		Status:   models.ReportJobPending,
+	}
+	if err := config.DB.Create(&job).Error; err != nil {
+		return job, err
+	}
+
+	select {
+	case jobRunner.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// GetReportJob obtiene un trabajo de reporte del usuario
+func GetReportJob(id, userID uint) (models.ReportJob, error) {
+	var job models.ReportJob
+	err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return job, ErrReportJobNotFound
+	}
+	return job, err
+}
+
+// GetPaginatedReportJobs lista los trabajos de reporte del usuario, del más reciente al más antiguo
+func GetPaginatedReportJobs(userID uint, page, pageSize int, status string) ([]models.ReportJob, int64, error) {
+	var jobs []models.ReportJob
+	var total int64
+
+	query := config.DB.Model(&models.ReportJob{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// GetReportJobFile devuelve el trabajo y su reporte firmado si el archivo está disponible para descargar
+func GetReportJobFile(id, userID uint) (models.ReportJob, models.SignedReport, error) {
+	var report models.SignedReport
+	job, err := GetReportJob(id, userID)
+	if err != nil {
+		return job, report, err
+	}
+
+	switch job.Status {
+	case models.ReportJobExpired:
+		return job, report, ErrReportExpired
+	case models.ReportJobCompleted:
+	default:
+		return job, report, ErrReportNotReady
+	}
+	if _, err := os.Stat(job.FilePath); err != nil {
+		return job, report, ErrReportExpired
+	}
+
+	err = config.DB.Where("report_id = ?", job.ReportID).First(&report).Error
+	return job, report, err
+}
+
+func (r *reportJobRunner) work() {
+	ticker := time.NewTicker(reportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			job, ok, err := r.claimNext()
+			if err != nil {
+				log.Printf("Error al obtener el siguiente reporte pendiente: %v", err)
+				break
+			}
+			if !ok {
+				break
+			}
+			r.run(job)
+		}
+
+		select {
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimNext toma el reporte pendiente más antiguo de un usuario que no haya alcanzado su límite de
+// reportes en ejecución. El cambio de estado es condicional para que dos workers no tomen el mismo.
+func (r *reportJobRunner) claimNext() (models.ReportJob, bool, error) {
+	r.claimMu.Lock()
+	defer r.claimMu.Unlock()
+
+	busyUsers := config.DB.Model(&models.ReportJob{}).Select("user_id").
+		Where("status = ?", models.ReportJobRunning).
+		Group("user_id").Having("COUNT(*) >= ?", r.runPerUser)
+
+	var job models.ReportJob
+	err := config.DB.Where("status = ? AND user_id NOT IN (?)", models.ReportJobPending, busyUsers).
+		Order("id").Limit(1).Find(&job).Error
+	if err != nil || job.ID == 0 {
+		return job, false, err
+	}
+
+	now := time.Now().UTC()
+	result := config.DB.Model(&models.ReportJob{}).
+		Where("id = ? AND status = ?", job.ID, models.ReportJobPending).
+		Updates(map[string]interface{}{"status": models.ReportJobRunning, "started_at": now, "progress": 0})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return job, false, result.Error
+	}
+	job.Status = models.ReportJobRunning
+	job.StartedAt = &now
+	return job, true, nil
+}
+
+func (r *reportJobRunner) run(job models.ReportJob) {
+	finish := func(updates map[string]interface{}) {
+		now := time.Now().UTC()
+		updates["finished_at"] = now
+		if err := config.DB.Model(&models.ReportJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+			log.Printf("Error al actualizar el reporte %d: %v", job.ID, err)
+		}
+	}
+	fail := func(err error) {
+		log.Printf("Error al generar el reporte %d: %v", job.ID, err)
+		finish(map[string]interface{}{"status": models.ReportJobFailed, "error": err.Error()})
+	}
+
+	var filters map[string]interface{}
+	if err := json.Unmarshal([]byte(job.Filters), &filters); err != nil {
+		fail(fmt.Errorf("filtros inválidos: %w", err))
+		return
+	}
+	layout, err := decodeReportLayout(job.Layout)
+	if err != nil {
+		fail(err)
+		return
+	}
+	options, err := decodeReportOutput(job.Output)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	lastProgress := 0
+	progress := func(value int) {
+		// Se escribe solo cuando el avance cambia lo suficiente para no saturar la base de datos
+		if value-lastProgress < 5 {
+			return
+		}
+		lastProgress = value
+		config.DB.Model(&models.ReportJob{}).Where("id = ?", job.ID).Update("progress", value)
+	}
+
+	generation, err := NewReport(job.Model, filters, job.UserID, job.Username, job.Format, job.Option, options, layout)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// El archivo se escribe directamente en disco a medida que se leen los registros
+	path := filepath.Join(r.storageDir, strconv.FormatUint(uint64(job.ID), 10)+"-"+generation.FileName)
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		fail(fmt.Errorf("no se pudo guardar el archivo: %w", err))
+		return
+	}
+	report, err := generation.render(file, progress)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("no se pudo guardar el archivo: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fail(err)
+		return
+	}
+	info, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		fail(fmt.Errorf("no se pudo guardar el archivo: %w", err))
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		fail(fmt.Errorf("no se pudo guardar el archivo: %w", err))
+		return
+	}
+
+	finish(map[string]interface{}{
+		"status":     models.ReportJobCompleted,
+		"progress":   100,
+		"file_name":  report.FileName,
+		"file_path":  path,
+		"size":       info.Size(),
+		"report_id":  report.ReportID,
+		"expires_at": time.Now().UTC().Add(r.retention),
+	})
+}
+
+func (r *reportJobRunner) cleanupLoop() {
+	ticker := time.NewTicker(reportCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if removed, err := CleanupExpiredReports(); err != nil {
+			log.Printf("Error al limpiar los reportes vencidos: %v", err)
+		} else if removed > 0 {
+			log.Printf("Se eliminaron %d reporte(s) vencidos", removed)
+		}
+	}
+}
+
+// CleanupExpiredReports elimina los archivos de los reportes cuya retención terminó
+func CleanupExpiredReports() (int, error) {
+	var jobs []models.ReportJob
+	err := config.DB.Where("status = ? AND expires_at < ?", models.ReportJobCompleted, time.Now().UTC()).Find(&jobs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, job := range jobs {
+		if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("No se pudo eliminar el archivo del reporte %d: %v", job.ID, err)
+			continue
+		}
+		if err := config.DB.Model(&job).Updates(map[string]interface{}{"status": models.ReportJobExpired, "file_path": ""}).Error; err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func reportEnvInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("%s inválido (%s), se usará %d", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	ReportFormatPDF    = "pdf"
+	ReportFormatExcel  = "excel"
+	ReportFormatCSV    = "csv"
+	ReportFormatJSON   = "json"
+	ReportFormatNDJSON = "ndjson"
+)
+
+type reportFormat struct {
+	extension   string
+	contentType string
+	// text indica un formato de texto que se escribe con rowWriter
+	text bool
+}
+
+var reportFormats = map[string]reportFormat{
+	ReportFormatPDF:    {extension: "pdf", contentType: "application/pdf"},
+	ReportFormatExcel:  {extension: "xlsx", contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	ReportFormatCSV:    {extension: "csv", contentType: "text/csv; charset=utf-8", text: true},
+	ReportFormatJSON:   {extension: "json", contentType: "application/json; charset=utf-8", text: true},
+	ReportFormatNDJSON: {extension: "ndjson", contentType: "application/x-ndjson; charset=utf-8", text: true},
+}
+
+var csvDelimiters = map[string]rune{"": ',', ",": ',', ";": ';', "|": '|', "\t": '\t', "tab": '\t'}
+
+// ReportOutputOptions ajusta la salida de los formatos de texto y la presentación del reporte. Los campos
+// de presentación vacíos se toman de la presentación guardada del módulo, de la general o de la
+// configuración del servicio, en ese orden.
+type ReportOutputOptions struct {
+	// Delimiter es el separador del CSV: ",", ";", "|" o "tab" (por defecto ",")
+	Delimiter string `json:"delimiter,omitempty"`
+	// BOM antepone la marca de orden de bytes UTF-8 al CSV para que Excel reconozca los acentos
+	BOM bool `json:"bom,omitempty"`
+	// Locale es el idioma de los encabezados y textos del reporte: "es" o "en"
+	Locale string `json:"locale,omitempty"`
+	// Timezone es la zona horaria IANA de la fecha de generación (p. ej. America/Guayaquil)
+	Timezone string `json:"timezone,omitempty"`
+	// DateFormat es el formato de las fechas, p. ej. "DD/MM/YYYY HH:mm"
+	DateFormat string `json:"date_format,omitempty"`
+	// Orientation es la orientación de la página del PDF: "portrait" o "landscape"
+	Orientation string `json:"orientation,omitempty"`
+	// BrandingModuleID usa el logo, los colores y la configuración guardados para ese módulo
+	BrandingModuleID uint `json:"branding_module_id,omitempty"`
+}
+
+// validate revisa el separador y que la presentación pedida sea válida
+func (o ReportOutputOptions) validate() error {
+	if _, ok := csvDelimiters[o.Delimiter]; !ok {
+		return fmt.Errorf("separador no soportado: %q", o.Delimiter)
+	}
+	_, err := resolveReportTheme(o)
+	return err
+}
+
+// encodeReportOutput guarda las opciones como JSON; las opciones por defecto se guardan vacías
+func encodeReportOutput(options ReportOutputOptions) (string, error) {
+	if options == (ReportOutputOptions{}) {
+		return "", nil
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", fmt.Errorf("opciones de salida inválidas: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func decodeReportOutput(encoded string) (ReportOutputOptions, error) {
+	var options ReportOutputOptions
+	if encoded == "" {
+		return options, nil
+	}
+	if err := json.Unmarshal([]byte(encoded), &options); err != nil {
+		return options, fmt.Errorf("opciones de salida inválidas: %w", err)
+	}
+	return options, nil
+}
+
+// ValidReportFormat indica si el formato de salida es uno de los soportados
+func ValidReportFormat(format string) bool {
+	_, ok := reportFormats[format]
+	return ok
+}
+
+// IsStreamedReportFormat indica si el formato es de texto y se puede enviar a medida que se genera
+func IsStreamedReportFormat(format string) bool {
+	return reportFormats[format].text
+}
+
+// ReportContentType devuelve el tipo MIME del archivo generado en el formato indicado
+func ReportContentType(format string) string {
+	if output, ok := reportFormats[format]; ok {
+		return output.contentType
+	}
+	return "application/octet-stream"
+}
+
+// rowWriter escribe las filas de un reporte en un formato de texto
+type rowWriter interface {
+	Write(row []string) error
+	Close() error
+}
+
+// newRowWriter escribe los encabezados traducidos en CSV; JSON y NDJSON usan las claves de las columnas
+func newRowWriter(w io.Writer, format string, headers, keys []string, options ReportOutputOptions) (rowWriter, error) {
+	switch format {
+	case ReportFormatCSV:
+		delimiter, ok := csvDelimiters[options.Delimiter]
+		if !ok {
+			return nil, fmt.Errorf("separador no soportado: %q", options.Delimiter)
+		}
+		buffered := bufio.NewWriter(w)
+		if options.BOM {
+			buffered.WriteString("\uFEFF")
+		}
+		writer := csv.NewWriter(buffered)
+		writer.Comma = delimiter
+		writer.UseCRLF = true
+		if err := writer.Write(headers); err != nil {
+			return nil, err
+		}
+		return &csvRowWriter{writer: writer, buffered: buffered}, nil
+	case ReportFormatJSON, ReportFormatNDJSON:
+		encodedKeys := make([][]byte, len(keys))
+		for i, key := range keys {
+			encoded, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			encodedKeys[i] = encoded
+		}
+		return &jsonRowWriter{writer: bufio.NewWriter(w), keys: encodedKeys, array: format == ReportFormatJSON}, nil
+	}
+	return nil, fmt.Errorf("formato no soportado")
+}
+
+// csvRowWriter escribe CSV según RFC 4180 (campos entre comillas cuando hace falta y fin de línea CRLF)
+type csvRowWriter struct {
+	writer   *csv.Writer
+	buffered *bufio.Writer
+}
+
+func (w *csvRowWriter) Write(row []string) error {
+	return w.writer.Write(row)
+}
+
+func (w *csvRowWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return err
+	}
+	return w.buffered.Flush()
+}
+
+// jsonRowWriter escribe cada fila como un objeto con las claves de las columnas, respetando el orden
+// de las columnas. En JSON las filas forman un arreglo; en NDJSON va un objeto por línea.
+type jsonRowWriter struct {
+	writer *bufio.Writer
+	keys   [][]byte
+	array  bool
+	rows   int
+}
+
+func (w *jsonRowWriter) Write(row []string) error {
+	if w.array {
+		if w.rows == 0 {
+			w.writer.WriteString("[\n")
+		} else {
+			w.writer.WriteString(",\n")
+		}
+	}
+	w.rows++
+
+	w.writer.WriteByte('{')
+	for i, key := range w.keys {
+		if i > 0 {
+			w.writer.WriteByte(',')
+		}
+		value := ""
+		if i < len(row) {
+			value = row[i]
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		w.writer.Write(key)
+		w.writer.WriteByte(':')
+		w.writer.Write(encoded)
+	}
+	_, err := w.writer.WriteString("}")
+	if !w.array {
+		_, err = w.writer.WriteString("\n")
+	}
+	return err
+}
+
+func (w *jsonRowWriter) Close() error {
+	if w.array {
+		if w.rows == 0 {
+			w.writer.WriteString("[")
+		}
+		w.writer.WriteString("\n]\n")
+	}
+	return w.writer.Flush()
+}
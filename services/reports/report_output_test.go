@@ -0,0 +1,39 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRowWriterKeys(t *testing.T) {
+	headers := []string{"Nombre", "Correo Electrónico"}
+	keys := []string{"name", "email"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{ReportFormatCSV, "Nombre,Correo Electrónico\r\nana,ana@utn.edu.ec\r\n"},
+		{ReportFormatJSON, "[\n{\"name\":\"ana\",\"email\":\"ana@utn.edu.ec\"}\n]\n"},
+		{ReportFormatNDJSON, "{\"name\":\"ana\",\"email\":\"ana@utn.edu.ec\"}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			writer, err := newRowWriter(&out, tt.format, headers, keys, ReportOutputOptions{Delimiter: ","})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Write([]string{"ana", "ana@utn.edu.ec"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("%s =\n%q\nse esperaba\n%q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReportPresetNotFound = errors.New("diseño de reporte no encontrado")
+	ErrReportPresetExists   = errors.New("ya tiene un diseño de reporte con ese nombre")
+)
+
+// ReportPresetData son los datos editables de un diseño de reporte
+type ReportPresetData struct {
+	Name   string
+	Model  string
+	Option string
+	Layout ReportLayout
+}
+
+func (d *ReportPresetData) validate() error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return errors.New("el nombre del diseño es obligatorio")
+	}
+	if _, ok := reportColumnRegistries[d.Model]; !ok {
+		return fmt.Errorf("el modelo %s no admite columnas personalizadas", d.Model)
+	}
+	if d.Layout.IsZero() {
+		return errors.New("el diseño debe indicar columnas, ordenamiento, agrupación o resumen")
+	}
+	return validateReportLayout(d.Model, d.Option, d.Layout)
+}
+
+func (d ReportPresetData) apply(preset *models.ReportPreset) error {
+	layout, err := encodeReportLayout(d.Layout)
+	if err != nil {
+		return err
+	}
+	preset.Name = d.Name
+	preset.Model = d.Model
+	preset.Option = d.Option
+	preset.Layout = layout
+	return nil
+}
+
+// CreateReportPreset guarda un diseño de reporte del usuario
+func CreateReportPreset(userID uint, data ReportPresetData) (models.ReportPreset, error) {
+	preset := models.ReportPreset{UserID: userID}
+	if err := data.validate(); err != nil {
+		return preset, err
+	}
+	if err := data.apply(&preset); err != nil {
+		return preset, err
+	}
+	if err := ensureUniquePresetName(userID, preset.Name, 0); err != nil {
+		return preset, err
+	}
+
+	err := config.DB.Create(&preset).Error
+	return preset, err
+}
+
+// UpdateReportPreset reemplaza los datos de un diseño del usuario
+func UpdateReportPreset(id, userID uint, data ReportPresetData) (models.ReportPreset, error) {
+	preset, err := GetReportPreset(id, userID)
+	if err != nil {
+		return preset, err
+	}
+	if err := data.validate(); err != nil {
+		return preset, err
+	}
+	if err := data.apply(&preset); err != nil {
+		return preset, err
+	}
+	if err := ensureUniquePresetName(userID, preset.Name, preset.ID); err != nil {
+		return preset, err
+	}
+
+	err = config.DB.Save(&preset).Error
+	return preset, err
+}
+
+// DeleteReportPreset elimina un diseño del usuario
+func DeleteReportPreset(id, userID uint) (models.ReportPreset, error) {
+	preset, err := GetReportPreset(id, userID)
+	if err != nil {
+		return preset, err
+	}
+	err = config.DB.Delete(&preset).Error
+	return preset, err
+}
+
+// GetReportPreset obtiene un diseño del usuario
+func GetReportPreset(id, userID uint) (models.ReportPreset, error) {
+	var preset models.ReportPreset
+	err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&preset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return preset, ErrReportPresetNotFound
+	}
+	return preset, err
+}
+
+// GetReportPresets lista los diseños del usuario, opcionalmente de un solo modelo
+func GetReportPresets(userID uint, modelName string) ([]models.ReportPreset, error) {
+	var presets []models.ReportPreset
+	query := config.DB.Where("user_id = ?", userID)
+	if modelName != "" {
+		query = query.Where("model = ?", modelName)
+	}
+	err := query.Order("name").Find(&presets).Error
+	return presets, err
+}
+
+// ResolveReportLayout combina el diseño guardado con el indicado en la solicitud: las columnas, el
+// ordenamiento y la agrupación enviados reemplazan a los del diseño y summary agrega el resumen. Sin opción
+// se usa la del diseño.
+func ResolveReportLayout(userID, presetID uint, modelName, option string, layout ReportLayout) (ReportLayout, string, error) {
+	if presetID == 0 {
+		return layout, option, nil
+	}
+	preset, err := GetReportPreset(presetID, userID)
+	if err != nil {
+		return layout, option, err
+	}
+	if preset.Model != modelName {
+		return layout, option, fmt.Errorf("el diseño %s es del modelo %s", preset.Name, preset.Model)
+	}
+	resolved, err := decodeReportLayout(preset.Layout)
+	if err != nil {
+		return layout, option, err
+	}
+
+	if len(layout.Columns) > 0 {
+		resolved.Columns = layout.Columns
+	}
+	if len(layout.Sort) > 0 {
+		resolved.Sort = layout.Sort
+	}
+	if layout.GroupBy != "" {
+		resolved.GroupBy = layout.GroupBy
+	}
+	resolved.Summary = resolved.Summary || layout.Summary
+	if option == "" {
+		option = preset.Option
+	}
+	return resolved, option, nil
+}
+
+func ensureUniquePresetName(userID uint, name string, excludeID uint) error {
+	var count int64
+	if err := config.DB.Model(&models.ReportPreset{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReportPresetExists
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"seguridad-api/config"
+	"strconv"
+)
+
+// reportRelation carga un dato de otra tabla (nombre, correo o cantidad de registros relacionados) para
+// los IDs de un lote con una sola consulta. Los reportes leen por lotes, así que cada relación cuesta una
+// consulta por lote y no una por registro.
+type reportRelation struct {
+	load func(ids []uint) (map[uint]string, error)
+}
+
+// relatedValues son los datos de las relaciones de un lote: nombre de la relación -> ID -> valor
+type relatedValues map[string]map[uint]string
+
+// reportRelations son las relaciones que pueden usar las columnas de los reportes
+var reportRelations = map[string]reportRelation{
+	"user_name":   {load: lookupColumn("users", "name")},
+	"user_email":  {load: lookupColumn("users", "email")},
+	"module_name": {load: lookupColumn("modules", "name")},
+
+	"user_roles":         {load: countRelated("SELECT user_id, COUNT(DISTINCT role_id) FROM user_roles WHERE user_id IN ? GROUP BY user_id")},
+	"user_permissions":   {load: countRelated("SELECT user_roles.user_id, COUNT(DISTINCT role_permissions.permission_id) FROM user_roles JOIN role_permissions ON role_permissions.role_id = user_roles.role_id WHERE user_roles.user_id IN ? GROUP BY user_roles.user_id")},
+	"role_users":         {load: countRelated("SELECT role_id, COUNT(DISTINCT user_id) FROM user_roles WHERE role_id IN ? GROUP BY role_id")},
+	"role_permissions":   {load: countRelated("SELECT role_id, COUNT(DISTINCT permission_id) FROM role_permissions WHERE role_id IN ? GROUP BY role_id")},
+	"permission_roles":   {load: countRelated("SELECT permission_id, COUNT(DISTINCT role_id) FROM role_permissions WHERE permission_id IN ? GROUP BY permission_id")},
+	"module_roles":       {load: countRelated("SELECT id_module, COUNT(*) FROM roles WHERE id_module IN ? GROUP BY id_module")},
+	"module_permissions": {load: countRelated("SELECT module_id, COUNT(*) FROM permissions WHERE module_id IN ? GROUP BY module_id")},
+}
+
+// lookupColumn lee una columna de la tabla por ID; la tabla y la columna vienen del registro, nunca de la
+// solicitud
+func lookupColumn(table, column string) func(ids []uint) (map[uint]string, error) {
+	return func(ids []uint) (map[uint]string, error) {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		err := config.DB.Table(table).Select("id, "+column+" AS value").Where("id IN ?", ids).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[uint]string, len(rows))
+		for _, row := range rows {
+			values[row.ID] = row.Value
+		}
+		return values, nil
+	}
+}
+
+// countRelated cuenta los registros relacionados con cada ID; los IDs sin relacionados quedan en 0. Las
+// tablas intermedias no tienen índice único, por eso las consultas cuentan valores distintos.
+func countRelated(query string) func(ids []uint) (map[uint]string, error) {
+	return func(ids []uint) (map[uint]string, error) {
+		rows, err := config.DB.Raw(query, ids).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		values := make(map[uint]string, len(ids))
+		for _, id := range ids {
+			values[id] = "0"
+		}
+		for rows.Next() {
+			var id uint
+			var count int64
+			if err := rows.Scan(&id, &count); err != nil {
+				return nil, err
+			}
+			values[id] = strconv.FormatInt(count, 10)
+		}
+		return values, rows.Err()
+	}
+}
+
+// relationLoader arma la carga de las relaciones que usan las columnas elegidas; devuelve nil si ninguna
+// columna usa relaciones
+func relationLoader[T any](columns []reportColumn[T]) func(batch []T) (relatedValues, error) {
+	used := map[string]func(T) uint{}
+	for _, column := range columns {
+		if column.relation != "" {
+			used[column.relation] = column.relationKey
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+
+	return func(batch []T) (relatedValues, error) {
+		related := make(relatedValues, len(used))
+		for name, key := range used {
+			seen := make(map[uint]bool, len(batch))
+			ids := make([]uint, 0, len(batch))
+			for _, record := range batch {
+				if id := key(record); id > 0 && !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			values, err := reportRelations[name].load(ids)
+			if err != nil {
+				return nil, fmt.Errorf("error al consultar los datos relacionados: %w", err)
+			}
+			related[name] = values
+		}
+		return related, nil
+	}
+}
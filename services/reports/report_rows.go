@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"seguridad-api/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// reportBatchSize es la cantidad de registros que se leen de la base por cada consulta
+const reportBatchSize = 500
+
+// reportKeyset recorre una consulta ordenada por las columnas elegidas y el ID de la tabla como
+// desempate. Cada página continúa después de los valores de la última fila leída, así el costo no
+// crece con la posición y las filas insertadas durante la exportación no desplazan a las demás.
+// Los NULL se tratan como los menores valores, igual que en el ORDER BY de MySQL.
+type reportKeyset struct {
+	table string
+	order []reportOrder
+}
+
+// ordered agrega el orden completo de la consulta
+func (k *reportKeyset) ordered(query *gorm.DB) *gorm.DB {
+	for _, item := range k.order {
+		query = query.Order(item.clause())
+	}
+	return query.Order(k.table + ".id")
+}
+
+// pages entrega los IDs de cada página en el orden del reporte
+func (k *reportKeyset) pages(query *gorm.DB, fn func(ids []uint) error) error {
+	selects := make([]string, 0, len(k.order)+1)
+	for _, item := range k.order {
+		selects = append(selects, item.column)
+	}
+	selects = append(selects, k.table+".id")
+
+	var last []interface{}
+	var lastID uint
+	for {
+		page := k.ordered(query.Session(&gorm.Session{}).Select(strings.Join(selects, ", ")))
+		if last != nil {
+			condition, args := k.after(last, lastID)
+			page = page.Where(condition, args...)
+		}
+		rows, err := page.Limit(reportBatchSize).Rows()
+		if err != nil {
+			return fmt.Errorf("error al consultar los datos: %w", err)
+		}
+
+		ids := make([]uint, 0, reportBatchSize)
+		values := make([]interface{}, len(k.order))
+		targets := make([]interface{}, len(k.order)+1)
+		for rows.Next() {
+			for i := range values {
+				targets[i] = &values[i]
+			}
+			targets[len(values)] = &lastID
+			if err := rows.Scan(targets...); err != nil {
+				rows.Close()
+				return fmt.Errorf("error al consultar los datos: %w", err)
+			}
+			ids = append(ids, lastID)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error al consultar los datos: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := fn(ids); err != nil {
+			return err
+		}
+		if len(ids) < reportBatchSize {
+			return nil
+		}
+		last = append(last[:0], values...)
+	}
+}
+
+// after arma la condición "posterior a la última fila": (c1 > v1) OR (c1 = v1 AND c2 > v2) ... OR
+// (c1 = v1 AND ... AND id > último id), con la comparación invertida en las columnas descendentes
+func (k *reportKeyset) after(last []interface{}, lastID uint) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for i := 0; i <= len(k.order); i++ {
+		terms := make([]string, 0, i+1)
+		termArgs := make([]interface{}, 0, i+1)
+		for j := 0; j < i; j++ {
+			if last[j] == nil {
+				terms = append(terms, k.order[j].column+" IS NULL")
+			} else {
+				terms = append(terms, k.order[j].column+" = ?")
+				termArgs = append(termArgs, last[j])
+			}
+		}
+		if i == len(k.order) {
+			terms = append(terms, k.table+".id > ?")
+			termArgs = append(termArgs, lastID)
+		} else {
+			item := k.order[i]
+			switch {
+			case last[i] == nil && item.desc:
+				// En orden descendente los NULL van al final: después solo quedan otros NULL
+				continue
+			case last[i] == nil:
+				terms = append(terms, item.column+" IS NOT NULL")
+			case item.desc:
+				terms = append(terms, "("+item.column+" < ? OR "+item.column+" IS NULL)")
+				termArgs = append(termArgs, last[i])
+			default:
+				terms = append(terms, item.column+" > ?")
+				termArgs = append(termArgs, last[i])
+			}
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		args = append(args, termArgs...)
+	}
+	return strings.Join(alternatives, " OR "), args
+}
+
+// reportSource recorre los registros de un modelo con los filtros del reporte y los entrega como filas
+// de texto, leyendo por lotes para no cargar toda la tabla en memoria
+type reportSource struct {
+	headers []string
+	count   func() (int64, error)
+	each    func(fn func(row []string) error) error
+
+	// keys son las claves estables de las columnas (no se traducen); se usan como claves en JSON y NDJSON
+	keys []string
+	// title reemplaza el título por defecto ("Reporte de <modelo>"); ya viene traducido
+	title string
+	// matrixLabels indica que el reporte es una matriz con esa cantidad de columnas de identificación
+	matrixLabels int
+	// abbreviate usa códigos con leyenda en las celdas de la matriz en PDF
+	abbreviate bool
+	// groupColumn es la columna por la que se agrupan las filas en PDF y Excel (-1 sin agrupación)
+	groupColumn int
+	// summary arma la página de resumen de PDF y Excel; es nil si no se pidió
+	summary func(records int) (*utils.ReportSummary, error)
+}
+
+func newReportSource(modelName string, filters map[string]interface{}, option string, layout ReportLayout, theme utils.ReportTheme) (*reportSource, error) {
+	conditions, err := parseReportFilters(modelName, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var model interface{}
+	switch modelName {
+	case "Permission":
+		model = &models.Permission{}
+	case "User":
+		model = &models.User{}
+	case "Role":
+		model = &models.Role{}
+	case "Module":
+		model = &models.Module{}
+	case "Audit":
+		model = &models.Audit{}
+	case AccessMatrixModel:
+		if !layout.IsZero() {
+			return nil, errors.New("la matriz de accesos no admite columnas, ordenamiento, agrupación ni resumen")
+		}
+		return newAccessMatrixSource(conditions, option, theme)
+	case AccountRiskModel:
+		if !layout.IsZero() {
+			return nil, errors.New("el reporte de cuentas de riesgo no admite columnas, ordenamiento, agrupación ni resumen")
+		}
+		return newAccountRiskSource(conditions, theme)
+	default:
+		return nil, fmt.Errorf("modelo no soportado")
+	}
+	if err := checkReportSummary(modelName, layout); err != nil {
+		return nil, err
+	}
+
+	dbQuery := conditions.apply(config.DB.Model(model))
+	source, err := reportColumnRegistries[modelName].source(dbQuery, option, layout, theme)
+	if err != nil {
+		return nil, err
+	}
+	if layout.Summary {
+		build := reportSummaries[modelName]
+		source.summary = func(records int) (*utils.ReportSummary, error) {
+			return build(conditions, records, theme)
+		}
+	}
+	return source, nil
+}
+
+// batchedSource arma el recorrido por lotes de un modelo; las relaciones se precargan en cada lote y
+// related (si no es nil) carga los datos de las relaciones sin precarga antes de convertir el lote.
+// Sin keyset se recorre con FindInBatches por clave primaria; con un orden elegido se pagina por keyset.
+func batchedSource[T any](headers, keys []string, query *gorm.DB, preloads []string, keyset *reportKeyset, related func(batch []T) (relatedValues, error), toRow func(T, relatedValues) []string) *reportSource {
+	// emit convierte y entrega las filas de un lote
+	emit := func(batch []T, fn func(row []string) error) error {
+		var values relatedValues
+		if related != nil {
+			var err error
+			if values, err = related(batch); err != nil {
+				return err
+			}
+		}
+		for _, record := range batch {
+			if err := fn(toRow(record, values)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &reportSource{
+		headers:     headers,
+		keys:        keys,
+		groupColumn: -1,
+		count: func() (int64, error) {
+			var total int64
+			err := query.Session(&gorm.Session{}).Count(&total).Error
+			return total, err
+		},
+		each: func(fn func(row []string) error) error {
+			tx := query.Session(&gorm.Session{})
+			for _, preload := range preloads {
+				tx = tx.Preload(preload)
+			}
+
+			if keyset != nil {
+				return keyset.pages(query, func(ids []uint) error {
+					var batch []T
+					if err := keyset.ordered(tx.Session(&gorm.Session{})).Where(keyset.table+".id IN ?", ids).Find(&batch).Error; err != nil {
+						return fmt.Errorf("error al consultar los datos: %w", err)
+					}
+					return emit(batch, fn)
+				})
+			}
+
+			var batch []T
+			var rowErr error
+			result := tx.FindInBatches(&batch, reportBatchSize, func(_ *gorm.DB, _ int) error {
+				rowErr = emit(batch, fn)
+				return rowErr
+			})
+			if rowErr != nil {
+				return rowErr
+			}
+			if result.Error != nil {
+				return fmt.Errorf("error al consultar los datos: %w", result.Error)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"seguridad-api/config"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
+	mailer "seguridad-api/services"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSchedulerInterval = 30 * time.Second
+	defaultScheduleWorkers   = 2
+)
+
+var ErrReportScheduleNotFound = errors.New("programación de reporte no encontrada")
+
+// sendReportEmail se puede reemplazar para enviar los reportes por otro medio
+var sendReportEmail = mailer.SendEmailWithAttachment
+
+// scheduleRunSlots limita las ejecuciones simultáneas de reportes programados (REPORT_SCHEDULE_WORKERS)
+var (
+	scheduleRunSlots     chan struct{}
+	scheduleRunSlotsOnce sync.Once
+)
+
+// ReportScheduleData son los datos editables de una programación de reporte
+type ReportScheduleData struct {
+	Name       string
+	Cron       string
+	Model      string
+	Filters    map[string]interface{}
+	Format     string
+	Option     string
+	Layout     ReportLayout
+	Output     ReportOutputOptions
+	Username   string
+	Recipients []string
+	Active     bool
+}
+
+// validate revisa la programación y devuelve la expresión cron interpretada
+func (d *ReportScheduleData) validate() (*CronSchedule, error) {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, errors.New("el nombre de la programación es obligatorio")
+	}
+	if !supportedReportModels[d.Model] {
+		return nil, fmt.Errorf("modelo no soportado")
+	}
+	if _, err := parseReportFilters(d.Model, d.Filters); err != nil {
+		return nil, err
+	}
+	if err := validateReportLayout(d.Model, d.Option, d.Layout); err != nil {
+		return nil, err
+	}
+	if err := d.Output.validate(); err != nil {
+		return nil, err
+	}
+	if !ValidReportFormat(d.Format) {
+		return nil, fmt.Errorf("formato no soportado")
+	}
+	if len(d.Recipients) == 0 {
+		return nil, errors.New("debe indicar al menos un destinatario")
+	}
+	for i, recipient := range d.Recipients {
+		address, err := mail.ParseAddress(strings.TrimSpace(recipient))
+		if err != nil {
+			return nil, fmt.Errorf("destinatario inválido: %s", recipient)
+		}
+		d.Recipients[i] = address.Address
+	}
+	return ParseCron(d.Cron, ReportLocation())
+}
+
+func (d ReportScheduleData) apply(schedule *models.ReportSchedule, cron *CronSchedule) error {
+	filters, err := json.Marshal(d.Filters)
+	if err != nil {
+		return fmt.Errorf("filtros inválidos: %w", err)
+	}
+	layout, err := encodeReportLayout(d.Layout)
+	if err != nil {
+		return err
+	}
+	output, err := encodeReportOutput(d.Output)
+	if err != nil {
+		return err
+	}
+
+	schedule.Name = d.Name
+	schedule.Cron = strings.TrimSpace(d.Cron)
+	schedule.Model = d.Model
+	schedule.Filters = string(filters)
+	schedule.Format = d.Format
+	schedule.Option = d.Option
+	schedule.Layout = layout
+	schedule.Output = output
+	schedule.Username = d.Username
+	schedule.Recipients = strings.Join(d.Recipients, ",")
+	schedule.Active = d.Active
+
+	schedule.NextRunAt = nil
+	if d.Active {
+		next := cron.Next(time.Now()).UTC()
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// CreateReportSchedule registra una programación y calcula su primera ejecución
+func CreateReportSchedule(data ReportScheduleData, createdBy uint) (models.ReportSchedule, error) {
+	schedule := models.ReportSchedule{CreatedBy: createdBy}
+	cron, err := data.validate()
+	if err != nil {
+		return schedule, err
+	}
+	if err := data.apply(&schedule, cron); err != nil {
+		return schedule, err
+	}
+
+	err = config.DB.Create(&schedule).Error
+	return schedule, err
+}
+
+// UpdateReportSchedule reemplaza los datos de una programación del usuario y recalcula la siguiente ejecución
+func UpdateReportSchedule(id, userID uint, data ReportScheduleData) (models.ReportSchedule, error) {
+	schedule, err := GetReportSchedule(id, userID)
+	if err != nil {
+		return schedule, err
+	}
+	cron, err := data.validate()
+	if err != nil {
+		return schedule, err
+	}
+	if err := data.apply(&schedule, cron); err != nil {
+		return schedule, err
+	}
+
+	err = config.DB.Save(&schedule).Error
+	return schedule, err
+}
+
+// DeleteReportSchedule elimina una programación del usuario junto con su historial de ejecuciones
+func DeleteReportSchedule(id, userID uint) (models.ReportSchedule, error) {
+	schedule, err := GetReportSchedule(id, userID)
+	if err != nil {
+		return schedule, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", id).Delete(&models.ReportScheduleRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&schedule).Error
+	})
+	return schedule, err
+}
+
+// GetReportSchedule obtiene una programación creada por el usuario. Las programaciones de otros
+// usuarios no se encuentran, para que nadie cambie los destinatarios de un reporte ajeno.
+func GetReportSchedule(id, userID uint) (models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	err := config.DB.Where("id = ? AND created_by = ?", id, userID).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return schedule, ErrReportScheduleNotFound
+	}
+	return schedule, err
+}
+
+// GetPaginatedReportSchedules lista las programaciones del usuario ordenadas por su próxima ejecución
+func GetPaginatedReportSchedules(userID uint, page, pageSize int) ([]models.ReportSchedule, int64, error) {
+	var schedules []models.ReportSchedule
+	var total int64
+
+	query := config.DB.Model(&models.ReportSchedule{}).Where("created_by = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("active DESC, next_run_at, id").Offset(offset).Limit(pageSize).Find(&schedules).Error
+	return schedules, total, err
+}
+
+// GetPaginatedReportScheduleRuns lista las ejecuciones de una programación, de la más reciente a la más antigua
+func GetPaginatedReportScheduleRuns(scheduleID uint, page, pageSize int) ([]models.ReportScheduleRun, int64, error) {
+	var runs []models.ReportScheduleRun
+	var total int64
+
+	query := config.DB.Model(&models.ReportScheduleRun{}).Where("schedule_id = ?", scheduleID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&runs).Error
+	return runs, total, err
+}
+
+// RunReportScheduleNow ejecuta una programación del usuario fuera de su horario sin alterar la siguiente
+// ejecución. El reporte se genera en segundo plano; el resultado queda en el historial de ejecuciones.
+func RunReportScheduleNow(id, userID uint) (models.ReportScheduleRun, error) {
+	schedule, err := GetReportSchedule(id, userID)
+	if err != nil {
+		return models.ReportScheduleRun{}, err
+	}
+
+	run, err := startScheduleRun(schedule, time.Now().UTC(), true)
+	if err != nil {
+		return run, err
+	}
+	dispatchScheduleRun(schedule, run)
+	return run, nil
+}
+
+// dispatchScheduleRun ejecuta la programación en segundo plano. Las ejecuciones esperan un lugar libre,
+// así un reporte grande no detiene al programador ni a las demás programaciones vencidas.
+func dispatchScheduleRun(schedule models.ReportSchedule, run models.ReportScheduleRun) {
+	scheduleRunSlotsOnce.Do(func() {
+		scheduleRunSlots = make(chan struct{}, reportEnvInt("REPORT_SCHEDULE_WORKERS", defaultScheduleWorkers))
+	})
+	go func() {
+		scheduleRunSlots <- struct{}{}
+		defer func() { <-scheduleRunSlots }()
+		executeScheduleRun(schedule, run)
+	}()
+}
+
+// StartReportScheduler revisa periódicamente las programaciones vencidas. El intervalo se configura
+// con REPORT_SCHEDULER_INTERVAL y el valor "off" lo desactiva; REPORT_SCHEDULE_WORKERS es la cantidad de
+// reportes programados que se generan a la vez.
+func StartReportScheduler() {
+	interval := defaultSchedulerInterval
+	if value := os.Getenv("REPORT_SCHEDULER_INTERVAL"); value != "" {
+		if strings.EqualFold(value, "off") {
+			log.Println("Programador de reportes deshabilitado")
+			return
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("REPORT_SCHEDULER_INTERVAL inválido (%s), se usará %s", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := RunDueReportSchedules(time.Now()); err != nil {
+				log.Printf("Error al ejecutar los reportes programados: %v", err)
+			}
+		}
+	}()
+}
+
+// RunDueReportSchedules inicia las programaciones activas cuya siguiente ejecución ya pasó; cada una se
+// genera en segundo plano. Si el servicio estuvo detenido, cada programación se ejecuta una sola vez y
+// continúa desde su próximo horario.
+func RunDueReportSchedules(now time.Time) error {
+	var due []models.ReportSchedule
+	err := config.DB.Where("active = ? AND next_run_at <= ?", true, now.UTC()).Order("next_run_at").Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range due {
+		cron, err := ParseCron(schedule.Cron, ReportLocation())
+		if err != nil {
+			log.Printf("La programación %d tiene una expresión cron inválida: %v", schedule.ID, err)
+			config.DB.Model(&schedule).Updates(map[string]interface{}{"active": false, "next_run_at": nil, "last_status": models.ReportRunFailed})
+			continue
+		}
+
+		// Se avanza la siguiente ejecución de forma condicional para que otra instancia no la repita
+		scheduledFor := *schedule.NextRunAt
+		next := cron.Next(now).UTC()
+		result := config.DB.Model(&models.ReportSchedule{}).
+			Where("id = ? AND next_run_at = ?", schedule.ID, scheduledFor).
+			Update("next_run_at", next)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		run, err := startScheduleRun(schedule, scheduledFor, false)
+		if err != nil {
+			log.Printf("Error al registrar la ejecución de la programación %d: %v", schedule.ID, err)
+			continue
+		}
+		dispatchScheduleRun(schedule, run)
+	}
+	return nil
+}
+
+func startScheduleRun(schedule models.ReportSchedule, scheduledFor time.Time, manual bool) (models.ReportScheduleRun, error) {
+	run := models.ReportScheduleRun{
+		ScheduleID:   schedule.ID,
+		ScheduledFor: scheduledFor,
+		StartedAt:    time.Now().UTC(),
+		Status:       models.ReportRunRunning,
+		Recipients:   schedule.Recipients,
+		Manual:       manual,
+	}
+	err := config.DB.Create(&run).Error
+	return run, err
+}
+
+// executeScheduleRun genera el reporte, lo envía a los destinatarios y registra el resultado
+func executeScheduleRun(schedule models.ReportSchedule, run models.ReportScheduleRun) {
+	updates := map[string]interface{}{"status": models.ReportRunSuccess}
+
+	if err := deliverScheduledReport(schedule, &run); err != nil {
+		log.Printf("Error en la ejecución %d de la programación %d: %v", run.ID, schedule.ID, err)
+		updates["status"] = models.ReportRunFailed
+		updates["error"] = err.Error()
+	}
+
+	now := time.Now().UTC()
+	updates["finished_at"] = now
+	updates["report_id"] = run.ReportID
+	updates["file_name"] = run.FileName
+	updates["records"] = run.Records
+	if err := config.DB.Model(&run).Updates(updates).Error; err != nil {
+		log.Printf("Error al guardar la ejecución %d: %v", run.ID, err)
+	}
+	if err := config.DB.Model(&models.ReportSchedule{}).Where("id = ?", schedule.ID).
+		Updates(map[string]interface{}{"last_run_at": now, "last_status": updates["status"]}).Error; err != nil {
+		log.Printf("Error al actualizar la programación %d: %v", schedule.ID, err)
+	}
+}
+
+func deliverScheduledReport(schedule models.ReportSchedule, run *models.ReportScheduleRun) error {
+	var filters map[string]interface{}
+	if err := json.Unmarshal([]byte(schedule.Filters), &filters); err != nil {
+		return fmt.Errorf("filtros inválidos: %w", err)
+	}
+	layout, err := decodeReportLayout(schedule.Layout)
+	if err != nil {
+		return err
+	}
+	options, err := decodeReportOutput(schedule.Output)
+	if err != nil {
+		return err
+	}
+
+	buffer, report, err := GenerateReport(schedule.Model, filters, schedule.CreatedBy, schedule.Username, schedule.Format, schedule.Option, layout, options)
+	if err != nil {
+		return err
+	}
+	run.ReportID = report.ReportID
+	run.FileName = report.FileName
+	run.Records = report.Records
+
+	generatedAt := helpers.AdjustToEcuadorTime(time.Now()).Format("02/01/2006 15:04")
+	subject := fmt.Sprintf("Reporte programado: %s", schedule.Name)
+	body := fmt.Sprintf("Se adjunta el reporte \"%s\" generado el %s.\nRegistros: %d\nID del reporte: %s\nSHA-256: %s",
+		schedule.Name, generatedAt, report.Records, report.ReportID, report.FileHash)
+
+	return sendReportEmail(strings.Split(schedule.Recipients, ","), subject, body, report.FileName, ReportContentType(schedule.Format), buffer.Bytes())
+}
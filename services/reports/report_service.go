@@ -2,289 +2,308 @@ package services
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"reflect"
+	"io"
 	"seguridad-api/config"
 	"seguridad-api/models"
 	"seguridad-api/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-func GenerateReport(modelName string, filters map[string]interface{}, userName string, format string, option string) (*bytes.Buffer, string, error) {
-	var headers []string
-	var data [][]string
-	var query interface{}
-
-	switch modelName {
-
-	case "Permission":
-		headers = []string{"Nombre", "Descripción", "Estado", "Módulo", "F. Creación", "F. Actualización"}
-		query = &[]models.Permission{}
-		dbQuery := config.DB.Model(query).Preload("Module")
-
-		// Aplicar filtros según los parámetros
-		for key, value := range filters {
-			switch key {
-			case "active":
-				dbQuery = dbQuery.Where("active = ?", value)
-			case "module_id":
-				dbQuery = dbQuery.Where("module_id = ?", value)
-			case "date_range":
-				dateRange, ok := value.(map[string]interface{})
-				if ok {
-					if start, exists := dateRange["start"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) >= ?", start)
-					}
-					if end, exists := dateRange["end"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) <= ?", end)
-					}
-				}
-			default:
-				dbQuery = dbQuery.Where(fmt.Sprintf("%s = ?", key), value)
-			}
-		}
+// ReportGeneration es un reporte preparado para escribirse. El ID y el nombre del archivo se conocen
+// antes de consultar los datos para poder enviarlos como encabezados de la descarga.
+type ReportGeneration struct {
+	ReportID    string
+	FileName    string
+	ContentType string
+	Format      string
 
-		// Realizar la consulta
-		if err := dbQuery.Find(query).Error; err != nil {
-			return nil, "", fmt.Errorf("error al consultar los datos: %w", err)
-		}
+	modelName string
+	filters   map[string]interface{}
+	// generatedBy es el nombre del usuario autenticado que pidió el reporte; userName solo se muestra
+	// en el encabezado del documento
+	generatedBy string
+	userName    string
+	option      string
+	options     ReportOutputOptions
+	theme       utils.ReportTheme
+	source      *reportSource
+	// total es la cantidad de registros contada al preparar el reporte; maxRows el máximo del formato
+	total   int64
+	maxRows int64
+}
 
-		// Construir las filas para exportar
-		rows := reflect.ValueOf(query).Elem()
-		for i := 0; i < rows.Len(); i++ {
-			permission := rows.Index(i).Interface().(models.Permission)
+// Máximo de registros por formato. gopdf conserva el documento completo hasta escribirlo, por eso el
+// PDF tiene el límite más bajo; el de Excel queda por debajo del máximo de filas de una hoja.
+const (
+	defaultReportPDFMaxRows   = 20000
+	defaultReportExcelMaxRows = 1000000
+)
 
-			// Determinar el estado
-			state := "Activo"
-			if !permission.Active {
-				state = "Inactivo"
-			}
+var ErrReportTooLarge = errors.New("el reporte supera el máximo de registros del formato")
 
-			// Verificar el nombre del módulo
-			moduleName := "Sin módulo"
-			if permission.Module.ID > 0 && permission.Module.Name != "" {
-				moduleName = permission.Module.Name
-			}
+// GenerateReport genera el reporte completo en memoria, lo firma y lo registra como emitido
+func GenerateReport(modelName string, filters map[string]interface{}, userID uint, userName string, format string, option string, layout ReportLayout, options ReportOutputOptions) (*bytes.Buffer, models.SignedReport, error) {
+	generation, err := NewReport(modelName, filters, userID, userName, format, option, options, layout)
+	if err != nil {
+		return nil, models.SignedReport{}, err
+	}
 
-			// Construir la fila
-			row := []string{
-				permission.Name,
-				permission.Description,
-				state,
-				moduleName,
-				permission.CreatedAt.Format("2006-01-02 15:04:05"),
-				permission.UpdatedAt.Format("2006-01-02 15:04:05"),
-			}
-			data = append(data, row)
-		}
+	var buffer bytes.Buffer
+	report, err := generation.Render(&buffer)
+	if err != nil {
+		return nil, report, err
+	}
+	return &buffer, report, nil
+}
 
-		// Validación extra para detectar claves foráneas inválidas (opcional)
-		if len(data) == 0 {
-			fmt.Println("Advertencia: Puede que haya claves foráneas inválidas en 'module_id'")
-		}
+// NewReport valida el modelo, el formato, las columnas, las opciones de salida y la presentación, cuenta
+// los registros y prepara la generación del reporte. Si los registros superan el máximo del formato
+// devuelve ErrReportTooLarge antes de escribir nada. El reporte se registra a nombre del usuario userID;
+// userName es solo la etiqueta del encabezado y, si está vacía, se usa el nombre del usuario.
+func NewReport(modelName string, filters map[string]interface{}, userID uint, userName string, format string, option string, options ReportOutputOptions, layout ReportLayout) (*ReportGeneration, error) {
+	output, ok := reportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("formato no soportado")
+	}
+	if _, ok := csvDelimiters[options.Delimiter]; !ok {
+		return nil, fmt.Errorf("separador no soportado: %q", options.Delimiter)
+	}
+	theme, err := resolveReportTheme(options)
+	if err != nil {
+		return nil, err
+	}
+	generatedBy, err := reportUserName(userID)
+	if err != nil {
+		return nil, err
+	}
+	if userName == "" {
+		userName = generatedBy
+	}
+	source, err := newReportSource(modelName, filters, option, layout, theme)
+	if err != nil {
+		return nil, err
+	}
+	total, err := source.count()
+	if err != nil {
+		return nil, fmt.Errorf("error al consultar los datos: %w", err)
+	}
+	maxRows := reportMaxRows(format)
+	if maxRows > 0 && total > maxRows {
+		return nil, tooLargeError(format, maxRows)
+	}
+	reportID, err := newReportID()
+	if err != nil {
+		return nil, fmt.Errorf("error al generar el ID del reporte: %w", err)
+	}
 
-	case "User":
-		if option == "usuariosCompletos" {
-			headers = []string{"Nombre", "Roles", "Permisos", "Módulos"}
-			var users []models.User
-			result := config.DB.Debug().
-				Preload("Roles.Permissions.Module").
-				Where(filters).
-				Find(&users)
-			if result.Error != nil {
-				return nil, "", fmt.Errorf("error al consultar los datos: %w", result.Error)
-			}
-			query = users
-		} else {
-			headers = []string{"Nombre", "Correo Electrónico", "Estado", "F. Creación", "F. Actualización"}
-			query = &[]models.User{}
-			dbQuery := config.DB.Model(query)
-			for key, value := range filters {
-				dbQuery = dbQuery.Where(fmt.Sprintf("%s = ?", key), value)
-			}
-			if err := dbQuery.Find(query).Error; err != nil {
-				return nil, "", fmt.Errorf("error al consultar los datos: %w", err)
-			}
-		}
+	return &ReportGeneration{
+		ReportID:    reportID,
+		FileName:    fmt.Sprintf("reporte_%s_%s.%s", modelName, time.Now().Format("20060102_150405"), output.extension),
+		ContentType: output.contentType,
+		Format:      format,
+		modelName:   modelName,
+		filters:     filters,
+		generatedBy: generatedBy,
+		userName:    userName,
+		option:      option,
+		options:     options,
+		theme:       theme,
+		source:      source,
+		total:       total,
+		maxRows:     maxRows,
+	}, nil
+}
 
-	case "Role":
-		headers = []string{"Nombre del Rol", "Descripción", "Estado", "F. Creación", "F. Actualización"}
-		query = &[]models.Role{}
-		dbQuery := config.DB.Model(query)
-		for key, value := range filters {
-			switch key {
-			case "active":
-				dbQuery = dbQuery.Where("active = ?", value)
-			case "date_range":
-				dateRange, ok := value.(map[string]interface{})
-				if ok {
-					if start, exists := dateRange["start"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) >= ?", start)
-					}
-					if end, exists := dateRange["end"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) <= ?", end)
-					}
-				}
-			default:
-				dbQuery = dbQuery.Where(fmt.Sprintf("%s = ?", key), value)
-			}
-		}
-		if err := dbQuery.Find(query).Error; err != nil {
-			return nil, "", fmt.Errorf("error al consultar los datos: %w", err)
+// reportUserName es el nombre registrado del usuario que solicita el reporte
+func reportUserName(userID uint) (string, error) {
+	var user models.User
+	if err := config.DB.Select("id", "name").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("usuario no encontrado")
 		}
+		return "", fmt.Errorf("error al obtener el usuario: %w", err)
+	}
+	return user.Name, nil
+}
 
-	case "Module":
-		headers = []string{"Nombre del Módulo", "Descripción", "Estado", "F. Creación", "F. Actualización"}
-		query = &[]models.Module{}
-		dbQuery := config.DB.Model(query)
-		for key, value := range filters {
-			switch key {
-			case "active":
-				dbQuery = dbQuery.Where("active = ?", value)
-			case "date_range":
-				dateRange, ok := value.(map[string]interface{})
-				if ok {
-					if start, exists := dateRange["start"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) >= ?", start)
-					}
-					if end, exists := dateRange["end"]; exists {
-						dbQuery = dbQuery.Where("DATE(created_at) <= ?", end)
-					}
-				}
-			default:
-				dbQuery = dbQuery.Where(fmt.Sprintf("%s = ?", key), value)
-			}
+// reportMaxRows es el máximo de registros del formato (REPORT_PDF_MAX_ROWS, REPORT_EXCEL_MAX_ROWS); los
+// formatos de texto no tienen límite
+func reportMaxRows(format string) int64 {
+	switch format {
+	case ReportFormatPDF:
+		return int64(reportEnvInt("REPORT_PDF_MAX_ROWS", defaultReportPDFMaxRows))
+	case ReportFormatExcel:
+		return int64(reportEnvInt("REPORT_EXCEL_MAX_ROWS", defaultReportExcelMaxRows))
+	}
+	return 0
+}
+
+func tooLargeError(format string, maxRows int64) error {
+	return fmt.Errorf("%w: el máximo para %s es %d registros; agregue filtros o use CSV, JSON o NDJSON", ErrReportTooLarge, format, maxRows)
+}
+
+// Render escribe el archivo en w a medida que se leen los registros, lo firma y lo registra como emitido.
+// Solo la matriz de accesos se arma en memoria, ya que se limita a un módulo.
+func (g *ReportGeneration) Render(w io.Writer) (models.SignedReport, error) {
+	return g.render(w, func(int) {})
+}
+
+// render informa el avance aproximado (0-100) en cada etapa
+func (g *ReportGeneration) render(w io.Writer, progress func(int)) (models.SignedReport, error) {
+	report := models.SignedReport{
+		ReportID:    g.ReportID,
+		FileName:    g.FileName,
+		Model:       g.modelName,
+		Format:      g.Format,
+		GeneratedBy: g.generatedBy,
+	}
+	progress(10)
+
+	content, err := newContentHasher(g.source.headers)
+	if err != nil {
+		return report, fmt.Errorf("error al calcular el hash del reporte: %w", err)
+	}
+	fileHash := sha256.New()
+	writer, err := g.newWriter(io.MultiWriter(w, fileHash))
+	if err != nil {
+		return report, fmt.Errorf("error al generar el archivo: %w", err)
+	}
+
+	// Cada fila se registra en el hash del contenido y pasa al formato de salida sin guardarse
+	err = g.source.each(func(row []string) error {
+		report.Records++
+		if g.maxRows > 0 && int64(report.Records) > g.maxRows {
+			return tooLargeError(g.Format, g.maxRows)
 		}
-		if err := dbQuery.Find(query).Error; err != nil {
-			return nil, "", fmt.Errorf("error al consultar los datos: %w", err)
+		if err := content.add(row); err != nil {
+			return err
 		}
-
-	case "Audit":
-		headers = []string{"Evento", "Descripción", "Usuario", "Servicio Origen", "Fecha"}
-		var audits []models.Audit
-		dbQuery := config.DB.Model(&audits)
-		for key, value := range filters {
-			switch key {
-			case "userId":
-				dbQuery = dbQuery.Where("user_id = ?", value)
-			case "date_range":
-				dateRange, ok := value.(map[string]interface{})
-				if ok {
-					if start, exists := dateRange["start"]; exists {
-						dbQuery = dbQuery.Where("DATE(date) >= ?", start)
-					}
-					if end, exists := dateRange["end"]; exists {
-						dbQuery = dbQuery.Where("DATE(date) <= ?", end)
-					}
-				}
-			}
+		if g.total > 0 && report.Records%reportBatchSize == 0 {
+			progress(10 + int(60*int64(report.Records)/g.total))
 		}
-		if err := dbQuery.Find(&audits).Error; err != nil {
-			return nil, "", fmt.Errorf("error al consultar los datos de auditoría: %w", err)
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error al generar el archivo: %w", err)
 		}
-		query = audits
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.ContentHash = content.sum()
+	progress(70)
 
-	default:
-		return nil, "", fmt.Errorf("modelo no soportado")
+	integrity := utils.ReportIntegrity{ReportID: g.ReportID, ContentHash: report.ContentHash}
+	if err := writer.Close(integrity); err != nil {
+		return report, fmt.Errorf("error al generar el archivo: %w", err)
 	}
+	progress(90)
 
-	// Procesar los datos
-	if query == nil {
-		return nil, "", fmt.Errorf("error: la consulta no devolvió resultados o el modelo '%s' no es válido", modelName)
+	report.FileHash = hex.EncodeToString(fileHash.Sum(nil))
+	if err := registerSignedReport(&report); err != nil {
+		return report, fmt.Errorf("error al firmar el reporte: %w", err)
 	}
+	return report, nil
+}
 
-	var rows reflect.Value
-	if reflect.TypeOf(query).Kind() == reflect.Ptr {
-		rows = reflect.ValueOf(query).Elem()
-	} else {
-		rows = reflect.ValueOf(query)
+// newWriter elige el escritor del formato: fila por fila para los formatos de texto, el escritor de
+// tablas para PDF y Excel, y la matriz para la matriz de accesos
+func (g *ReportGeneration) newWriter(out io.Writer) (utils.TableWriter, error) {
+	if reportFormats[g.Format].text {
+		writer, err := newRowWriter(out, g.Format, g.source.headers, g.source.keys, g.options)
+		if err != nil {
+			return nil, err
+		}
+		return textTableWriter{writer}, nil
 	}
 
-	for i := 0; i < rows.Len(); i++ {
-		row := []string{}
-		switch modelName {
-		case "User":
-			user := rows.Index(i).Interface().(models.User)
-			state := "Activo"
-			if !user.Active {
-				state = "Inactivo"
-			}
-			if option == "usuariosCompletos" {
-				roles, permissions, modules := formatUserDetails(user)
-				row = append(row, user.Name, roles, permissions, modules)
-			} else {
-				row = append(row,
-					user.Name,
-					user.Email,
-					state,
-					// user.ModuleKey,
-					user.CreatedAt.Format("2006-01-02 15:04:05"),
-					user.UpdatedAt.Format("2006-01-02 15:04:05"),
-				)
-			}
-		case "Role":
-			role := rows.Index(i).Interface().(models.Role)
-			state := "Activo"
-			if !role.Active {
-				state = "Inactivo"
-			}
-			row = append(row,
-				role.Name,
-				role.Description,
-				state,
-				role.CreatedAt.Format("2006-01-02 15:04:05"),
-				role.UpdatedAt.Format("2006-01-02 15:04:05"),
-			)
-		case "Module":
-			module := rows.Index(i).Interface().(models.Module)
-			state := "Activo"
-			if !module.Active {
-				state = "Inactivo"
-			}
-			row = append(row,
-				module.Name,
-				module.Description,
-				state,
-				module.CreatedAt.Format("2006-01-02 15:04:05"),
-				module.UpdatedAt.Format("2006-01-02 15:04:05"),
-			)
-		case "Audit": // Procesar datos para auditoría
-			audit := rows.Index(i).Interface().(models.Audit)
-			row = append(row,
-				audit.Event,
-				audit.Description,
-				fmt.Sprintf("%d", audit.UserID),
-				audit.OriginService,
-				audit.Date.Format("2006-01-02 15:04:05"),
-			)
+	title := g.source.title
+	if title == "" {
+		title = g.theme.Tf("Reporte de %s", g.theme.T(g.modelName))
+	}
+	filtersLabel := g.theme.Tf("Filtros [ %s ]", formatFilters(g.theme, g.filters))
+
+	if g.source.matrixLabels > 0 {
+		return &matrixTableWriter{out: out, pdf: g.Format == ReportFormatPDF, report: utils.MatrixReport{
+			Title:        title,
+			Filters:      filtersLabel,
+			UserName:     g.userName,
+			Headers:      g.source.headers,
+			LabelColumns: g.source.matrixLabels,
+			Abbreviate:   g.source.abbreviate,
+			Theme:        g.theme,
+		}}, nil
+	}
+
+	table := utils.TableReport{
+		Title:       title,
+		Filters:     filtersLabel,
+		UserName:    g.userName,
+		Option:      g.option,
+		Headers:     g.source.headers,
+		GroupColumn: g.source.groupColumn,
+		Theme:       g.theme,
+	}
+	if g.source.summary != nil {
+		summary, err := g.source.summary(int(g.total))
+		if err != nil {
+			return nil, fmt.Errorf("error al armar el resumen: %w", err)
 		}
-		data = append(data, row)
+		table.Summary = summary
 	}
+	if g.Format == ReportFormatPDF {
+		return utils.NewPDFTableWriter(out, table)
+	}
+	return utils.NewExcelTableWriter(out, table)
+}
 
-	// Generar el archivo
-	title := fmt.Sprintf("Reporte de %s", modelName)
-	formattedFilters := formatFilters(filters)
+// textTableWriter adapta un rowWriter; los formatos de texto no imprimen la identificación del reporte
+type textTableWriter struct {
+	rowWriter
+}
+
+func (w textTableWriter) Close(utils.ReportIntegrity) error {
+	return w.rowWriter.Close()
+}
 
+// matrixTableWriter retiene las filas de la matriz y la arma al cerrar
+type matrixTableWriter struct {
+	out    io.Writer
+	pdf    bool
+	report utils.MatrixReport
+}
+
+func (w *matrixTableWriter) Write(row []string) error {
+	w.report.Data = append(w.report.Data, row)
+	return nil
+}
+
+func (w *matrixTableWriter) Close(integrity utils.ReportIntegrity) error {
+	w.report.Integrity = integrity
 	var fileBuffer *bytes.Buffer
-	var fileName string
 	var err error
-
-	switch format {
-	case "pdf":
-		fileBuffer, err = utils.GeneratePDF(title, "Filtros [ "+formattedFilters+" ]", data, headers, userName, option)
-		fileName = fmt.Sprintf("reporte_%s_%s.pdf", modelName, time.Now().Format("20060102_150405"))
-	case "excel":
-		fileBuffer, err = utils.GenerateExcel(title, headers, data, "Filtros [ "+formattedFilters+" ]", userName, option)
-		fileName = fmt.Sprintf("reporte_%s_%s.xlsx", modelName, time.Now().Format("20060102_150405"))
-	default:
-		return nil, "", fmt.Errorf("formato no soportado")
+	if w.pdf {
+		fileBuffer, err = utils.GenerateMatrixPDF(w.report)
+	} else {
+		fileBuffer, err = utils.GenerateMatrixExcel(w.report)
 	}
-
 	if err != nil {
-		return nil, "", fmt.Errorf("error al generar el archivo: %w", err)
+		return err
 	}
+	_, err = io.Copy(w.out, fileBuffer)
+	return err
+}
 
-	return fileBuffer, fileName, nil
+func activeState(theme utils.ReportTheme, active bool) string {
+	if active {
+		return theme.T("Activo")
+	}
+	return theme.T("Inactivo")
 }
 
 func formatUserDetails(user models.User) (roles, permissions, modules string) {
@@ -311,9 +330,9 @@ func formatUserDetails(user models.User) (roles, permissions, modules string) {
 	return
 }
 
-func formatFilters(filters map[string]interface{}) string {
+func formatFilters(theme utils.ReportTheme, filters map[string]interface{}) string {
 	if len(filters) == 0 {
-		return "Ninguno"
+		return theme.T("Ninguno")
 	}
 
 	var result string
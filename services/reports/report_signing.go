@@ -0,0 +1,349 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const defaultReportKeyFile = "storage/keys/report-signing.pem"
+
+// ReportSigner firma los reportes con la clave Ed25519 del servicio
+type ReportSigner struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	KeyID      string
+}
+
+// ReportVerification es el resultado de verificar un archivo contra los reportes emitidos
+type ReportVerification struct {
+	Valid    bool                 `json:"valid"`
+	Reason   string               `json:"reason,omitempty"`
+	FileHash string               `json:"file_hash"`
+	Report   *models.SignedReport `json:"report,omitempty"`
+}
+
+var (
+	signerOnce sync.Once
+	signer     *ReportSigner
+	signerErr  error
+)
+
+// GetReportSigner carga la clave privada PKCS#8 de REPORT_SIGNING_KEY_FILE. Si la variable está
+// configurada el archivo debe existir; sin ella se usa storage/keys y, si no existe, se genera una clave
+// nueva. En despliegues con varias instancias todas deben compartir el mismo archivo. La clave pública
+// se registra en report_signing_keys para verificar los reportes firmados con ella aun después de cambiarla.
+func GetReportSigner() (*ReportSigner, error) {
+	signerOnce.Do(func() {
+		path := os.Getenv("REPORT_SIGNING_KEY_FILE")
+		configured := path != ""
+		if !configured {
+			path = defaultReportKeyFile
+		}
+		signer, signerErr = loadReportSigner(path, configured)
+		if signerErr == nil {
+			signerErr = registerSigningKey(signer)
+		}
+	})
+	return signer, signerErr
+}
+
+func loadReportSigner(path string, configured bool) (*ReportSigner, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Generar otra clave en la ruta configurada ocultaría un volumen o secreto mal montado
+		if configured {
+			return nil, fmt.Errorf("no existe la clave de firma de reportes configurada en REPORT_SIGNING_KEY_FILE (%s)", path)
+		}
+		return generateReportKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la clave de firma de reportes: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("la clave de firma de reportes no está en formato PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("clave de firma de reportes inválida: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("la clave de firma de reportes debe ser Ed25519")
+	}
+	return newReportSigner(privateKey), nil
+}
+
+func generateReportKey(path string) (*ReportSigner, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio de la clave de firma: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, fmt.Errorf("no se pudo guardar la clave de firma: %w", err)
+	}
+
+	log.Printf("ADVERTENCIA: REPORT_SIGNING_KEY_FILE no está configurado; se generó una nueva clave de firma de reportes en %s. "+
+		"Si el almacenamiento no es persistente la clave cambiará en cada despliegue", path)
+	return newReportSigner(privateKey), nil
+}
+
+// registerSigningKey guarda la clave pública vigente y marca como retiradas las anteriores
+func registerSigningKey(s *ReportSigner) error {
+	publicKey, err := s.PublicKeyPEM()
+	if err != nil {
+		return err
+	}
+	key := models.ReportSigningKey{KeyID: s.KeyID, PublicKey: publicKey}
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&key).Error; err != nil {
+		return fmt.Errorf("no se pudo registrar la clave de firma de reportes: %w", err)
+	}
+	// Una clave que se vuelve a usar deja de estar retirada
+	if err := config.DB.Model(&models.ReportSigningKey{}).Where("key_id = ?", s.KeyID).Update("retired_at", nil).Error; err != nil {
+		return err
+	}
+	return config.DB.Model(&models.ReportSigningKey{}).
+		Where("key_id <> ? AND retired_at IS NULL", s.KeyID).
+		Update("retired_at", time.Now().UTC()).Error
+}
+
+// signingPublicKey devuelve la clave pública con la que se firmó un reporte: la vigente o una retirada
+// del registro de claves. Devuelve nil si la clave no se conoce.
+func signingPublicKey(s *ReportSigner, keyID string) (ed25519.PublicKey, bool, error) {
+	if keyID == s.KeyID {
+		return s.publicKey, false, nil
+	}
+
+	var key models.ReportSigningKey
+	if err := config.DB.Where("key_id = ?", keyID).Limit(1).Find(&key).Error; err != nil {
+		return nil, false, err
+	}
+	if key.KeyID == "" {
+		return nil, false, nil
+	}
+	block, _ := pem.Decode([]byte(key.PublicKey))
+	if block == nil {
+		return nil, false, fmt.Errorf("la clave pública %s no está en formato PEM", keyID)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("clave pública %s inválida: %w", keyID, err)
+	}
+	publicKey, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, false, fmt.Errorf("la clave pública %s no es Ed25519", keyID)
+	}
+	return publicKey, true, nil
+}
+
+// GetReportPublicKey devuelve la clave pública registrada con ese ID, vigente o retirada
+func GetReportPublicKey(keyID string) (models.ReportSigningKey, error) {
+	var key models.ReportSigningKey
+	if err := config.DB.Where("key_id = ?", keyID).Limit(1).Find(&key).Error; err != nil {
+		return key, err
+	}
+	if key.KeyID == "" {
+		return key, errors.New("clave de firma no encontrada")
+	}
+	return key, nil
+}
+
+func newReportSigner(privateKey ed25519.PrivateKey) *ReportSigner {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(publicKey)
+	return &ReportSigner{privateKey: privateKey, publicKey: publicKey, KeyID: hex.EncodeToString(sum[:8])}
+}
+
+// PublicKeyPEM devuelve la clave pública para verificar las firmas fuera del servicio
+func (s *ReportSigner) PublicKeyPEM() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.publicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign firma el ID del reporte junto con el hash del archivo; devuelve la firma en base64
+func (s *ReportSigner) Sign(reportID, fileHash string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, signedReportMessage(reportID, fileHash)))
+}
+
+// Verify comprueba una firma generada con Sign
+func (s *ReportSigner) Verify(reportID, fileHash, signature string) bool {
+	return verifyReportSignature(s.publicKey, reportID, fileHash, signature)
+}
+
+func verifyReportSignature(publicKey ed25519.PublicKey, reportID, fileHash, signature string) bool {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(publicKey, signedReportMessage(reportID, fileHash), raw)
+}
+
+func signedReportMessage(reportID, fileHash string) []byte {
+	return []byte(reportID + "\n" + fileHash)
+}
+
+// newReportID genera el identificador que se imprime en el reporte
+func newReportID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// contentHasher resume las columnas y filas del reporte, independientemente del formato del archivo.
+// Las filas se agregan una a una; el resultado equivale al SHA-256 de {"headers":[...],"rows":[[...],...]}.
+type contentHasher struct {
+	hash hash.Hash
+	rows int
+}
+
+func newContentHasher(headers []string) (*contentHasher, error) {
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	h := &contentHasher{hash: sha256.New()}
+	h.hash.Write([]byte(`{"headers":`))
+	h.hash.Write(encoded)
+	h.hash.Write([]byte(`,"rows":`))
+	return h, nil
+}
+
+func (h *contentHasher) add(row []string) error {
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if h.rows == 0 {
+		h.hash.Write([]byte("["))
+	} else {
+		h.hash.Write([]byte(","))
+	}
+	h.hash.Write(encoded)
+	h.rows++
+	return nil
+}
+
+func (h *contentHasher) sum() string {
+	if h.rows == 0 {
+		h.hash.Write([]byte("null"))
+	} else {
+		h.hash.Write([]byte("]"))
+	}
+	h.hash.Write([]byte("}"))
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// registerSignedReport firma el hash del archivo generado y lo guarda en el registro de reportes emitidos
+func registerSignedReport(report *models.SignedReport) error {
+	reportSigner, err := GetReportSigner()
+	if err != nil {
+		return err
+	}
+
+	report.Signature = reportSigner.Sign(report.ReportID, report.FileHash)
+	report.KeyID = reportSigner.KeyID
+	return config.DB.Create(report).Error
+}
+
+// VerifyReport comprueba que el archivo fue emitido por el servicio y no fue modificado. Se busca en el
+// registro por el hash del archivo; si se envía una firma separada junto con el ID del reporte, se
+// valida también sin depender del registro.
+func VerifyReport(file io.Reader, reportID, signature string) (ReportVerification, error) {
+	reportSigner, err := GetReportSigner()
+	if err != nil {
+		return ReportVerification{}, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ReportVerification{}, fmt.Errorf("no se pudo leer el archivo: %w", err)
+	}
+	result := ReportVerification{FileHash: hex.EncodeToString(hash.Sum(nil))}
+
+	// Varios reportes de texto pueden compartir el archivo; si se envía la firma se busca ese registro primero
+	var report models.SignedReport
+	if signature != "" {
+		if err := config.DB.Where("file_hash = ? AND signature = ?", result.FileHash, signature).Limit(1).Find(&report).Error; err != nil {
+			return result, err
+		}
+	}
+	if report.ID == 0 {
+		if err := config.DB.Where("file_hash = ?", result.FileHash).Order("id DESC").Limit(1).Find(&report).Error; err != nil {
+			return result, err
+		}
+	}
+
+	if report.ID != 0 {
+		result.Report = &report
+		publicKey, retired, err := signingPublicKey(reportSigner, report.KeyID)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case publicKey == nil:
+			result.Reason = "El reporte fue firmado con una clave desconocida (" + report.KeyID + ")"
+		case !verifyReportSignature(publicKey, report.ReportID, report.FileHash, report.Signature):
+			result.Reason = "La firma registrada no corresponde al archivo"
+		case signature != "" && signature != report.Signature:
+			result.Reason = "La firma enviada no corresponde al archivo"
+		default:
+			result.Valid = true
+			if retired {
+				result.Reason = "Firma válida con la clave retirada " + report.KeyID
+			}
+		}
+		return result, nil
+	}
+
+	if reportID != "" && signature != "" && reportSigner.Verify(reportID, result.FileHash, signature) {
+		result.Valid = true
+		result.Reason = "Firma válida, pero el reporte no está en el registro de reportes emitidos"
+		return result, nil
+	}
+
+	if reportID != "" {
+		var issued models.SignedReport
+		if err := config.DB.Where("report_id = ?", reportID).Limit(1).Find(&issued).Error; err != nil {
+			return result, err
+		}
+		if issued.ID != 0 {
+			result.Reason = "El archivo del reporte " + reportID + " fue modificado"
+			return result, nil
+		}
+	}
+
+	result.Reason = "El archivo no corresponde a ningún reporte emitido por el servicio o fue modificado"
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	auditService "seguridad-api/services"
+	"seguridad-api/utils"
+	"sort"
+	"time"
+)
+
+const (
+	// maxSummaryCategories limita las barras de un gráfico; el resto se suma en "Otros"
+	maxSummaryCategories = 15
+	// maxDailySummaryDays es el periodo máximo que se grafica por día; más largo se agrupa por mes
+	maxDailySummaryDays = 92
+)
+
+// reportSummaries arma la página de resumen de cada modelo con los mismos filtros del reporte, con los textos
+// en el idioma del reporte
+var reportSummaries = map[string]func(conditions reportFilters, records int, theme utils.ReportTheme) (*utils.ReportSummary, error){
+	"Audit": auditSummary,
+	"User":  usersPerRoleSummary("usuarios"),
+	"Role":  usersPerRoleSummary("roles"),
+}
+
+// checkReportSummary revisa que el modelo tenga resumen cuando se pide
+func checkReportSummary(modelName string, layout ReportLayout) error {
+	if _, ok := reportSummaries[modelName]; layout.Summary && !ok {
+		return fmt.Errorf("el resumen con gráficos no está disponible para el modelo %s", modelName)
+	}
+	return nil
+}
+
+type summaryCount struct {
+	Name  string
+	Total float64
+	Fails float64
+}
+
+// auditSummary resume las auditorías por día, servicio de origen y evento. Los totales por servicio y
+// evento salen del mismo agregado que /audit/statistics.
+func auditSummary(conditions reportFilters, records int, theme utils.ReportTheme) (*utils.ReportSummary, error) {
+	var stats []struct {
+		Event         string
+		OriginService string
+		Total         int
+		Failures      int
+	}
+	if err := auditService.AuditStatisticsQuery(conditions.apply(config.DB.Model(&models.Audit{}))).Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	byService := map[string]*summaryCount{}
+	byEvent := map[string]*summaryCount{}
+	failures := 0
+	for _, stat := range stats {
+		for _, entry := range []struct {
+			counts map[string]*summaryCount
+			name   string
+		}{{byService, stat.OriginService}, {byEvent, stat.Event}} {
+			count, ok := entry.counts[entry.name]
+			if !ok {
+				count = &summaryCount{Name: entry.name}
+				entry.counts[entry.name] = count
+			}
+			count.Total += float64(stat.Total)
+			count.Fails += float64(stat.Failures)
+		}
+		failures += stat.Failures
+	}
+
+	var days []struct {
+		Day   string
+		Total float64
+		Fails float64
+	}
+	err := conditions.apply(config.DB.Model(&models.Audit{})).
+		Select("DATE(date) AS day, COUNT(*) AS total, SUM(CASE WHEN outcome = ? THEN 1 ELSE 0 END) AS fails", models.AuditOutcomeFailure).
+		Group("DATE(date)").Order("day").Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	daily := make([]summaryCount, len(days))
+	for i, day := range days {
+		// MySQL devuelve la fecha con hora y zona; basta con AAAA-MM-DD
+		name := day.Day
+		if len(name) > 10 {
+			name = name[:10]
+		}
+		daily[i] = summaryCount{Name: name, Total: day.Total, Fails: day.Fails}
+	}
+	daily, period := summarizeByPeriod(daily)
+
+	figures := []utils.SummaryFigure{
+		{Label: theme.T("Total de eventos"), Value: fmt.Sprintf("%d", records)},
+		{Label: theme.T("Eventos fallidos"), Value: fmt.Sprintf("%d", failures)},
+		{Label: theme.T("Servicios de origen"), Value: fmt.Sprintf("%d", len(byService))},
+	}
+	if len(days) > 0 {
+		figures = append(figures, utils.SummaryFigure{Label: theme.T("Periodo"), Value: theme.Tf("%s a %s", daily[0].Name, daily[len(daily)-1].Name)})
+	}
+
+	return &utils.ReportSummary{
+		Figures: figures,
+		Charts: []utils.SummaryChart{
+			summaryChart(theme, "Eventos por "+period, utils.ChartLine, daily, true),
+			summaryChart(theme, "Eventos por servicio de origen", utils.ChartBar, topCounts(theme, byService), true),
+			summaryChart(theme, "Eventos por tipo", utils.ChartColumn, topCounts(theme, byEvent), false),
+		},
+	}, nil
+}
+
+// usersPerRoleSummary cuenta los usuarios de cada rol dentro de los usuarios o roles del reporte
+func usersPerRoleSummary(subject string) func(conditions reportFilters, records int, theme utils.ReportTheme) (*utils.ReportSummary, error) {
+	return func(conditions reportFilters, records int, theme utils.ReportTheme) (*utils.ReportSummary, error) {
+		var roles []summaryCount
+		query := config.DB.Table("roles").
+			Joins("LEFT JOIN user_roles ON user_roles.role_id = roles.id").
+			Joins("LEFT JOIN users ON users.id = user_roles.user_id")
+		err := conditions.apply(query).
+			Select("roles.name AS name, COUNT(DISTINCT users.id) AS total").
+			Group("roles.id, roles.name").Order("total DESC, roles.name").Scan(&roles).Error
+		if err != nil {
+			return nil, err
+		}
+
+		counts := make(map[string]*summaryCount, len(roles))
+		for i := range roles {
+			counts[roles[i].Name] = &roles[i]
+		}
+
+		figures := []utils.SummaryFigure{{Label: theme.T("Total de " + subject), Value: fmt.Sprintf("%d", records)}}
+		if subject == "usuarios" {
+			var withoutRoles int64
+			query := config.DB.Model(&models.User{}).Where("NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)")
+			if err := conditions.apply(query).Count(&withoutRoles).Error; err != nil {
+				return nil, err
+			}
+			figures = append(figures, utils.SummaryFigure{Label: theme.T("Usuarios sin roles"), Value: fmt.Sprintf("%d", withoutRoles)})
+		} else {
+			unassigned := 0
+			for _, role := range roles {
+				if role.Total == 0 {
+					unassigned++
+				}
+			}
+			figures = append(figures, utils.SummaryFigure{Label: theme.T("Roles sin usuarios"), Value: fmt.Sprintf("%d", unassigned)})
+		}
+
+		return &utils.ReportSummary{
+			Figures: figures,
+			Charts:  []utils.SummaryChart{summaryChart(theme, "Usuarios por rol", utils.ChartBar, topCounts(theme, counts), false)},
+		}, nil
+	}
+}
+
+// summaryChart arma un gráfico; el título se escribe en español y se traduce
+func summaryChart(theme utils.ReportTheme, title, chartType string, counts []summaryCount, withFailures bool) utils.SummaryChart {
+	chart := utils.SummaryChart{Title: theme.T(title), Type: chartType, Categories: make([]string, len(counts))}
+	totals := utils.SummarySeries{Name: theme.T("Total"), Values: make([]float64, len(counts))}
+	fails := utils.SummarySeries{Name: theme.T("Fallidos"), Values: make([]float64, len(counts))}
+	for i, count := range counts {
+		chart.Categories[i] = count.Name
+		totals.Values[i] = count.Total
+		fails.Values[i] = count.Fails
+	}
+	chart.Series = []utils.SummarySeries{totals}
+	if withFailures {
+		chart.Series = append(chart.Series, fails)
+	}
+	return chart
+}
+
+// topCounts ordena de mayor a menor y suma en "Otros" lo que excede maxSummaryCategories
+func topCounts(theme utils.ReportTheme, counts map[string]*summaryCount) []summaryCount {
+	sorted := make([]summaryCount, 0, len(counts))
+	for _, count := range counts {
+		sorted = append(sorted, *count)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if len(sorted) <= maxSummaryCategories {
+		return sorted
+	}
+	others := summaryCount{Name: theme.T("Otros")}
+	for _, count := range sorted[maxSummaryCategories-1:] {
+		others.Total += count.Total
+		others.Fails += count.Fails
+	}
+	return append(sorted[:maxSummaryCategories-1], others)
+}
+
+// summarizeByPeriod completa con cero los días sin eventos; si el periodo es largo agrupa por mes
+func summarizeByPeriod(days []summaryCount) ([]summaryCount, string) {
+	if len(days) == 0 {
+		return days, "día"
+	}
+	first, errFirst := time.Parse("2006-01-02", days[0].Name)
+	last, errLast := time.Parse("2006-01-02", days[len(days)-1].Name)
+	if errFirst != nil || errLast != nil {
+		return days, "día"
+	}
+
+	if last.Sub(first) <= maxDailySummaryDays*24*time.Hour {
+		filled := make([]summaryCount, 0, int(last.Sub(first).Hours()/24)+1)
+		next := 0
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			name := day.Format("2006-01-02")
+			if next < len(days) && days[next].Name == name {
+				filled = append(filled, days[next])
+				next++
+				continue
+			}
+			filled = append(filled, summaryCount{Name: name})
+		}
+		return filled, "día"
+	}
+
+	var months []summaryCount
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(last); month = month.AddDate(0, 1, 0) {
+		months = append(months, summaryCount{Name: month.Format("2006-01")})
+	}
+	for _, day := range days {
+		for i := range months {
+			if day.Name[:7] == months[i].Name {
+				months[i].Total += day.Total
+				months[i].Fails += day.Fails
+				break
+			}
+		}
+	}
+	return months, "mes"
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"seguridad-api/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	reportFontsDir         = "assets/fonts"
+	defaultBrandingLogoDir = "storage/branding"
+	MaxReportBrandingLogo  = 1 << 20
+)
+
+var (
+	ErrReportBrandingNotFound = errors.New("presentación de reporte no encontrada")
+	ErrReportBrandingExists   = errors.New("ya existe una presentación de reportes general o para ese módulo")
+)
+
+// reportPresentation son los campos de presentación de un reporte. Pueden venir de la configuración del
+// servicio, de una presentación guardada o de la solicitud; solo se aplican los campos con valor.
+type reportPresentation struct {
+	Locale       string
+	Timezone     string
+	DateFormat   string
+	Orientation  string
+	PrimaryColor string
+	Font         string
+	LogoPath     string
+}
+
+func (p reportPresentation) apply(theme *utils.ReportTheme) error {
+	if p.Locale != "" {
+		if !utils.ValidReportLocale(p.Locale) {
+			return fmt.Errorf("idioma no soportado: %s (permitidos: %s)", p.Locale, strings.Join(utils.ReportLocales(), ", "))
+		}
+		theme.Locale = p.Locale
+	}
+	if p.Timezone != "" {
+		location, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return fmt.Errorf("zona horaria inválida: %s", p.Timezone)
+		}
+		theme.Location = location
+	}
+	if p.DateFormat != "" {
+		layout, err := utils.ParseReportDateFormat(p.DateFormat)
+		if err != nil {
+			return err
+		}
+		theme.DateFormat = layout
+	}
+	switch p.Orientation {
+	case "":
+	case utils.OrientationPortrait:
+		theme.Landscape = false
+	case utils.OrientationLandscape:
+		theme.Landscape = true
+	default:
+		return fmt.Errorf("orientación inválida: %s (use %s o %s)", p.Orientation, utils.OrientationPortrait, utils.OrientationLandscape)
+	}
+	if p.PrimaryColor != "" {
+		if _, err := utils.ParseReportColor(p.PrimaryColor); err != nil {
+			return err
+		}
+		theme.PrimaryColor = strings.ToUpper(p.PrimaryColor)
+	}
+	if p.Font != "" {
+		// Solo se aceptan fuentes del directorio de fuentes del servicio, nunca una ruta arbitraria
+		if filepath.Base(p.Font) != p.Font || !strings.EqualFold(filepath.Ext(p.Font), ".ttf") {
+			return fmt.Errorf("fuente inválida: %s (indique el nombre de un archivo .ttf de %s)", p.Font, reportFontsDir)
+		}
+		path := filepath.Join(reportFontsDir, p.Font)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("fuente no disponible: %s", p.Font)
+		}
+		theme.FontPath = path
+	}
+	if p.LogoPath != "" {
+		theme.LogoPath = p.LogoPath
+	}
+	return nil
+}
+
+func (p reportPresentation) validate() error {
+	theme := utils.DefaultReportTheme()
+	return p.apply(&theme)
+}
+
+var (
+	serviceThemeOnce sync.Once
+	serviceTheme     utils.ReportTheme
+)
+
+// defaultReportTheme es la presentación por defecto con los ajustes de REPORT_LOCALE, REPORT_TIMEZONE,
+// REPORT_DATE_FORMAT, REPORT_ORIENTATION, REPORT_PRIMARY_COLOR, REPORT_FONT y REPORT_LOGO
+func defaultReportTheme() utils.ReportTheme {
+	serviceThemeOnce.Do(func() {
+		serviceTheme = utils.DefaultReportTheme()
+		settings := []struct {
+			name  string
+			apply func(value string) reportPresentation
+		}{
+			{"REPORT_LOCALE", func(v string) reportPresentation { return reportPresentation{Locale: v} }},
+			{"REPORT_TIMEZONE", func(v string) reportPresentation { return reportPresentation{Timezone: v} }},
+			{"REPORT_DATE_FORMAT", func(v string) reportPresentation { return reportPresentation{DateFormat: v} }},
+			{"REPORT_ORIENTATION", func(v string) reportPresentation { return reportPresentation{Orientation: v} }},
+			{"REPORT_PRIMARY_COLOR", func(v string) reportPresentation { return reportPresentation{PrimaryColor: v} }},
+			{"REPORT_FONT", func(v string) reportPresentation { return reportPresentation{Font: v} }},
+		}
+		for _, setting := range settings {
+			value := os.Getenv(setting.name)
+			if value == "" {
+				continue
+			}
+			if err := setting.apply(value).apply(&serviceTheme); err != nil {
+				log.Printf("%s inválido (%v), se usará el valor por defecto", setting.name, err)
+			}
+		}
+		if logo := os.Getenv("REPORT_LOGO"); logo != "" {
+			if _, err := os.Stat(logo); err != nil {
+				log.Printf("REPORT_LOGO inválido (%v), se usará el logo por defecto", err)
+			} else {
+				serviceTheme.LogoPath = logo
+			}
+		}
+	})
+	return serviceTheme
+}
+
+// resolveReportTheme arma la presentación del reporte: la configuración del servicio, luego la presentación
+// general guardada, la del módulo indicado y por último lo pedido en la solicitud
+func resolveReportTheme(options ReportOutputOptions) (utils.ReportTheme, error) {
+	theme := defaultReportTheme()
+
+	var brandings []models.ReportBranding
+	query := config.DB.Where("module_id IS NULL")
+	if options.BrandingModuleID != 0 {
+		query = query.Or("module_id = ?", options.BrandingModuleID)
+	}
+	if err := query.Find(&brandings).Error; err != nil {
+		return theme, fmt.Errorf("error al consultar la presentación del reporte: %w", err)
+	}
+	// La presentación general va primero para que la del módulo la reemplace
+	for _, general := range []bool{true, false} {
+		for _, branding := range brandings {
+			if (branding.ModuleID == nil) == general {
+				if err := brandingPresentation(branding).apply(&theme); err != nil {
+					return theme, fmt.Errorf("la presentación guardada %d es inválida: %w", branding.ID, err)
+				}
+			}
+		}
+	}
+
+	err := reportPresentation{
+		Locale:      options.Locale,
+		Timezone:    options.Timezone,
+		DateFormat:  options.DateFormat,
+		Orientation: options.Orientation,
+	}.apply(&theme)
+	return theme, err
+}
+
+func brandingPresentation(branding models.ReportBranding) reportPresentation {
+	return reportPresentation{
+		Locale:       branding.Locale,
+		Timezone:     branding.Timezone,
+		DateFormat:   branding.DateFormat,
+		Orientation:  branding.Orientation,
+		PrimaryColor: branding.PrimaryColor,
+		Font:         branding.Font,
+		LogoPath:     branding.LogoPath,
+	}
+}
+
+// ReportBrandingData son los datos editables de una presentación; sin ModuleID es la presentación general
+type ReportBrandingData struct {
+	ModuleID     *uint
+	Locale       string
+	Timezone     string
+	DateFormat   string
+	Orientation  string
+	PrimaryColor string
+	Font         string
+}
+
+func (d ReportBrandingData) validate() error {
+	if d.ModuleID != nil {
+		var count int64
+		if err := config.DB.Model(&models.Module{}).Where("id = ?", *d.ModuleID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("módulo no encontrado")
+		}
+	}
+	return reportPresentation{
+		Locale:       d.Locale,
+		Timezone:     d.Timezone,
+		DateFormat:   d.DateFormat,
+		Orientation:  d.Orientation,
+		PrimaryColor: d.PrimaryColor,
+		Font:         d.Font,
+	}.validate()
+}
+
+func (d ReportBrandingData) apply(branding *models.ReportBranding) {
+	branding.ModuleID = d.ModuleID
+	branding.Locale = d.Locale
+	branding.Timezone = d.Timezone
+	branding.DateFormat = d.DateFormat
+	branding.Orientation = d.Orientation
+	branding.PrimaryColor = strings.ToUpper(d.PrimaryColor)
+	branding.Font = d.Font
+}
+
+// CreateReportBranding guarda la presentación general o la de un módulo
+func CreateReportBranding(data ReportBrandingData) (models.ReportBranding, error) {
+	var branding models.ReportBranding
+	if err := data.validate(); err != nil {
+		return branding, err
+	}
+	data.apply(&branding)
+	if err := ensureUniqueBranding(branding.ModuleID, 0); err != nil {
+		return branding, err
+	}
+	err := config.DB.Create(&branding).Error
+	return branding, err
+}
+
+// UpdateReportBranding reemplaza los datos de una presentación; el logo se conserva
+func UpdateReportBranding(id uint, data ReportBrandingData) (models.ReportBranding, error) {
+	branding, err := GetReportBranding(id)
+	if err != nil {
+		return branding, err
+	}
+	if err := data.validate(); err != nil {
+		return branding, err
+	}
+	data.apply(&branding)
+	if err := ensureUniqueBranding(branding.ModuleID, branding.ID); err != nil {
+		return branding, err
+	}
+	err = config.DB.Save(&branding).Error
+	return branding, err
+}
+
+// DeleteReportBranding elimina la presentación y su logo
+func DeleteReportBranding(id uint) (models.ReportBranding, error) {
+	branding, err := GetReportBranding(id)
+	if err != nil {
+		return branding, err
+	}
+	if err := config.DB.Delete(&branding).Error; err != nil {
+		return branding, err
+	}
+	if branding.LogoPath != "" {
+		if err := os.Remove(branding.LogoPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("No se pudo eliminar el logo de la presentación %d: %v", branding.ID, err)
+		}
+	}
+	return branding, nil
+}
+
+// GetReportBranding obtiene una presentación
+func GetReportBranding(id uint) (models.ReportBranding, error) {
+	var branding models.ReportBranding
+	err := config.DB.First(&branding, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return branding, ErrReportBrandingNotFound
+	}
+	branding.HasLogo = branding.LogoPath != ""
+	return branding, err
+}
+
+// GetReportBrandings lista las presentaciones, la general primero
+func GetReportBrandings() ([]models.ReportBranding, error) {
+	var brandings []models.ReportBranding
+	err := config.DB.Order("module_id IS NOT NULL, module_id").Find(&brandings).Error
+	for i := range brandings {
+		brandings[i].HasLogo = brandings[i].LogoPath != ""
+	}
+	return brandings, err
+}
+
+// SetReportBrandingLogo guarda el logo (PNG o JPEG) de una presentación en REPORT_BRANDING_DIR
+func SetReportBrandingLogo(id uint, content []byte) (models.ReportBranding, error) {
+	branding, err := GetReportBranding(id)
+	if err != nil {
+		return branding, err
+	}
+	if len(content) > MaxReportBrandingLogo {
+		return branding, fmt.Errorf("el logo supera el tamaño máximo de %d KB", MaxReportBrandingLogo>>10)
+	}
+	extension := ""
+	switch http.DetectContentType(content) {
+	case "image/png":
+		extension = ".png"
+	case "image/jpeg":
+		extension = ".jpg"
+	default:
+		return branding, errors.New("el logo debe ser una imagen PNG o JPEG")
+	}
+
+	dir := os.Getenv("REPORT_BRANDING_DIR")
+	if dir == "" {
+		dir = defaultBrandingLogoDir
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return branding, fmt.Errorf("error al guardar el logo: %w", err)
+	}
+	// Un nombre nuevo en cada carga evita que un reporte en curso lea un archivo a medio escribir
+	path := filepath.Join(dir, fmt.Sprintf("branding_%d_%d%s", branding.ID, time.Now().UnixNano(), extension))
+	if err := os.WriteFile(path, content, 0o640); err != nil {
+		return branding, fmt.Errorf("error al guardar el logo: %w", err)
+	}
+
+	previous := branding.LogoPath
+	if err := config.DB.Model(&branding).Update("logo_path", path).Error; err != nil {
+		os.Remove(path)
+		return branding, err
+	}
+	if previous != "" {
+		if err := os.Remove(previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("No se pudo eliminar el logo anterior de la presentación %d: %v", branding.ID, err)
+		}
+	}
+	branding.LogoPath = path
+	branding.HasLogo = true
+	return branding, nil
+}
+
+func ensureUniqueBranding(moduleID *uint, excludeID uint) error {
+	query := config.DB.Model(&models.ReportBranding{}).Where("id <> ?", excludeID)
+	if moduleID == nil {
+		query = query.Where("module_id IS NULL")
+	} else {
+		query = query.Where("module_id = ?", *moduleID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReportBrandingExists
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"seguridad-api/config"
@@ -21,7 +22,7 @@ func PermissionExists(permissionID uint) (bool, error) {
 	return result.RowsAffected > 0, result.Error
 }
 
-func AssignPermissionToRole(roleID, permissionID uint) (models.RolePermission, error) {
+func AssignPermissionToRole(ctx context.Context, roleID, permissionID uint) (models.RolePermission, error) {
 	fmt.Printf("Validando existencia del rol: %d\n", roleID)
 	exists, err := RoleExists(roleID)
 	if err != nil {
@@ -49,7 +50,7 @@ func AssignPermissionToRole(roleID, permissionID uint) (models.RolePermission, e
 		RoleID:       roleID,
 		PermissionID: permissionID,
 	}
-	result := config.DB.Create(&rolePermission)
+	result := config.DB.WithContext(ctx).Create(&rolePermission)
 	if result.Error != nil {
 		fmt.Printf("Error al crear relación rol-permiso: %v\n", result.Error)
 		return models.RolePermission{}, result.Error
@@ -60,7 +61,7 @@ func AssignPermissionToRole(roleID, permissionID uint) (models.RolePermission, e
 }
 
 // Eliminar un permiso de un rol
-func RemovePermissionFromRole(roleID, permissionID uint) error {
+func RemovePermissionFromRole(ctx context.Context, roleID, permissionID uint) error {
 	// Validar rol y permiso
 	exists, err := RoleExists(roleID)
 	if err != nil || !exists {
@@ -73,7 +74,7 @@ func RemovePermissionFromRole(roleID, permissionID uint) error {
 	}
 
 	// Eliminar la relación
-	result := config.DB.Where("role_id = ? AND permission_id = ?", roleID, permissionID).Delete(&models.RolePermission{})
+	result := config.DB.WithContext(ctx).Where("role_id = ? AND permission_id = ?", roleID, permissionID).Delete(&models.RolePermission{})
 	return result.Error
 }
 
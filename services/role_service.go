@@ -1,15 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"seguridad-api/config"
 	"seguridad-api/models"
 )
 
 // Crear un rol
-func CreateRole(name, description string, module uint) (models.Role, error) {
+func CreateRole(ctx context.Context, name, description string, module uint) (models.Role, error) {
 	role := models.Role{Name: name, Description: description, IDModule: module}
-	result := config.DB.Create(&role)
+	result := config.DB.WithContext(ctx).Create(&role)
 	return role, result.Error
 }
 
@@ -28,7 +29,7 @@ func GetRolesActive() ([]models.Role, error) {
 }
 
 // Actualizar un rol existente
-func UpdateRole(id int, name string, description string, active bool) (models.Role, error) {
+func UpdateRole(ctx context.Context, id int, name string, description string, active bool) (models.Role, error) {
 	var role models.Role
 
 	// Buscar el rol por ID
@@ -42,7 +43,7 @@ func UpdateRole(id int, name string, description string, active bool) (models.Ro
 	role.Active = active // Agregar actualización del estado
 
 	// Guardar los cambios
-	if err := config.DB.Save(&role).Error; err != nil {
+	if err := config.DB.WithContext(ctx).Save(&role).Error; err != nil {
 		return role, errors.New("error al actualizar el rol")
 	}
 
@@ -77,7 +78,7 @@ func GetPaginatedRoles(page, pageSize int, filters map[string]interface{}) ([]mo
 
 	return roles, total, nil
 }
-func UpdateRoleState(id int, active bool) error {
+func UpdateRoleState(ctx context.Context, id int, active bool) error {
 	var role models.Role
 
 	// Buscar el rol por ID
@@ -89,7 +90,7 @@ func UpdateRoleState(id int, active bool) error {
 	role.Active = active
 
 	// Guardar los cambios
-	if err := config.DB.Save(&role).Error; err != nil {
+	if err := config.DB.WithContext(ctx).Save(&role).Error; err != nil {
 		return errors.New("error al actualizar el estado del rol")
 	}
 
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlertNotFound = errors.New("alerta no encontrada")
+
+// GetPaginatedSecurityAlerts obtiene las alertas filtradas por regla, severidad, estado, usuario y fechas
+func GetPaginatedSecurityAlerts(page, pageSize int, filters map[string]interface{}) ([]models.SecurityAlert, int64, error) {
+	var alerts []models.SecurityAlert
+	var total int64
+
+	query := config.DB.Model(&models.SecurityAlert{})
+
+	if rule, ok := filters["rule"]; ok {
+		query = query.Where("rule = ?", rule)
+	}
+	if severity, ok := filters["severity"]; ok {
+		query = query.Where("severity = ?", severity)
+	}
+	if status, ok := filters["status"]; ok {
+		query = query.Where("status = ?", status)
+	}
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip, ok := filters["ip"]; ok {
+		query = query.Where("ip = ?", ip)
+	}
+	if from, ok := filters["from"]; ok {
+		query = query.Where("last_seen_at >= ?", from)
+	}
+	if to, ok := filters["to"]; ok {
+		query = query.Where("first_seen_at <= ?", to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("last_seen_at DESC").Offset(offset).Limit(pageSize).Find(&alerts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, total, nil
+}
+
+// UpdateSecurityAlertStatus cambia el estado de una alerta y registra quién la atendió
+func UpdateSecurityAlertStatus(id uint, status, note string, handledBy uint) (models.SecurityAlert, error) {
+	var alert models.SecurityAlert
+	if err := config.DB.First(&alert, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return alert, ErrAlertNotFound
+		}
+		return alert, err
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":     status,
+		"handled_by": handledBy,
+		"handled_at": now,
+	}
+	if note != "" {
+		updates["note"] = note
+	}
+	if err := config.DB.Model(&alert).Updates(updates).Error; err != nil {
+		return alert, err
+	}
+	return alert, nil
+}
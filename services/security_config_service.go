@@ -0,0 +1,590 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"seguridad-api/config"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// SecurityConfigVersion es la versión del documento de configuración que genera y acepta el servicio
+const SecurityConfigVersion = 1
+
+// Acciones y entidades del plan de importación de la configuración
+const (
+	ConfigActionCreate = "create"
+	ConfigActionUpdate = "update"
+	ConfigActionDelete = "delete"
+
+	ConfigEntityModule         = "module"
+	ConfigEntityPermission     = "permission"
+	ConfigEntityRole           = "role"
+	ConfigEntityRolePermission = "role_permission"
+)
+
+// errConfigRollback descarta la transacción de una importación de prueba
+var errConfigRollback = errors.New("importación de prueba")
+
+// SecurityConfig es el modelo de seguridad completo (módulos, permisos, roles y sus permisos) para
+// llevarlo de un ambiente a otro. Los registros se identifican por su clave natural y no por su ID: el
+// módulo por su clave, el permiso por su módulo y nombre y el rol por su nombre.
+type SecurityConfig struct {
+	Version    int            `json:"version" yaml:"version"`
+	ExportedAt string         `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Modules    []ConfigModule `json:"modules" yaml:"modules"`
+	Roles      []ConfigRole   `json:"roles" yaml:"roles"`
+}
+
+// ConfigModule es un módulo con sus permisos; Active en nil equivale a activo
+type ConfigModule struct {
+	Key         string             `json:"key" yaml:"key"`
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Active      *bool              `json:"active,omitempty" yaml:"active,omitempty"`
+	Permissions []ConfigPermission `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+type ConfigPermission struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Active      *bool  `json:"active,omitempty" yaml:"active,omitempty"`
+}
+
+// ConfigRole es un rol con la clave de su módulo y la lista completa de sus permisos
+type ConfigRole struct {
+	Name        string                `json:"name" yaml:"name"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Active      *bool                 `json:"active,omitempty" yaml:"active,omitempty"`
+	Module      string                `json:"module" yaml:"module"`
+	Permissions []ConfigPermissionRef `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+// ConfigPermissionRef referencia un permiso por la clave de su módulo y su nombre
+type ConfigPermissionRef struct {
+	Module string `json:"module" yaml:"module"`
+	Name   string `json:"name" yaml:"name"`
+}
+
+// ConfigError es un documento de configuración inválido o un modelo que no se puede exportar; Problems
+// detalla cada problema encontrado
+type ConfigError struct {
+	Message  string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Message + ": " + strings.Join(e.Problems, "; ")
+}
+
+// ErrConfigConflicts indica que el plan no se puede aplicar sin perder datos; el detalle está en el plan
+var ErrConfigConflicts = errors.New("el plan tiene conflictos y no se aplicó")
+
+// ConfigChange es un cambio del plan; Key es la clave natural del registro
+type ConfigChange struct {
+	Action  string                        `json:"action"`
+	Entity  string                        `json:"entity"`
+	Key     string                        `json:"key"`
+	Changes map[string]models.FieldChange `json:"changes,omitempty"`
+
+	apply func(tx *gorm.DB, state *configState) error
+}
+
+// ConfigPlan son los cambios que lleva la base al estado del documento. Summary cuenta los cambios por
+// acción; Applied indica si se guardaron.
+type ConfigPlan struct {
+	DryRun    bool           `json:"dry_run"`
+	Prune     bool           `json:"prune"`
+	Applied   bool           `json:"applied"`
+	Summary   map[string]int `json:"summary"`
+	Changes   []ConfigChange `json:"changes"`
+	Conflicts []string       `json:"conflicts,omitempty"`
+}
+
+// ConfigImportOptions indica si la importación solo calcula el plan (DryRun) y si elimina los módulos,
+// permisos y roles que no están en el documento (Prune)
+type ConfigImportOptions struct {
+	DryRun bool
+	Prune  bool
+}
+
+// configState son los IDs de la base por clave natural; la aplicación del plan agrega los registros creados
+type configState struct {
+	modules     map[string]uint
+	permissions map[string]uint
+	roles       map[string]uint
+}
+
+func permissionKey(module, name string) string {
+	return module + "/" + strings.ToLower(name)
+}
+
+func activeValue(active *bool) bool {
+	return active == nil || *active
+}
+
+// ExportSecurityConfig arma el documento con la configuración actual. Falla si la base tiene datos que
+// no se pueden identificar por su clave natural (claves de módulo repetidas o referencias a registros
+// inexistentes), ya que el documento no se podría importar.
+func ExportSecurityConfig() (*SecurityConfig, error) {
+	var modules []models.Module
+	if err := config.DB.Preload("Permissions", func(db *gorm.DB) *gorm.DB { return db.Order("name") }).Order("module_key").Find(&modules).Error; err != nil {
+		return nil, fmt.Errorf("error al consultar los módulos: %w", err)
+	}
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("error al consultar los roles: %w", err)
+	}
+
+	var problems []string
+	keys := make(map[uint]string, len(modules))
+	seen := make(map[string]bool, len(modules))
+	document := &SecurityConfig{Version: SecurityConfigVersion, ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+	for _, module := range modules {
+		if module.ModuleKey == "" || seen[module.ModuleKey] {
+			problems = append(problems, fmt.Sprintf("el módulo %s no tiene una clave única (%q)", module.Name, module.ModuleKey))
+		}
+		seen[module.ModuleKey] = true
+		keys[module.ID] = module.ModuleKey
+
+		active := module.Active
+		entry := ConfigModule{Key: module.ModuleKey, Name: module.Name, Description: module.Description, Active: &active}
+		for _, permission := range module.Permissions {
+			active := permission.Active
+			entry.Permissions = append(entry.Permissions, ConfigPermission{Name: permission.Name, Description: permission.Description, Active: &active})
+		}
+		document.Modules = append(document.Modules, entry)
+	}
+
+	for _, role := range roles {
+		module, ok := keys[role.IDModule]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("el rol %s pertenece al módulo %d, que no existe", role.Name, role.IDModule))
+		}
+		active := role.Active
+		entry := ConfigRole{Name: role.Name, Description: role.Description, Active: &active, Module: module}
+		// role_permissions no tiene índice único; una asignación repetida se exporta una vez
+		listed := make(map[uint]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			if listed[permission.ID] {
+				continue
+			}
+			listed[permission.ID] = true
+			permissionModule, ok := keys[permission.ModuleID]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("el permiso %s del rol %s pertenece al módulo %d, que no existe", permission.Name, role.Name, permission.ModuleID))
+				continue
+			}
+			entry.Permissions = append(entry.Permissions, ConfigPermissionRef{Module: permissionModule, Name: permission.Name})
+		}
+		sort.Slice(entry.Permissions, func(i, j int) bool {
+			a, b := entry.Permissions[i], entry.Permissions[j]
+			return a.Module < b.Module || (a.Module == b.Module && a.Name < b.Name)
+		})
+		document.Roles = append(document.Roles, entry)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Message: "la configuración actual no se puede exportar", Problems: problems}
+	}
+	return document, nil
+}
+
+// ParseSecurityConfig lee el documento en YAML o JSON y valida su versión, sus claves y sus referencias
+func ParseSecurityConfig(content []byte) (*SecurityConfig, error) {
+	var document SecurityConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&document); err != nil {
+		return nil, &ConfigError{Message: "el documento no es YAML o JSON válido", Problems: []string{err.Error()}}
+	}
+
+	var problems []string
+	if document.Version != SecurityConfigVersion {
+		problems = append(problems, fmt.Sprintf("versión no soportada: %d (se espera %d)", document.Version, SecurityConfigVersion))
+	}
+	moduleKeys := make(map[string]bool, len(document.Modules))
+	moduleNames := make(map[string]bool, len(document.Modules))
+	permissions := make(map[string]bool)
+	for _, module := range document.Modules {
+		switch {
+		case module.Key == "":
+			problems = append(problems, fmt.Sprintf("el módulo %s no tiene clave", module.Name))
+		case len(module.Key) > 15:
+			problems = append(problems, fmt.Sprintf("la clave del módulo %s supera los 15 caracteres", module.Key))
+		case moduleKeys[module.Key]:
+			problems = append(problems, fmt.Sprintf("la clave de módulo %s está repetida", module.Key))
+		}
+		moduleKeys[module.Key] = true
+		if module.Name == "" || len(module.Name) > 100 {
+			problems = append(problems, fmt.Sprintf("el nombre del módulo %s es obligatorio y admite hasta 100 caracteres", module.Key))
+		} else if moduleNames[strings.ToLower(module.Name)] {
+			problems = append(problems, fmt.Sprintf("el nombre de módulo %s está repetido", module.Name))
+		}
+		moduleNames[strings.ToLower(module.Name)] = true
+
+		for _, permission := range module.Permissions {
+			key := permissionKey(module.Key, permission.Name)
+			if permission.Name == "" || len(permission.Name) > 100 {
+				problems = append(problems, fmt.Sprintf("un permiso del módulo %s no tiene nombre o supera los 100 caracteres", module.Key))
+			} else if permissions[key] {
+				problems = append(problems, fmt.Sprintf("el permiso %s está repetido en el módulo %s", permission.Name, module.Key))
+			}
+			permissions[key] = true
+		}
+	}
+
+	roles := make(map[string]bool, len(document.Roles))
+	for _, role := range document.Roles {
+		if role.Name == "" || len(role.Name) > 100 {
+			problems = append(problems, "un rol no tiene nombre o supera los 100 caracteres")
+		} else if roles[strings.ToLower(role.Name)] {
+			problems = append(problems, fmt.Sprintf("el rol %s está repetido", role.Name))
+		}
+		roles[strings.ToLower(role.Name)] = true
+		if !moduleKeys[role.Module] {
+			problems = append(problems, fmt.Sprintf("el rol %s referencia el módulo %q, que no está en el documento", role.Name, role.Module))
+		}
+		for _, ref := range role.Permissions {
+			if !permissions[permissionKey(ref.Module, ref.Name)] {
+				problems = append(problems, fmt.Sprintf("el rol %s referencia el permiso %s/%s, que no está en el documento", role.Name, ref.Module, ref.Name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Message: "el documento de configuración no es válido", Problems: problems}
+	}
+	return &document, nil
+}
+
+// ImportSecurityConfig calcula el plan que lleva la base al estado del documento y, salvo en DryRun, lo
+// aplica en una transacción. El plan se calcula dentro de la misma transacción para que no quede
+// desactualizado. Si hay conflictos no se aplica nada y se devuelve ErrConfigConflicts con el plan.
+func ImportSecurityConfig(ctx context.Context, document *SecurityConfig, options ConfigImportOptions, userID uint) (*ConfigPlan, error) {
+	var plan *ConfigPlan
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var state *configState
+		var err error
+		if plan, state, err = buildConfigPlan(tx, document, options); err != nil {
+			return err
+		}
+		if len(plan.Conflicts) > 0 {
+			return ErrConfigConflicts
+		}
+		if options.DryRun {
+			return errConfigRollback
+		}
+		for _, change := range plan.Changes {
+			if err := change.apply(tx, state); err != nil {
+				return fmt.Errorf("no se pudo aplicar %s de %s %s: %w", change.Action, change.Entity, change.Key, err)
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errConfigRollback):
+		return plan, nil
+	case err != nil:
+		return plan, err
+	}
+	plan.Applied = true
+
+	events := map[string]string{ConfigActionCreate: "INSERT", ConfigActionUpdate: "UPDATE", ConfigActionDelete: "DELETE"}
+	verbs := map[string]string{ConfigActionCreate: "creó", ConfigActionUpdate: "actualizó", ConfigActionDelete: "eliminó"}
+	for _, change := range plan.Changes {
+		description := fmt.Sprintf("Se %s %s %s por importación de configuración", verbs[change.Action], configEntityLabel(change.Entity), change.Key)
+		if auditErr := RegisterAudit(ctx, events[change.Action], description, userID, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+			return plan, fmt.Errorf("configuración aplicada, pero no se pudo registrar la auditoría: %w", auditErr)
+		}
+	}
+	return plan, nil
+}
+
+func configEntityLabel(entity string) string {
+	switch entity {
+	case ConfigEntityModule:
+		return "el módulo"
+	case ConfigEntityPermission:
+		return "el permiso"
+	case ConfigEntityRole:
+		return "el rol"
+	default:
+		return "la asignación"
+	}
+}
+
+// buildConfigPlan compara el documento con la base. Los cambios se ordenan para aplicarse sin romper
+// referencias: primero se crean y actualizan módulos, permisos y roles, luego se ajustan las asignaciones
+// y al final se eliminan roles, permisos y módulos.
+func buildConfigPlan(tx *gorm.DB, document *SecurityConfig, options ConfigImportOptions) (*ConfigPlan, *configState, error) {
+	var modules []models.Module
+	var permissions []models.Permission
+	var roles []models.Role
+	var assignments []models.RolePermission
+	if err := tx.Find(&modules).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Find(&permissions).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Find(&roles).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Select("DISTINCT role_id, permission_id").Find(&assignments).Error; err != nil {
+		return nil, nil, err
+	}
+
+	plan := &ConfigPlan{DryRun: options.DryRun, Prune: options.Prune, Summary: map[string]int{}}
+	state := &configState{modules: map[string]uint{}, permissions: map[string]uint{}, roles: map[string]uint{}}
+
+	moduleByKey := make(map[string]models.Module, len(modules))
+	moduleKeyByID := make(map[uint]string, len(modules))
+	for _, module := range modules {
+		if _, repeated := moduleByKey[module.ModuleKey]; repeated {
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("hay más de un módulo con la clave %s en la base", module.ModuleKey))
+		}
+		moduleByKey[module.ModuleKey] = module
+		moduleKeyByID[module.ID] = module.ModuleKey
+		state.modules[module.ModuleKey] = module.ID
+	}
+	permissionByKey := make(map[string]models.Permission, len(permissions))
+	permissionKeyByID := make(map[uint]string, len(permissions))
+	for _, permission := range permissions {
+		if module, ok := moduleKeyByID[permission.ModuleID]; ok {
+			key := permissionKey(module, permission.Name)
+			permissionByKey[key] = permission
+			permissionKeyByID[permission.ID] = key
+			state.permissions[key] = permission.ID
+		}
+	}
+	roleByName := make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		roleByName[strings.ToLower(role.Name)] = role
+		state.roles[strings.ToLower(role.Name)] = role.ID
+	}
+	assigned := make(map[uint]map[string]bool)
+	for _, assignment := range assignments {
+		if key, ok := permissionKeyByID[assignment.PermissionID]; ok {
+			if assigned[assignment.RoleID] == nil {
+				assigned[assignment.RoleID] = map[string]bool{}
+			}
+			assigned[assignment.RoleID][key] = true
+		}
+	}
+
+	var upserts, assignmentChanges, deletes []ConfigChange
+	documentModules := make(map[string]bool, len(document.Modules))
+	documentPermissions := make(map[string]bool)
+	for _, entry := range document.Modules {
+		documentModules[entry.Key] = true
+		active := activeValue(entry.Active)
+
+		if current, ok := moduleByKey[entry.Key]; ok {
+			changes := fieldChanges(
+				"name", current.Name, entry.Name,
+				"description", current.Description, entry.Description,
+				"active", current.Active, active,
+			)
+			if len(changes) > 0 {
+				upserts = append(upserts, ConfigChange{Action: ConfigActionUpdate, Entity: ConfigEntityModule, Key: entry.Key, Changes: changes,
+					apply: func(tx *gorm.DB, state *configState) error {
+						return tx.Model(&models.Module{ID: current.ID}).Updates(map[string]interface{}{"name": entry.Name, "description": entry.Description, "active": active}).Error
+					}})
+			}
+		} else {
+			upserts = append(upserts, ConfigChange{Action: ConfigActionCreate, Entity: ConfigEntityModule, Key: entry.Key,
+				Changes: fieldChanges("name", nil, entry.Name, "description", nil, entry.Description, "active", nil, active),
+				apply: func(tx *gorm.DB, state *configState) error {
+					module := models.Module{Name: entry.Name, Description: entry.Description, ModuleKey: entry.Key}
+					if err := createKeepingActive(tx, &module, active); err != nil {
+						return err
+					}
+					state.modules[entry.Key] = module.ID
+					return nil
+				}})
+		}
+
+		for _, permissionEntry := range entry.Permissions {
+			key := permissionKey(entry.Key, permissionEntry.Name)
+			documentPermissions[key] = true
+			permissionActive := activeValue(permissionEntry.Active)
+			label := entry.Key + "/" + permissionEntry.Name
+
+			if current, ok := permissionByKey[key]; ok {
+				changes := fieldChanges(
+					"name", current.Name, permissionEntry.Name,
+					"description", current.Description, permissionEntry.Description,
+					"active", current.Active, permissionActive,
+				)
+				if len(changes) > 0 {
+					upserts = append(upserts, ConfigChange{Action: ConfigActionUpdate, Entity: ConfigEntityPermission, Key: label, Changes: changes,
+						apply: func(tx *gorm.DB, state *configState) error {
+							return tx.Model(&models.Permission{ID: current.ID}).Updates(map[string]interface{}{"name": permissionEntry.Name, "description": permissionEntry.Description, "active": permissionActive}).Error
+						}})
+				}
+				continue
+			}
+			upserts = append(upserts, ConfigChange{Action: ConfigActionCreate, Entity: ConfigEntityPermission, Key: label,
+				Changes: fieldChanges("description", nil, permissionEntry.Description, "active", nil, permissionActive),
+				apply: func(tx *gorm.DB, state *configState) error {
+					permission := models.Permission{Name: permissionEntry.Name, Description: permissionEntry.Description, ModuleID: state.modules[entry.Key]}
+					if err := createKeepingActive(tx, &permission, permissionActive); err != nil {
+						return err
+					}
+					state.permissions[key] = permission.ID
+					return nil
+				}})
+		}
+	}
+
+	documentRoles := make(map[string]bool, len(document.Roles))
+	for _, entry := range document.Roles {
+		roleKey := strings.ToLower(entry.Name)
+		documentRoles[roleKey] = true
+		active := activeValue(entry.Active)
+
+		current, exists := roleByName[roleKey]
+		if exists {
+			changes := fieldChanges(
+				"name", current.Name, entry.Name,
+				"description", current.Description, entry.Description,
+				"active", current.Active, active,
+				"module", moduleKeyByID[current.IDModule], entry.Module,
+			)
+			if len(changes) > 0 {
+				upserts = append(upserts, ConfigChange{Action: ConfigActionUpdate, Entity: ConfigEntityRole, Key: entry.Name, Changes: changes,
+					apply: func(tx *gorm.DB, state *configState) error {
+						return tx.Model(&models.Role{ID: current.ID}).Updates(map[string]interface{}{"name": entry.Name, "description": entry.Description, "active": active, "id_module": state.modules[entry.Module]}).Error
+					}})
+			}
+		} else {
+			upserts = append(upserts, ConfigChange{Action: ConfigActionCreate, Entity: ConfigEntityRole, Key: entry.Name,
+				Changes: fieldChanges("description", nil, entry.Description, "active", nil, active, "module", nil, entry.Module),
+				apply: func(tx *gorm.DB, state *configState) error {
+					role := models.Role{Name: entry.Name, Description: entry.Description, IDModule: state.modules[entry.Module]}
+					if err := createKeepingActive(tx, &role, active); err != nil {
+						return err
+					}
+					state.roles[roleKey] = role.ID
+					return nil
+				}})
+		}
+
+		// La lista de permisos del rol es completa: se agregan los que faltan y se quitan los demás
+		desired := make(map[string]bool, len(entry.Permissions))
+		for _, ref := range entry.Permissions {
+			key := permissionKey(ref.Module, ref.Name)
+			if desired[key] {
+				continue
+			}
+			desired[key] = true
+			if exists && assigned[current.ID][key] {
+				continue
+			}
+			assignmentChanges = append(assignmentChanges, ConfigChange{Action: ConfigActionCreate, Entity: ConfigEntityRolePermission, Key: entry.Name + " -> " + ref.Module + "/" + ref.Name,
+				apply: func(tx *gorm.DB, state *configState) error {
+					return tx.Create(&models.RolePermission{RoleID: state.roles[roleKey], PermissionID: state.permissions[key]}).Error
+				}})
+		}
+		if exists {
+			for _, key := range sortedKeys(assigned[current.ID]) {
+				if desired[key] {
+					continue
+				}
+				permissionID := permissionByKey[key].ID
+				assignmentChanges = append(assignmentChanges, ConfigChange{Action: ConfigActionDelete, Entity: ConfigEntityRolePermission, Key: entry.Name + " -> " + moduleKeyByID[permissionByKey[key].ModuleID] + "/" + permissionByKey[key].Name,
+					apply: func(tx *gorm.DB, state *configState) error {
+						return tx.Where("role_id = ? AND permission_id = ?", current.ID, permissionID).Delete(&models.RolePermission{}).Error
+					}})
+			}
+		}
+	}
+
+	if options.Prune {
+		for _, role := range roles {
+			if documentRoles[strings.ToLower(role.Name)] {
+				continue
+			}
+			var users int64
+			if err := tx.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Count(&users).Error; err != nil {
+				return nil, nil, err
+			}
+			if users > 0 {
+				plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("el rol %s no está en el documento pero tiene %d usuarios asignados", role.Name, users))
+			}
+			deletes = append(deletes, ConfigChange{Action: ConfigActionDelete, Entity: ConfigEntityRole, Key: role.Name,
+				apply: func(tx *gorm.DB, state *configState) error {
+					if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+						return err
+					}
+					return tx.Delete(&models.Role{}, role.ID).Error
+				}})
+		}
+		for _, permission := range permissions {
+			key, ok := permissionKeyByID[permission.ID]
+			if ok && documentPermissions[key] {
+				continue
+			}
+			deletes = append(deletes, ConfigChange{Action: ConfigActionDelete, Entity: ConfigEntityPermission, Key: fmt.Sprintf("%s/%s", moduleKeyByID[permission.ModuleID], permission.Name),
+				apply: func(tx *gorm.DB, state *configState) error {
+					if err := tx.Where("permission_id = ?", permission.ID).Delete(&models.RolePermission{}).Error; err != nil {
+						return err
+					}
+					return tx.Delete(&models.Permission{}, permission.ID).Error
+				}})
+		}
+		for _, module := range modules {
+			if documentModules[module.ModuleKey] {
+				continue
+			}
+			deletes = append(deletes, ConfigChange{Action: ConfigActionDelete, Entity: ConfigEntityModule, Key: module.ModuleKey,
+				apply: func(tx *gorm.DB, state *configState) error {
+					return tx.Delete(&models.Module{}, module.ID).Error
+				}})
+		}
+	}
+
+	plan.Changes = append(append(append([]ConfigChange{}, upserts...), assignmentChanges...), deletes...)
+	for _, change := range plan.Changes {
+		plan.Summary[change.Action]++
+	}
+	return plan, state, nil
+}
+
+// fieldChanges arma el detalle de los campos que cambian a partir de triples nombre, anterior, nuevo;
+// en una creación el valor anterior es nil
+func fieldChanges(fields ...interface{}) map[string]models.FieldChange {
+	changes := map[string]models.FieldChange{}
+	for i := 0; i+2 < len(fields); i += 3 {
+		previous, next := fields[i+1], fields[i+2]
+		if previous != nil && previous == next {
+			continue
+		}
+		if previous == nil && (next == "" || next == nil) {
+			continue
+		}
+		changes[fields[i].(string)] = models.FieldChange{Old: previous, New: next}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"log"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strings"
+	"time"
+)
+
+// RecordSecurityEvent guarda un evento de seguridad; los errores solo se registran en el log
+// para no interrumpir el flujo de autenticación
+func RecordSecurityEvent(ctx context.Context, eventType string, userID *uint, email, moduleKey, detail string) {
+	severity, ok := models.SecurityEventSeverity(eventType)
+	if !ok {
+		log.Printf("Tipo de evento de seguridad desconocido: %s", eventType)
+		severity = models.SecuritySeverityWarning
+	}
+
+	meta := RequestMetadataFromContext(ctx)
+	event := models.SecurityEvent{
+		Type:      eventType,
+		Severity:  severity,
+		UserID:    userID,
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		ModuleKey: moduleKey,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		RequestID: meta.RequestID,
+		Detail:    detail,
+		Date:      time.Now().UTC(),
+	}
+
+	if err := config.DB.WithContext(ctx).Create(&event).Error; err != nil {
+		log.Printf("Error al registrar el evento de seguridad %s: %v", eventType, err)
+		return
+	}
+	observeSecurityEvent(event)
+}
+
+// GetPaginatedSecurityEvents obtiene los eventos de seguridad filtrados por tipo, IP, usuario y ventana de tiempo
+func GetPaginatedSecurityEvents(page, pageSize int, filters map[string]interface{}) ([]models.SecurityEvent, int64, error) {
+	var events []models.SecurityEvent
+	var total int64
+
+	query := config.DB.Model(&models.SecurityEvent{})
+
+	if types, ok := filters["types"]; ok {
+		query = query.Where("type IN ?", types)
+	}
+	if severity, ok := filters["severity"]; ok {
+		query = query.Where("severity = ?", severity)
+	}
+	if ip, ok := filters["ip"]; ok {
+		query = query.Where("ip = ?", ip)
+	}
+	if email, ok := filters["email"]; ok {
+		query = query.Where("email LIKE ?", "%"+strings.ToLower(email.(string))+"%")
+	}
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("user_id = ?", userID)
+	}
+	if from, ok := filters["from"]; ok {
+		query = query.Where("date >= ?", from)
+	}
+	if to, ok := filters["to"]; ok {
+		query = query.Where("date <= ?", to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("date DESC").Offset(offset).Limit(pageSize).Find(&events).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
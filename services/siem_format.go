@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"seguridad-api/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SiemFormatCEF  = "cef"
+	SiemFormatJSON = "json"
+
+	// Facility "log audit" de RFC 5424
+	syslogFacilityAudit = 13
+	syslogSeverityWarn  = 4
+	syslogSeverityInfo  = 6
+
+	// Número de empresa reservado para documentación (RFC 5612) en el SD-ID
+	syslogSDID = "audit@32473"
+
+	cefVendor  = "UTN"
+	cefProduct = "SEGURIDAD-API"
+	cefVersion = "2.0"
+)
+
+var syslogHostname = func() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "-"
+	}
+	return syslogToken(host, 255)
+}()
+
+// FormatSyslogMessage arma un mensaje RFC 5424 con la auditoría en formato CEF o JSON. El reenvío es al
+// menos una vez, por eso el ID de la auditoría va siempre en el cuerpo para que el SIEM descarte duplicados.
+func FormatSyslogMessage(audit models.AuditResponse, format, appName string) (string, error) {
+	severity := syslogSeverityInfo
+	if audit.Outcome == models.AuditOutcomeFailure {
+		severity = syslogSeverityWarn
+	}
+
+	var body string
+	switch format {
+	case SiemFormatJSON:
+		payload, err := json.Marshal(audit)
+		if err != nil {
+			return "", err
+		}
+		body = string(payload)
+	default:
+		body = FormatCEF(audit)
+	}
+
+	structuredData := fmt.Sprintf("[%s id=\"%d\" outcome=\"%s\" requestId=\"%s\"]", syslogSDID,
+		audit.ID, sdParamValue(audit.Outcome), sdParamValue(audit.RequestID))
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		syslogFacilityAudit*8+severity,
+		audit.Date.UTC().Format(time.RFC3339Nano),
+		syslogHostname,
+		syslogToken(appName, 48),
+		os.Getpid(),
+		syslogToken(audit.Event, 32),
+		structuredData,
+		body,
+	), nil
+}
+
+// FormatCEF convierte la auditoría al formato Common Event Format
+func FormatCEF(audit models.AuditResponse) string {
+	severity := 3
+	if audit.Outcome == models.AuditOutcomeFailure {
+		severity = 7
+	}
+
+	extensions := []string{
+		"rt=" + strconv.FormatInt(audit.Date.UnixMilli(), 10),
+		"externalId=" + strconv.FormatUint(uint64(audit.ID), 10),
+		"suid=" + strconv.FormatUint(uint64(audit.UserID), 10),
+		"suser=" + cefExtensionValue(audit.User),
+		"cs1Label=originService",
+		"cs1=" + cefExtensionValue(audit.OriginService),
+		"outcome=" + cefExtensionValue(audit.Outcome),
+	}
+	optional := []struct{ key, value string }{
+		{"src", audit.IP},
+		{"requestClientApplication", audit.UserAgent},
+		{"requestMethod", audit.Method},
+		{"request", audit.Route},
+		{"reason", audit.FailureReason},
+	}
+	for _, field := range optional {
+		if field.value != "" {
+			extensions = append(extensions, field.key+"="+cefExtensionValue(field.value))
+		}
+	}
+	if audit.RequestID != "" {
+		extensions = append(extensions, "cs2Label=requestId", "cs2="+cefExtensionValue(audit.RequestID))
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeader(cefVendor), cefHeader(cefProduct), cefHeader(cefVersion),
+		cefHeader(audit.Event), cefHeader(audit.Description), severity,
+		strings.Join(extensions, " "))
+}
+
+func cefHeader(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+func cefExtensionValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "=", `\=`)
+	return strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(value)
+}
+
+func sdParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(value)
+}
+
+// syslogToken limita el valor a caracteres ASCII imprimibles sin espacios, como exige RFC 5424
+func syslogToken(value string, maxLength int) string {
+	var builder strings.Builder
+	for _, r := range value {
+		if r < 33 || r > 126 {
+			r = '_'
+		}
+		builder.WriteRune(r)
+		if builder.Len() >= maxLength {
+			break
+		}
+	}
+	if builder.Len() == 0 {
+		return "-"
+	}
+	return builder.String()
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"seguridad-api/models"
+)
+
+// El reenvío al SIEM es al menos una vez; el cuerpo debe llevar el ID para descartar duplicados
+func TestFormatSyslogMessageCarriesAuditID(t *testing.T) {
+	audit := models.AuditResponse{ID: 4821, Event: "LOGIN", Description: "Inicio de sesión", UserID: 7, User: "ana", OriginService: "SEGURIDAD", Date: time.Now()}
+
+	message, err := FormatSyslogMessage(audit, SiemFormatCEF, "seguridad-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := message[strings.Index(message, "CEF:0|"):]; !strings.Contains(body, " externalId=4821 ") {
+		t.Errorf("el cuerpo CEF no incluye externalId=4821: %s", body)
+	}
+
+	message, err = FormatSyslogMessage(audit, SiemFormatJSON, "seguridad-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(message[strings.Index(message, "] {")+2:]), &body); err != nil {
+		t.Fatalf("el cuerpo JSON no es válido: %v", err)
+	}
+	if body.ID != audit.ID {
+		t.Errorf("el cuerpo JSON tiene id=%d, se esperaba %d", body.ID, audit.ID)
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	siemCursorName       = "syslog"
+	siemBatchSize        = 100
+	siemDefaultInterval  = 10 * time.Second
+	siemDefaultLag       = 30 * time.Second
+	siemWriteTimeout     = 5 * time.Second
+	siemDefaultAppName   = "seguridad-api"
+	siemDefaultProtocol  = "tcp"
+	siemMaxUDPMessageLen = 8192
+)
+
+// SiemConfig es la configuración del reenvío, tomada de las variables SIEM_*
+type SiemConfig struct {
+	Address  string        `json:"address"`
+	Protocol string        `json:"protocol"`
+	Format   string        `json:"format"`
+	AppName  string        `json:"app_name"`
+	Interval time.Duration `json:"-"`
+	Lag      time.Duration `json:"-"`
+	Enabled  bool          `json:"enabled"`
+
+	caFile   string
+	insecure bool
+}
+
+// SiemStatus resume el estado del reenvío para el endpoint de monitoreo
+type SiemStatus struct {
+	Config    SiemConfig `json:"config"`
+	Interval  string     `json:"interval"`
+	Lag       string     `json:"lag"`
+	LastID    uint       `json:"last_id"`
+	LastSent  *time.Time `json:"last_sent"`
+	LastError string     `json:"last_error"`
+	Connected bool       `json:"connected"`
+}
+
+type siemForwarder struct {
+	cfg       SiemConfig
+	conn      net.Conn
+	mu        sync.Mutex
+	lastSent  *time.Time
+	lastError string
+
+	// settledID es el mayor ID que ya existía hace al menos cfg.Lag: un ID menor que falte ya no va a
+	// aparecer (transacción revertida o registro depurado). sampleID y sampleAt son la muestra pendiente.
+	settledID uint
+	sampleID  uint
+	sampleAt  time.Time
+}
+
+var forwarder *siemForwarder
+
+// LoadSiemConfig lee la configuración: SIEM_SYSLOG_ADDR (host:puerto), SIEM_SYSLOG_PROTOCOL (tcp, udp, tls),
+// SIEM_FORMAT (cef, json), SIEM_APP_NAME, SIEM_FORWARD_INTERVAL, SIEM_FORWARD_LAG, SIEM_TLS_CA_FILE y
+// SIEM_TLS_INSECURE
+func LoadSiemConfig() (SiemConfig, error) {
+	cfg := SiemConfig{
+		Address:  os.Getenv("SIEM_SYSLOG_ADDR"),
+		Protocol: strings.ToLower(os.Getenv("SIEM_SYSLOG_PROTOCOL")),
+		Format:   strings.ToLower(os.Getenv("SIEM_FORMAT")),
+		AppName:  os.Getenv("SIEM_APP_NAME"),
+		Interval: siemDefaultInterval,
+		Lag:      siemDefaultLag,
+		caFile:   os.Getenv("SIEM_TLS_CA_FILE"),
+		insecure: os.Getenv("SIEM_TLS_INSECURE") == "true",
+	}
+	cfg.Enabled = cfg.Address != ""
+
+	if cfg.Protocol == "" {
+		cfg.Protocol = siemDefaultProtocol
+	}
+	if cfg.Protocol != "tcp" && cfg.Protocol != "udp" && cfg.Protocol != "tls" {
+		return cfg, fmt.Errorf("SIEM_SYSLOG_PROTOCOL inválido: %s", cfg.Protocol)
+	}
+	if cfg.Format == "" {
+		cfg.Format = SiemFormatCEF
+	}
+	if cfg.Format != SiemFormatCEF && cfg.Format != SiemFormatJSON {
+		return cfg, fmt.Errorf("SIEM_FORMAT inválido: %s", cfg.Format)
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = siemDefaultAppName
+	}
+	if value := os.Getenv("SIEM_FORWARD_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return cfg, fmt.Errorf("SIEM_FORWARD_INTERVAL inválido: %s", value)
+		}
+		cfg.Interval = interval
+	}
+	if value := os.Getenv("SIEM_FORWARD_LAG"); value != "" {
+		lag, err := time.ParseDuration(value)
+		if err != nil || lag < 0 {
+			return cfg, fmt.Errorf("SIEM_FORWARD_LAG inválido: %s", value)
+		}
+		cfg.Lag = lag
+	}
+	return cfg, nil
+}
+
+// StartSiemForwarder inicia el reenvío periódico de auditorías al syslog configurado.
+// En el primer arranque solo se envían las auditorías nuevas, salvo que SIEM_FORWARD_HISTORY=true.
+func StartSiemForwarder() {
+	cfg, err := LoadSiemConfig()
+	if err != nil {
+		log.Printf("Reenvío al SIEM deshabilitado: %v", err)
+		return
+	}
+	if !cfg.Enabled {
+		log.Println("Reenvío al SIEM deshabilitado: SIEM_SYSLOG_ADDR no está configurada")
+		return
+	}
+
+	if err := initSiemCursor(os.Getenv("SIEM_FORWARD_HISTORY") == "true"); err != nil {
+		log.Printf("Error al inicializar el cursor del SIEM: %v", err)
+		return
+	}
+
+	forwarder = &siemForwarder{cfg: cfg}
+	go forwarder.run()
+}
+
+// GetSiemStatus devuelve la configuración y el avance del reenvío
+func GetSiemStatus() (SiemStatus, error) {
+	cfg, err := LoadSiemConfig()
+	status := SiemStatus{Config: cfg, Interval: cfg.Interval.String(), Lag: cfg.Lag.String()}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+
+	var cursor models.SiemCursor
+	if err := config.DB.Where("name = ?", siemCursorName).Limit(1).Find(&cursor).Error; err != nil {
+		return status, err
+	}
+	status.LastID = cursor.LastID
+
+	if forwarder != nil {
+		forwarder.mu.Lock()
+		status.LastSent = forwarder.lastSent
+		status.LastError = forwarder.lastError
+		status.Connected = forwarder.conn != nil
+		forwarder.mu.Unlock()
+	}
+	return status, nil
+}
+
+func initSiemCursor(fromBeginning bool) error {
+	cursor := models.SiemCursor{Name: siemCursorName}
+	if !fromBeginning {
+		if err := config.DB.Model(&models.Audit{}).Select("COALESCE(MAX(id), 0)").Scan(&cursor.LastID).Error; err != nil {
+			return err
+		}
+	}
+	// Si el cursor ya existe se conserva para continuar donde se quedó
+	return config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error
+}
+
+func (f *siemForwarder) run() {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := f.forwardPending(); err != nil {
+			f.mu.Lock()
+			f.lastError = err.Error()
+			f.mu.Unlock()
+			log.Printf("Error al reenviar auditorías al SIEM: %v", err)
+		}
+	}
+}
+
+// forwardPending envía en lotes las auditorías posteriores al cursor. El cursor avanza solo hasta el
+// último mensaje escrito con éxito, así un fallo no provoca pérdidas, pero la entrega es al menos una vez:
+// una escritura fallida puede haber llegado en parte y, si el proceso se detiene antes de guardar el
+// cursor, el lote se reenvía al reiniciar. El SIEM descarta los duplicados con el ID de la auditoría, que
+// va en cada mensaje (externalId en CEF, id en JSON y en los datos estructurados).
+// Un ID puede confirmarse después de otro mayor (transacciones concurrentes o el escritor por lotes),
+// por eso el envío se detiene en el primer ID faltante hasta que ese hueco quede asentado.
+func (f *siemForwarder) forwardPending() error {
+	if err := f.sampleSettledID(); err != nil {
+		return err
+	}
+	for {
+		var cursor models.SiemCursor
+		if err := config.DB.First(&cursor, "name = ?", siemCursorName).Error; err != nil {
+			return err
+		}
+
+		audits, err := siemAuditsAfter(cursor.LastID, siemBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(audits) == 0 {
+			return nil
+		}
+
+		sent := cursor.LastID
+		var sendErr error
+		waiting := false
+		for _, audit := range audits {
+			// Los IDs entre sent y audit.ID faltan; se pasa sobre ellos solo si ya están asentados
+			if audit.ID != sent+1 && audit.ID-1 > f.settledID {
+				waiting = true
+				break
+			}
+			if sendErr = f.send(audit); sendErr != nil {
+				break
+			}
+			sent = audit.ID
+		}
+
+		if sent != cursor.LastID {
+			if err := saveSiemCursor(cursor.LastID, sent); err != nil {
+				return err
+			}
+			now := time.Now()
+			f.mu.Lock()
+			f.lastSent = &now
+			f.lastError = ""
+			f.mu.Unlock()
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+		if waiting || len(audits) < siemBatchSize {
+			return nil
+		}
+	}
+}
+
+// sampleSettledID toma cada cfg.Lag el mayor ID de auditoría; cuando la muestra cumple ese tiempo, los
+// IDs menores que todavía falten se consideran perdidos y el envío puede pasar sobre ellos
+func (f *siemForwarder) sampleSettledID() error {
+	now := time.Now()
+	if !f.sampleAt.IsZero() && now.Sub(f.sampleAt) < f.cfg.Lag {
+		return nil
+	}
+	if !f.sampleAt.IsZero() {
+		f.settledID = f.sampleID
+	}
+	var maxID uint
+	if err := config.DB.Model(&models.Audit{}).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return err
+	}
+	f.sampleID, f.sampleAt = maxID, now
+	if f.cfg.Lag == 0 {
+		f.settledID = maxID
+	}
+	return nil
+}
+
+// siemAuditsAfter obtiene las auditorías posteriores al cursor. A diferencia del listado usa LEFT JOIN:
+// una auditoría de un usuario que no existe en users también se reenvía.
+func siemAuditsAfter(lastID uint, limit int) ([]models.AuditResponse, error) {
+	var audits []models.AuditResponse
+	err := config.DB.Model(&models.Audit{}).
+		Joins("LEFT JOIN users ON users.id = audit.user_id").
+		Select(strings.Replace(auditResponseColumns, "users.name AS user", "COALESCE(users.name, '') AS user", 1)).
+		Where("audit.id > ?", lastID).
+		Order("audit.id").
+		Limit(limit).
+		Find(&audits).Error
+	return audits, err
+}
+
+// saveSiemCursor solo avanza el cursor si nadie más lo movió, para no retroceder ni duplicar envíos
+func saveSiemCursor(previous, last uint) error {
+	result := config.DB.Model(&models.SiemCursor{}).
+		Where("name = ? AND last_id = ?", siemCursorName, previous).
+		Updates(map[string]interface{}{"last_id": last, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("el cursor del SIEM fue modificado por otro proceso")
+	}
+	return nil
+}
+
+func (f *siemForwarder) send(audit models.AuditResponse) error {
+	message, err := FormatSyslogMessage(audit, f.cfg.Format, f.cfg.AppName)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn == nil {
+		if f.conn, err = dialSiem(f.cfg); err != nil {
+			f.conn = nil
+			return fmt.Errorf("no se pudo conectar con %s: %w", f.cfg.Address, err)
+		}
+	}
+
+	var frame string
+	if f.cfg.Protocol == "udp" {
+		if len(message) > siemMaxUDPMessageLen {
+			// Se corta al inicio de un carácter para no dejar una secuencia UTF-8 incompleta en MSG
+			end := siemMaxUDPMessageLen
+			for end > 0 && !utf8.RuneStart(message[end]) {
+				end--
+			}
+			message = message[:end]
+		}
+		frame = message
+	} else {
+		// Octet counting (RFC 6587) para delimitar mensajes sobre TCP/TLS
+		frame = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	f.conn.SetWriteDeadline(time.Now().Add(siemWriteTimeout))
+	if _, err := f.conn.Write([]byte(frame)); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return fmt.Errorf("error al enviar la auditoría %d: %w", audit.ID, err)
+	}
+	return nil
+}
+
+func dialSiem(cfg SiemConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: siemWriteTimeout}
+
+	switch cfg.Protocol {
+	case "udp":
+		return dialer.Dial("udp", cfg.Address)
+	case "tls":
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: cfg.insecure, MinVersion: tls.VersionTLS12}
+		if cfg.caFile != "" {
+			pem, err := os.ReadFile(cfg.caFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("SIEM_TLS_CA_FILE no contiene certificados válidos")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		return tls.DialWithDialer(dialer, "tcp", cfg.Address, tlsConfig)
+	default:
+		return dialer.Dial("tcp", cfg.Address)
+	}
+}
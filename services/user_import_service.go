@@ -0,0 +1,628 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"path/filepath"
+	"seguridad-api/config"
+	helpers "seguridad-api/helpers"
+	"seguridad-api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	UserImportStatusCreated = "Creado"
+	UserImportStatusError   = "Error"
+
+	userImportSheet = "Usuarios"
+	// userImportBatch es la cantidad de correos y roles que se consultan por vez al validar el archivo
+	userImportBatch = 500
+)
+
+// userImportField es una columna del archivo de importación; header es el encabezado de la plantilla
+type userImportField struct {
+	key      string
+	header   string
+	required bool
+}
+
+var userImportFields = []userImportField{
+	{key: "name", header: "Nombre", required: true},
+	{key: "email", header: "Correo Electrónico", required: true},
+	{key: "password", header: "Contraseña", required: true},
+	{key: "active", header: "Activo"},
+	{key: "role_id", header: "ID Rol", required: true},
+}
+
+// UserImportError es un archivo de importación que no se puede procesar (formato, encabezados o tamaño)
+type UserImportError struct {
+	Message string
+}
+
+func (e *UserImportError) Error() string {
+	return e.Message
+}
+
+// UserImportRow es el resultado de una fila del archivo; Row es el número de fila en el archivo
+type UserImportRow struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	UserID  uint   `json:"user_id,omitempty"`
+}
+
+// UserImportResult resume la importación e incluye el archivo original con el estado de cada fila
+type UserImportResult struct {
+	Total       int             `json:"total"`
+	Created     int             `json:"created"`
+	Failed      int             `json:"failed"`
+	Rows        []UserImportRow `json:"rows"`
+	File        []byte          `json:"-"`
+	FileName    string          `json:"-"`
+	ContentType string          `json:"-"`
+}
+
+// userImportCandidate es una fila ya leída con sus valores convertidos
+type userImportCandidate struct {
+	result   *UserImportRow
+	name     string
+	email    string
+	password string
+	active   bool
+	roleID   uint
+}
+
+// userImportTable son las filas leídas del archivo; delimiter solo aplica a CSV
+type userImportTable struct {
+	excel     bool
+	delimiter rune
+	header    []string
+	rows      [][]string
+	// lines es el número de fila en el archivo de cada elemento de rows
+	lines []int
+}
+
+// UserImportTemplate genera la plantilla de Excel para importar usuarios, con una hoja de referencia de
+// los roles activos
+func UserImportTemplate() ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	if err := file.SetSheetName("Sheet1", userImportSheet); err != nil {
+		return nil, err
+	}
+	headerStyle, err := file.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"1F4E78"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]interface{}, len(userImportFields))
+	for i, field := range userImportFields {
+		headers[i] = field.header
+	}
+	if err := file.SetSheetRow(userImportSheet, "A1", &headers); err != nil {
+		return nil, err
+	}
+	lastColumn, _ := excelize.ColumnNumberToName(len(userImportFields))
+	if err := file.SetCellStyle(userImportSheet, "A1", lastColumn+"1", headerStyle); err != nil {
+		return nil, err
+	}
+	if err := file.SetColWidth(userImportSheet, "A", lastColumn, 25); err != nil {
+		return nil, err
+	}
+
+	// El estado se elige de una lista y el rol debe ser un número
+	active := excelize.NewDataValidation(true)
+	active.Sqref = "D2:D1048576"
+	if err := active.SetDropList([]string{"Sí", "No"}); err != nil {
+		return nil, err
+	}
+	role := excelize.NewDataValidation(true)
+	role.Sqref = "E2:E1048576"
+	if err := role.SetRange(1, 4294967295, excelize.DataValidationTypeWhole, excelize.DataValidationOperatorBetween); err != nil {
+		return nil, err
+	}
+	role.SetError(excelize.DataValidationErrorStyleStop, "ID Rol", "Ingrese el ID de un rol de la hoja Roles")
+	for _, validation := range []*excelize.DataValidation{active, role} {
+		if err := file.AddDataValidation(userImportSheet, validation); err != nil {
+			return nil, err
+		}
+	}
+
+	var roles []struct {
+		ID         uint
+		Name       string
+		ModuleName string
+	}
+	err = config.DB.Table("roles").
+		Select("roles.id, roles.name, modules.name AS module_name").
+		Joins("LEFT JOIN modules ON modules.id = roles.id_module").
+		Where("roles.active = ?", true).
+		Order("modules.name, roles.name").
+		Scan(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("error al consultar los roles: %w", err)
+	}
+	if _, err := file.NewSheet("Roles"); err != nil {
+		return nil, err
+	}
+	if err := file.SetSheetRow("Roles", "A1", &[]interface{}{"ID Rol", "Rol", "Módulo"}); err != nil {
+		return nil, err
+	}
+	if err := file.SetCellStyle("Roles", "A1", "C1", headerStyle); err != nil {
+		return nil, err
+	}
+	for i, role := range roles {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := file.SetSheetRow("Roles", cell, &[]interface{}{role.ID, role.Name, role.ModuleName}); err != nil {
+			return nil, err
+		}
+	}
+	if err := file.SetColWidth("Roles", "A", "C", 25); err != nil {
+		return nil, err
+	}
+
+	buffer, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// ImportUsers crea los usuarios de un archivo .xlsx o .csv y les asigna su rol. mapping indica, para
+// cada campo (name, email, password, active, role_id), el encabezado de la columna en el archivo; los
+// campos sin mapeo se buscan por su nombre o por el encabezado de la plantilla. Cada fila se valida y
+// se crea por separado: una fila con errores no impide crear las demás.
+func ImportUsers(ctx context.Context, file io.Reader, fileName string, mapping map[string]string, requestedBy uint) (*UserImportResult, error) {
+	table, err := readUserImportTable(file, fileName, envInt("USER_IMPORT_MAX_ROWS", 1000))
+	if err != nil {
+		return nil, err
+	}
+	columns, err := userImportColumns(table.header, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.rows) == 0 {
+		return nil, &UserImportError{Message: "el archivo no tiene filas para importar"}
+	}
+
+	result := &UserImportResult{Rows: make([]UserImportRow, len(table.rows))}
+	candidates := make([]*userImportCandidate, len(table.rows))
+	for i, cells := range table.rows {
+		result.Rows[i] = UserImportRow{Row: table.lines[i]}
+		candidates[i] = parseUserImportRow(&result.Rows[i], cells, columns)
+	}
+	if err := validateUserImport(candidates); err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		if candidate.result.Status == UserImportStatusError {
+			continue
+		}
+		user, err := createImportedUser(ctx, candidate)
+		if err != nil {
+			candidate.fail("Error al crear el usuario: " + err.Error())
+			continue
+		}
+		candidate.result.Status = UserImportStatusCreated
+		candidate.result.UserID = user.ID
+
+		description := fmt.Sprintf("Se creó el usuario con el email: %s y el rol %d por importación del archivo %s", user.Email, candidate.roleID, fileName)
+		if auditErr := RegisterAudit(ctx, "INSERT", description, requestedBy, "SEGURIDAD", helpers.AdjustToEcuadorTime(time.Now())); auditErr != nil {
+			log.Printf("Error al registrar la auditoría de la importación del usuario %s: %v", user.Email, auditErr)
+		}
+	}
+
+	for _, row := range result.Rows {
+		result.Total++
+		if row.Status == UserImportStatusCreated {
+			result.Created++
+		} else {
+			result.Failed++
+		}
+	}
+	if err := writeUserImportResult(result, table, fileName, columns["password"]); err != nil {
+		return nil, fmt.Errorf("error al generar el archivo de resultados: %w", err)
+	}
+	return result, nil
+}
+
+// Límites al descomprimir el Excel: el archivo completo y la parte de una hoja que se mantiene en memoria
+// (el resto se descomprime a un archivo temporal)
+const (
+	userImportUnzipSizeLimit    = 100 << 20
+	userImportUnzipXMLSizeLimit = 10 << 20
+)
+
+// readUserImportTable lee la primera hoja del Excel o el CSV (separado por comas o punto y coma) y
+// descarta las filas vacías. Las filas se leen de a una y la lectura se detiene al pasar de maxRows.
+func readUserImportTable(file io.Reader, fileName string, maxRows int) (*userImportTable, error) {
+	table := &userImportTable{}
+	tooManyRows := &UserImportError{Message: fmt.Sprintf("el archivo supera el máximo de %d filas por importación", maxRows)}
+	// add agrega el registro de la línea indicada; devuelve false cuando ya se superó maxRows
+	add := func(line int, record []string) bool {
+		if isEmptyRecord(record) {
+			return true
+		}
+		if table.header == nil {
+			table.header = record
+			return true
+		}
+		table.rows = append(table.rows, record)
+		table.lines = append(table.lines, line)
+		return len(table.rows) <= maxRows
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx":
+		table.excel = true
+		workbook, err := excelize.OpenReader(file, excelize.Options{UnzipSizeLimit: userImportUnzipSizeLimit, UnzipXMLSizeLimit: userImportUnzipXMLSizeLimit})
+		if err != nil {
+			return nil, &UserImportError{Message: "no se pudo leer el archivo Excel"}
+		}
+		defer workbook.Close()
+		rows, err := workbook.Rows(workbook.GetSheetName(0))
+		if err != nil {
+			return nil, &UserImportError{Message: "no se pudo leer la primera hoja del archivo Excel"}
+		}
+		defer rows.Close()
+		for line := 1; rows.Next(); line++ {
+			record, err := rows.Columns()
+			if err != nil {
+				return nil, &UserImportError{Message: "no se pudo leer la primera hoja del archivo Excel"}
+			}
+			if !add(line, record) {
+				return nil, tooManyRows
+			}
+		}
+		if rows.Error() != nil {
+			return nil, &UserImportError{Message: "no se pudo leer la primera hoja del archivo Excel"}
+		}
+	case ".csv":
+		buffered := bufio.NewReader(file)
+		if bom, _ := buffered.Peek(3); bytes.Equal(bom, []byte("\ufeff")) {
+			buffered.Discard(3)
+		}
+		firstLine, _ := buffered.Peek(buffered.Size())
+		firstLine, _, _ = bytes.Cut(firstLine, []byte("\n"))
+		table.delimiter = ','
+		if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+			table.delimiter = ';'
+		}
+		reader := csv.NewReader(buffered)
+		reader.Comma = table.delimiter
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, &UserImportError{Message: "el archivo CSV no es válido: " + err.Error()}
+			}
+			if !add(line, record) {
+				return nil, tooManyRows
+			}
+		}
+	default:
+		return nil, &UserImportError{Message: "formato no soportado; envíe un archivo .xlsx o .csv"}
+	}
+
+	if table.header == nil {
+		return nil, &UserImportError{Message: "el archivo está vacío"}
+	}
+	return table, nil
+}
+
+func isEmptyRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// userImportColumns ubica la columna de cada campo en el encabezado del archivo
+func userImportColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[normalizeImportHeader(name)] = i
+	}
+	known := make(map[string]bool, len(userImportFields))
+	for _, field := range userImportFields {
+		known[field.key] = true
+	}
+	for key := range mapping {
+		if !known[key] {
+			return nil, &UserImportError{Message: fmt.Sprintf("campo de mapeo no soportado: %s (use name, email, password, active, role_id)", key)}
+		}
+	}
+
+	columns := make(map[string]int, len(userImportFields))
+	var missing []string
+	for _, field := range userImportFields {
+		candidates := []string{field.key, field.header}
+		if mapped, ok := mapping[field.key]; ok {
+			candidates = []string{mapped}
+		}
+		found := false
+		for _, candidate := range candidates {
+			if position, ok := positions[normalizeImportHeader(candidate)]; ok {
+				columns[field.key], found = position, true
+				break
+			}
+		}
+		if !found && field.required {
+			missing = append(missing, fmt.Sprintf("%s (%s)", field.key, strings.Join(candidates, " o ")))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &UserImportError{Message: fmt.Sprintf("no se encontraron las columnas %s; encabezados del archivo: %s", strings.Join(missing, ", "), strings.Join(header, ", "))}
+	}
+	return columns, nil
+}
+
+func normalizeImportHeader(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// parseUserImportRow convierte los valores de la fila y registra los errores de formato
+func parseUserImportRow(result *UserImportRow, cells []string, columns map[string]int) *userImportCandidate {
+	candidate := &userImportCandidate{result: result, active: true}
+	cell := func(key string) string {
+		position, ok := columns[key]
+		if !ok || position >= len(cells) {
+			return ""
+		}
+		return strings.TrimSpace(cells[position])
+	}
+
+	candidate.name = cell("name")
+	candidate.email = cell("email")
+	candidate.password = cell("password")
+	result.Email = candidate.email
+
+	switch {
+	case candidate.name == "":
+		candidate.fail("El nombre es obligatorio")
+	case len(candidate.name) > 100:
+		candidate.fail("El nombre supera los 100 caracteres")
+	}
+	if candidate.email == "" {
+		candidate.fail("El correo es obligatorio")
+	} else if !isValidEmail(candidate.email) {
+		candidate.fail("El correo no tiene un formato válido")
+	}
+	if candidate.password == "" {
+		candidate.fail("La contraseña es obligatoria")
+	}
+	if active := cell("active"); active != "" {
+		switch strings.ToLower(active) {
+		case "sí", "si", "true", "1", "activo", "yes":
+			candidate.active = true
+		case "no", "false", "0", "inactivo":
+			candidate.active = false
+		default:
+			candidate.fail("El valor de Activo no es válido (use Sí o No)")
+		}
+	}
+	if roleID, err := strconv.ParseUint(cell("role_id"), 10, 32); err != nil || roleID == 0 {
+		candidate.fail("El ID del rol debe ser un número válido")
+	} else {
+		candidate.roleID = uint(roleID)
+	}
+	return candidate
+}
+
+// fail marca la fila con error; los mensajes de una misma fila se acumulan
+func (candidate *userImportCandidate) fail(message string) {
+	candidate.result.Status = UserImportStatusError
+	if candidate.result.Message != "" {
+		message = candidate.result.Message + "; " + message
+	}
+	candidate.result.Message = message
+}
+
+// validateUserImport revisa los correos repetidos en el archivo y en la base y que los roles existan
+func validateUserImport(candidates []*userImportCandidate) error {
+	firstRow := make(map[string]int, len(candidates))
+	var emails []string
+	roles := make(map[uint]bool)
+	for _, candidate := range candidates {
+		if candidate.email != "" {
+			email := strings.ToLower(candidate.email)
+			if row, ok := firstRow[email]; ok {
+				candidate.fail(fmt.Sprintf("El correo está repetido en la fila %d del archivo", row))
+			} else {
+				firstRow[email] = candidate.result.Row
+				emails = append(emails, email)
+			}
+		}
+		if candidate.roleID > 0 {
+			roles[candidate.roleID] = false
+		}
+	}
+
+	existing := make(map[string]bool)
+	for start := 0; start < len(emails); start += userImportBatch {
+		var found []string
+		batch := emails[start:min(start+userImportBatch, len(emails))]
+		if err := config.DB.Model(&models.User{}).Where("LOWER(email) IN ?", batch).Pluck("LOWER(email)", &found).Error; err != nil {
+			return fmt.Errorf("error al consultar los correos existentes: %w", err)
+		}
+		for _, email := range found {
+			existing[email] = true
+		}
+	}
+
+	roleIDs := make([]uint, 0, len(roles))
+	for id := range roles {
+		roleIDs = append(roleIDs, id)
+	}
+	for start := 0; start < len(roleIDs); start += userImportBatch {
+		var found []uint
+		batch := roleIDs[start:min(start+userImportBatch, len(roleIDs))]
+		if err := config.DB.Model(&models.Role{}).Where("id IN ?", batch).Pluck("id", &found).Error; err != nil {
+			return fmt.Errorf("error al consultar los roles: %w", err)
+		}
+		for _, id := range found {
+			roles[id] = true
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate.email != "" && existing[strings.ToLower(candidate.email)] {
+			candidate.fail("Ya existe un usuario con ese correo")
+		}
+		if candidate.roleID > 0 && !roles[candidate.roleID] {
+			candidate.fail(fmt.Sprintf("El rol %d no existe", candidate.roleID))
+		}
+	}
+	return nil
+}
+
+// createImportedUser crea el usuario y le asigna el rol en una transacción, para no dejar usuarios sin rol
+func createImportedUser(ctx context.Context, candidate *userImportCandidate) (models.User, error) {
+	hashedPassword, err := hashPassword(candidate.password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error al encriptar la contraseña")
+	}
+	user := models.User{Name: candidate.name, Email: candidate.email, Password: hashedPassword, Active: candidate.active}
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createUserWithRole(tx, &user, candidate.roleID)
+	})
+	return user, err
+}
+
+// createUserWithRole crea el usuario con la contraseña ya encriptada y le asigna el rol dentro de tx
+func createUserWithRole(tx *gorm.DB, user *models.User, roleID uint) error {
+	if err := createKeepingActive(tx, user, user.Active); err != nil {
+		return err
+	}
+	return tx.Create(&models.UserRole{UserID: user.ID, RoleID: roleID}).Error
+}
+
+// userImportMaskedPassword reemplaza la contraseña en el archivo de resultados
+const userImportMaskedPassword = "********"
+
+// writeUserImportResult genera el archivo de resultados en el formato recibido: las filas originales con
+// las columnas Estado y Detalle. El archivo circula entre quienes hacen la carga, por eso la columna de
+// la contraseña (passwordColumn) se enmascara.
+func writeUserImportResult(result *UserImportResult, table *userImportTable, fileName string, passwordColumn int) error {
+	base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	header := append(append([]string{}, table.header...), "Estado", "Detalle")
+	statusColumn := len(header) - 1
+	resultRow := func(i int) []string {
+		row := make([]string, len(table.header), statusColumn+1)
+		copy(row, table.rows[i])
+		if row[passwordColumn] != "" {
+			row[passwordColumn] = userImportMaskedPassword
+		}
+		return append(row, result.Rows[i].Status, userImportDetail(result.Rows[i]))
+	}
+
+	if !table.excel {
+		var buffer bytes.Buffer
+		buffer.WriteString("\ufeff")
+		writer := csv.NewWriter(&buffer)
+		writer.Comma = table.delimiter
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for i := range table.rows {
+			if err := writer.Write(resultRow(i)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		result.File, result.FileName, result.ContentType = buffer.Bytes(), base+"_resultado.csv", "text/csv"
+		return nil
+	}
+
+	file := excelize.NewFile()
+	defer file.Close()
+	if err := file.SetSheetName("Sheet1", userImportSheet); err != nil {
+		return err
+	}
+	headerStyle, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	createdStyle, err := file.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"C6EFCE"}}})
+	if err != nil {
+		return err
+	}
+	errorStyle, err := file.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}})
+	if err != nil {
+		return err
+	}
+
+	if err := file.SetSheetRow(userImportSheet, "A1", &header); err != nil {
+		return err
+	}
+	lastColumn, _ := excelize.ColumnNumberToName(len(header))
+	if err := file.SetCellStyle(userImportSheet, "A1", lastColumn+"1", headerStyle); err != nil {
+		return err
+	}
+	statusName, _ := excelize.ColumnNumberToName(statusColumn)
+	for i := range table.rows {
+		line := i + 2
+		row := resultRow(i)
+		cell, _ := excelize.CoordinatesToCellName(1, line)
+		if err := file.SetSheetRow(userImportSheet, cell, &row); err != nil {
+			return err
+		}
+		style := errorStyle
+		if result.Rows[i].Status == UserImportStatusCreated {
+			style = createdStyle
+		}
+		if err := file.SetCellStyle(userImportSheet, fmt.Sprintf("%s%d", statusName, line), fmt.Sprintf("%s%d", lastColumn, line), style); err != nil {
+			return err
+		}
+	}
+	if err := file.SetColWidth(userImportSheet, lastColumn, lastColumn, 60); err != nil {
+		return err
+	}
+
+	buffer, err := file.WriteToBuffer()
+	if err != nil {
+		return err
+	}
+	result.File, result.FileName = buffer.Bytes(), base+"_resultado.xlsx"
+	result.ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	return nil
+}
+
+// isValidEmail acepta solo una dirección simple (sin nombre) que quepa en la columna email
+func isValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email && len(email) <= 150
+}
+
+func userImportDetail(row UserImportRow) string {
+	if row.Status == UserImportStatusCreated {
+		return fmt.Sprintf("Usuario creado con ID %d", row.UserID)
+	}
+	return row.Message
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadUserImportTableExcel(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	sheet := file.GetSheetName(0)
+	file.SetSheetRow(sheet, "A1", &[]string{"Nombre", "Correo"})
+	file.SetSheetRow(sheet, "A2", &[]string{"Ana", "ana@utn.edu.ec"})
+	// La fila 3 queda vacía y no se importa
+	file.SetSheetRow(sheet, "A4", &[]string{"Luis", "luis@utn.edu.ec"})
+	var content bytes.Buffer
+	if err := file.Write(&content); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := readUserImportTable(bytes.NewReader(content.Bytes()), "usuarios.xlsx", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.header, []string{"Nombre", "Correo"}) {
+		t.Errorf("encabezado %v", table.header)
+	}
+	if !reflect.DeepEqual(table.lines, []int{2, 4}) {
+		t.Errorf("filas %v, se esperaba [2 4]", table.lines)
+	}
+}
+
+func TestReadUserImportTableStopsPastMaxRows(t *testing.T) {
+	csv := "\ufeffnombre;correo\n" + strings.Repeat("Ana;ana@utn.edu.ec\n", 4)
+
+	table, err := readUserImportTable(strings.NewReader(csv), "usuarios.csv", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.delimiter != ';' || len(table.rows) != 4 || table.header[0] != "nombre" {
+		t.Errorf("tabla inesperada: separador %q, %d filas, encabezado %v", table.delimiter, len(table.rows), table.header)
+	}
+
+	_, err = readUserImportTable(strings.NewReader(csv), "usuarios.csv", 3)
+	var importErr *UserImportError
+	if !errors.As(err, &importErr) || !strings.Contains(importErr.Message, "máximo de 3 filas") {
+		t.Errorf("se esperaba el error del máximo de filas, se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"log"
+	"seguridad-api/config"
+	"seguridad-api/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// recordUserLogin guarda el inicio de sesión exitoso del usuario en el módulo; un error solo se registra
+// en el log para no impedir el acceso
+func recordUserLogin(ctx context.Context, userID uint, moduleKey string) {
+	var module models.Module
+	if err := config.DB.WithContext(ctx).Where("module_key = ?", moduleKey).First(&module).Error; err != nil {
+		log.Printf("Error al registrar el acceso del usuario %d al módulo %s: %v", userID, moduleKey, err)
+		return
+	}
+
+	login := models.UserLogin{
+		UserID:      userID,
+		ModuleID:    module.ID,
+		LastLoginAt: time.Now(),
+		IP:          RequestMetadataFromContext(ctx).IP,
+	}
+	err := config.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "module_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_login_at", "ip"}),
+	}).Create(&login).Error
+	if err != nil {
+		log.Printf("Error al registrar el acceso del usuario %d al módulo %s: %v", userID, moduleKey, err)
+	}
+}
+
+// GetUserLogins devuelve el último acceso del usuario a cada módulo, del más reciente al más antiguo
+func GetUserLogins(userID uint) ([]models.UserLoginResponse, error) {
+	logins := []models.UserLoginResponse{}
+	err := config.DB.Table("user_logins").
+		Select("user_logins.module_id, modules.name AS module_name, modules.module_key, user_logins.last_login_at, user_logins.ip").
+		Joins("LEFT JOIN modules ON modules.id = user_logins.module_id").
+		Where("user_logins.user_id = ?", userID).
+		Order("user_logins.last_login_at DESC").
+		Scan(&logins).Error
+	return logins, err
+}
@@ -1,24 +1,25 @@
 package services
 
 import (
+	"context"
 	"seguridad-api/config"
 	"seguridad-api/models"
 )
 
 // Asignar un rol a un usuario
-func AssignRoleToUser(userID, roleID uint) (models.UserRole, error) {
+func AssignRoleToUser(ctx context.Context, userID, roleID uint) (models.UserRole, error) {
 	userRole := models.UserRole{
 		UserID: userID,
 		RoleID: roleID,
 	}
-	result := config.DB.Create(&userRole) // Inserta el nuevo registro en la tabla user_roles
+	result := config.DB.WithContext(ctx).Create(&userRole) // Inserta el nuevo registro en la tabla user_roles
 	return userRole, result.Error
 }
 
 // Eliminar un rol de un usuario
-func RemoveRoleFromUser(userID, roleID uint) error {
+func RemoveRoleFromUser(ctx context.Context, userID, roleID uint) error {
 	// Elimina el registro que coincide con el userID y roleID
-	result := config.DB.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{})
+	result := config.DB.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{})
 	return result.Error
 }
 
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"seguridad-api/config"
 	"seguridad-api/models"
@@ -16,7 +17,7 @@ func hashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-func CreateUser(name, email, password string, active bool) (models.User, error) {
+func CreateUser(ctx context.Context, name, email, password string, active bool) (models.User, error) {
 	hashedPassword, err := hashPassword(password)
 	if err != nil {
 		return models.User{}, errors.New("error al encriptar la contraseña")
@@ -29,7 +30,7 @@ func CreateUser(name, email, password string, active bool) (models.User, error)
 		Active:   active,
 	}
 
-	result := config.DB.Create(&user)
+	result := config.DB.WithContext(ctx).Create(&user)
 	if result.Error != nil {
 		return models.User{}, result.Error
 	}
@@ -70,7 +71,7 @@ func GetUserPermissions(userID uint) ([]models.Permission, error) {
 	return permissions, nil
 }
 
-func UpdateUser(id string, name, email string, active *bool) (models.User, error) {
+func UpdateUser(ctx context.Context, id string, name, email string, active *bool) (models.User, error) {
 	var user models.User
 
 	if err := config.DB.First(&user, "id = ?", id).Error; err != nil {
@@ -87,11 +88,11 @@ func UpdateUser(id string, name, email string, active *bool) (models.User, error
 		user.Active = *active
 	}
 
-	result := config.DB.Save(&user)
+	result := config.DB.WithContext(ctx).Save(&user)
 	return user, result.Error
 }
 
-func DeleteUser(id string) error {
+func DeleteUser(ctx context.Context, id string) error {
 	var user models.User
 
 	if err := config.DB.First(&user, "id = ?", id).Error; err != nil {
@@ -100,7 +101,7 @@ func DeleteUser(id string) error {
 
 	// user.Active = false
 	user.Active = !user.Active
-	return config.DB.Save(&user).Error
+	return config.DB.WithContext(ctx).Save(&user).Error
 }
 
 func GetPaginatedUsers(page, pageSize int, filters map[string]interface{}) ([]models.User, int64, error) {
@@ -132,3 +133,33 @@ func GetPaginatedUsers(page, pageSize int, filters map[string]interface{}) ([]mo
 
 	return users, total, nil
 }
+
+// FindExistingUserIDs devuelve cuáles de los IDs corresponden a usuarios registrados
+func FindExistingUserIDs(ids []uint) (map[uint]bool, error) {
+	existing := make(map[uint]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var found []uint
+	if err := config.DB.Model(&models.User{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// UserHasPermission indica si alguno de los roles activos del usuario tiene el permiso activo indicado
+func UserHasPermission(userID uint, permissionName string) (bool, error) {
+	var count int64
+	err := config.DB.Model(&models.Permission{}).
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND permissions.name = ? AND permissions.active = ? AND roles.active = ?",
+			userID, permissionName, true, true).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -3,115 +3,435 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
-	helpers "seguridad-api/helpers"
+	"math"
 	"time"
+	"unicode/utf8"
 
 	"github.com/signintech/gopdf"
 	"github.com/xuri/excelize/v2"
 )
 
-func GenerateExcel(title string, headers []string, data [][]string, usernameAndFilters string, userName string, option string) (*bytes.Buffer, error) {
+// ReportIntegrity identifica el reporte generado; se imprime en el documento para poder verificarlo
+type ReportIntegrity struct {
+	ReportID    string
+	ContentHash string
+}
+
+func (r ReportIntegrity) label(theme ReportTheme) string {
+	return theme.Tf("Reporte [ %s ]  SHA-256 del contenido [ %s ]", r.ReportID, r.ContentHash)
+}
+
+// groupLabel es el título de un grupo de filas con el valor de la columna de agrupación
+func groupLabel(theme ReportTheme, header, value string) string {
+	if value == "" {
+		value = theme.T("(sin valor)")
+	}
+	return fmt.Sprintf("%s: %s", header, value)
+}
+
+func groupSubtotalLabel(theme ReportTheme, value string, count int) string {
+	if value == "" {
+		value = theme.T("(sin valor)")
+	}
+	return theme.Tf("Subtotal %s: %d registros", value, count)
+}
+
+// TableReport es un reporte tabular; con GroupColumn >= 0 las filas (ya ordenadas por esa columna) se
+// separan en grupos con su título y subtotal. Summary agrega la página de resumen con gráficos. Los textos
+// propios del documento, el logo, la fuente, los colores, la fecha y la orientación salen de Theme.
+type TableReport struct {
+	Title       string
+	Filters     string
+	UserName    string
+	Option      string
+	Headers     []string
+	Data        [][]string
+	GroupColumn int
+	Summary     *ReportSummary
+	Integrity   ReportIntegrity
+	Theme       ReportTheme
+}
+
+const (
+	// minColumnWidth y maxColumnWidth acotan el ancho automático de las columnas en el PDF (puntos)
+	minColumnWidth = 30.0
+	// excelMinColumnWidth y excelMaxColumnWidth acotan el ancho automático en Excel (caracteres)
+	excelMinColumnWidth = 10.0
+	excelMaxColumnWidth = 60.0
+)
+
+// TableWriter escribe un reporte tabular fila por fila. Close recibe la identificación del reporte, que
+// solo se conoce después de la última fila, y termina de escribir el archivo.
+type TableWriter interface {
+	Write(row []string) error
+	Close(integrity ReportIntegrity) error
+}
+
+// widthSampleRows es la cantidad de filas que se retienen antes de empezar a escribir para calcular el
+// ancho de las columnas; el resto de filas se escribe sin guardarse
+const widthSampleRows = 200
+
+// tableRows lleva la cuenta de las filas y los grupos de un reporte tabular y retiene las primeras filas
+// hasta conocer el ancho de las columnas
+type tableRows struct {
+	report     TableReport
+	sample     [][]string
+	started    bool
+	records    int
+	groupValue string
+	groupCount int
+}
+
+// add retiene la fila si todavía no empezó la escritura; start se llama una vez con las filas de muestra
+func (t *tableRows) add(row []string, start func(sample [][]string) error, write func(row []string) error) error {
+	if !t.started {
+		t.sample = append(t.sample, row)
+		if len(t.sample) < widthSampleRows {
+			return nil
+		}
+		return t.flush(start, write)
+	}
+	return write(row)
+}
+
+func (t *tableRows) flush(start func(sample [][]string) error, write func(row []string) error) error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+	sample := t.sample
+	t.sample = nil
+	if err := start(sample); err != nil {
+		return err
+	}
+	for _, row := range sample {
+		if err := write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next avanza al registro siguiente; devuelve si la fila abre un grupo nuevo y, si cierra uno anterior,
+// su valor y cantidad de registros
+func (t *tableRows) next(row []string) (opens bool, closed string, closedCount int, closes bool) {
+	groupColumn := t.report.GroupColumn
+	if groupColumn >= 0 && groupColumn < len(row) && (t.records == 0 || row[groupColumn] != t.groupValue) {
+		opens = true
+		if t.records > 0 {
+			closed, closedCount, closes = t.groupValue, t.groupCount, true
+		}
+		t.groupValue, t.groupCount = row[groupColumn], 0
+	}
+	t.records++
+	t.groupCount++
+	return
+}
+
+// grouped indica si al cerrar el reporte hay que escribir el último subtotal y el total
+func (t *tableRows) grouped() bool {
+	return t.report.GroupColumn >= 0 && t.records > 0
+}
+
+// GenerateExcel arma el reporte en una hoja y, si tiene resumen, agrega la hoja de resumen con gráficos
+func GenerateExcel(report TableReport) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	writer, err := NewExcelTableWriter(buf, report)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTable(writer, report); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeTable escribe las filas de report.Data con el escritor indicado
+func writeTable(writer TableWriter, report TableReport) error {
+	for _, row := range report.Data {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return writer.Close(report.Integrity)
+}
+
+// excelTableWriter escribe la hoja con el StreamWriter de excelize, que pasa las filas a un archivo
+// temporal en lugar de mantener la hoja en memoria
+type excelTableWriter struct {
+	tableRows
+	out       io.Writer
+	file      *excelize.File
+	sheet     *excelize.StreamWriter
+	rowNumber int
+
+	headerStyleID   int
+	groupStyleID    int
+	subtotalStyleID int
+	wrapStyleID     int
+	titleStyleID    int
+}
+
+// NewExcelTableWriter prepara el libro; las filas se agregan con Write y el archivo se escribe en w al
+// llamar a Close. report.Data no se usa.
+func NewExcelTableWriter(w io.Writer, report TableReport) (TableWriter, error) {
+	theme := report.Theme
 	f := excelize.NewFile()
 
-	sheetName := "Reporte"
-	if option == "usuariosCompletos" {
-		sheetName = "Usuarios Completos"
+	sheetName := theme.T("Reporte")
+	if report.Option == "usuariosCompletos" {
+		sheetName = theme.T("Usuarios Completos")
 	}
 	index, err := f.NewSheet(sheetName)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 	f.SetActiveSheet(index)
 
-	titleCell := "A1"
-	f.SetCellValue(sheetName, titleCell, title)
-	style := &excelize.Style{
+	writer := &excelTableWriter{tableRows: tableRows{report: report}, out: w, file: f, rowNumber: 6}
+	writer.titleStyleID, err = f.NewStyle(&excelize.Style{
 		Font: &excelize.Font{
-			Bold: true,
-			Size: 16,
+			Bold:  true,
+			Size:  16,
+			Color: theme.PrimaryColor,
 		},
 		Alignment: &excelize.Alignment{
 			Horizontal: "center",
 		},
-	}
-	styleID, err := f.NewStyle(style)
+	})
 	if err != nil {
+		f.Close()
 		return nil, fmt.Errorf("error creating style: %w", err)
 	}
-	f.MergeCell(sheetName, titleCell, fmt.Sprintf("%s1", columnNameFromIndex(len(headers)-1)))
-	f.SetCellStyle(sheetName, titleCell, fmt.Sprintf("%s1", columnNameFromIndex(len(headers)-1)), styleID)
+	headerStyle := &excelize.Style{Font: &excelize.Font{Bold: true}}
+	if theme.PrimaryColor != "" {
+		headerStyle.Font.Color = "#FFFFFF"
+		headerStyle.Fill = excelize.Fill{Type: "pattern", Color: []string{theme.PrimaryColor}, Pattern: 1}
+	}
+	if writer.headerStyleID, err = f.NewStyle(headerStyle); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creando estilo de encabezado: %w", err)
+	}
+	if writer.groupStyleID, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Color: []string{"#E7E6E6"}, Pattern: 1}}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creando estilo de grupo: %w", err)
+	}
+	if writer.subtotalStyleID, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Italic: true}}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creando estilo de subtotal: %w", err)
+	}
+	if writer.wrapStyleID, err = f.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true}}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creando estilo de ajuste de texto: %w", err)
+	}
+	if writer.sheet, err = f.NewStreamWriter(sheetName); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return writer, nil
+}
 
-	currentTime := time.Now()
-	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
-	f.SetCellValue(sheetName, "A2", fmt.Sprintf("Fecha [ %s ]", ecuadorTime.Format("02/01/2006 15:04:05")))
-	f.SetCellValue(sheetName, "A3", fmt.Sprintf("Generado por: [ %s ]", userName))
-	f.SetCellValue(sheetName, "A4", usernameAndFilters)
+// start fija el ancho de las columnas (el StreamWriter lo exige antes de la primera fila) y escribe el
+// título, los datos del reporte y los encabezados
+func (w *excelTableWriter) start(sample [][]string) error {
+	report, theme := w.report, w.report.Theme
+	for i, width := range excelColumnWidths(report.Headers, sample) {
+		if err := w.sheet.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
 
-	for i := 0; i < 4; i++ {
-		f.SetColWidth(sheetName, columnNameFromIndex(i), columnNameFromIndex(i), 20)
+	lastColumn := columnNameFromIndex(len(report.Headers) - 1)
+	if err := w.sheet.SetRow("A1", []interface{}{excelize.Cell{StyleID: w.titleStyleID, Value: report.Title}}); err != nil {
+		return err
+	}
+	if lastColumn != "A" {
+		if err := w.sheet.MergeCell("A1", lastColumn+"1"); err != nil {
+			return err
+		}
+	}
+	lines := []string{
+		theme.Tf("Fecha [ %s ]", theme.Now()),
+		theme.Tf("Generado por: [ %s ]", report.UserName),
+		report.Filters,
+	}
+	for i, line := range lines {
+		if err := w.sheet.SetRow(fmt.Sprintf("A%d", i+2), []interface{}{line}); err != nil {
+			return err
+		}
 	}
 
-	if option == "usuariosCompletos" {
-		headers = []string{"Nombre", "Roles", "Permisos", "Módulos"}
+	headers := make([]interface{}, len(report.Headers))
+	for i, header := range report.Headers {
+		headers[i] = excelize.Cell{StyleID: w.headerStyleID, Value: header}
 	}
+	return w.sheet.SetRow("A5", headers)
+}
 
-	for i, header := range headers {
-		cell := fmt.Sprintf("%s5", columnNameFromIndex(i))
-		f.SetCellValue(sheetName, cell, header)
-	}
-
-	for rowIndex, row := range data {
-		for colIndex, value := range row {
-			cell := fmt.Sprintf("%s%d", columnNameFromIndex(colIndex), rowIndex+6)
-			f.SetCellValue(sheetName, cell, value)
-			if option == "usuariosCompletos" {
-				if colIndex == 1 || colIndex == 2 || colIndex == 3 {
-					style := &excelize.Style{
-						Alignment: &excelize.Alignment{
-							WrapText: true,
-						},
-					}
-					styleID, err := f.NewStyle(style)
-					if err != nil {
-						return nil, fmt.Errorf("error creando estilo de ajuste de texto: %w", err)
-					}
-					f.SetCellStyle(sheetName, cell, cell, styleID)
-				}
-			}
+// band escribe una fila que ocupa todo el ancho de la tabla (título o subtotal de un grupo)
+func (w *excelTableWriter) band(text string, styleID int) error {
+	cells := make([]interface{}, len(w.report.Headers))
+	cells[0] = excelize.Cell{StyleID: styleID, Value: text}
+	for i := 1; i < len(cells); i++ {
+		cells[i] = excelize.Cell{StyleID: styleID}
+	}
+	first := fmt.Sprintf("A%d", w.rowNumber)
+	if err := w.sheet.SetRow(first, cells); err != nil {
+		return err
+	}
+	if len(cells) > 1 {
+		last := fmt.Sprintf("%s%d", columnNameFromIndex(len(cells)-1), w.rowNumber)
+		if err := w.sheet.MergeCell(first, last); err != nil {
+			return err
+		}
+	}
+	w.rowNumber++
+	return nil
+}
+
+func (w *excelTableWriter) writeRow(row []string) error {
+	theme, headers := w.report.Theme, w.report.Headers
+	opens, closed, closedCount, closes := w.next(row)
+	if closes {
+		if err := w.band(groupSubtotalLabel(theme, closed, closedCount), w.subtotalStyleID); err != nil {
+			return err
+		}
+	}
+	if opens {
+		if err := w.band(groupLabel(theme, headers[w.report.GroupColumn], w.groupValue), w.groupStyleID); err != nil {
+			return err
 		}
 	}
 
-	for i := range headers {
-		column := columnNameFromIndex(i)
-		f.SetColWidth(sheetName, column, column, 40)
+	cells := make([]interface{}, len(row))
+	for colIndex, value := range row {
+		cells[colIndex] = value
+		if w.report.Option == "usuariosCompletos" && (colIndex == 1 || colIndex == 2 || colIndex == 3) {
+			cells[colIndex] = excelize.Cell{StyleID: w.wrapStyleID, Value: value}
+		}
+	}
+	if err := w.sheet.SetRow(fmt.Sprintf("A%d", w.rowNumber), cells); err != nil {
+		return err
 	}
+	w.rowNumber++
+	return nil
+}
 
-	buf := new(bytes.Buffer)
-	if err := f.Write(buf); err != nil {
-		return nil, err
+func (w *excelTableWriter) Write(row []string) error {
+	return w.add(row, w.start, w.writeRow)
+}
+
+func (w *excelTableWriter) Close(integrity ReportIntegrity) error {
+	defer w.file.Close()
+	theme := w.report.Theme
+	if err := w.flush(w.start, w.writeRow); err != nil {
+		return err
+	}
+	if w.grouped() {
+		if err := w.band(groupSubtotalLabel(theme, w.groupValue, w.groupCount), w.subtotalStyleID); err != nil {
+			return err
+		}
+		if err := w.band(theme.Tf("Total: %d registros", w.records), w.groupStyleID); err != nil {
+			return err
+		}
+	}
+	if err := w.sheet.SetRow(fmt.Sprintf("A%d", w.rowNumber+1), []interface{}{integrity.label(theme)}); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
 	}
 
-	return buf, nil
+	if w.report.Summary != nil {
+		if err := addSummarySheet(w.file, *w.report.Summary, theme); err != nil {
+			return err
+		}
+	}
+	if err := w.file.SetDocProps(&excelize.DocProperties{
+		Title:       w.report.Title,
+		Creator:     w.report.UserName,
+		Identifier:  integrity.ReportID,
+		Description: theme.Tf("SHA-256 del contenido: %s", integrity.ContentHash),
+	}); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+// excelColumnWidths ajusta cada columna al texto más largo entre el encabezado y los valores
+func excelColumnWidths(headers []string, data [][]string) []float64 {
+	widths := make([]float64, len(headers))
+	for i, header := range headers {
+		widths[i] = float64(utf8.RuneCountInString(header))
+	}
+	for _, row := range data {
+		for i, value := range row {
+			if i < len(widths) {
+				widths[i] = math.Max(widths[i], float64(utf8.RuneCountInString(value)))
+			}
+		}
+	}
+	for i := range widths {
+		widths[i] = math.Min(math.Max(widths[i]+2, excelMinColumnWidth), excelMaxColumnWidth)
+	}
+	return widths
 }
 
 func columnNameFromIndex(index int) string {
 	name := ""
 	for index >= 0 {
-		name = string('A'+(index%26)) + name
+		name = string(rune('A'+(index%26))) + name
 		index = index/26 - 1
 	}
 	return name
 }
 
-func GeneratePDF(title, usernameAndFilters string, data [][]string, headers []string, userName string, option string) (*bytes.Buffer, error) {
+// GeneratePDF arma el reporte en A4 vertical u horizontal según el tema, con el ancho de cada columna
+// ajustado a su contenido; si tiene resumen, la primera página muestra los gráficos
+func GeneratePDF(report TableReport) (*bytes.Buffer, error) {
 	var buf bytes.Buffer
-	pdf := gopdf.GoPdf{}
+	writer, err := NewPDFTableWriter(&buf, report)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTable(writer, report); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// pdfTableWriter dibuja las páginas a medida que llegan las filas, pero no las emite por partes: gopdf
+// conserva el documento completo en memoria hasta Close, así que la memoria crece con el PDF y su tamaño
+// se acota con el máximo de filas del formato (REPORT_PDF_MAX_ROWS). Los pies de página se dibujan al
+// final porque llevan el hash del contenido.
+type pdfTableWriter struct {
+	tableRows
+	out          io.Writer
+	pdf          *gopdf.GoPdf
+	currentY     float64
+	columnWidths []float64
+	columnX      []float64
+}
 
-	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+const (
+	pdfMarginX     = 30.0
+	pdfMarginY     = 30.0
+	pdfCellHeight  = 18.0
+	pdfLineSpacing = 8.0
+)
+
+// NewPDFTableWriter prepara el documento y dibuja el encabezado y el resumen; las filas se agregan con
+// Write y el archivo se escribe en w al llamar a Close. report.Data no se usa.
+func NewPDFTableWriter(w io.Writer, report TableReport) (TableWriter, error) {
+	theme := report.Theme
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: theme.pageSize()})
 	pdf.AddPage()
 
-	err := pdf.AddTTFFont("arial", "assets/fonts/Roboto-Regular.ttf")
+	err := pdf.AddTTFFont("arial", theme.fontPath())
 	if err != nil {
 		log.Println("Error al agregar fuente:", err)
 		return nil, err
@@ -122,135 +442,295 @@ func GeneratePDF(title, usernameAndFilters string, data [][]string, headers []st
 		return nil, err
 	}
 
-	marginX := 30.0
-	marginY := 30.0
-	cellHeight := 18.0
-	lineSpacing := 8.0
-	pageWidth := gopdf.PageSizeA4.W
-	pageHeight := gopdf.PageSizeA4.H
-	usableWidth := pageWidth - 2*marginX
-	columnWidth := usableWidth / float64(len(headers))
-	startX := marginX
-	startY := marginY + 50.0
-	currentTime := time.Now()
+	writer := &pdfTableWriter{tableRows: tableRows{report: report}, out: w, pdf: pdf}
+	writer.addHeader()
+	if report.Summary != nil {
+		pageHeight := theme.pageSize().H
+		drawSummaryPDF(pdf, *report.Summary, theme, pdfMarginX, writer.startY(), writer.usableWidth(), pageHeight-pdfMarginY, func() float64 {
+			writer.addPage()
+			return writer.startY()
+		})
+		writer.addPage()
+	}
+	return writer, nil
+}
 
-	ecuadorTime := helpers.AdjustToEcuadorTime(currentTime)
+func (w *pdfTableWriter) startY() float64 {
+	return pdfMarginY + 50.0
+}
 
-	addHeader := func(pageNum int) {
-		pdf.Br(20)
+func (w *pdfTableWriter) usableWidth() float64 {
+	return w.report.Theme.pageSize().W - 2*pdfMarginX
+}
 
-		imgPath := "assets/img/security.png"
-		err := pdf.Image(imgPath, pageWidth-marginX-50, marginY, &gopdf.Rect{W: 20, H: 20})
-		if err != nil {
-			log.Println("Error al cargar la imagen:", err)
-		}
+func (w *pdfTableWriter) addHeader() {
+	pdf, theme := w.pdf, w.report.Theme
+	pageWidth := theme.pageSize().W
+	pdf.Br(20)
 
-		pdf.SetFont("arial", "B", 20)
-		pdf.SetX(marginX)
-		pdf.Cell(nil, title)
+	err := pdf.Image(theme.logoPath(), pageWidth-pdfMarginX-50, pdfMarginY, &gopdf.Rect{W: 20, H: 20})
+	if err != nil {
+		log.Println("Error al cargar la imagen:", err)
+	}
 
-		pdf.Br(12)
-		pdf.SetFont("arial", "", 8)
-		pdf.SetX(marginX)
-		pdf.Cell(nil, fmt.Sprintf("Fecha [ %s ]", ecuadorTime.Format("02/01/2006 15:04:05")))
-		pdf.Br(12)
+	pdf.SetFont("arial", "B", 20)
+	pdf.SetX(pdfMarginX)
+	if r, g, b, ok := theme.primaryRGB(); ok {
+		pdf.SetTextColor(r, g, b)
+	}
+	pdf.Cell(nil, w.report.Title)
+	pdf.SetTextColor(0, 0, 0)
 
-		pdf.SetFont("arial", "", 8)
-		pdf.SetX(marginX)
-		pdf.Cell(nil, fmt.Sprintf("Generado por: [ %s ]", userName))
-		pdf.Br(12)
+	pdf.Br(12)
+	pdf.SetFont("arial", "", 8)
+	pdf.SetX(pdfMarginX)
+	pdf.Cell(nil, theme.Tf("Fecha [ %s ]", theme.Now()))
+	pdf.Br(12)
 
-		pdf.SetFont("arial", "", 8)
-		pdf.SetX(marginX)
-		pdf.Cell(nil, usernameAndFilters)
-		pdf.Br(15)
-	}
+	pdf.SetFont("arial", "", 8)
+	pdf.SetX(pdfMarginX)
+	pdf.Cell(nil, theme.Tf("Generado por: [ %s ]", w.report.UserName))
+	pdf.Br(12)
 
-	addFooter := func(pageNum int) {
-		pdf.SetFont("arial", "", 8)
-		pdf.SetX(marginX)
-		pdf.SetY(pageHeight - marginY + 10)
-		pdf.Cell(nil, fmt.Sprintf("Página %d", pageNum))
-	}
+	pdf.SetFont("arial", "", 8)
+	pdf.SetX(pdfMarginX)
+	pdf.Cell(nil, w.report.Filters)
+	pdf.Br(15)
+}
 
-	addHeader(1)
+func (w *pdfTableWriter) addPage() {
+	w.pdf.AddPage()
+	w.addHeader()
+}
+
+// start reparte el ancho de las columnas según las filas de muestra y dibuja los encabezados de la tabla
+func (w *pdfTableWriter) start(sample [][]string) error {
+	pdf, headers := w.pdf, w.report.Headers
+	w.columnWidths = fitColumnWidths(pdf, headers, sample, w.usableWidth())
+	w.columnX = make([]float64, len(w.columnWidths))
+	for i := range w.columnWidths {
+		w.columnX[i] = pdfMarginX
+		if i > 0 {
+			w.columnX[i] = w.columnX[i-1] + w.columnWidths[i-1]
+		}
+	}
 
+	primaryR, primaryG, primaryB, hasPrimary := w.report.Theme.primaryRGB()
 	pdf.SetFont("arial", "B", 8)
-	currentY := startY
+	w.currentY = w.startY()
 	pdf.SetTextColor(0, 0, 0)
 	for i, header := range headers {
-		x := startX + float64(i)*columnWidth
-		pdf.RectFromUpperLeftWithStyle(x, currentY, columnWidth, cellHeight, "D")
-		pdf.SetXY(x+2, currentY+2)
-		pdf.Cell(nil, header)
+		style := "D"
+		if hasPrimary {
+			style = "FD"
+			pdf.SetFillColor(primaryR, primaryG, primaryB)
+		}
+		pdf.RectFromUpperLeftWithStyle(w.columnX[i], w.currentY, w.columnWidths[i], pdfCellHeight, style)
+		// SetFillColor también cambia el color del texto en gopdf
+		pdf.SetFillColor(0, 0, 0)
+		if hasPrimary {
+			pdf.SetTextColor(255, 255, 255)
+		}
+		pdf.SetXY(w.columnX[i]+2, w.currentY+2)
+		pdf.Cell(nil, fitText(pdf, header, w.columnWidths[i]-4))
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Br(pdfCellHeight)
+	pdf.SetFont("arial", "", 8)
+	return nil
+}
+
+func (w *pdfTableWriter) nextPageIfFull() {
+	if w.currentY+pdfCellHeight > w.report.Theme.pageSize().H-pdfMarginY {
+		w.addPage()
+		w.currentY = w.startY()
+		w.pdf.SetY(w.currentY)
 	}
-	pdf.Br(cellHeight)
+}
 
+// addBand dibuja una fila que ocupa todo el ancho de la tabla (título o subtotal de un grupo)
+func (w *pdfTableWriter) addBand(text string, fill bool) {
+	pdf := w.pdf
+	w.currentY = pdf.GetY()
+	style := "D"
+	if fill {
+		style = "FD"
+		pdf.SetFillColor(231, 230, 230)
+		pdf.SetFont("arial", "B", 8)
+	}
+	pdf.RectFromUpperLeftWithStyle(pdfMarginX, w.currentY, w.usableWidth(), pdfCellHeight, style)
+	// SetFillColor también cambia el color del texto en gopdf
+	pdf.SetFillColor(0, 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(pdfMarginX+2, w.currentY+4)
+	pdf.Cell(nil, text)
 	pdf.SetFont("arial", "", 8)
-	pageNum := 1
-	for _, row := range data {
-		currentY = pdf.GetY()
-		maxLinesInRow := 0
 
-		defaultMaxLines := 3
-		if option == "usuariosCompletos" {
-			defaultMaxLines = 15
-		}
+	w.currentY += pdfCellHeight
+	pdf.SetY(w.currentY)
+	w.nextPageIfFull()
+}
 
-		cellLines := make([]int, len(row))
-		for i, col := range row {
-			lines := wrapText(col, columnWidth-4, &pdf)
-			if len(lines) > defaultMaxLines {
-				lines = lines[:defaultMaxLines]
-			}
-			cellLines[i] = len(lines)
+func (w *pdfTableWriter) writeRow(row []string) error {
+	pdf, theme := w.pdf, w.report.Theme
+	opens, closed, closedCount, closes := w.next(row)
+	if closes {
+		w.addBand(groupSubtotalLabel(theme, closed, closedCount), false)
+	}
+	if opens {
+		w.addBand(groupLabel(theme, w.report.Headers[w.report.GroupColumn], w.groupValue), true)
+	}
 
-			if len(lines) > maxLinesInRow {
-				maxLinesInRow = len(lines)
-			}
+	w.currentY = pdf.GetY()
+	maxLinesInRow := 0
+
+	defaultMaxLines := 3
+	if w.report.Option == "usuariosCompletos" {
+		defaultMaxLines = 15
+	}
+
+	cellLines := make([][]string, len(row))
+	for i, col := range row {
+		if i >= len(w.columnWidths) {
+			break
+		}
+		lines := wrapText(col, w.columnWidths[i]-4, pdf)
+		if len(lines) > defaultMaxLines {
+			lines = lines[:defaultMaxLines]
 		}
+		cellLines[i] = lines
 
-		if maxLinesInRow < defaultMaxLines {
-			maxLinesInRow = defaultMaxLines
+		if len(lines) > maxLinesInRow {
+			maxLinesInRow = len(lines)
 		}
+	}
 
-		for i, col := range row {
-			x := startX + float64(i)*columnWidth
-			lines := wrapText(col, columnWidth-4, &pdf)
+	if maxLinesInRow < defaultMaxLines {
+		maxLinesInRow = defaultMaxLines
+	}
 
-			if len(lines) > maxLinesInRow {
-				lines = lines[:maxLinesInRow]
-			}
+	for i, lines := range cellLines {
+		if i >= len(w.columnWidths) {
+			break
+		}
+		pdf.RectFromUpperLeftWithStyle(w.columnX[i], w.currentY, w.columnWidths[i], float64(maxLinesInRow)*pdfLineSpacing+2, "D")
+		for j, line := range lines {
+			pdf.SetXY(w.columnX[i]+2, w.currentY+2+float64(j)*pdfLineSpacing)
+			pdf.Cell(nil, line)
+		}
+	}
 
-			pdf.RectFromUpperLeftWithStyle(x, currentY, columnWidth, float64(maxLinesInRow)*lineSpacing+2, "D")
-			for j, line := range lines {
-				pdf.SetXY(x+2, currentY+2+float64(j)*lineSpacing)
-				pdf.Cell(nil, line)
-			}
+	w.currentY += float64(maxLinesInRow)*pdfLineSpacing + 2
+	pdf.SetY(w.currentY)
+	w.nextPageIfFull()
+	return nil
+}
+
+func (w *pdfTableWriter) Write(row []string) error {
+	return w.add(row, w.start, w.writeRow)
+}
+
+// Close dibuja el último subtotal y los pies de todas las páginas, ya con el hash del contenido, y
+// escribe el documento en w
+func (w *pdfTableWriter) Close(integrity ReportIntegrity) error {
+	pdf, theme := w.pdf, w.report.Theme
+	if err := w.flush(w.start, w.writeRow); err != nil {
+		return err
+	}
+	if w.grouped() {
+		w.addBand(groupSubtotalLabel(theme, w.groupValue, w.groupCount), false)
+		w.addBand(theme.Tf("Total: %d registros", w.records), true)
+	}
+
+	pageHeight := theme.pageSize().H
+	for page := 1; page <= pdf.GetNumberOfPages(); page++ {
+		if err := pdf.SetPage(page); err != nil {
+			return err
 		}
+		pdf.SetFont("arial", "", 8)
+		pdf.SetX(pdfMarginX)
+		pdf.SetY(pageHeight - pdfMarginY + 10)
+		pdf.Cell(nil, theme.Tf("Página %d", page)+"    "+integrity.label(theme))
+	}
 
-		currentY += float64(maxLinesInRow)*lineSpacing + 2
-		pdf.SetY(currentY)
+	pdf.SetInfo(gopdf.PdfInfo{
+		Title:        w.report.Title,
+		Author:       w.report.UserName,
+		Subject:      integrity.label(theme),
+		Creator:      "API SEGURIDAD",
+		CreationDate: time.Now(),
+	})
+	if _, err := pdf.WriteTo(w.out); err != nil {
+		log.Println("Error al escribir el archivo PDF:", err)
+		return err
+	}
+	return nil
+}
 
-		if currentY+cellHeight > pageHeight-marginY {
-			addFooter(pageNum)
-			pageNum++
-			pdf.AddPage()
-			addHeader(pageNum)
-			currentY = startY
-			pdf.SetY(currentY)
+// fitColumnWidths reparte el ancho de la tabla según el texto de cada columna. Si todo entra, el espacio
+// sobrante se reparte en proporción; si no, las columnas angostas conservan su ancho y las anchas se
+// reparten el resto (y su texto pasa a varias líneas).
+func fitColumnWidths(pdf *gopdf.GoPdf, headers []string, data [][]string, usableWidth float64) []float64 {
+	wanted := make([]float64, len(headers))
+	pdf.SetFont("arial", "B", 8)
+	for i, header := range headers {
+		width, _ := pdf.MeasureTextWidth(header)
+		wanted[i] = width
+	}
+	pdf.SetFont("arial", "", 8)
+	for _, row := range data {
+		for i, value := range row {
+			if i >= len(wanted) || value == "" {
+				continue
+			}
+			width, _ := pdf.MeasureTextWidth(value)
+			wanted[i] = math.Max(wanted[i], width)
 		}
 	}
 
-	addFooter(pageNum)
+	total := 0.0
+	for i := range wanted {
+		wanted[i] = math.Max(wanted[i]+6, minColumnWidth)
+		total += wanted[i]
+	}
 
-	_, err = pdf.WriteTo(&buf)
-	if err != nil {
-		log.Println("Error al escribir el archivo PDF:", err)
-		return nil, err
+	widths := make([]float64, len(wanted))
+	if total <= usableWidth {
+		for i := range wanted {
+			widths[i] = wanted[i] * usableWidth / total
+		}
+		return widths
 	}
 
-	return &buf, nil
+	// Se fijan primero las columnas que entran en la parte igual del ancho que queda por repartir
+	remaining, pending := usableWidth, len(wanted)
+	fixed := make([]bool, len(wanted))
+	for changed := true; changed && pending > 0; {
+		changed = false
+		share := remaining / float64(pending)
+		for i := range wanted {
+			if !fixed[i] && wanted[i] <= share {
+				widths[i], fixed[i] = wanted[i], true
+				remaining -= wanted[i]
+				pending--
+				changed = true
+			}
+		}
+	}
+	if pending > 0 {
+		pendingTotal := 0.0
+		for i := range wanted {
+			if !fixed[i] {
+				pendingTotal += wanted[i]
+			}
+		}
+		for i := range wanted {
+			if !fixed[i] {
+				widths[i] = wanted[i] * remaining / pendingTotal
+			}
+		}
+	}
+	return widths
 }
 
 func wrapText(text string, maxWidth float64, pdf *gopdf.GoPdf) []string {
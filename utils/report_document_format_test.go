@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchmarkReport usa la fuente y el logo del proyecto; las pruebas se ejecutan desde utils/
+func benchmarkReport() TableReport {
+	theme := DefaultReportTheme()
+	theme.FontPath = filepath.Join("..", DefaultReportFont)
+	theme.LogoPath = filepath.Join("..", DefaultReportLogo)
+	return TableReport{
+		Title:       "Reporte de Audit",
+		Filters:     "Filtros [ Ninguno ]",
+		UserName:    "benchmark",
+		Headers:     []string{"ID", "Evento", "Descripción", "Usuario", "Servicio Origen", "Fecha"},
+		GroupColumn: -1,
+		Theme:       theme,
+	}
+}
+
+func syntheticRow(i int) []string {
+	return []string{
+		fmt.Sprintf("%d", i),
+		"UPDATE",
+		fmt.Sprintf("Se actualizó el usuario %d con nuevos roles y permisos asignados", i),
+		fmt.Sprintf("%d", i%500),
+		"SEGURIDAD",
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute).Format("2006-01-02 15:04:05"),
+	}
+}
+
+// heapSampleEvery es cada cuántas filas se mide la memoria en uso durante la escritura
+const heapSampleEvery = 2000
+
+// liveHeap devuelve la memoria ocupada por objetos vivos, después de una recolección completa
+func liveHeap() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// writeSyntheticReport escribe rows filas sintéticas y devuelve el máximo de memoria viva medido
+// durante la escritura, descontando la que ya estaba en uso antes de crear el writer
+func writeSyntheticReport(tb testing.TB, rows int, newWriter func(io.Writer, TableReport) (TableWriter, error)) uint64 {
+	report := benchmarkReport()
+	integrity := ReportIntegrity{ReportID: "benchmark", ContentHash: strings.Repeat("0", 64)}
+
+	base := liveHeap()
+	var peak uint64
+	sample := func() {
+		if heap := liveHeap(); heap > base && heap-base > peak {
+			peak = heap - base
+		}
+	}
+
+	writer, err := newWriter(io.Discard, report)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 1; i <= rows; i++ {
+		if err := writer.Write(syntheticRow(i)); err != nil {
+			tb.Fatal(err)
+		}
+		if i%heapSampleEvery == 0 {
+			sample()
+		}
+	}
+	// Antes de Close el writer aún tiene todo lo acumulado; es el punto de mayor memoria del PDF
+	sample()
+	if err := writer.Close(integrity); err != nil {
+		tb.Fatal(err)
+	}
+	return peak
+}
+
+// benchmarkTableWriter informa peak-live-MB: la memoria viva máxima durante la escritura. A diferencia
+// de B/op, que acumula todo lo asignado, muestra si la memoria se mantiene plana al crecer las filas.
+func benchmarkTableWriter(b *testing.B, rows int, newWriter func(io.Writer, TableReport) (TableWriter, error)) {
+	b.ReportAllocs()
+	var peak uint64
+	for n := 0; n < b.N; n++ {
+		if used := writeSyntheticReport(b, rows, newWriter); used > peak {
+			peak = used
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-live-MB")
+}
+
+func BenchmarkExcelTableWriter(b *testing.B) {
+	for _, rows := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			benchmarkTableWriter(b, rows, NewExcelTableWriter)
+		})
+	}
+}
+
+// El PDF se arma completo en memoria hasta Close, así que su pico crece con las filas
+func BenchmarkPDFTableWriter(b *testing.B) {
+	for _, rows := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			benchmarkTableWriter(b, rows, NewPDFTableWriter)
+		})
+	}
+}
+
+// TestExcelTableWriterBoundedMemory comprueba que el StreamWriter de Excel no acumula las filas. excelize
+// guarda hasta 16 MB en memoria antes de volcar la hoja a un archivo temporal; pasado ese punto, triplicar
+// las filas no debe aumentar la memoria viva máxima.
+func TestExcelTableWriterBoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("escribe decenas de miles de filas")
+	}
+	small := writeSyntheticReport(t, 30000, NewExcelTableWriter)
+	large := writeSyntheticReport(t, 90000, NewExcelTableWriter)
+	t.Logf("memoria viva máxima: %.1f MB con 30000 filas, %.1f MB con 90000 filas", float64(small)/(1<<20), float64(large)/(1<<20))
+
+	if limit := small + 8<<20; large > limit {
+		t.Errorf("la memoria creció con las filas: %d bytes con 90000 filas, límite %d", large, limit)
+	}
+}
@@ -0,0 +1,396 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/signintech/gopdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// MatrixReport es un reporte en forma de matriz: las primeras LabelColumns columnas identifican la fila
+// y el resto son las columnas de la matriz; una celda vacía indica que no hay acceso
+type MatrixReport struct {
+	Title        string
+	Filters      string
+	UserName     string
+	Headers      []string
+	Data         [][]string
+	LabelColumns int
+	// Abbreviate reemplaza en el PDF los valores de las celdas por códigos, con una leyenda al final
+	Abbreviate bool
+	Integrity  ReportIntegrity
+	// Theme da los textos, el logo, la fuente y la fecha; la matriz siempre va en horizontal
+	Theme ReportTheme
+}
+
+const (
+	matrixHeaderRow    = 5
+	matrixFirstDataRow = 6
+)
+
+// GenerateMatrixExcel genera la matriz con los encabezados y las columnas de identificación fijas y las
+// celdas con acceso resaltadas mediante formato condicional
+func GenerateMatrixExcel(report MatrixReport) (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	theme := report.Theme
+	sheetName := theme.T("Matriz")
+	index, err := f.NewSheet(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	f.SetActiveSheet(index)
+	f.DeleteSheet("Sheet1")
+
+	lastColumn := columnNameFromIndex(len(report.Headers) - 1)
+	firstMatrixColumn := columnNameFromIndex(report.LabelColumns)
+	lastRow := matrixFirstDataRow + len(report.Data) - 1
+
+	titleStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Size: 16, Color: theme.PrimaryColor},
+		Alignment: &excelize.Alignment{Horizontal: "center"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+	f.SetCellValue(sheetName, "A1", report.Title)
+	f.MergeCell(sheetName, "A1", lastColumn+"1")
+	f.SetCellStyle(sheetName, "A1", lastColumn+"1", titleStyle)
+
+	f.SetCellValue(sheetName, "A2", theme.Tf("Fecha [ %s ]", theme.Now()))
+	f.SetCellValue(sheetName, "A3", theme.Tf("Generado por: [ %s ]", report.UserName))
+	f.SetCellValue(sheetName, "A4", report.Filters)
+
+	border := []excelize.Border{
+		{Type: "left", Color: "BFBFBF", Style: 1},
+		{Type: "right", Color: "BFBFBF", Style: 1},
+		{Type: "top", Color: "BFBFBF", Style: 1},
+		{Type: "bottom", Color: "BFBFBF", Style: 1},
+	}
+	headerFill := excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}}
+	labelHeaderStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      headerFill,
+		Border:    border,
+		Alignment: &excelize.Alignment{Vertical: "bottom", WrapText: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+	matrixHeaderStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      headerFill,
+		Border:    border,
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "bottom", TextRotation: 90},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+	labelStyle, err := f.NewStyle(&excelize.Style{Border: border})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+	cellStyle, err := f.NewStyle(&excelize.Style{
+		Border:    border,
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+	grantedStyle, err := f.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "006100"},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"C6EFCE"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creando estilo: %w", err)
+	}
+
+	for i, header := range report.Headers {
+		cell := fmt.Sprintf("%s%d", columnNameFromIndex(i), matrixHeaderRow)
+		f.SetCellValue(sheetName, cell, header)
+		if i < report.LabelColumns {
+			f.SetCellStyle(sheetName, cell, cell, labelHeaderStyle)
+			f.SetColWidth(sheetName, columnNameFromIndex(i), columnNameFromIndex(i), 30)
+		} else {
+			f.SetCellStyle(sheetName, cell, cell, matrixHeaderStyle)
+			f.SetColWidth(sheetName, columnNameFromIndex(i), columnNameFromIndex(i), 14)
+		}
+	}
+	f.SetRowHeight(sheetName, matrixHeaderRow, 130)
+
+	for rowIndex, row := range report.Data {
+		for colIndex, value := range row {
+			f.SetCellValue(sheetName, fmt.Sprintf("%s%d", columnNameFromIndex(colIndex), rowIndex+matrixFirstDataRow), value)
+		}
+	}
+
+	if len(report.Data) > 0 {
+		lastLabelColumn := columnNameFromIndex(report.LabelColumns - 1)
+		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", matrixFirstDataRow), fmt.Sprintf("%s%d", lastLabelColumn, lastRow), labelStyle)
+		if len(report.Headers) > report.LabelColumns {
+			matrixRange := fmt.Sprintf("%s%d:%s%d", firstMatrixColumn, matrixFirstDataRow, lastColumn, lastRow)
+			f.SetCellStyle(sheetName, fmt.Sprintf("%s%d", firstMatrixColumn, matrixFirstDataRow), fmt.Sprintf("%s%d", lastColumn, lastRow), cellStyle)
+			err := f.SetConditionalFormat(sheetName, matrixRange, []excelize.ConditionalFormatOptions{{
+				Type:     "formula",
+				Criteria: fmt.Sprintf("LEN(TRIM(%s%d))>0", firstMatrixColumn, matrixFirstDataRow),
+				Format:   &grantedStyle,
+			}})
+			if err != nil {
+				return nil, fmt.Errorf("error aplicando el formato condicional: %w", err)
+			}
+		}
+	}
+
+	if err := f.SetPanes(sheetName, &excelize.Panes{
+		Freeze:      true,
+		XSplit:      report.LabelColumns,
+		YSplit:      matrixHeaderRow,
+		TopLeftCell: fmt.Sprintf("%s%d", firstMatrixColumn, matrixFirstDataRow),
+		ActivePane:  "bottomRight",
+	}); err != nil {
+		return nil, err
+	}
+
+	f.SetCellValue(sheetName, fmt.Sprintf("A%d", lastRow+2), report.Integrity.label(theme))
+	if err := f.SetDocProps(&excelize.DocProperties{
+		Title:       report.Title,
+		Creator:     report.UserName,
+		Identifier:  report.Integrity.ReportID,
+		Description: theme.Tf("SHA-256 del contenido: %s", report.Integrity.ContentHash),
+	}); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := f.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// GenerateMatrixPDF genera la matriz en A4 horizontal con los encabezados girados. Si las columnas no
+// caben en una página, la matriz continúa en bloques de columnas repitiendo las columnas de identificación.
+func GenerateMatrixPDF(report MatrixReport) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	pdf := gopdf.GoPdf{}
+	theme := report.Theme
+
+	pageSize := *gopdf.PageSizeA4Landscape
+	pdf.Start(gopdf.Config{PageSize: pageSize})
+	pdf.SetInfo(gopdf.PdfInfo{
+		Title:        report.Title,
+		Author:       report.UserName,
+		Subject:      report.Integrity.label(theme),
+		Creator:      "API SEGURIDAD",
+		CreationDate: time.Now(),
+	})
+
+	if err := pdf.AddTTFFont("arial", theme.fontPath()); err != nil {
+		log.Println("Error al agregar fuente:", err)
+		return nil, err
+	}
+
+	marginX := 30.0
+	marginY := 30.0
+	labelWidth := 140.0
+	cellWidth := 26.0
+	headerHeight := 100.0
+	rowHeight := 14.0
+	tableY := marginY + 70.0
+	generatedAt := theme.Now()
+
+	codes, legend := matrixLegend(report)
+	columns := len(report.Headers) - report.LabelColumns
+	perPage := int((pageSize.W - 2*marginX - labelWidth*float64(report.LabelColumns)) / cellWidth)
+	if perPage < 1 {
+		perPage = 1
+	}
+
+	pageNum := 0
+	addPage := func() {
+		if pageNum > 0 {
+			pdf.SetFont("arial", "", 8)
+			pdf.SetXY(marginX, pageSize.H-marginY+10)
+			pdf.Cell(nil, theme.Tf("Página %d", pageNum)+"    "+report.Integrity.label(theme))
+		}
+		pageNum++
+		pdf.AddPage()
+
+		if err := pdf.Image(theme.logoPath(), pageSize.W-marginX-50, marginY, &gopdf.Rect{W: 20, H: 20}); err != nil {
+			log.Println("Error al cargar la imagen:", err)
+		}
+		pdf.SetFont("arial", "", 20)
+		pdf.SetXY(marginX, marginY)
+		if r, g, b, ok := theme.primaryRGB(); ok {
+			pdf.SetTextColor(r, g, b)
+		}
+		pdf.Cell(nil, report.Title)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont("arial", "", 8)
+		pdf.SetXY(marginX, marginY+26)
+		pdf.Cell(nil, theme.Tf("Fecha [ %s ]", generatedAt))
+		pdf.SetXY(marginX, marginY+38)
+		pdf.Cell(nil, theme.Tf("Generado por: [ %s ]", report.UserName))
+		pdf.SetXY(marginX, marginY+50)
+		pdf.Cell(nil, report.Filters)
+	}
+
+	drawHeader := func(first, last int) float64 {
+		pdf.SetFont("arial", "", 8)
+		x := marginX
+		for i := 0; i < report.LabelColumns; i++ {
+			pdf.RectFromUpperLeftWithStyle(x, tableY, labelWidth, headerHeight, "D")
+			pdf.SetXY(x+2, tableY+headerHeight-12)
+			pdf.Cell(nil, fitText(&pdf, report.Headers[i], labelWidth-4))
+			x += labelWidth
+		}
+		for column := first; column < last; column++ {
+			pdf.RectFromUpperLeftWithStyle(x, tableY, cellWidth, headerHeight, "D")
+			// El texto girado 90° se escribe hacia arriba desde la base de la celda
+			baseX, baseY := x+cellWidth/2+3, tableY+headerHeight-3
+			pdf.Rotate(90, baseX, baseY)
+			pdf.SetXY(baseX, baseY)
+			pdf.Cell(nil, fitText(&pdf, report.Headers[report.LabelColumns+column], headerHeight-6))
+			pdf.RotateReset()
+			x += cellWidth
+		}
+		return tableY + headerHeight
+	}
+
+	for first := 0; first < columns || first == 0; first += perPage {
+		last := first + perPage
+		if last > columns {
+			last = columns
+		}
+		addPage()
+		y := drawHeader(first, last)
+
+		for _, row := range report.Data {
+			if y+rowHeight > pageSize.H-marginY {
+				addPage()
+				y = drawHeader(first, last)
+			}
+
+			pdf.SetFont("arial", "", 7)
+			x := marginX
+			for i := 0; i < report.LabelColumns; i++ {
+				pdf.RectFromUpperLeftWithStyle(x, y, labelWidth, rowHeight, "D")
+				pdf.SetXY(x+2, y+3)
+				pdf.Cell(nil, fitText(&pdf, row[i], labelWidth-4))
+				x += labelWidth
+			}
+
+			pdf.SetFont("arial", "", 6)
+			for column := first; column < last; column++ {
+				value := row[report.LabelColumns+column]
+				if strings.TrimSpace(value) != "" {
+					// En gopdf el color de relleno también afecta al texto; se restablece después de pintar la celda
+					pdf.SetFillColor(198, 239, 206)
+					pdf.RectFromUpperLeftWithStyle(x, y, cellWidth, rowHeight, "FD")
+					pdf.SetFillColor(0, 0, 0)
+					if report.Abbreviate {
+						value = codes(value)
+					}
+					pdf.SetTextColor(0, 97, 0)
+					pdf.SetXY(x+1, y+4)
+					pdf.Cell(nil, fitText(&pdf, value, cellWidth-2))
+					pdf.SetTextColor(0, 0, 0)
+				} else {
+					pdf.RectFromUpperLeftWithStyle(x, y, cellWidth, rowHeight, "D")
+				}
+				x += cellWidth
+			}
+			y += rowHeight
+		}
+		if columns == 0 {
+			break
+		}
+	}
+
+	if len(legend) > 0 {
+		addPage()
+		pdf.SetFont("arial", "", 10)
+		pdf.SetXY(marginX, tableY)
+		pdf.Cell(nil, theme.T("Leyenda"))
+		pdf.SetFont("arial", "", 8)
+		y := tableY + 16
+		for _, entry := range legend {
+			if y > pageSize.H-marginY {
+				addPage()
+				y = tableY
+			}
+			pdf.SetXY(marginX, y)
+			pdf.Cell(nil, entry)
+			y += 12
+		}
+	}
+
+	pdf.SetFont("arial", "", 8)
+	pdf.SetXY(marginX, pageSize.H-marginY+10)
+	pdf.Cell(nil, theme.Tf("Página %d", pageNum)+"    "+report.Integrity.label(theme))
+
+	if _, err := pdf.WriteTo(&buf); err != nil {
+		log.Println("Error al escribir el archivo PDF:", err)
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// matrixLegend asigna un código corto (R1, R2, ...) a cada valor que aparece en las celdas separadas por
+// comas, para que quepan en el PDF; devuelve la función de conversión y las líneas de la leyenda
+func matrixLegend(report MatrixReport) (func(string) string, []string) {
+	if !report.Abbreviate {
+		return func(value string) string { return value }, nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range report.Data {
+		for _, value := range row[report.LabelColumns:] {
+			for _, name := range strings.Split(value, ", ") {
+				if name = strings.TrimSpace(name); name != "" && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+
+	codes := make(map[string]string, len(names))
+	legend := make([]string, len(names))
+	for i, name := range names {
+		codes[name] = fmt.Sprintf("R%d", i+1)
+		legend[i] = fmt.Sprintf("%s = %s", codes[name], name)
+	}
+
+	return func(value string) string {
+		parts := strings.Split(value, ", ")
+		for i, part := range parts {
+			parts[i] = codes[strings.TrimSpace(part)]
+		}
+		return strings.Join(parts, ",")
+	}, legend
+}
+
+// fitText recorta el texto para que no supere el ancho indicado
+func fitText(pdf *gopdf.GoPdf, text string, maxWidth float64) string {
+	if width, _ := pdf.MeasureTextWidth(text); width <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "..."
+		if width, _ := pdf.MeasureTextWidth(candidate); width <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
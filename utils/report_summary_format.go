@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/signintech/gopdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// Tipos de gráfico del resumen: columnas verticales, barras horizontales o línea
+const (
+	ChartColumn = "column"
+	ChartBar    = "bar"
+	ChartLine   = "line"
+)
+
+// ReportSummary es la página de resumen de un reporte: cifras clave y gráficos de los agregados
+type ReportSummary struct {
+	Figures []SummaryFigure
+	Charts  []SummaryChart
+}
+
+type SummaryFigure struct {
+	Label string
+	Value string
+}
+
+type SummaryChart struct {
+	Title      string
+	Type       string
+	Categories []string
+	Series     []SummarySeries
+}
+
+type SummarySeries struct {
+	Name   string
+	Values []float64
+}
+
+var summaryChartTypes = map[string]excelize.ChartType{
+	ChartColumn: excelize.Col,
+	ChartBar:    excelize.Bar,
+	ChartLine:   excelize.Line,
+}
+
+// summaryColors son los colores de las series en el PDF, en el mismo orden que la paleta de Excel
+var summaryColors = [][3]uint8{{68, 114, 196}, {237, 125, 49}, {165, 165, 165}}
+
+// addSummarySheet agrega la hoja de resumen: cada gráfico se arma con un gráfico nativo de Excel a
+// partir de una tabla con sus datos, para que se pueda consultar y reutilizar
+func addSummarySheet(f *excelize.File, summary ReportSummary, theme ReportTheme) error {
+	summarySheet := theme.T("Resumen")
+	if _, err := f.NewSheet(summarySheet); err != nil {
+		return err
+	}
+	titleStyleID, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 16}})
+	if err != nil {
+		return err
+	}
+	boldStyleID, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	f.SetCellValue(summarySheet, "A1", summarySheet)
+	f.SetCellStyle(summarySheet, "A1", "A1", titleStyleID)
+	f.SetColWidth(summarySheet, "A", "A", 28)
+	f.SetColWidth(summarySheet, "B", "D", 14)
+
+	row := 3
+	for _, figure := range summary.Figures {
+		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), figure.Label)
+		f.SetCellValue(summarySheet, fmt.Sprintf("B%d", row), figure.Value)
+		f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), boldStyleID)
+		row++
+	}
+	row++
+
+	for _, chart := range summary.Charts {
+		if len(chart.Categories) == 0 {
+			continue
+		}
+		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), chart.Title)
+		f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), boldStyleID)
+		headerRow := row + 1
+		first, last := headerRow+1, headerRow+len(chart.Categories)
+		for i, category := range chart.Categories {
+			f.SetCellValue(summarySheet, fmt.Sprintf("A%d", first+i), category)
+		}
+
+		series := make([]excelize.ChartSeries, len(chart.Series))
+		for s, item := range chart.Series {
+			column := columnNameFromIndex(s + 1)
+			f.SetCellValue(summarySheet, fmt.Sprintf("%s%d", column, headerRow), item.Name)
+			f.SetCellStyle(summarySheet, fmt.Sprintf("%s%d", column, headerRow), fmt.Sprintf("%s%d", column, headerRow), boldStyleID)
+			for i, value := range item.Values {
+				f.SetCellValue(summarySheet, fmt.Sprintf("%s%d", column, first+i), value)
+			}
+			series[s] = excelize.ChartSeries{
+				Name:       fmt.Sprintf("%s!$%s$%d", summarySheet, column, headerRow),
+				Categories: fmt.Sprintf("%s!$A$%d:$A$%d", summarySheet, first, last),
+				Values:     fmt.Sprintf("%s!$%s$%d:$%s$%d", summarySheet, column, first, column, last),
+			}
+		}
+
+		legend := excelize.ChartLegend{Position: "none"}
+		if len(series) > 1 {
+			legend.Position = "bottom"
+		}
+		if err := f.AddChart(summarySheet, fmt.Sprintf("F%d", row), &excelize.Chart{
+			Type:      summaryChartTypes[chart.Type],
+			Series:    series,
+			Title:     []excelize.RichTextRun{{Text: chart.Title}},
+			Legend:    legend,
+			Dimension: excelize.ChartDimension{Width: 560, Height: 300},
+		}); err != nil {
+			return fmt.Errorf("error al crear el gráfico %s: %w", chart.Title, err)
+		}
+
+		// El gráfico ocupa unas 16 filas; la siguiente tabla empieza debajo del más alto de los dos
+		row = max(last, row+16) + 3
+	}
+	return nil
+}
+
+// drawSummaryPDF dibuja las cifras y los gráficos del resumen como gráficos vectoriales a partir de y.
+// newPage se llama cuando un gráfico no entra en la página y devuelve la nueva posición inicial.
+func drawSummaryPDF(pdf *gopdf.GoPdf, summary ReportSummary, theme ReportTheme, x, y, width, bottom float64, newPage func() float64) {
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("arial", "B", 12)
+	pdf.SetXY(x, y)
+	pdf.Cell(nil, theme.T("Resumen"))
+	y += 20
+
+	pdf.SetFont("arial", "", 9)
+	for _, figure := range summary.Figures {
+		pdf.SetXY(x, y)
+		pdf.Cell(nil, figure.Label+": "+figure.Value)
+		y += 13
+	}
+	y += 10
+
+	const chartHeight = 200.0
+	for _, chart := range summary.Charts {
+		if len(chart.Categories) == 0 {
+			continue
+		}
+		if y+chartHeight > bottom {
+			y = newPage()
+		}
+		drawChartPDF(pdf, chart, x, y, width, chartHeight)
+		y += chartHeight + 15
+	}
+}
+
+func drawChartPDF(pdf *gopdf.GoPdf, chart SummaryChart, x, y, width, height float64) {
+	pdf.SetFont("arial", "B", 9)
+	pdf.SetXY(x, y)
+	pdf.Cell(nil, chart.Title)
+
+	// Leyenda a la derecha del título cuando hay más de una serie
+	if len(chart.Series) > 1 {
+		pdf.SetFont("arial", "", 7)
+		legendX := x + width
+		for s := len(chart.Series) - 1; s >= 0; s-- {
+			name := chart.Series[s].Name
+			nameWidth, _ := pdf.MeasureTextWidth(name)
+			legendX -= nameWidth + 16
+			setSummaryFill(pdf, s)
+			pdf.RectFromUpperLeftWithStyle(legendX, y+2, 8, 6, "F")
+			resetSummaryColors(pdf)
+			pdf.SetXY(legendX+11, y)
+			pdf.Cell(nil, name)
+		}
+	}
+
+	maxValue := 0.0
+	for _, series := range chart.Series {
+		for _, value := range series.Values {
+			maxValue = math.Max(maxValue, value)
+		}
+	}
+	scale := niceScale(maxValue)
+
+	pdf.SetFont("arial", "", 7)
+	top := y + 16
+	plotHeight := height - 16
+	if chart.Type == ChartBar {
+		drawBarChartPDF(pdf, chart, x, top, width, plotHeight, scale)
+		return
+	}
+
+	// Columnas y línea: eje de valores a la izquierda y categorías debajo
+	left := x + 34
+	plotWidth := width - 34
+	plotHeight -= 14
+	bottom := top + plotHeight
+	drawValueGridPDF(pdf, scale, func(value float64) (float64, float64, float64, float64) {
+		lineY := bottom - value/scale*plotHeight
+		return left, lineY, left + plotWidth, lineY
+	}, func(label string, value float64) {
+		labelWidth, _ := pdf.MeasureTextWidth(label)
+		pdf.SetXY(left-labelWidth-3, bottom-value/scale*plotHeight-4)
+		pdf.Cell(nil, label)
+	})
+
+	slot := plotWidth / float64(len(chart.Categories))
+	// Se muestran solo las etiquetas que entran sin superponerse
+	widest := 0.0
+	for _, category := range chart.Categories {
+		labelWidth, _ := pdf.MeasureTextWidth(category)
+		widest = math.Max(widest, labelWidth)
+	}
+	labelEvery := int(math.Max(1, math.Ceil((widest+4)/slot)))
+	for i := 0; i < len(chart.Categories); i += labelEvery {
+		labelWidth, _ := pdf.MeasureTextWidth(chart.Categories[i])
+		pdf.SetXY(left+slot*float64(i)+slot/2-labelWidth/2, bottom+3)
+		pdf.Cell(nil, chart.Categories[i])
+	}
+
+	if chart.Type == ChartLine {
+		pdf.SetLineWidth(1.2)
+		for s, series := range chart.Series {
+			color := summaryColors[s%len(summaryColors)]
+			pdf.SetStrokeColor(color[0], color[1], color[2])
+			for i := 1; i < len(series.Values); i++ {
+				pdf.Line(left+slot*(float64(i)-0.5), bottom-series.Values[i-1]/scale*plotHeight,
+					left+slot*(float64(i)+0.5), bottom-series.Values[i]/scale*plotHeight)
+			}
+			if len(series.Values) == 1 {
+				setSummaryFill(pdf, s)
+				pdf.RectFromUpperLeftWithStyle(left+slot/2-2, bottom-series.Values[0]/scale*plotHeight-2, 4, 4, "F")
+			}
+		}
+		pdf.SetLineWidth(1)
+		resetSummaryColors(pdf)
+	} else {
+		barWidth := slot * 0.7 / float64(len(chart.Series))
+		for s, series := range chart.Series {
+			setSummaryFill(pdf, s)
+			for i, value := range series.Values {
+				barHeight := value / scale * plotHeight
+				if barHeight > 0 {
+					pdf.RectFromUpperLeftWithStyle(left+slot*float64(i)+slot*0.15+barWidth*float64(s), bottom-barHeight, barWidth, barHeight, "F")
+				}
+			}
+		}
+		resetSummaryColors(pdf)
+	}
+	pdf.Line(left, top, left, bottom)
+	pdf.Line(left, bottom, left+plotWidth, bottom)
+}
+
+// drawBarChartPDF dibuja barras horizontales con las categorías a la izquierda
+func drawBarChartPDF(pdf *gopdf.GoPdf, chart SummaryChart, x, top, width, plotHeight, scale float64) {
+	labelWidth := width * 0.3
+	left := x + labelWidth
+	plotWidth := width - labelWidth - 30
+	plotHeight -= 12
+	bottom := top + plotHeight
+
+	drawValueGridPDF(pdf, scale, func(value float64) (float64, float64, float64, float64) {
+		lineX := left + value/scale*plotWidth
+		return lineX, top, lineX, bottom
+	}, func(label string, value float64) {
+		textWidth, _ := pdf.MeasureTextWidth(label)
+		pdf.SetXY(left+value/scale*plotWidth-textWidth/2, bottom+3)
+		pdf.Cell(nil, label)
+	})
+
+	slot := plotHeight / float64(len(chart.Categories))
+	barHeight := math.Min(slot*0.7/float64(len(chart.Series)), 14)
+	for i, category := range chart.Categories {
+		pdf.SetXY(x, top+slot*float64(i)+slot/2-4)
+		pdf.Cell(nil, fitText(pdf, category, labelWidth-6))
+	}
+	for s, series := range chart.Series {
+		for i, value := range series.Values {
+			barY := top + slot*float64(i) + (slot-barHeight*float64(len(chart.Series)))/2 + barHeight*float64(s)
+			barWidth := value / scale * plotWidth
+			if barWidth > 0 {
+				setSummaryFill(pdf, s)
+				pdf.RectFromUpperLeftWithStyle(left, barY, barWidth, barHeight, "F")
+				resetSummaryColors(pdf)
+			}
+			pdf.SetXY(left+barWidth+3, barY+barHeight/2-4)
+			pdf.Cell(nil, formatSummaryValue(value))
+		}
+	}
+	pdf.Line(left, top, left, bottom)
+}
+
+// drawValueGridPDF dibuja cinco líneas guía del eje de valores con sus etiquetas
+func drawValueGridPDF(pdf *gopdf.GoPdf, scale float64, line func(value float64) (float64, float64, float64, float64), label func(text string, value float64)) {
+	pdf.SetStrokeColor(220, 220, 220)
+	for tick := 0; tick <= 4; tick++ {
+		value := scale * float64(tick) / 4
+		x1, y1, x2, y2 := line(value)
+		pdf.Line(x1, y1, x2, y2)
+	}
+	resetSummaryColors(pdf)
+	for tick := 0; tick <= 4; tick++ {
+		value := scale * float64(tick) / 4
+		label(formatSummaryValue(value), value)
+	}
+}
+
+func setSummaryFill(pdf *gopdf.GoPdf, series int) {
+	color := summaryColors[series%len(summaryColors)]
+	pdf.SetFillColor(color[0], color[1], color[2])
+}
+
+// resetSummaryColors vuelve al negro; en gopdf el color de relleno también es el del texto
+func resetSummaryColors(pdf *gopdf.GoPdf) {
+	pdf.SetFillColor(0, 0, 0)
+	pdf.SetStrokeColor(0, 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// niceScale redondea el máximo del eje a 1, 2 o 5 por una potencia de diez
+func niceScale(maxValue float64) float64 {
+	if maxValue <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(maxValue)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if step*magnitude >= maxValue {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatSummaryValue(value float64) string {
+	if value == math.Trunc(value) {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signintech/gopdf"
+)
+
+const (
+	ReportLocaleES = "es"
+	ReportLocaleEN = "en"
+
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+
+	DefaultReportLogo       = "assets/img/security.png"
+	DefaultReportFont       = "assets/fonts/Roboto-Regular.ttf"
+	DefaultReportDateFormat = "2006-01-02 15:04:05"
+)
+
+// ReportTheme es la presentación de un reporte: idioma de los textos, logo, fuente, color principal, zona
+// horaria y formato de fechas, y orientación de la página en PDF
+type ReportTheme struct {
+	Locale   string
+	LogoPath string
+	FontPath string
+	// PrimaryColor (#RRGGBB) pinta el título y el fondo de los encabezados; vacío deja el reporte en negro
+	PrimaryColor string
+	Location     *time.Location
+	// DateFormat es un formato de Go (ver ParseReportDateFormat)
+	DateFormat string
+	Landscape  bool
+}
+
+// DefaultReportTheme es la presentación de siempre: español, hora de Ecuador y A4 vertical
+func DefaultReportTheme() ReportTheme {
+	location, err := time.LoadLocation("America/Guayaquil")
+	if err != nil {
+		location = time.FixedZone("ECT", -5*60*60)
+	}
+	return ReportTheme{
+		Locale:     ReportLocaleES,
+		LogoPath:   DefaultReportLogo,
+		FontPath:   DefaultReportFont,
+		Location:   location,
+		DateFormat: DefaultReportDateFormat,
+	}
+}
+
+// ReportLocales son los idiomas disponibles para los textos de los reportes
+func ReportLocales() []string {
+	return []string{ReportLocaleES, ReportLocaleEN}
+}
+
+// ValidReportLocale indica si hay textos para el idioma
+func ValidReportLocale(locale string) bool {
+	return locale == ReportLocaleES || reportTranslations[locale] != nil
+}
+
+// T traduce un texto del reporte al idioma del tema. Los textos se escriben en español y el español es
+// también el idioma de respaldo cuando falta una traducción.
+func (t ReportTheme) T(text string) string {
+	if translated, ok := reportTranslations[t.Locale][text]; ok {
+		return translated
+	}
+	return text
+}
+
+// Tf traduce el formato y luego lo completa con los argumentos
+func (t ReportTheme) Tf(format string, args ...interface{}) string {
+	return fmt.Sprintf(t.T(format), args...)
+}
+
+// FormatDate escribe una fecha guardada en la base con el formato del tema. Las fechas se guardan con la
+// hora local del servicio, por eso solo se cambia el formato y no la zona horaria.
+func (t ReportTheme) FormatDate(date time.Time) string {
+	return date.Format(t.dateFormat())
+}
+
+// Now es la fecha de generación del reporte en la zona horaria del tema
+func (t ReportTheme) Now() string {
+	now := time.Now()
+	if t.Location != nil {
+		now = now.In(t.Location)
+	}
+	return now.Format(t.dateFormat())
+}
+
+func (t ReportTheme) dateFormat() string {
+	if t.DateFormat == "" {
+		return DefaultReportDateFormat
+	}
+	return t.DateFormat
+}
+
+func (t ReportTheme) logoPath() string {
+	if t.LogoPath == "" {
+		return DefaultReportLogo
+	}
+	return t.LogoPath
+}
+
+func (t ReportTheme) fontPath() string {
+	if t.FontPath == "" {
+		return DefaultReportFont
+	}
+	return t.FontPath
+}
+
+// pageSize es A4 en la orientación del tema
+func (t ReportTheme) pageSize() gopdf.Rect {
+	if t.Landscape {
+		return *gopdf.PageSizeA4Landscape
+	}
+	return *gopdf.PageSizeA4
+}
+
+// primaryRGB devuelve el color principal; ok es falso si no se configuró
+func (t ReportTheme) primaryRGB() (r, g, b uint8, ok bool) {
+	rgb, err := ParseReportColor(t.PrimaryColor)
+	if err != nil || t.PrimaryColor == "" {
+		return 0, 0, 0, false
+	}
+	return rgb[0], rgb[1], rgb[2], true
+}
+
+// ParseReportColor lee un color #RRGGBB
+func ParseReportColor(color string) ([3]uint8, error) {
+	var rgb [3]uint8
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) != 6 {
+		return rgb, fmt.Errorf("color inválido: %s (use #RRGGBB)", color)
+	}
+	for i := range rgb {
+		value, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return rgb, fmt.Errorf("color inválido: %s (use #RRGGBB)", color)
+		}
+		rgb[i] = uint8(value)
+	}
+	return rgb, nil
+}
+
+// reportDateTokens traduce el patrón de fecha de la configuración al formato de Go; los tokens más largos
+// van primero para que YYYY no se lea como dos YY
+var reportDateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"}, {"MM", "01"}, {"DD", "02"},
+	{"HH", "15"}, {"hh", "03"}, {"mm", "04"}, {"ss", "05"}, {"A", "PM"},
+}
+
+// ParseReportDateFormat convierte un patrón como "DD/MM/YYYY HH:mm" al formato de Go. Se admiten YYYY, YY,
+// MM, DD, HH (24 h), hh (12 h), mm, ss y A (AM/PM); el resto de caracteres se copia tal cual.
+func ParseReportDateFormat(pattern string) (string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return "", fmt.Errorf("el formato de fecha está vacío")
+	}
+	var layout strings.Builder
+	hasToken := false
+	for rest := pattern; rest != ""; {
+		matched := false
+		for _, item := range reportDateTokens {
+			if strings.HasPrefix(rest, item.token) {
+				layout.WriteString(item.layout)
+				rest = rest[len(item.token):]
+				matched, hasToken = true, true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		// Letras y dígitos sueltos se confundirían con los valores de referencia del formato de Go
+		if c := rest[0]; c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			return "", fmt.Errorf("formato de fecha inválido: %s (use YYYY, MM, DD, HH, mm, ss)", pattern)
+		}
+		layout.WriteByte(rest[0])
+		rest = rest[1:]
+	}
+	if !hasToken {
+		return "", fmt.Errorf("formato de fecha inválido: %s (use YYYY, MM, DD, HH, mm, ss)", pattern)
+	}
+	return layout.String(), nil
+}
+
+// reportTranslations son los textos de los reportes en cada idioma, indexados por el texto en español
+var reportTranslations = map[string]map[string]string{
+	ReportLocaleEN: {
+		// Encabezado, pie y hojas
+		"Reporte de %s":        "%s report",
+		"Fecha [ %s ]":         "Date [ %s ]",
+		"Generado por: [ %s ]": "Generated by: [ %s ]",
+		"Filtros [ %s ]":       "Filters [ %s ]",
+		"Ninguno":              "None",
+		"Página %d":            "Page %d",
+		"Reporte [ %s ]  SHA-256 del contenido [ %s ]": "Report [ %s ]  Content SHA-256 [ %s ]",
+		"SHA-256 del contenido: %s":                    "Content SHA-256: %s",
+		"Reporte":                                      "Report",
+		"Usuarios Completos":                           "Users Detail",
+		"Matriz":                                       "Matrix",
+		"Leyenda":                                      "Legend",
+		"Resumen":                                      "Summary",
+		"(sin valor)":                                  "(no value)",
+		"Subtotal %s: %d registros":                    "Subtotal %s: %d records",
+		"Total: %d registros":                          "Total: %d records",
+		"Permission":                                   "Permissions",
+		"User":                                         "Users",
+		"Role":                                         "Roles",
+		"Module":                                       "Modules",
+		"Matriz de accesos por rol - %s":               "Access matrix by role - %s",
+		"Matriz de accesos por usuario - %s":           "Access matrix by user - %s",
+
+		// Cuentas de riesgo
+		"Cuentas sin uso y de riesgo (%d días sin acceso, más de %d roles)": "Unused and risky accounts (%d days without access, more than %d roles)",
+		"Último acceso":                  "Last Access",
+		"Días sin acceso":                "Days Without Access",
+		"Roles en módulos inactivos":     "Roles in Inactive Modules",
+		"Bloqueada hasta":                "Locked Until",
+		"Restablecimiento vigente hasta": "Reset Valid Until",
+		"Riesgos":                        "Risks",
+		"Nunca":                          "Never",
+		"Cuenta sin uso":                 "Unused account",
+		"Rol en módulo inactivo":         "Role in inactive module",
+		"Cuenta bloqueada":               "Locked account",
+		"Restablecimiento pendiente":     "Pending reset",
+		"Exceso de roles":                "Too many roles",
+
+		// Columnas
+		"ID":                 "ID",
+		"Nombre":             "Name",
+		"Descripción":        "Description",
+		"Estado":             "Status",
+		"Módulo":             "Module",
+		"F. Creación":        "Created",
+		"F. Actualización":   "Updated",
+		"Correo Electrónico": "Email",
+		"Roles":              "Roles",
+		"Permisos":           "Permissions",
+		"Módulos":            "Modules",
+		"Nombre del Rol":     "Role Name",
+		"ID Módulo":          "Module ID",
+		"Nombre del Módulo":  "Module Name",
+		"Clave":              "Key",
+		"Evento":             "Event",
+		"Usuario":            "User",
+		"Servicio Origen":    "Origin Service",
+		"Resultado":          "Outcome",
+		"IP":                 "IP",
+		"Fecha":              "Date",
+		"Rol":                "Role",
+		"ID Usuario":         "User ID",
+		"Correo del Usuario": "User Email",
+		"Cant. Roles":        "Role Count",
+		"Cant. Permisos":     "Permission Count",
+		"Cant. Usuarios":     "User Count",
+
+		// Valores
+		"Activo":              "Active",
+		"Inactivo":            "Inactive",
+		"Sin módulo":          "No module",
+		"Usuario desconocido": "Unknown user",
+		"Sí":                  "Yes",
+
+		// Resumen
+		"Total de eventos":               "Total events",
+		"Eventos fallidos":               "Failed events",
+		"Servicios de origen":            "Origin services",
+		"Periodo":                        "Period",
+		"%s a %s":                        "%s to %s",
+		"Eventos por día":                "Events per day",
+		"Eventos por mes":                "Events per month",
+		"Eventos por servicio de origen": "Events per origin service",
+		"Eventos por tipo":               "Events per type",
+		"Total de usuarios":              "Total users",
+		"Total de roles":                 "Total roles",
+		"Usuarios sin roles":             "Users without roles",
+		"Roles sin usuarios":             "Roles without users",
+		"Usuarios por rol":               "Users per role",
+		"Total":                          "Total",
+		"Fallidos":                       "Failed",
+		"Otros":                          "Others",
+	},
+}